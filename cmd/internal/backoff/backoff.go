@@ -0,0 +1,55 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package backoff provides exponential backoff with jitter.
+package backoff
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff is an exponential backoff with jitter.
+type Backoff struct {
+	// Min is the backoff of the first attempt.
+	Min time.Duration
+	// Max is the maximum backoff.
+	Max time.Duration
+	// Jitter is the fraction of the backoff that is randomized, between 0 and 1.
+	// With jitter 0.5, the backoff is between half and the full exponential backoff.
+	Jitter float64
+}
+
+// Default is the default backoff.
+var Default = Backoff{
+	Min:    time.Second,
+	Max:    time.Minute,
+	Jitter: 0.5,
+}
+
+// Duration returns the backoff of the attempt. The first attempt is 0. The backoff doubles every attempt until Max.
+func (b Backoff) Duration(attempt int) time.Duration {
+	d := b.Min
+	for i := 0; i < attempt && d < b.Max; i++ {
+		d *= 2
+	}
+	if d > b.Max {
+		d = b.Max
+	}
+	if j := int64(b.Jitter * float64(d)); j > 0 {
+		d -= time.Duration(rand.Int63n(j + 1))
+	}
+	return d
+}
+
+// Wait waits for the backoff of the attempt. It returns the context error if the context is done before.
+func (b Backoff) Wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(b.Duration(attempt))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,48 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package backoff
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 10 * time.Second}
+	for attempt, expected := range []time.Duration{
+		time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		8 * time.Second,
+		10 * time.Second,
+		10 * time.Second,
+	} {
+		if d := b.Duration(attempt); d != expected {
+			t.Fatalf("attempt %d: expected %s, got %s", attempt, expected, d)
+		}
+	}
+
+	b.Jitter = 0.5
+	for attempt := 0; attempt < 100; attempt++ {
+		d := b.Duration(attempt % 6)
+		upper := b.Min << (attempt % 6)
+		if upper > b.Max {
+			upper = b.Max
+		}
+		if d < upper/2 || d > upper {
+			t.Fatalf("attempt %d: expected between %s and %s, got %s", attempt%6, upper/2, upper, d)
+		}
+	}
+}
+
+func TestWait(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := (Backoff{Min: time.Millisecond, Max: time.Millisecond}).Wait(ctx, 0); err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+	cancel()
+	if err := (Backoff{Min: time.Hour, Max: time.Hour}).Wait(ctx, 0); err != context.Canceled {
+		t.Fatalf("Expected context canceled, got %v", err)
+	}
+}
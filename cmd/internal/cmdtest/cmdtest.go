@@ -0,0 +1,186 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package cmdtest provides a harness to test the commands end-to-end against in-memory Packet Broker services.
+//
+// The harness serves the services of package mock on a loopback listener, together with an OAuth 2.0 token endpoint.
+// Commands run in-process with injected standard input and output, so that tests can assert on the output and on
+// the calls that are received by the services.
+package cmdtest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.packetbroker.org/pb/pkg/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Env is a test environment with in-memory Packet Broker services.
+type Env struct {
+	// Server is the in-memory server. Use it to set up state and to inspect the received calls.
+	Server *mock.Server
+	// Address is the address of the gRPC services.
+	Address string
+	// TokenURL is the URL of the OAuth 2.0 token endpoint.
+	TokenURL string
+	// ConfigFile is an empty configuration file, so that the configuration of the user is not used.
+	ConfigFile string
+
+	mu         sync.Mutex
+	grpcServer *grpc.Server
+}
+
+// NewEnv starts a new test environment. The environment is stopped when the test finishes.
+func NewEnv(t testing.TB) *Env {
+	t.Helper()
+	s := mock.NewServer()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	grpcServer := s.NewGRPCServer()
+	go grpcServer.Serve(lis)
+
+	tokenServer := httptest.NewServer(mock.TokenHandler())
+	t.Cleanup(tokenServer.Close)
+
+	configFile := filepath.Join(t.TempDir(), ".pb.yaml")
+	if err := os.WriteFile(configFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	env := &Env{
+		Server:     s,
+		Address:    lis.Addr().String(),
+		TokenURL:   tokenServer.URL,
+		ConfigFile: configFile,
+		grpcServer: grpcServer,
+	}
+	t.Cleanup(func() {
+		env.mu.Lock()
+		env.grpcServer.Stop()
+		env.mu.Unlock()
+	})
+	return env
+}
+
+// Restart restarts the services on the same address, like a router that is redeployed. The active subscriptions are
+// canceled first, like a server that stops gracefully cancels the calls that do not finish. The state of the server is
+// kept.
+func (e *Env) Restart() error {
+	e.Server.CloseSubscriptions(status.Error(codes.Canceled, "server stopping"))
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.grpcServer.Stop()
+	lis, err := net.Listen("tcp", e.Address)
+	if err != nil {
+		return err
+	}
+	e.grpcServer = e.Server.NewGRPCServer()
+	go e.grpcServer.Serve(lis)
+	return nil
+}
+
+// Args returns the arguments to connect to the services without TLS and with OAuth 2.0 client credentials.
+// The services are the names of the address flags, i.e. iam, controlplane, reports or router.
+func (e *Env) Args(services ...string) []string {
+	res := []string{
+		"--config", e.ConfigFile,
+		"--insecure",
+		"--client-id", "test",
+		"--client-secret", "test",
+		"--token-url", e.TokenURL,
+		"--token-cache-file=",
+	}
+	for _, s := range services {
+		res = append(res, fmt.Sprintf("--%s-address", s), e.Address)
+	}
+	return res
+}
+
+// Dial returns a client connection to the services. The connection is closed when the test finishes.
+func (e *Env) Dial(t testing.TB) *grpc.ClientConn {
+	t.Helper()
+	conn, err := grpc.Dial(e.Address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// Methods returns the full method names of the calls received by the services.
+func (e *Env) Methods() []string {
+	calls := e.Server.Calls()
+	res := make([]string, len(calls))
+	for i, c := range calls {
+		res[i] = c.Method
+	}
+	return res
+}
+
+// Buffer is a bytes.Buffer that is safe for concurrent use. Use it as output of commands that are inspected while
+// they are running.
+type Buffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write implements io.Writer.
+func (b *Buffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns the contents written so far.
+func (b *Buffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// Golden compares actual with the golden file testdata/<name>.golden. Run the tests with -update to write the golden
+// files.
+func Golden(t testing.TB, name, actual string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(actual), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Read golden file (run with -update to create): %v", err)
+	}
+	if !bytes.Equal(expected, []byte(actual)) {
+		t.Fatalf("Output does not match %s:\n--- expected\n%s\n--- actual\n%s", path, expected, actual)
+	}
+}
+
+// Contains fails the test if s does not contain all substrings.
+func Contains(t testing.TB, s string, substrs ...string) {
+	t.Helper()
+	for _, substr := range substrs {
+		if !strings.Contains(s, substr) {
+			t.Fatalf("Expected %q in:\n%s", substr, s)
+		}
+	}
+}
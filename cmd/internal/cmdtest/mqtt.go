@@ -0,0 +1,182 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmdtest
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+)
+
+// MQTTMessage is a message published to the MQTT broker.
+type MQTTMessage struct {
+	Topic   string
+	Payload []byte
+}
+
+// MQTTBroker is a minimal in-memory MQTT 3.1.1 broker. It supports QoS 0 and 1 and topic filters with wildcards.
+// There are no sessions and no retained messages. Messages are delivered to subscribers with QoS 0.
+type MQTTBroker struct {
+	// Address is the URL of the broker.
+	Address string
+
+	mu       sync.Mutex
+	conns    map[*mqttConn]struct{}
+	messages []MQTTMessage
+}
+
+type mqttConn struct {
+	net.Conn
+	writeMu sync.Mutex
+	filters []string
+}
+
+func (c *mqttConn) write(p packets.ControlPacket) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return p.Write(c.Conn)
+}
+
+// NewMQTTBroker starts a new MQTT broker. The broker is stopped when the test finishes.
+func NewMQTTBroker(t testing.TB) *MQTTBroker {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &MQTTBroker{
+		Address: "tcp://" + lis.Addr().String(),
+		conns:   make(map[*mqttConn]struct{}),
+	}
+	t.Cleanup(func() {
+		lis.Close()
+		b.mu.Lock()
+		for c := range b.conns {
+			c.Close()
+		}
+		b.mu.Unlock()
+	})
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(&mqttConn{Conn: conn})
+		}
+	}()
+	return b
+}
+
+func (b *MQTTBroker) serve(c *mqttConn) {
+	b.mu.Lock()
+	b.conns[c] = struct{}{}
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, c)
+		b.mu.Unlock()
+		c.Close()
+	}()
+	for {
+		p, err := packets.ReadPacket(c)
+		if err != nil {
+			return
+		}
+		switch p := p.(type) {
+		case *packets.ConnectPacket:
+			err = c.write(packets.NewControlPacket(packets.Connack))
+		case *packets.SubscribePacket:
+			ack := packets.NewControlPacket(packets.Suback).(*packets.SubackPacket)
+			ack.MessageID = p.MessageID
+			b.mu.Lock()
+			for i, filter := range p.Topics {
+				c.filters = append(c.filters, filter)
+				qos := p.Qoss[i]
+				if qos > 1 {
+					qos = 1
+				}
+				ack.ReturnCodes = append(ack.ReturnCodes, qos)
+			}
+			b.mu.Unlock()
+			err = c.write(ack)
+		case *packets.PublishPacket:
+			b.Publish(p.TopicName, p.Payload)
+			b.mu.Lock()
+			b.messages = append(b.messages, MQTTMessage{Topic: p.TopicName, Payload: p.Payload})
+			b.mu.Unlock()
+			if p.Qos > 0 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				err = c.write(ack)
+			}
+		case *packets.PingreqPacket:
+			err = c.write(packets.NewControlPacket(packets.Pingresp))
+		case *packets.DisconnectPacket:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Publish publishes the message to the subscribers.
+func (b *MQTTBroker) Publish(topic string, payload []byte) {
+	b.mu.Lock()
+	var subscribers []*mqttConn
+	for c := range b.conns {
+		for _, filter := range c.filters {
+			if matchTopic(filter, topic) {
+				subscribers = append(subscribers, c)
+				break
+			}
+		}
+	}
+	b.mu.Unlock()
+	for _, c := range subscribers {
+		p := packets.NewControlPacket(packets.Publish).(*packets.PublishPacket)
+		p.TopicName, p.Payload = topic, payload
+		c.write(p)
+	}
+}
+
+// Subscriptions returns the number of topic filters that clients subscribed to.
+func (b *MQTTBroker) Subscriptions() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for c := range b.conns {
+		n += len(c.filters)
+	}
+	return n
+}
+
+// Messages returns the messages that are published by clients.
+func (b *MQTTBroker) Messages() []MQTTMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := make([]MQTTMessage, len(b.messages))
+	copy(res, b.messages)
+	return res
+}
+
+// matchTopic returns whether the topic matches the filter. The filter may contain + and # wildcards.
+func matchTopic(filter, topic string) bool {
+	var (
+		filterLevels = strings.Split(filter, "/")
+		topicLevels  = strings.Split(topic, "/")
+	)
+	for i, f := range filterLevels {
+		if f == "#" {
+			return true
+		}
+		if i >= len(topicLevels) || f != "+" && f != topicLevels[i] {
+			return false
+		}
+	}
+	return len(filterLevels) == len(topicLevels)
+}
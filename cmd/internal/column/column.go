@@ -456,23 +456,31 @@ func WritePolicies(w io.Writer, defaults bool, policies ...*packetbroker.Routing
 		fmt.Fprint(w, "Home Network\t\t")
 	}
 	fmt.Fprintln(w, "J\tM\tA\tS\tL\tJ\tM\tA\t")
+	return WritePolicyRows(w, defaults, policies...)
+}
 
-	for _, p := range policies {
-		fmt.Fprintf(w, "%s\t%s\t",
-			packetbroker.NetID(p.GetForwarderNetId()),
-			p.GetForwarderTenantId(),
-		)
-		if !defaults {
-			netID, tenantID := p.GetHomeNetworkNetId(), p.GetHomeNetworkTenantId()
-			if netID == 0 && tenantID == "" {
-				fmt.Fprint(w, "\t\t")
-			} else {
-				fmt.Fprintf(w, "%s\t%s\t",
-					packetbroker.NetID(p.GetHomeNetworkNetId()),
-					p.GetHomeNetworkTenantId(),
-				)
-			}
+func writePolicyNetworks(w io.Writer, defaults bool, p *packetbroker.RoutingPolicy) {
+	fmt.Fprintf(w, "%s\t%s\t",
+		packetbroker.NetID(p.GetForwarderNetId()),
+		p.GetForwarderTenantId(),
+	)
+	if !defaults {
+		netID, tenantID := p.GetHomeNetworkNetId(), p.GetHomeNetworkTenantId()
+		if netID == 0 && tenantID == "" {
+			fmt.Fprint(w, "\t\t")
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t",
+				packetbroker.NetID(p.GetHomeNetworkNetId()),
+				p.GetHomeNetworkTenantId(),
+			)
 		}
+	}
+}
+
+// WritePolicyRows writes the policies as table rows, without header.
+func WritePolicyRows(w io.Writer, defaults bool, policies ...*packetbroker.RoutingPolicy) error {
+	for _, p := range policies {
+		writePolicyNetworks(w, defaults, p)
 		for _, b := range []bool{
 			p.GetUplink().GetJoinRequest(),
 			p.GetUplink().GetMacData(),
@@ -500,6 +508,15 @@ func WritePolicies(w io.Writer, defaults bool, policies ...*packetbroker.Routing
 	return nil
 }
 
+// WriteDeletedPolicyRows writes the deleted policies as table rows, without header.
+func WriteDeletedPolicyRows(w io.Writer, defaults bool, policies ...*packetbroker.RoutingPolicy) error {
+	for _, p := range policies {
+		writePolicyNetworks(w, defaults, p)
+		fmt.Fprintln(w, "(deleted)\t")
+	}
+	return nil
+}
+
 // WriteVisibilities writes the gateway visibilities as a table.
 func WriteVisibilities(w io.Writer, defaults bool, visibilities ...*packetbroker.GatewayVisibility) error {
 	fmt.Fprint(w, "Forwarder\t\t")
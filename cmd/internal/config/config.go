@@ -7,8 +7,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"net"
+	"sort"
+	"strings"
 
+	homedir "github.com/mitchellh/go-homedir"
 	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"go.packetbroker.org/pb/pkg/client"
@@ -43,12 +47,184 @@ func mustRealmConfig(realm BasicAuthRealm) BasicAuthRealmConfig {
 	return conf
 }
 
-// ClientFlags defines common flags used for Client configuration.
+// Config is the configuration of a command, read from the configuration file, environment variables and flags.
+// Each command execution has its own Config, so that commands in the same process do not share configuration.
+type Config struct {
+	v *viper.Viper
+}
+
+// Init reads the configuration file and applies the active profile. If cfgFile is empty, .pb.yaml is read from the
+// working directory or the home directory.
+//
+// The flags are bound to the configuration, so that the values of the executing command take precedence over the
+// configuration file and environment variables.
+func Init(cfgFile string, flags *flag.FlagSet) (*Config, error) {
+	v := viper.New()
+	if cfgFile != "" {
+		v.SetConfigFile(cfgFile)
+	} else {
+		home, err := homedir.Dir()
+		if err != nil {
+			return nil, err
+		}
+		v.AddConfigPath(".")
+		v.AddConfigPath(home)
+		v.SetConfigName(".pb")
+		v.SetConfigType("yaml")
+	}
+
+	v.AutomaticEnv()
+	v.SetEnvPrefix("pb")
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	if err := v.BindPFlags(flags); err != nil {
+		return nil, err
+	}
+	v.ReadInConfig()
+	c := &Config{v: v}
+	if err := c.ApplyProfile(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// ConfigFileUsed returns the path of the configuration file that is in use, or an empty string if there is none.
+func (c *Config) ConfigFileUsed() string {
+	return c.v.ConfigFileUsed()
+}
+
+const (
+	profilesKey       = "profiles"
+	currentProfileKey = "current-profile"
+)
+
+// ProfileFlags defines flags used to select a configuration profile.
+func ProfileFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.String("profile", "", "configuration profile (default is current-profile in config file)")
+	return flags
+}
+
+// ActiveProfile returns the name of the active configuration profile.
+// The profile flag and environment variable take precedence over the current profile in the configuration file.
+// An empty string is returned if no profile is active.
+func (c *Config) ActiveProfile() string {
+	if name := c.v.GetString("profile"); name != "" {
+		return normalizeProfile(name)
+	}
+	return normalizeProfile(c.v.GetString(currentProfileKey))
+}
+
+// normalizeProfile returns the profile name in lowercase. Profile names are case-insensitive, as the keys in the
+// configuration file are case-insensitive.
+func normalizeProfile(name string) string {
+	return strings.ToLower(name)
+}
+
+// ListProfiles returns the names of the configuration profiles, sorted by name.
+func (c *Config) ListProfiles() []string {
+	profiles := c.v.GetStringMap(profilesKey)
+	res := make([]string, 0, len(profiles))
+	for name := range profiles {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func profileKey(name string) string {
+	return fmt.Sprintf("%s.%s", profilesKey, name)
+}
+
+// ApplyProfile merges the settings of the active profile into the configuration.
+// Settings in the profile take precedence over the top-level settings in the configuration file. Flags and environment
+// variables take precedence over the profile. This makes the values returned by the flags defined by ClientFlags,
+// BasicAuthClientFlags and OAuth2ClientFlags resolve from the active profile.
+func (c *Config) ApplyProfile() error {
+	name := c.ActiveProfile()
+	if name == "" {
+		return nil
+	}
+	if !c.v.IsSet(profileKey(name)) {
+		return fmt.Errorf("profile %q not found", name)
+	}
+	return c.v.MergeConfigMap(c.v.GetStringMap(profileKey(name)))
+}
+
+// updateConfigFile updates the configuration file that is in use.
+func (c *Config) updateConfigFile(update func(v *viper.Viper) error) error {
+	path := c.v.ConfigFileUsed()
+	if path == "" {
+		return errors.New("no configuration file found")
+	}
+	return writeConfigFile(path, update)
+}
+
+// writeConfigFile updates the configuration file at the path. If the file does not exist, it is created.
+func writeConfigFile(path string, update func(v *viper.Viper) error) error {
+	// Use a new instance so that only the settings of the configuration file are written.
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := update(v); err != nil {
+		return err
+	}
+	return v.WriteConfig()
+}
+
+// settingKey returns the key of the setting in the configuration file. If a profile is active, the key is in the
+// profile.
+func (c *Config) settingKey(name string) string {
+	if profile := c.ActiveProfile(); profile != "" {
+		return fmt.Sprintf("%s.%s", profileKey(profile), name)
+	}
+	return name
+}
+
+// SetSettings writes the settings to the configuration file that is in use, or to .pb.yaml in the working directory
+// if no configuration file is in use. If a profile is active, the settings are written to the profile. Other settings
+// in the file are preserved. The path of the configuration file is returned.
+func (c *Config) SetSettings(settings map[string]string) (string, error) {
+	path := c.v.ConfigFileUsed()
+	if path == "" {
+		path = ".pb.yaml"
+	}
+	if err := writeConfigFile(path, func(v *viper.Viper) error {
+		for name, value := range settings {
+			v.Set(c.settingKey(name), value)
+		}
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// UseProfile sets the current profile in the configuration file. The name is case-insensitive.
+func (c *Config) UseProfile(name string) error {
+	name = normalizeProfile(name)
+	return c.updateConfigFile(func(v *viper.Viper) error {
+		if !v.IsSet(profileKey(name)) {
+			return fmt.Errorf("profile %q not found in %s", name, v.ConfigFileUsed())
+		}
+		v.Set(currentProfileKey, name)
+		return nil
+	})
+}
+
+// ClientFlags defines the address flag used for Client configuration of the service.
 func ClientFlags(service, defaultAddress string) *flag.FlagSet {
 	flags := new(flag.FlagSet)
 	flags.String(fmt.Sprintf("%s-address", service), defaultAddress, `address of the server "host[:port]"`)
+	return flags
+}
+
+// InsecureFlags defines the insecure flag. Add it once to the root command, so that it applies to the clients of all
+// services.
+func InsecureFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
 	flags.Bool("insecure", false, "insecure")
-	viper.BindPFlags(flags)
 	return flags
 }
 
@@ -58,7 +234,6 @@ func BasicAuthClientFlags(realm BasicAuthRealm) *flag.FlagSet {
 	conf := mustRealmConfig(realm)
 	flags.String(fmt.Sprintf("%s-username", conf.ConfigKey), "", fmt.Sprintf("%s username", conf.Name))
 	flags.String(fmt.Sprintf("%s-password", conf.ConfigKey), "", fmt.Sprintf("%s password", conf.Name))
-	viper.BindPFlags(flags)
 	return flags
 }
 
@@ -68,15 +243,15 @@ func OAuth2ClientFlags() *flag.FlagSet {
 	flags.String("client-id", "", "OAuth 2.0 client ID")
 	flags.String("client-secret", "", "OAuth 2.0 client secret")
 	flags.String("token-url", client.DefaultTokenURL, "OAuth 2.0 token URL")
-	viper.BindPFlags(flags)
+	flags.String("token-cache-file", client.DefaultTokenCacheFile(), "OAuth 2.0 token cache file (empty to disable)")
 	return flags
 }
 
 // initClient returns initial client configuration.
-func initClient(service string) (*client.Config, error) {
+func (c *Config) initClient(service string) (*client.Config, error) {
 	res := client.Config{
-		Address:  viper.GetString(fmt.Sprintf("%s-address", service)),
-		Insecure: viper.GetBool("insecure"),
+		Address:  c.v.GetString(fmt.Sprintf("%s-address", service)),
+		Insecure: c.v.GetBool("insecure"),
 	}
 	if res.Address == "" {
 		return nil, errors.New("missing server address")
@@ -87,53 +262,64 @@ func initClient(service string) (*client.Config, error) {
 var errNoCredentials = errors.New("no credentials")
 
 // BasicAuthClient returns a client configured with Basic authentication.
-func BasicAuthClient(service string, realm BasicAuthRealm) (*client.Config, error) {
-	res, err := initClient(service)
+func (c *Config) BasicAuthClient(service string, realm BasicAuthRealm) (*client.Config, error) {
+	res, err := c.initClient(service)
 	if err != nil {
 		return nil, err
 	}
 	conf := mustRealmConfig(realm)
-	username := viper.GetString(fmt.Sprintf("%s-username", conf.ConfigKey))
-	password := viper.GetString(fmt.Sprintf("%s-password", conf.ConfigKey))
+	username := c.v.GetString(fmt.Sprintf("%s-username", conf.ConfigKey))
+	password := c.v.GetString(fmt.Sprintf("%s-password", conf.ConfigKey))
 	if username == "" || password == "" {
 		return nil, errNoCredentials
 	}
-	allowInsecure := viper.GetBool("insecure")
+	password, err = c.ResolveSecret(password)
+	if err != nil {
+		return nil, err
+	}
+	allowInsecure := c.v.GetBool("insecure")
 	res.Credentials = client.BasicAuth(username, password, allowInsecure)
 	return res, nil
 }
 
 // OAuth2Client returns a client configured with OAuth Client Credentials authentication.
-func OAuth2Client(ctx context.Context, service string, scopes ...string) (*client.Config, error) {
-	res, err := initClient(service)
+func (c *Config) OAuth2Client(ctx context.Context, service string, scopes ...string) (*client.Config, error) {
+	res, err := c.initClient(service)
 	if err != nil {
 		return nil, err
 	}
-	tokenURL := viper.GetString("token-url")
-	clientID := viper.GetString("client-id")
-	clientSecret := viper.GetString("client-secret")
+	tokenURL := c.v.GetString("token-url")
+	clientID := c.v.GetString("client-id")
+	clientSecret := c.v.GetString("client-secret")
 	if clientID == "" || clientSecret == "" {
 		return nil, errNoCredentials
 	}
+	clientSecret, err = c.ResolveSecret(clientSecret)
+	if err != nil {
+		return nil, err
+	}
 	audience := res.Address
 	if host, _, err := net.SplitHostPort(audience); err == nil {
 		audience = host
 	}
-	allowInsecure := viper.GetBool("insecure")
-	res.Credentials = client.OAuth2(ctx, tokenURL, clientID, clientSecret, audience, scopes, allowInsecure)
+	allowInsecure := c.v.GetBool("insecure")
+	tokenCacheFile := c.v.GetString("token-cache-file")
+	res.Credentials = client.OAuth2(ctx, tokenURL, clientID, clientSecret, audience, scopes, allowInsecure,
+		client.WithTokenCacheFile(tokenCacheFile),
+	)
 	return res, nil
 }
 
 // AutomaticClient returns a client configured based on available settings.
 // Basic authentication is preferred with the given realm.
 // If Basic authentication is not configured, OAuth 2.0 Client Credentials are used.
-func AutomaticClient(ctx context.Context, service string, basicAuthRealm BasicAuthRealm, oauthScopes ...string) (*client.Config, error) {
+func (c *Config) AutomaticClient(ctx context.Context, service string, basicAuthRealm BasicAuthRealm, oauthScopes ...string) (*client.Config, error) {
 	for _, initFn := range []func() (*client.Config, error){
 		func() (*client.Config, error) {
-			return BasicAuthClient(service, basicAuthRealm)
+			return c.BasicAuthClient(service, basicAuthRealm)
 		},
 		func() (*client.Config, error) {
-			return OAuth2Client(ctx, service, oauthScopes...)
+			return c.OAuth2Client(ctx, service, oauthScopes...)
 		},
 	} {
 		config, err := initFn()
@@ -144,5 +330,5 @@ func AutomaticClient(ctx context.Context, service string, basicAuthRealm BasicAu
 			return nil, err
 		}
 	}
-	return initClient(service)
+	return c.initClient(service)
 }
@@ -0,0 +1,104 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+const profilesConfig = `iam-username: "top"
+iam-password: "top"
+client-id: "top"
+current-profile: "Prod"
+profiles:
+  Prod:
+    iam-username: "profile"
+    client-id: "profile"
+  staging:
+    iam-username: "staging"
+`
+
+// initConfig initializes the configuration from the configuration file with the arguments.
+func initConfig(t *testing.T, cfg string, args ...string) *Config {
+	t.Helper()
+	cfgFile := filepath.Join(t.TempDir(), ".pb.yaml")
+	if err := os.WriteFile(cfgFile, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	flags := new(flag.FlagSet)
+	flags.AddFlagSet(BasicAuthClientFlags(BasicAuthIAM))
+	flags.AddFlagSet(OAuth2ClientFlags())
+	flags.AddFlagSet(ProfileFlags())
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Init(cfgFile, flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestApplyProfile(t *testing.T) {
+	t.Run("Precedence", func(t *testing.T) {
+		c := initConfig(t, profilesConfig, "--client-id", "flag")
+		if name := c.ActiveProfile(); name != "prod" {
+			t.Fatalf("Expected active profile prod, got %q", name)
+		}
+		for key, expected := range map[string]string{
+			"iam-username": "profile",
+			"iam-password": "top",
+			"client-id":    "flag",
+		} {
+			if actual := c.v.GetString(key); actual != expected {
+				t.Fatalf("Expected %s to be %q, got %q", key, expected, actual)
+			}
+		}
+	})
+
+	t.Run("Flag", func(t *testing.T) {
+		c := initConfig(t, profilesConfig, "--profile", "Staging")
+		if name := c.ActiveProfile(); name != "staging" {
+			t.Fatalf("Expected active profile staging, got %q", name)
+		}
+		if actual := c.v.GetString("iam-username"); actual != "staging" {
+			t.Fatalf("Expected iam-username to be %q, got %q", "staging", actual)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		c := &Config{v: viper.New()}
+		c.v.Set("profile", "unknown")
+		if err := c.ApplyProfile(); err == nil {
+			t.Fatal("Expected error for unknown profile")
+		}
+	})
+}
+
+func TestInsecure(t *testing.T) {
+	flags := new(flag.FlagSet)
+	flags.AddFlagSet(ClientFlags("iam", "iam.packetbroker.net:443"))
+	flags.AddFlagSet(ClientFlags("controlplane", "cp.packetbroker.net:443"))
+	flags.AddFlagSet(InsecureFlags())
+	if err := flags.Parse([]string{"--insecure"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := Init(filepath.Join(t.TempDir(), ".pb.yaml"), flags)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, service := range []string{"iam", "controlplane"} {
+		conf, err := c.initClient(service)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !conf.Insecure {
+			t.Fatalf("Expected insecure client for %s", service)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package config
+
+import (
+	"fmt"
+
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.packetbroker.org/pb/cmd/internal/secret"
+)
+
+// Secret backends.
+const (
+	SecretBackendKeyring = "keyring"
+	SecretBackendFile    = "file"
+	SecretBackendNone    = "none"
+)
+
+const secretService = "pb"
+
+// SecretFlags defines flags used to store and resolve secrets.
+// The passphrase of the file backend is read from the PB_SECRET_PASSPHRASE environment variable.
+func SecretFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.String("secret-backend", SecretBackendKeyring, fmt.Sprintf("backend to store secrets (%s, %s, %s)",
+		SecretBackendKeyring, SecretBackendFile, SecretBackendNone))
+	flags.AddFlagSet(SecretFileFlags())
+	return flags
+}
+
+// SecretFileFlags defines flags used to resolve secrets. Use this for commands that do not store secrets.
+// The passphrase of the file backend is read from the PB_SECRET_PASSPHRASE environment variable.
+func SecretFileFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.String("secret-file", secret.DefaultFile(), "encrypted secrets file used by the file backend")
+	return flags
+}
+
+func (c *Config) secretStore(backend string) (secret.Store, bool) {
+	switch backend {
+	case SecretBackendKeyring:
+		return secret.Keyring(), true
+	case SecretBackendFile:
+		path := c.v.GetString("secret-file")
+		if path == "" {
+			path = secret.DefaultFile()
+		}
+		return secret.File(path, c.v.GetString("secret-passphrase")), true
+	default:
+		return nil, false
+	}
+}
+
+// ResolveSecret returns the secret value referenced by the given value.
+// Values that do not reference a secret in a known backend are returned as-is.
+func (c *Config) ResolveSecret(value string) (string, error) {
+	ref, ok := secret.ParseReference(value)
+	if !ok {
+		return value, nil
+	}
+	store, ok := c.secretStore(ref.Backend)
+	if !ok {
+		return value, nil
+	}
+	res, err := store.Get(ref.Service, ref.Key)
+	if err != nil {
+		return "", fmt.Errorf("resolve secret %s: %w", ref, err)
+	}
+	return res, nil
+}
+
+// StoreSecret stores the secret value in the configured backend and returns the reference to store in the
+// configuration file. If the backend is none, the value is returned as-is.
+// If the keyring is not available and PB_SECRET_PASSPHRASE is set, the secret is stored in the encrypted file.
+func (c *Config) StoreSecret(key, value string) (string, error) {
+	backend := c.v.GetString("secret-backend")
+	if backend == "" || backend == SecretBackendNone {
+		return value, nil
+	}
+	store, ok := c.secretStore(backend)
+	if !ok {
+		return "", fmt.Errorf("invalid secret backend %q", backend)
+	}
+	err := store.Set(secretService, key, value)
+	if err != nil && backend == SecretBackendKeyring && c.v.GetString("secret-passphrase") != "" {
+		backend = SecretBackendFile
+		store, _ = c.secretStore(backend)
+		err = store.Set(secretService, key, value)
+	}
+	if err != nil {
+		switch backend {
+		case SecretBackendKeyring:
+			return "", fmt.Errorf("store secret in keyring: %w (set PB_SECRET_PASSPHRASE to use an encrypted file instead, "+
+				"or pass --secret-backend %s to store the secret in plaintext)", err, SecretBackendNone)
+		case SecretBackendFile:
+			return "", fmt.Errorf("store secret in file: %w (set PB_SECRET_PASSPHRASE, "+
+				"or pass --secret-backend %s to store the secret in plaintext)", err, SecretBackendNone)
+		}
+		return "", fmt.Errorf("store secret in %s: %w", backend, err)
+	}
+	return secret.Reference{
+		Backend: backend,
+		Service: secretService,
+		Key:     key,
+	}.String(), nil
+}
+
+// SetSecret stores the secret value of the setting in the configured backend and writes the reference to the
+// configuration file. If a profile is active, the setting is written to the profile.
+func (c *Config) SetSecret(name, value string) (string, error) {
+	key, configKey := name, c.settingKey(name)
+	if profile := c.ActiveProfile(); profile != "" {
+		key = fmt.Sprintf("%s/%s", profile, name)
+	}
+	ref, err := c.StoreSecret(key, value)
+	if err != nil {
+		return "", err
+	}
+	if err := c.updateConfigFile(func(v *viper.Viper) error {
+		v.Set(configKey, ref)
+		return nil
+	}); err != nil {
+		return "", err
+	}
+	return ref, nil
+}
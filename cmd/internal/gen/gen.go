@@ -4,8 +4,13 @@ package gen
 
 import "github.com/spf13/cobra"
 
-// Cmd contains sub-commands to generate things.
-var Cmd = &cobra.Command{
-	Use:   "gen",
-	Short: "Generation commands",
+// NewCommand returns a new command that contains sub-commands to generate things.
+func NewCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gen",
+		Short: "Generation commands",
+	}
+	cmd.AddCommand(newHugoDocCommand())
+	cmd.AddCommand(newTreeCommand())
+	return cmd
 }
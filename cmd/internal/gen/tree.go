@@ -31,27 +31,26 @@ func commandTree(cmd *cobra.Command) (res command) {
 	return
 }
 
-var treeCmd = &cobra.Command{
-	Use:   "tree",
-	Short: "Generate command tree",
-	RunE: func(cmd *cobra.Command, args []string) error {
-		out, _ := cmd.Flags().GetString("out")
-
-		f, err := os.Create(out)
-		if err != nil {
-			return err
-		}
-		defer f.Close()
-
-		enc := json.NewEncoder(f)
-		enc.SetIndent("", "  ")
-		return enc.Encode(map[string]command{
-			cmd.Root().Name(): commandTree(cmd.Root()),
-		})
-	},
-}
-
-func init() {
-	treeCmd.Flags().String("out", "tree.json", "output file")
-	Cmd.AddCommand(treeCmd)
+func newTreeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tree",
+		Short: "Generate command tree",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, _ := cmd.Flags().GetString("out")
+
+			f, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "  ")
+			return enc.Encode(map[string]command{
+				cmd.Root().Name(): commandTree(cmd.Root()),
+			})
+		},
+	}
+	cmd.Flags().String("out", "tree.json", "output file")
+	return cmd
 }
@@ -3,21 +3,36 @@
 // Package logging implements common logging functionality used by commands.
 package logging
 
-import "go.uber.org/zap"
+import (
+	"io"
+	"os"
 
-// GetLogger returns a new logger.
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// GetLogger returns a new logger that writes to standard error.
 func GetLogger(debug bool) *zap.Logger {
-	var logger *zap.Logger
+	return NewLogger(os.Stderr, debug)
+}
+
+// NewLogger returns a new logger that writes to w. In debug mode, debug messages are logged.
+func NewLogger(w io.Writer, debug bool) *zap.Logger {
+	var (
+		ws    = zapcore.Lock(zapcore.AddSync(w))
+		level = zap.InfoLevel
+		opts  = []zap.Option{zap.ErrorOutput(ws), zap.AddCaller()}
+	)
 	if debug {
-		logger, _ = zap.NewDevelopment()
+		level = zap.DebugLevel
+		opts = append(opts, zap.Development(), zap.AddStacktrace(zap.WarnLevel))
 	} else {
-		logger, _ = zap.Config{
-			Level:            zap.NewAtomicLevelAt(zap.InfoLevel),
-			Encoding:         "console",
-			EncoderConfig:    zap.NewDevelopmentEncoderConfig(),
-			OutputPaths:      []string{"stderr"},
-			ErrorOutputPaths: []string{"stderr"},
-		}.Build()
+		opts = append(opts, zap.AddStacktrace(zap.ErrorLevel))
 	}
-	return logger
+	core := zapcore.NewCore(
+		zapcore.NewConsoleEncoder(zap.NewDevelopmentEncoderConfig()),
+		ws,
+		level,
+	)
+	return zap.New(core, opts...)
 }
@@ -0,0 +1,151 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package mqttclient connects to MQTT servers, configured by command-line flags.
+package mqttclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	flag "github.com/spf13/pflag"
+	"go.uber.org/zap"
+)
+
+// Flags returns flags to connect to an MQTT server.
+func Flags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.String("mqtt-server", "", "MQTT server URL (tcp://, ssl://, ws:// or wss://)")
+	flags.String("mqtt-client-id", "", "MQTT client ID (default is random)")
+	flags.String("mqtt-username", "", "MQTT username")
+	flags.String("mqtt-password", "", "MQTT password")
+	flags.Int("mqtt-qos", 1, "MQTT quality of service (0, 1 or 2)")
+	flags.String("mqtt-ca-file", "", "file with CA certificates to verify the MQTT server")
+	flags.String("mqtt-cert-file", "", "file with the MQTT client certificate")
+	flags.String("mqtt-key-file", "", "file with the MQTT client key")
+	flags.Bool("mqtt-insecure-skip-verify", false, "do not verify the MQTT server certificate")
+	flags.Duration("mqtt-connect-timeout", 30*time.Second, "timeout of connecting to the MQTT server")
+	flags.Duration("mqtt-max-reconnect-interval", time.Minute, "maximum interval between attempts to reconnect to the MQTT server")
+	return flags
+}
+
+// Config is the configuration of an MQTT client.
+type Config struct {
+	Server               string
+	ClientID             string
+	Username, Password   string
+	QoS                  byte
+	TLS                  *tls.Config
+	ConnectTimeout       time.Duration
+	MaxReconnectInterval time.Duration
+}
+
+// GetConfig returns the configuration from the flags. It returns false if no MQTT server is specified.
+func GetConfig(flags *flag.FlagSet) (Config, bool, error) {
+	var (
+		server, _               = flags.GetString("mqtt-server")
+		clientID, _             = flags.GetString("mqtt-client-id")
+		username, _             = flags.GetString("mqtt-username")
+		password, _             = flags.GetString("mqtt-password")
+		qos, _                  = flags.GetInt("mqtt-qos")
+		caFile, _               = flags.GetString("mqtt-ca-file")
+		certFile, _             = flags.GetString("mqtt-cert-file")
+		keyFile, _              = flags.GetString("mqtt-key-file")
+		insecureSkipVerify, _   = flags.GetBool("mqtt-insecure-skip-verify")
+		connectTimeout, _       = flags.GetDuration("mqtt-connect-timeout")
+		maxReconnectInterval, _ = flags.GetDuration("mqtt-max-reconnect-interval")
+	)
+	if server == "" {
+		return Config{}, false, nil
+	}
+	if qos < 0 || qos > 2 {
+		return Config{}, false, fmt.Errorf("invalid MQTT quality of service %d", qos)
+	}
+	if clientID == "" {
+		var buf [8]byte
+		if _, err := rand.Read(buf[:]); err != nil {
+			return Config{}, false, err
+		}
+		clientID = "pb-" + hex.EncodeToString(buf[:])
+	}
+	conf := Config{
+		Server:               server,
+		ClientID:             clientID,
+		Username:             username,
+		Password:             password,
+		QoS:                  byte(qos),
+		ConnectTimeout:       connectTimeout,
+		MaxReconnectInterval: maxReconnectInterval,
+	}
+	if caFile != "" || certFile != "" || keyFile != "" || insecureSkipVerify {
+		conf.TLS = &tls.Config{
+			InsecureSkipVerify: insecureSkipVerify,
+		}
+		if caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return Config{}, false, fmt.Errorf("read MQTT CA file: %w", err)
+			}
+			conf.TLS.RootCAs = x509.NewCertPool()
+			if !conf.TLS.RootCAs.AppendCertsFromPEM(pem) {
+				return Config{}, false, errors.New("no certificates in MQTT CA file")
+			}
+		}
+		if certFile != "" || keyFile != "" {
+			cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+			if err != nil {
+				return Config{}, false, fmt.Errorf("load MQTT client certificate: %w", err)
+			}
+			conf.TLS.Certificates = []tls.Certificate{cert}
+		}
+	}
+	return conf, true, nil
+}
+
+// Connect connects to the MQTT server. The client reconnects automatically when the connection is lost.
+// If onConnect is not nil, it is called on every connect, for example to subscribe.
+func Connect(ctx context.Context, logger *zap.Logger, conf Config, onConnect func(mqtt.Client)) (mqtt.Client, error) {
+	logger = logger.With(zap.String("mqtt_server", conf.Server), zap.String("mqtt_client_id", conf.ClientID))
+	opts := mqtt.NewClientOptions().
+		AddBroker(conf.Server).
+		SetClientID(conf.ClientID).
+		SetUsername(conf.Username).
+		SetPassword(conf.Password).
+		SetConnectTimeout(conf.ConnectTimeout).
+		SetAutoReconnect(true).
+		SetMaxReconnectInterval(conf.MaxReconnectInterval).
+		SetOnConnectHandler(func(c mqtt.Client) {
+			logger.Info("Connected to MQTT server")
+			if onConnect != nil {
+				onConnect(c)
+			}
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logger.Warn("Lost connection to MQTT server", zap.Error(err))
+		}).
+		SetReconnectingHandler(func(mqtt.Client, *mqtt.ClientOptions) {
+			logger.Info("Reconnecting to MQTT server")
+		})
+	if conf.TLS != nil {
+		opts.SetTLSConfig(conf.TLS)
+	}
+	client := mqtt.NewClient(opts)
+	token := client.Connect()
+	select {
+	case <-ctx.Done():
+		client.Disconnect(0)
+		return nil, ctx.Err()
+	case <-token.Done():
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("connect to MQTT server: %w", err)
+	}
+	return client, nil
+}
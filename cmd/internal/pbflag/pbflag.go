@@ -13,6 +13,7 @@ import (
 
 	flag "github.com/spf13/pflag"
 	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/pkg/lorawan"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
@@ -179,11 +180,11 @@ type devAddrBlocksValue []*packetbroker.DevAddrBlock
 func (f *devAddrBlocksValue) String() string {
 	ss := make([]string, len(*f))
 	for i, b := range *f {
-		prefix, _ := b.Prefix.MarshalText()
+		prefix := lorawan.FromDevAddrPrefix(b.Prefix)
 		if b.HomeNetworkClusterId != "" {
-			ss[i] = fmt.Sprintf("%s=%s", string(prefix), b.HomeNetworkClusterId)
+			ss[i] = fmt.Sprintf("%s=%s", prefix, b.HomeNetworkClusterId)
 		} else {
-			ss[i] = string(prefix)
+			ss[i] = prefix.String()
 		}
 	}
 	return strings.Join(ss, ",")
@@ -198,15 +199,16 @@ func (f *devAddrBlocksValue) Set(s string) error {
 	res := make([]*packetbroker.DevAddrBlock, len(blocks))
 	for i, b := range blocks {
 		parts := strings.SplitN(b, "=", 2)
+		var prefix lorawan.DevAddrPrefix
+		if err := prefix.UnmarshalText([]byte(parts[0])); err != nil {
+			return err
+		}
 		res[i] = &packetbroker.DevAddrBlock{
-			Prefix: &packetbroker.DevAddrPrefix{},
+			Prefix: prefix.Proto(),
 		}
 		if len(parts) == 2 {
 			res[i].HomeNetworkClusterId = parts[1]
 		}
-		if err := res[i].Prefix.UnmarshalText([]byte(parts[0])); err != nil {
-			return err
-		}
 	}
 	*f = res
 	return nil
@@ -244,8 +246,7 @@ type joinEUIPrefixesValue []*packetbroker.JoinEUIPrefix
 func (f *joinEUIPrefixesValue) String() string {
 	ss := make([]string, len(*f))
 	for i, b := range *f {
-		prefix, _ := b.MarshalText()
-		ss[i] = string(prefix)
+		ss[i] = lorawan.FromJoinEUIPrefix(b).String()
 	}
 	return strings.Join(ss, ",")
 }
@@ -258,10 +259,11 @@ func (f *joinEUIPrefixesValue) Set(s string) error {
 	blocks := strings.Split(s, ",")
 	res := make([]*packetbroker.JoinEUIPrefix, len(blocks))
 	for i, b := range blocks {
-		res[i] = new(packetbroker.JoinEUIPrefix)
-		if err := res[i].UnmarshalText([]byte(b)); err != nil {
+		var prefix lorawan.EUI64Prefix
+		if err := prefix.UnmarshalText([]byte(b)); err != nil {
 			return err
 		}
+		res[i] = prefix.Proto()
 	}
 	*f = res
 	return nil
@@ -284,6 +286,170 @@ func GetJoinEUIPrefixes(flags *flag.FlagSet) []*packetbroker.JoinEUIPrefix {
 	return []*packetbroker.JoinEUIPrefix(*blocks)
 }
 
+type devAddrPrefixesValue []lorawan.DevAddrPrefix
+
+func (f *devAddrPrefixesValue) String() string {
+	ss := make([]string, len(*f))
+	for i, p := range *f {
+		ss[i] = p.String()
+	}
+	return strings.Join(ss, ",")
+}
+
+func (f *devAddrPrefixesValue) Set(s string) error {
+	if s == "" {
+		*f = []lorawan.DevAddrPrefix{}
+		return nil
+	}
+	prefixes := strings.Split(s, ",")
+	res := make([]lorawan.DevAddrPrefix, len(prefixes))
+	for i, p := range prefixes {
+		if err := res[i].UnmarshalText([]byte(p)); err != nil {
+			return err
+		}
+	}
+	*f = res
+	return nil
+}
+
+func (f *devAddrPrefixesValue) Type() string {
+	return "devAddrPrefixes"
+}
+
+// DevAddrPrefixes returns flags for DevAddr prefixes.
+func DevAddrPrefixes(name, usage string) *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.Var(new(devAddrPrefixesValue), name, usage)
+	return flags
+}
+
+// GetDevAddrPrefixes returns the DevAddr prefixes from the flags.
+func GetDevAddrPrefixes(flags *flag.FlagSet, name string) []lorawan.DevAddrPrefix {
+	return []lorawan.DevAddrPrefix(*flags.Lookup(name).Value.(*devAddrPrefixesValue))
+}
+
+type eui64PrefixesValue []lorawan.EUI64Prefix
+
+func (f *eui64PrefixesValue) String() string {
+	ss := make([]string, len(*f))
+	for i, p := range *f {
+		ss[i] = p.String()
+	}
+	return strings.Join(ss, ",")
+}
+
+func (f *eui64PrefixesValue) Set(s string) error {
+	if s == "" {
+		*f = []lorawan.EUI64Prefix{}
+		return nil
+	}
+	prefixes := strings.Split(s, ",")
+	res := make([]lorawan.EUI64Prefix, len(prefixes))
+	for i, p := range prefixes {
+		if err := res[i].UnmarshalText([]byte(p)); err != nil {
+			return err
+		}
+	}
+	*f = res
+	return nil
+}
+
+func (f *eui64PrefixesValue) Type() string {
+	return "eui64Prefixes"
+}
+
+// EUI64Prefixes returns flags for EUI-64 prefixes.
+func EUI64Prefixes(name, usage string) *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.Var(new(eui64PrefixesValue), name, usage)
+	return flags
+}
+
+// GetEUI64Prefixes returns the EUI-64 prefixes from the flags.
+func GetEUI64Prefixes(flags *flag.FlagSet, name string) []lorawan.EUI64Prefix {
+	return []lorawan.EUI64Prefix(*flags.Lookup(name).Value.(*eui64PrefixesValue))
+}
+
+type devAddrValue struct {
+	devAddr *lorawan.DevAddr
+}
+
+func (f *devAddrValue) String() string {
+	if f.devAddr == nil {
+		return ""
+	}
+	return f.devAddr.String()
+}
+
+func (f *devAddrValue) Set(s string) error {
+	var devAddr lorawan.DevAddr
+	if err := devAddr.UnmarshalText([]byte(s)); err != nil {
+		return err
+	}
+	f.devAddr = &devAddr
+	return nil
+}
+
+func (f *devAddrValue) Type() string {
+	return "devAddr"
+}
+
+// DevAddr returns flags for a DevAddr.
+func DevAddr(name, usage string) *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.Var(new(devAddrValue), name, usage)
+	return flags
+}
+
+// GetDevAddr returns the DevAddr from the flags.
+func GetDevAddr(flags *flag.FlagSet, name string) (lorawan.DevAddr, bool) {
+	devAddr := flags.Lookup(name).Value.(*devAddrValue).devAddr
+	if devAddr == nil {
+		return 0, false
+	}
+	return *devAddr, true
+}
+
+type eui64Value struct {
+	eui *lorawan.EUI64
+}
+
+func (f *eui64Value) String() string {
+	if f.eui == nil {
+		return ""
+	}
+	return f.eui.String()
+}
+
+func (f *eui64Value) Set(s string) error {
+	var eui lorawan.EUI64
+	if err := eui.UnmarshalText([]byte(s)); err != nil {
+		return err
+	}
+	f.eui = &eui
+	return nil
+}
+
+func (f *eui64Value) Type() string {
+	return "eui64"
+}
+
+// EUI64 returns flags for an EUI-64.
+func EUI64(name, usage string) *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.Var(new(eui64Value), name, usage)
+	return flags
+}
+
+// GetEUI64 returns the EUI-64 from the flags.
+func GetEUI64(flags *flag.FlagSet, name string) (lorawan.EUI64, bool) {
+	eui := flags.Lookup(name).Value.(*eui64Value).eui
+	if eui == nil {
+		return 0, false
+	}
+	return *eui, true
+}
+
 type monthYear struct {
 	valid       bool
 	month, year int
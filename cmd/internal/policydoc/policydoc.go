@@ -0,0 +1,382 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package policydoc implements documents with routing policies and gateway visibilities of a Forwarder in symbolic
+// notation.
+package policydoc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	packetbroker "go.packetbroker.org/api/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	uplinkLetters   = "JMASL"
+	downlinkLetters = "JMA"
+)
+
+// normalizeLetters returns the letters in s in the order of valid.
+// The letters are case sensitive. A dash means no letters.
+func normalizeLetters(s, valid string) (string, error) {
+	if s == "-" {
+		return "", nil
+	}
+	for _, r := range s {
+		if !strings.ContainsRune(valid, r) {
+			return "", fmt.Errorf("invalid letter %q, use %s", r, strings.Join(strings.Split(valid, ""), ", "))
+		}
+	}
+	var res strings.Builder
+	for _, r := range valid {
+		if strings.ContainsRune(s, r) {
+			res.WriteRune(r)
+		}
+	}
+	return res.String(), nil
+}
+
+// Policy is a routing policy in symbolic notation.
+// The uplink policy uses letters J (join-request), M (MAC data), A (application data), S (signal quality) and L (localization).
+// The downlink policy uses letters J (join-accept), M (MAC data) and A (application data).
+type Policy struct {
+	Uplink   string `json:"uplink" yaml:"uplink"`
+	Downlink string `json:"downlink" yaml:"downlink"`
+}
+
+// FromRoutingPolicy returns the policy in symbolic notation. A routing policy without uplink and downlink is no
+// policy, so the result is nil. A routing policy that allows nothing is an empty policy.
+func FromRoutingPolicy(p *packetbroker.RoutingPolicy) *Policy {
+	if p.GetUplink() == nil && p.GetDownlink() == nil {
+		return nil
+	}
+	res := new(Policy)
+	for i, b := range []bool{
+		p.GetUplink().GetJoinRequest(),
+		p.GetUplink().GetMacData(),
+		p.GetUplink().GetApplicationData(),
+		p.GetUplink().GetSignalQuality(),
+		p.GetUplink().GetLocalization(),
+	} {
+		if b {
+			res.Uplink += uplinkLetters[i : i+1]
+		}
+	}
+	for i, b := range []bool{
+		p.GetDownlink().GetJoinAccept(),
+		p.GetDownlink().GetMacData(),
+		p.GetDownlink().GetApplicationData(),
+	} {
+		if b {
+			res.Downlink += downlinkLetters[i : i+1]
+		}
+	}
+	return res
+}
+
+// Normalize returns the policy with the letters in canonical order.
+func (p Policy) Normalize() (Policy, error) {
+	uplink, err := normalizeLetters(p.Uplink, uplinkLetters)
+	if err != nil {
+		return Policy{}, fmt.Errorf("uplink: %w", err)
+	}
+	downlink, err := normalizeLetters(p.Downlink, downlinkLetters)
+	if err != nil {
+		return Policy{}, fmt.Errorf("downlink: %w", err)
+	}
+	return Policy{Uplink: uplink, Downlink: downlink}, nil
+}
+
+// IsEmpty returns whether the policy allows nothing.
+func (p Policy) IsEmpty() bool {
+	return p.Uplink == "" && p.Downlink == ""
+}
+
+// RoutingPolicy returns the routing policy of the Forwarder and, if not empty, the Home Network.
+// The policy must be normalized. If the policy is nil, the routing policy has no uplink and downlink, which deletes
+// the policy.
+func (p *Policy) RoutingPolicy(forwarder, homeNetwork packetbroker.TenantID) *packetbroker.RoutingPolicy {
+	res := &packetbroker.RoutingPolicy{
+		ForwarderNetId:      uint32(forwarder.NetID),
+		ForwarderTenantId:   forwarder.ID,
+		HomeNetworkNetId:    uint32(homeNetwork.NetID),
+		HomeNetworkTenantId: homeNetwork.ID,
+	}
+	if p == nil {
+		return res
+	}
+	res.Uplink = &packetbroker.RoutingPolicy_Uplink{
+		JoinRequest:     strings.ContainsRune(p.Uplink, 'J'),
+		MacData:         strings.ContainsRune(p.Uplink, 'M'),
+		ApplicationData: strings.ContainsRune(p.Uplink, 'A'),
+		SignalQuality:   strings.ContainsRune(p.Uplink, 'S'),
+		Localization:    strings.ContainsRune(p.Uplink, 'L'),
+	}
+	res.Downlink = &packetbroker.RoutingPolicy_Downlink{
+		JoinAccept:      strings.ContainsRune(p.Downlink, 'J'),
+		MacData:         strings.ContainsRune(p.Downlink, 'M'),
+		ApplicationData: strings.ContainsRune(p.Downlink, 'A'),
+	}
+	return res
+}
+
+// Network identifies a network or tenant.
+type Network struct {
+	NetID    string `json:"net-id" yaml:"net-id"`
+	TenantID string `json:"tenant-id,omitempty" yaml:"tenant-id,omitempty"`
+}
+
+// NewNetwork returns the network of the tenant ID.
+func NewNetwork(id packetbroker.TenantID) Network {
+	return Network{
+		NetID:    id.NetID.String(),
+		TenantID: id.ID,
+	}
+}
+
+// Parse parses the network as tenant ID.
+func (n Network) Parse() (packetbroker.TenantID, error) {
+	var netID packetbroker.NetID
+	if err := netID.UnmarshalText([]byte(n.NetID)); err != nil {
+		return packetbroker.TenantID{}, fmt.Errorf("invalid NetID %q: %w", n.NetID, err)
+	}
+	return packetbroker.TenantID{
+		NetID: netID,
+		ID:    n.TenantID,
+	}, nil
+}
+
+func (n Network) String() string {
+	if n.TenantID == "" {
+		return n.NetID
+	}
+	return n.NetID + "/" + n.TenantID
+}
+
+func lessNetwork(a, b Network) bool {
+	aID, _ := a.Parse()
+	bID, _ := b.Parse()
+	return lessTenantID(aID, bID)
+}
+
+func lessTenantID(a, b packetbroker.TenantID) bool {
+	if a.NetID != b.NetID {
+		return a.NetID < b.NetID
+	}
+	return a.ID < b.ID
+}
+
+func sortTenantIDs(ids []packetbroker.TenantID) {
+	sort.Slice(ids, func(i, j int) bool {
+		return lessTenantID(ids[i], ids[j])
+	})
+}
+
+// normalizeForwarder validates and normalizes the Forwarder, if not nil.
+func normalizeForwarder(forwarder *Network) error {
+	if forwarder == nil {
+		return nil
+	}
+	id, err := forwarder.Parse()
+	if err != nil {
+		return fmt.Errorf("forwarder: %w", err)
+	}
+	*forwarder = NewNetwork(id)
+	return nil
+}
+
+// normalizeHomeNetworks validates and normalizes the Home Networks, which must be unique.
+func normalizeHomeNetworks(networks []*Network) error {
+	seen := make(map[packetbroker.TenantID]bool, len(networks))
+	for i, n := range networks {
+		id, err := n.Parse()
+		if err != nil {
+			return fmt.Errorf("home network %d: %w", i+1, err)
+		}
+		if seen[id] {
+			return fmt.Errorf("home network %s: duplicate", n)
+		}
+		seen[id] = true
+		*n = NewNetwork(id)
+	}
+	return nil
+}
+
+// writeYAML writes the document as YAML, with the names of the Forwarder and the Home Networks as comments.
+// The Home Networks must be in the order of the document.
+func writeYAML(w io.Writer, doc interface{}, forwarder *Network, homeNetworks []Network, names map[packetbroker.TenantID]string) error {
+	var node yaml.Node
+	if err := node.Encode(doc); err != nil {
+		return err
+	}
+	nameOf := func(n Network) string {
+		id, err := n.Parse()
+		if err != nil {
+			return ""
+		}
+		return names[id]
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "forwarder":
+			key.LineComment = nameOf(*forwarder)
+		case "home-networks":
+			for j, item := range value.Content {
+				item.HeadComment = nameOf(homeNetworks[j])
+			}
+		}
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// HomeNetworkPolicy is a policy of a Forwarder with a Home Network.
+type HomeNetworkPolicy struct {
+	Network `yaml:",inline"`
+	Policy  `yaml:",inline"`
+}
+
+// Document contains the default routing policy and the routing policies with Home Networks of a Forwarder.
+type Document struct {
+	Forwarder    *Network            `json:"forwarder,omitempty" yaml:"forwarder,omitempty"`
+	Defaults     *Policy             `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	HomeNetworks []HomeNetworkPolicy `json:"home-networks" yaml:"home-networks"`
+}
+
+// Read reads a document in YAML or JSON. The document is validated and normalized.
+func Read(r io.Reader) (*Document, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var doc Document
+	if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := doc.Normalize(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// WriteYAML writes the document as YAML. The names of the networks and tenants are written as comments.
+func (d *Document) WriteYAML(w io.Writer, names map[packetbroker.TenantID]string) error {
+	networks := make([]Network, len(d.HomeNetworks))
+	for i, hn := range d.HomeNetworks {
+		networks[i] = hn.Network
+	}
+	return writeYAML(w, d, d.Forwarder, networks, names)
+}
+
+// WriteJSON writes the document as indented JSON.
+func (d *Document) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// Normalize validates the document, normalizes the policies and sorts the Home Networks.
+func (d *Document) Normalize() error {
+	if err := normalizeForwarder(d.Forwarder); err != nil {
+		return err
+	}
+	if d.Defaults != nil {
+		p, err := d.Defaults.Normalize()
+		if err != nil {
+			return fmt.Errorf("defaults: %w", err)
+		}
+		*d.Defaults = p
+	}
+	networks := make([]*Network, len(d.HomeNetworks))
+	for i := range d.HomeNetworks {
+		networks[i] = &d.HomeNetworks[i].Network
+	}
+	if err := normalizeHomeNetworks(networks); err != nil {
+		return err
+	}
+	for i, hn := range d.HomeNetworks {
+		p, err := hn.Policy.Normalize()
+		if err != nil {
+			return fmt.Errorf("home network %s: %w", hn.Network, err)
+		}
+		d.HomeNetworks[i].Policy = p
+	}
+	sort.Slice(d.HomeNetworks, func(i, j int) bool {
+		return lessNetwork(d.HomeNetworks[i].Network, d.HomeNetworks[j].Network)
+	})
+	return nil
+}
+
+// Defines returns whether the document defines the policy with the Home Network, or the default policy if nil.
+func (d *Document) Defines(homeNetwork *packetbroker.TenantID) bool {
+	if homeNetwork == nil {
+		return d.Defaults != nil
+	}
+	for _, hn := range d.HomeNetworks {
+		if id, err := hn.Network.Parse(); err == nil && id == *homeNetwork {
+			return true
+		}
+	}
+	return false
+}
+
+// Change is a difference in policy. Absent policies are nil. Empty policies are not nil, as an empty policy allows
+// nothing, whereas an absent policy falls back to the default policy.
+type Change struct {
+	// HomeNetwork is nil for the default policy.
+	HomeNetwork *packetbroker.TenantID
+	From, To    *Policy
+}
+
+func (c Change) differs() bool {
+	switch {
+	case c.From == nil && c.To == nil:
+		return false
+	case c.From == nil || c.To == nil:
+		return true
+	default:
+		return *c.From != *c.To
+	}
+}
+
+// Compare returns the changes from one document to the other. The documents must be normalized.
+// The changes are ordered by the default policy first, followed by the Home Networks.
+func Compare(from, to *Document) []Change {
+	var res []Change
+	if c := (Change{From: from.Defaults, To: to.Defaults}); c.differs() {
+		res = append(res, c)
+	}
+
+	policies := make(map[packetbroker.TenantID]*Change)
+	var ids []packetbroker.TenantID
+	add := func(hns []HomeNetworkPolicy, set func(*Change, *Policy)) {
+		for i := range hns {
+			id, _ := hns[i].Network.Parse()
+			c, ok := policies[id]
+			if !ok {
+				id := id
+				c = &Change{HomeNetwork: &id}
+				policies[id] = c
+				ids = append(ids, id)
+			}
+			set(c, &hns[i].Policy)
+		}
+	}
+	add(from.HomeNetworks, func(c *Change, p *Policy) { c.From = p })
+	add(to.HomeNetworks, func(c *Change, p *Policy) { c.To = p })
+	sortTenantIDs(ids)
+	for _, id := range ids {
+		if c := policies[id]; c.differs() {
+			res = append(res, *c)
+		}
+	}
+	return res
+}
@@ -0,0 +1,141 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package policydoc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	packetbroker "go.packetbroker.org/api/v3"
+)
+
+func TestRead(t *testing.T) {
+	doc, err := Read(strings.NewReader(`
+forwarder:
+  net-id: "000013"
+  tenant-id: tti
+defaults:
+  uplink: LSAMJ
+  downlink: "-"
+home-networks:
+- net-id: c00123
+  tenant-id: tenant-b
+  uplink: JM
+  downlink: MJ
+- net-id: "000009"
+  uplink: JMASL
+  downlink: JMA
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := &Document{
+		Forwarder: &Network{NetID: "000013", TenantID: "tti"},
+		Defaults:  &Policy{Uplink: "JMASL"},
+		HomeNetworks: []HomeNetworkPolicy{
+			{Network: Network{NetID: "000009"}, Policy: Policy{Uplink: "JMASL", Downlink: "JMA"}},
+			{Network: Network{NetID: "C00123", TenantID: "tenant-b"}, Policy: Policy{Uplink: "JM", Downlink: "JM"}},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, doc)
+	}
+
+	for _, invalid := range []string{
+		"defaults: {uplink: X}",
+		"defaults: {downlink: S}",
+		"home-networks: [{net-id: xyz}]",
+		"home-networks: [{net-id: '000009'}, {net-id: '000009'}]",
+		"unknown: true",
+	} {
+		if _, err := Read(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	from := &Document{
+		Defaults: &Policy{Uplink: "JMASL", Downlink: "JMA"},
+		HomeNetworks: []HomeNetworkPolicy{
+			{Network: Network{NetID: "000009"}, Policy: Policy{Uplink: "JM", Downlink: "JM"}},
+			{Network: Network{NetID: "000013"}, Policy: Policy{Uplink: "JMA", Downlink: "JMA"}},
+			{Network: Network{NetID: "000013", TenantID: "tti"}, Policy: Policy{}},
+		},
+	}
+	to := &Document{
+		Defaults: &Policy{Uplink: "JMASL", Downlink: "JMA"},
+		HomeNetworks: []HomeNetworkPolicy{
+			{Network: Network{NetID: "000009"}, Policy: Policy{Uplink: "JMASL", Downlink: "JMA"}},
+			{Network: Network{NetID: "C00123"}, Policy: Policy{Uplink: "J", Downlink: "J"}},
+		},
+	}
+	id := func(netID uint32, tenantID string) *packetbroker.TenantID {
+		return &packetbroker.TenantID{NetID: packetbroker.NetID(netID), ID: tenantID}
+	}
+	expected := []Change{
+		{HomeNetwork: id(0x000009, ""), From: &Policy{Uplink: "JM", Downlink: "JM"}, To: &Policy{Uplink: "JMASL", Downlink: "JMA"}},
+		{HomeNetwork: id(0x000013, ""), From: &Policy{Uplink: "JMA", Downlink: "JMA"}},
+		{HomeNetwork: id(0x000013, "tti"), From: &Policy{}},
+		{HomeNetwork: id(0xC00123, ""), To: &Policy{Uplink: "J", Downlink: "J"}},
+	}
+	if changes := Compare(from, to); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, changes)
+	}
+
+	// An empty policy allows nothing, which differs from no policy.
+	expected = []Change{{To: &Policy{}}}
+	if changes := Compare(&Document{}, &Document{Defaults: &Policy{}}); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, changes)
+	}
+
+	if !to.Defines(nil) || !to.Defines(id(0xC00123, "")) || to.Defines(id(0x000013, "")) {
+		t.Fatal("unexpected defines")
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	doc := &Document{
+		Forwarder: &Network{NetID: "000013", TenantID: "tti"},
+		Defaults:  &Policy{Uplink: "JMASL", Downlink: "JMA"},
+		HomeNetworks: []HomeNetworkPolicy{
+			{Network: Network{NetID: "000009"}, Policy: Policy{Uplink: "JM", Downlink: "JM"}},
+			{Network: Network{NetID: "C00123", TenantID: "tenant-b"}, Policy: Policy{Uplink: "J"}},
+		},
+	}
+	var buf strings.Builder
+	if err := doc.WriteYAML(&buf, map[packetbroker.TenantID]string{
+		{NetID: 0x000013, ID: "tti"}: "The Things Industries",
+		{NetID: 0x000009}:            "Senet",
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `forwarder: # The Things Industries
+  net-id: "000013"
+  tenant-id: tti
+defaults:
+  uplink: JMASL
+  downlink: JMA
+home-networks:
+  # Senet
+  - net-id: "000009"
+    uplink: JM
+    downlink: JM
+  - net-id: C00123
+    tenant-id: tenant-b
+    uplink: J
+    downlink: ""
+`
+	if buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+
+	read, err := Read(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(read, doc) {
+		t.Fatalf("expected %+v, got %+v", doc, read)
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package policydoc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	packetbroker "go.packetbroker.org/api/v3"
+	"gopkg.in/yaml.v3"
+)
+
+var visibilitySymbols = [...]string{"Lo", "Ap", "Ac", "Ft", "Ci", "St", "Fp", "Pr"}
+
+// NormalizeVisibility returns the gateway visibility symbols in canonical order.
+// The symbols are Lo (location), Ap (antenna placement), Ac (antenna count), Ft (fine timestamps),
+// Ci (contact information), St (status), Fp (frequency plan) and Pr (packet rates). A dash means no symbols.
+func NormalizeVisibility(s string) (string, error) {
+	if s == "-" {
+		return "", nil
+	}
+	if len(s)%2 != 0 {
+		return "", fmt.Errorf("invalid symbols %q, use %s", s, strings.Join(visibilitySymbols[:], ", "))
+	}
+	set := make(map[string]bool, len(s)/2)
+	for i := 0; i < len(s); i += 2 {
+		sym := s[i : i+2]
+		valid := false
+		for _, v := range visibilitySymbols {
+			if v == sym {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return "", fmt.Errorf("invalid symbol %q, use %s", sym, strings.Join(visibilitySymbols[:], ", "))
+		}
+		set[sym] = true
+	}
+	var res strings.Builder
+	for _, v := range visibilitySymbols {
+		if set[v] {
+			res.WriteString(v)
+		}
+	}
+	return res.String(), nil
+}
+
+// FromGatewayVisibility returns the gateway visibility in symbolic notation.
+func FromGatewayVisibility(v *packetbroker.GatewayVisibility) string {
+	var res strings.Builder
+	for i, b := range []bool{
+		v.GetLocation(),
+		v.GetAntennaPlacement(),
+		v.GetAntennaCount(),
+		v.GetFineTimestamps(),
+		v.GetContactInfo(),
+		v.GetStatus(),
+		v.GetFrequencyPlan(),
+		v.GetPacketRates(),
+	} {
+		if b {
+			res.WriteString(visibilitySymbols[i])
+		}
+	}
+	return res.String()
+}
+
+// GatewayVisibility returns the gateway visibility of the Forwarder and, if not empty, the Home Network.
+// The symbols must be normalized.
+func GatewayVisibility(symbols string, forwarder, homeNetwork packetbroker.TenantID) *packetbroker.GatewayVisibility {
+	return &packetbroker.GatewayVisibility{
+		ForwarderNetId:      uint32(forwarder.NetID),
+		ForwarderTenantId:   forwarder.ID,
+		HomeNetworkNetId:    uint32(homeNetwork.NetID),
+		HomeNetworkTenantId: homeNetwork.ID,
+		Location:            strings.Contains(symbols, "Lo"),
+		AntennaPlacement:    strings.Contains(symbols, "Ap"),
+		AntennaCount:        strings.Contains(symbols, "Ac"),
+		FineTimestamps:      strings.Contains(symbols, "Ft"),
+		ContactInfo:         strings.Contains(symbols, "Ci"),
+		Status:              strings.Contains(symbols, "St"),
+		FrequencyPlan:       strings.Contains(symbols, "Fp"),
+		PacketRates:         strings.Contains(symbols, "Pr"),
+	}
+}
+
+// HomeNetworkVisibility is a gateway visibility of a Forwarder with a Home Network.
+type HomeNetworkVisibility struct {
+	Network    `yaml:",inline"`
+	Visibility string `json:"visibility" yaml:"visibility"`
+}
+
+// VisibilityDocument contains the default gateway visibility and the gateway visibilities with Home Networks of a
+// Forwarder.
+type VisibilityDocument struct {
+	Forwarder    *Network                `json:"forwarder,omitempty" yaml:"forwarder,omitempty"`
+	Defaults     *string                 `json:"defaults,omitempty" yaml:"defaults,omitempty"`
+	HomeNetworks []HomeNetworkVisibility `json:"home-networks" yaml:"home-networks"`
+}
+
+// ReadVisibilities reads a document in YAML or JSON. The document is validated and normalized.
+func ReadVisibilities(r io.Reader) (*VisibilityDocument, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var doc VisibilityDocument
+	if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err := doc.Normalize(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// WriteYAML writes the document as YAML. The names of the networks and tenants are written as comments.
+func (d *VisibilityDocument) WriteYAML(w io.Writer, names map[packetbroker.TenantID]string) error {
+	networks := make([]Network, len(d.HomeNetworks))
+	for i, hn := range d.HomeNetworks {
+		networks[i] = hn.Network
+	}
+	return writeYAML(w, d, d.Forwarder, networks, names)
+}
+
+// WriteJSON writes the document as indented JSON.
+func (d *VisibilityDocument) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// Normalize validates the document, normalizes the visibilities and sorts the Home Networks.
+func (d *VisibilityDocument) Normalize() error {
+	if err := normalizeForwarder(d.Forwarder); err != nil {
+		return err
+	}
+	if d.Defaults != nil {
+		v, err := NormalizeVisibility(*d.Defaults)
+		if err != nil {
+			return fmt.Errorf("defaults: %w", err)
+		}
+		*d.Defaults = v
+	}
+	networks := make([]*Network, len(d.HomeNetworks))
+	for i := range d.HomeNetworks {
+		networks[i] = &d.HomeNetworks[i].Network
+	}
+	if err := normalizeHomeNetworks(networks); err != nil {
+		return err
+	}
+	for i, hn := range d.HomeNetworks {
+		v, err := NormalizeVisibility(hn.Visibility)
+		if err != nil {
+			return fmt.Errorf("home network %s: %w", hn.Network, err)
+		}
+		d.HomeNetworks[i].Visibility = v
+	}
+	sort.Slice(d.HomeNetworks, func(i, j int) bool {
+		return lessNetwork(d.HomeNetworks[i].Network, d.HomeNetworks[j].Network)
+	})
+	return nil
+}
+
+// Defines returns whether the document defines the visibility with the Home Network, or the default visibility if
+// nil.
+func (d *VisibilityDocument) Defines(homeNetwork *packetbroker.TenantID) bool {
+	if homeNetwork == nil {
+		return d.Defaults != nil
+	}
+	for _, hn := range d.HomeNetworks {
+		if id, err := hn.Network.Parse(); err == nil && id == *homeNetwork {
+			return true
+		}
+	}
+	return false
+}
+
+// VisibilityChange is a difference in gateway visibility. Absent visibilities are nil. Empty visibilities are not nil,
+// as an empty visibility hides everything, whereas an absent visibility falls back to the default visibility.
+type VisibilityChange struct {
+	// HomeNetwork is nil for the default visibility.
+	HomeNetwork *packetbroker.TenantID
+	From, To    *string
+}
+
+func (c VisibilityChange) differs() bool {
+	switch {
+	case c.From == nil && c.To == nil:
+		return false
+	case c.From == nil || c.To == nil:
+		return true
+	default:
+		return *c.From != *c.To
+	}
+}
+
+// CompareVisibilities returns the changes from one document to the other. The documents must be normalized.
+// The changes are ordered by the default visibility first, followed by the Home Networks.
+func CompareVisibilities(from, to *VisibilityDocument) []VisibilityChange {
+	var res []VisibilityChange
+	if c := (VisibilityChange{From: from.Defaults, To: to.Defaults}); c.differs() {
+		res = append(res, c)
+	}
+
+	visibilities := make(map[packetbroker.TenantID]*VisibilityChange)
+	var ids []packetbroker.TenantID
+	add := func(hns []HomeNetworkVisibility, set func(*VisibilityChange, *string)) {
+		for i := range hns {
+			id, _ := hns[i].Network.Parse()
+			c, ok := visibilities[id]
+			if !ok {
+				id := id
+				c = &VisibilityChange{HomeNetwork: &id}
+				visibilities[id] = c
+				ids = append(ids, id)
+			}
+			set(c, &hns[i].Visibility)
+		}
+	}
+	add(from.HomeNetworks, func(c *VisibilityChange, v *string) { c.From = v })
+	add(to.HomeNetworks, func(c *VisibilityChange, v *string) { c.To = v })
+	sortTenantIDs(ids)
+	for _, id := range ids {
+		if c := visibilities[id]; c.differs() {
+			res = append(res, *c)
+		}
+	}
+	return res
+}
@@ -0,0 +1,103 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package policydoc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	packetbroker "go.packetbroker.org/api/v3"
+)
+
+func TestReadVisibilities(t *testing.T) {
+	doc, err := ReadVisibilities(strings.NewReader(`
+forwarder:
+  net-id: "000013"
+defaults: FpStLo
+home-networks:
+- net-id: c00123
+  tenant-id: tenant-b
+  visibility: "-"
+- net-id: "000009"
+  visibility: PrLoAc
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defaults := "LoStFp"
+	expected := &VisibilityDocument{
+		Forwarder: &Network{NetID: "000013"},
+		Defaults:  &defaults,
+		HomeNetworks: []HomeNetworkVisibility{
+			{Network: Network{NetID: "000009"}, Visibility: "LoAcPr"},
+			{Network: Network{NetID: "C00123", TenantID: "tenant-b"}, Visibility: ""},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, doc)
+	}
+
+	for _, invalid := range []string{
+		"defaults: Xx",
+		"defaults: Lo1",
+		"home-networks: [{net-id: '000009', visibility: Lo}, {net-id: '000009', visibility: St}]",
+		"home-networks: [{net-id: '000009', uplink: JM}]",
+	} {
+		if _, err := ReadVisibilities(strings.NewReader(invalid)); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+}
+
+func TestGatewayVisibility(t *testing.T) {
+	forwarder := packetbroker.TenantID{NetID: 0x13, ID: "tti"}
+	homeNetwork := packetbroker.TenantID{NetID: 0x9}
+	v := GatewayVisibility("LoFtPr", forwarder, homeNetwork)
+	if !v.Location || !v.FineTimestamps || !v.PacketRates || v.Status || v.ContactInfo {
+		t.Fatalf("unexpected visibility %+v", v)
+	}
+	if v.ForwarderNetId != 0x13 || v.ForwarderTenantId != "tti" || v.HomeNetworkNetId != 0x9 {
+		t.Fatalf("unexpected identifiers %+v", v)
+	}
+	if s := FromGatewayVisibility(v); s != "LoFtPr" {
+		t.Fatalf("expected LoFtPr, got %q", s)
+	}
+}
+
+func TestCompareVisibilities(t *testing.T) {
+	defaults := "Lo"
+	from := &VisibilityDocument{
+		Defaults: &defaults,
+		HomeNetworks: []HomeNetworkVisibility{
+			{Network: Network{NetID: "000009"}, Visibility: "LoSt"},
+			{Network: Network{NetID: "000013"}, Visibility: "Lo"},
+		},
+	}
+	to := &VisibilityDocument{
+		HomeNetworks: []HomeNetworkVisibility{
+			{Network: Network{NetID: "000009"}, Visibility: "LoSt"},
+			{Network: Network{NetID: "C00123", TenantID: "tenant-b"}, Visibility: "Fp"},
+		},
+	}
+	nwk13 := packetbroker.TenantID{NetID: 0x13}
+	tntB := packetbroker.TenantID{NetID: 0xC00123, ID: "tenant-b"}
+	str := func(s string) *string { return &s }
+	expected := []VisibilityChange{
+		{From: str("Lo")},
+		{HomeNetwork: &nwk13, From: str("Lo")},
+		{HomeNetwork: &tntB, To: str("Fp")},
+	}
+	if changes := CompareVisibilities(from, to); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, changes)
+	}
+
+	// An empty visibility hides everything, which differs from no visibility.
+	expected = []VisibilityChange{{HomeNetwork: &nwk13, To: str("")}}
+	if changes := CompareVisibilities(&VisibilityDocument{Defaults: str("Lo")}, &VisibilityDocument{
+		Defaults:     str("Lo"),
+		HomeNetworks: []HomeNetworkVisibility{{Network: Network{NetID: "000013"}}},
+	}); !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, changes)
+	}
+}
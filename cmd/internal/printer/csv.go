@@ -0,0 +1,171 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package printer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"go.packetbroker.org/pb/cmd/internal/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// flatten flattens the JSON value into the fields map, with nested object keys separated by dots.
+// Arrays are kept as compact JSON values. The keys are appended to keys in order of first occurrence.
+func flatten(prefix string, value interface{}, fields map[string]string, keys *[]string) error {
+	add := func(s string) {
+		if _, ok := fields[prefix]; !ok {
+			*keys = append(*keys, prefix)
+		}
+		fields[prefix] = s
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			key := name
+			if prefix != "" {
+				key = prefix + "." + name
+			}
+			if err := flatten(key, v[name], fields, keys); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		add(string(buf))
+	case nil:
+		add("")
+	case string:
+		add(v)
+	default:
+		add(fmt.Sprint(v))
+	}
+	return nil
+}
+
+// csvColumns returns the CSV columns of the message type: the JSON names of the fields in declaration order. Fields
+// of message types are expanded to the fields of the message, with the names separated by dots. Lists, maps,
+// well-known types and recursive messages are not expanded.
+func csvColumns(md protoreflect.MessageDescriptor, prefix string, expanding map[protoreflect.FullName]bool) []string {
+	var res []string
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		name := fd.JSONName()
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		if m := fd.Message(); m != nil && !fd.IsList() && !fd.IsMap() && m.Fields().Len() > 0 &&
+			m.ParentFile().Package() != "google.protobuf" && !expanding[m.FullName()] {
+			expanding[m.FullName()] = true
+			res = append(res, csvColumns(m, name, expanding)...)
+			delete(expanding, m.FullName())
+			continue
+		}
+		res = append(res, name)
+	}
+	return res
+}
+
+// csvValue returns the value of the JSON value at the column. Missing and null values are empty, and objects and
+// arrays are compact JSON.
+func csvValue(value interface{}, column string) (string, error) {
+	for _, name := range strings.Split(column, ".") {
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return "", nil
+		}
+		value = obj[name]
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// writeCSV writes the messages of the list as CSV with a header. The columns are derived from the message type (see
+// csvColumns), so that the columns do not depend on the messages. If the type is nil, the type of the first message is
+// used. As structs have no fixed fields, the columns of structs are the columns of the list or, if there are none, the
+// flattened JSON fields of the messages.
+func writeCSV(w io.Writer, l List) error {
+	typ := l.Type
+	if typ == nil && len(l.Items) > 0 {
+		typ = l.Items[0].ProtoReflect().Descriptor()
+	}
+	var (
+		header = l.Columns
+		rows   = make([]map[string]string, len(l.Items))
+		seen   = make(map[string]bool)
+	)
+	if typ != nil && typ.FullName() != "google.protobuf.Struct" {
+		header = csvColumns(typ, "", make(map[protoreflect.FullName]bool))
+	}
+	fixed := header != nil
+	for i, item := range l.Items {
+		buf, err := protojson.Marshal(item)
+		if err != nil {
+			return err
+		}
+		value, err := decodeJSON(buf)
+		if err != nil {
+			return err
+		}
+		rows[i] = make(map[string]string)
+		if fixed {
+			for _, column := range header {
+				if rows[i][column], err = csvValue(value, column); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		var keys []string
+		if err := flatten("", value, rows[i], &keys); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				header = append(header, k)
+			}
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(header))
+		for i, k := range header {
+			record[i] = row[k]
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,211 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package printer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPathSegment is a step in a JSONPath expression: a field name, an array index or a wildcard.
+type jsonPathSegment struct {
+	field    string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// jsonPathNode is literal text or a JSONPath expression.
+type jsonPathNode struct {
+	text string
+	path []jsonPathSegment
+	expr bool
+}
+
+// jsonPath is a JSONPath template, like kubectl's: literal text with expressions in braces.
+// Expressions support fields (.name), array indices ([0], [-1]), wildcards ([*], .*) and quoted strings ({"\n"}).
+// Missing fields and indices out of range evaluate to no values.
+type jsonPath []jsonPathNode
+
+var errUnterminatedExpression = errors.New("printer: unterminated JSONPath expression")
+
+func parseJSONPath(text string) (jsonPath, error) {
+	var res jsonPath
+	for text != "" {
+		start := strings.IndexByte(text, '{')
+		if start == -1 {
+			res = append(res, jsonPathNode{text: text})
+			break
+		}
+		if start > 0 {
+			res = append(res, jsonPathNode{text: text[:start]})
+		}
+		text = text[start+1:]
+		expr := strings.TrimSpace(text)
+		if strings.HasPrefix(expr, `"`) {
+			quoted, err := strconv.QuotedPrefix(expr)
+			if err != nil {
+				return nil, fmt.Errorf("printer: invalid JSONPath string: %w", err)
+			}
+			s, _ := strconv.Unquote(quoted)
+			rest := strings.TrimSpace(expr[len(quoted):])
+			if !strings.HasPrefix(rest, "}") {
+				return nil, errUnterminatedExpression
+			}
+			res = append(res, jsonPathNode{text: s})
+			text = rest[1:]
+			continue
+		}
+		end := strings.IndexByte(text, '}')
+		if end == -1 {
+			return nil, errUnterminatedExpression
+		}
+		path, err := parseJSONPathExpression(strings.TrimSpace(text[:end]))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, jsonPathNode{path: path, expr: true})
+		text = text[end+1:]
+	}
+	return res, nil
+}
+
+func parseJSONPathExpression(expr string) ([]jsonPathSegment, error) {
+	invalid := func() error {
+		return fmt.Errorf("printer: invalid JSONPath expression %q", expr)
+	}
+	s := strings.TrimPrefix(expr, "$")
+	if s == "" || s == "." {
+		return nil, nil
+	}
+	var res []jsonPathSegment
+	for s != "" {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[")
+			if end == -1 {
+				end = len(s)
+			}
+			name := s[:end]
+			switch name {
+			case "":
+				return nil, invalid()
+			case "*":
+				res = append(res, jsonPathSegment{wildcard: true})
+			default:
+				res = append(res, jsonPathSegment{field: name})
+			}
+			s = s[end:]
+		case '[':
+			end := strings.IndexByte(s, ']')
+			if end == -1 {
+				return nil, invalid()
+			}
+			if index := s[1:end]; index == "*" {
+				res = append(res, jsonPathSegment{wildcard: true})
+			} else {
+				i, err := strconv.Atoi(index)
+				if err != nil {
+					return nil, invalid()
+				}
+				res = append(res, jsonPathSegment{index: i, isIndex: true})
+			}
+			s = s[end+1:]
+		default:
+			return nil, invalid()
+		}
+	}
+	return res, nil
+}
+
+func (s jsonPathSegment) evaluate(values []interface{}) []interface{} {
+	var res []interface{}
+	for _, value := range values {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if s.wildcard {
+				keys := make([]string, 0, len(v))
+				for k := range v {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					res = append(res, v[k])
+				}
+			} else if !s.isIndex {
+				if fv, ok := v[s.field]; ok {
+					res = append(res, fv)
+				}
+			}
+		case []interface{}:
+			if s.wildcard {
+				res = append(res, v...)
+			} else if s.isIndex {
+				i := s.index
+				if i < 0 {
+					i += len(v)
+				}
+				if i >= 0 && i < len(v) {
+					res = append(res, v[i])
+				}
+			}
+		}
+	}
+	return res
+}
+
+func formatJSONPathValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+}
+
+// execute evaluates the template on the value and writes the result, followed by a newline if there is none.
+// Multiple values of an expression are separated by spaces.
+func (p jsonPath) execute(w io.Writer, value interface{}) error {
+	var b strings.Builder
+	for _, n := range p {
+		if !n.expr {
+			b.WriteString(n.text)
+			continue
+		}
+		values := []interface{}{value}
+		for _, s := range n.path {
+			values = s.evaluate(values)
+		}
+		for i, v := range values {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			s, err := formatJSONPathValue(v)
+			if err != nil {
+				return err
+			}
+			b.WriteString(s)
+		}
+	}
+	if !strings.HasSuffix(b.String(), "\n") {
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
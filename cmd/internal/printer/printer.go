@@ -0,0 +1,326 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package printer writes Packet Broker messages in the output format selected on the command-line.
+package printer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	flag "github.com/spf13/pflag"
+	"go.packetbroker.org/pb/cmd/internal/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format.
+type Format string
+
+// Output formats.
+const (
+	Table Format = "table"
+	JSON  Format = "json"
+	YAML  Format = "yaml"
+	CSV   Format = "csv"
+
+	// Template executes a Go text/template for each message, i.e. template={{.netId}} {{.name}}.
+	Template Format = "template"
+	// JSONPath evaluates a JSONPath template on the document, i.e. jsonpath={.networks[*].name}.
+	JSONPath Format = "jsonpath"
+)
+
+var (
+	formats    = [...]Format{Table, JSON, YAML, CSV}
+	argFormats = [...]Format{Template, JSONPath}
+)
+
+func (f Format) String() string {
+	return string(f)
+}
+
+// Output is an output format with its argument.
+type Output struct {
+	Format Format
+	Arg    string
+}
+
+func (o Output) String() string {
+	if o.Arg == "" {
+		return string(o.Format)
+	}
+	return string(o.Format) + "=" + o.Arg
+}
+
+// Set implements flag.Value.
+func (o *Output) Set(s string) error {
+	name, arg, hasArg := strings.Cut(s, "=")
+	if !hasArg {
+		for _, f := range formats {
+			if string(f) == name {
+				*o = Output{Format: f}
+				return nil
+			}
+		}
+	} else {
+		for _, f := range argFormats {
+			if string(f) == name {
+				res := Output{Format: f, Arg: arg}
+				if err := res.validate(); err != nil {
+					return err
+				}
+				*o = res
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("printer: invalid output format %q", s)
+}
+
+// Type implements flag.Value.
+func (o *Output) Type() string {
+	return "output"
+}
+
+func (o Output) validate() error {
+	switch o.Format {
+	case Template:
+		_, err := parseTemplate(o.Arg)
+		return err
+	case JSONPath:
+		_, err := parseJSONPath(o.Arg)
+		return err
+	default:
+		return nil
+	}
+}
+
+// Flags returns flags for the output format.
+func Flags() *flag.FlagSet {
+	names := make([]string, 0, len(formats)+len(argFormats))
+	for _, f := range formats {
+		names = append(names, string(f))
+	}
+	for _, f := range argFormats {
+		names = append(names, string(f)+"=...")
+	}
+	flags := new(flag.FlagSet)
+	output := Output{Format: Table}
+	flags.VarP(&output, "output", "o", fmt.Sprintf("output format (%s)", strings.Join(names, ", ")))
+	return flags
+}
+
+// GetOutput returns the output format from the flags.
+func GetOutput(flags *flag.FlagSet) Output {
+	if f := flags.Lookup("output"); f != nil {
+		return *f.Value.(*Output)
+	}
+	return Output{Format: Table}
+}
+
+// Printer writes messages in an output format.
+type Printer struct {
+	output Output
+	table  io.Writer
+	out    io.Writer
+}
+
+// New returns a printer for the output format from the flags.
+// Tables are written to table, which is typically a tabwriter. Other formats are written to out.
+func New(flags *flag.FlagSet, table, out io.Writer) *Printer {
+	return &Printer{
+		output: GetOutput(flags),
+		table:  table,
+		out:    out,
+	}
+}
+
+// List is a named list of messages.
+type List struct {
+	Kind  string
+	Items []proto.Message
+	// Type is the message type of the items. In CSV, the type determines the columns, also if there are no items.
+	Type protoreflect.MessageDescriptor
+	// Columns are the CSV columns of structs, as structs have no fixed fields.
+	Columns []string
+}
+
+// NewList returns a named list of the messages with their message type.
+func NewList[T proto.Message](kind string, items []T) List {
+	var zero T
+	return List{
+		Kind:  kind,
+		Items: Messages(items),
+		Type:  zero.ProtoReflect().Descriptor(),
+	}
+}
+
+// Messages returns the items as a slice of messages.
+func Messages[T proto.Message](items []T) []proto.Message {
+	res := make([]proto.Message, len(items))
+	for i, item := range items {
+		res[i] = item
+	}
+	return res
+}
+
+// Write writes the message. If the output format is table, writeTable is called.
+func (p *Printer) Write(msg proto.Message, writeTable func(w io.Writer) error) error {
+	switch p.output.Format {
+	case Table:
+		return writeTable(p.table)
+	case CSV:
+		return writeCSV(p.out, List{Items: []proto.Message{msg}})
+	case Template:
+		return p.writeTemplate([]proto.Message{msg})
+	default:
+		buf, err := protojson.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return p.writeDocument(buf)
+	}
+}
+
+// WriteList writes the list of messages. If the output format is table, writeTable is called.
+func (p *Printer) WriteList(list List, writeTable func(w io.Writer) error) error {
+	return p.WriteLists(writeTable, list)
+}
+
+// WriteLists writes the lists of messages. If the output format is table, writeTable is called.
+// In JSON, YAML and JSONPath, the lists are written as one object with the kinds as keys.
+// In CSV, the lists are written as separate tables, separated by an empty line.
+// With a template, the template is executed for each message of all lists.
+func (p *Printer) WriteLists(writeTable func(w io.Writer) error, lists ...List) error {
+	switch p.output.Format {
+	case Table:
+		return writeTable(p.table)
+	case Template:
+		var items []proto.Message
+		for _, l := range lists {
+			items = append(items, l.Items...)
+		}
+		return p.writeTemplate(items)
+	case CSV:
+		for i, l := range lists {
+			if i > 0 {
+				if _, err := fmt.Fprintln(p.out); err != nil {
+					return err
+				}
+			}
+			if err := writeCSV(p.out, l); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		doc := make(map[string][]json.RawMessage, len(lists))
+		for _, l := range lists {
+			items := make([]json.RawMessage, len(l.Items))
+			for i, item := range l.Items {
+				buf, err := protojson.Marshal(item)
+				if err != nil {
+					return err
+				}
+				items[i] = buf
+			}
+			doc[l.Kind] = items
+		}
+		buf, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		return p.writeDocument(buf)
+	}
+}
+
+// writeDocument writes the JSON document in the output format.
+func (p *Printer) writeDocument(doc []byte) error {
+	switch p.output.Format {
+	case JSON:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, doc, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err := buf.WriteTo(p.out)
+		return err
+	case YAML:
+		// JSON is valid YAML. Decoding into a node preserves the order of the fields.
+		var node yaml.Node
+		if err := yaml.Unmarshal(doc, &node); err != nil {
+			return err
+		}
+		blockStyle(&node)
+		enc := yaml.NewEncoder(p.out)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return err
+		}
+		return enc.Close()
+	case JSONPath:
+		path, err := parseJSONPath(p.output.Arg)
+		if err != nil {
+			return err
+		}
+		value, err := decodeJSON(doc)
+		if err != nil {
+			return err
+		}
+		return path.execute(p.out, value)
+	default:
+		return fmt.Errorf("printer: unsupported output format %q", p.output.Format)
+	}
+}
+
+// writeTemplate executes the template for each message, followed by a newline.
+func (p *Printer) writeTemplate(items []proto.Message) error {
+	tmpl, err := parseTemplate(p.output.Arg)
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		buf, err := protojson.Marshal(item)
+		if err != nil {
+			return err
+		}
+		value, err := decodeJSON(buf)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(p.out, value); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(p.out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	return template.New("output").Option("missingkey=zero").Parse(text)
+}
+
+// decodeJSON decodes the JSON document. Numbers are decoded as json.Number to preserve their formatting.
+func decodeJSON(doc []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// blockStyle clears the JSON flow and quoting styles, so that the encoder uses block style and quotes only when needed.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, n := range node.Content {
+		blockStyle(n)
+	}
+}
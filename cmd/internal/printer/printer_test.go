@@ -0,0 +1,155 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package printer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestJSONPath(t *testing.T) {
+	doc := []byte(`{
+		"networks": [
+			{"netId": 19, "name": "The Things Network", "listed": true},
+			{"netId": 9, "name": "Senet", "listed": false, "contact": null}
+		]
+	}`)
+	value, err := decodeJSON(doc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, tc := range []struct {
+		path     string
+		expected string
+	}{
+		{path: "{.networks[*].name}", expected: "The Things Network Senet\n"},
+		{path: "{.networks[0].netId}", expected: "19\n"},
+		{path: "{$.networks[-1].listed}", expected: "false\n"},
+		{path: "{.networks[1].contact}", expected: "\n"},
+		{path: "{.networks[2].name}", expected: "\n"},
+		{path: "{.networks[0].unknown}", expected: "\n"},
+		{path: "{.networks[1].*}", expected: " false Senet 9\n"},
+		{path: `NetID {.networks[0].netId}{"\n"}`, expected: "NetID 19\n"},
+		{path: `{.networks[0]}`, expected: `{"listed":true,"name":"The Things Network","netId":19}` + "\n"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			p, err := parseJSONPath(tc.path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var buf bytes.Buffer
+			if err := p.execute(&buf, value); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if buf.String() != tc.expected {
+				t.Fatalf("expected %q, got %q", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestOutput(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		expected Output
+		ok       bool
+	}{
+		{value: "json", expected: Output{Format: JSON}, ok: true},
+		{value: "template={{.name}}", expected: Output{Format: Template, Arg: "{{.name}}"}, ok: true},
+		{value: "jsonpath={.networks[*].name}", expected: Output{Format: JSONPath, Arg: "{.networks[*].name}"}, ok: true},
+		{value: "xml"},
+		{value: "json=x"},
+		{value: "template"},
+		{value: "template={{.name}"},
+		{value: "jsonpath={.networks["},
+		{value: "jsonpath={networks}"},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			var o Output
+			err := o.Set(tc.value)
+			if (err == nil) != tc.ok {
+				t.Fatalf("expected ok %v, got error %v", tc.ok, err)
+			}
+			if o != tc.expected {
+				t.Fatalf("expected %v, got %v", tc.expected, o)
+			}
+		})
+	}
+}
+
+func TestWriteListTemplate(t *testing.T) {
+	flags := Flags()
+	if err := flags.Parse([]string{"-o", "template={{.netId}} {{.name}}"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var items []*structpb.Struct
+	for _, m := range []map[string]interface{}{
+		{"netId": 19, "name": "The Things Network"},
+		{"netId": 9, "name": "Senet"},
+	} {
+		s, err := structpb.NewStruct(m)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		items = append(items, s)
+	}
+	var buf bytes.Buffer
+	p := New(flags, io.Discard, &buf)
+	if err := p.WriteList(NewList("networks", items), func(io.Writer) error {
+		t.Fatal("unexpected table")
+		return nil
+	}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := "19 The Things Network\n9 Senet\n"; buf.String() != expected {
+		t.Fatalf("expected %q, got %q", expected, buf.String())
+	}
+}
+
+func TestWriteListCSV(t *testing.T) {
+	flags := Flags()
+	if err := flags.Parse([]string{"-o", "csv"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	write := func(items []*packetbroker.RoutingPolicy) string {
+		var buf bytes.Buffer
+		p := New(flags, io.Discard, &buf)
+		if err := p.WriteList(NewList("policies", items), func(io.Writer) error {
+			t.Fatal("unexpected table")
+			return nil
+		}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return buf.String()
+	}
+
+	// The columns are the fields of the message type, so that they do not depend on the messages.
+	header := write(nil)
+	if !strings.HasPrefix(header, "forwarderNetId,forwarderTenantId,") || !strings.Contains(header, ",uplink.joinRequest,") {
+		t.Fatalf("unexpected header %q", header)
+	}
+	lines := strings.Split(write([]*packetbroker.RoutingPolicy{
+		{ForwarderNetId: 0x13, ForwarderTenantId: "tti", Uplink: &packetbroker.RoutingPolicy_Uplink{JoinRequest: true}},
+		{ForwarderNetId: 0x9},
+	}), "\n")
+	if len(lines) != 4 || lines[0]+"\n" != header {
+		t.Fatalf("expected header %q and two rows, got %q", header, lines)
+	}
+	columns := strings.Split(lines[0], ",")
+	for i, tc := range []map[string]string{
+		{"forwarderNetId": "19", "forwarderTenantId": "tti", "uplink.joinRequest": "true", "uplink.macData": "false"},
+		{"forwarderNetId": "9", "forwarderTenantId": "", "uplink.joinRequest": "", "uplink.macData": ""},
+	} {
+		values := strings.Split(lines[i+1], ",")
+		for j, column := range columns {
+			if expected, ok := tc[column]; ok && values[j] != expected {
+				t.Fatalf("expected %s of row %d to be %q, got %q", column, i, expected, values[j])
+			}
+		}
+	}
+}
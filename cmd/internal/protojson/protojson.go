@@ -24,6 +24,18 @@ func Marshal(m proto.Message) ([]byte, error) {
 	return marshalOptions.Marshal(m)
 }
 
+var compactMarshalOptions = protojson.MarshalOptions{
+	AllowPartial:    true,
+	UseProtoNames:   false,
+	UseEnumNumbers:  false,
+	EmitUnpopulated: true,
+}
+
+// MarshalCompact marshals the proto message on a single line. The options are the same as Marshal otherwise.
+func MarshalCompact(m proto.Message) ([]byte, error) {
+	return compactMarshalOptions.Marshal(m)
+}
+
 // Write marshals the proto message (see Marshal) and writes it to the given writer.
 func Write(w io.Writer, m proto.Message) error {
 	rawMsg, err := Marshal(m)
@@ -0,0 +1,240 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package query
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"go.packetbroker.org/pb/pkg/lorawan"
+)
+
+// Expr is an expression that evaluates to true or false. Values that are missing or cannot be compared evaluate
+// comparisons to false.
+type Expr interface {
+	// Eval evaluates the expression on the value, as decoded by Decode.
+	Eval(v any) bool
+}
+
+type operand interface {
+	value(v any) (any, bool)
+}
+
+func (p Path) value(v any) (any, bool) {
+	_, res, ok := p.Resolve(v)
+	return res, ok
+}
+
+type literal struct {
+	v any
+}
+
+func (l literal) value(any) (any, bool) {
+	return l.v, true
+}
+
+type devAddrPrefix lorawan.DevAddrPrefix
+
+func (p devAddrPrefix) value(any) (any, bool) {
+	return p, true
+}
+
+func (p devAddrPrefix) match(v any) bool {
+	n, ok := unsigned(v)
+	return ok && n <= 0xffffffff && lorawan.DevAddrPrefix(p).Match(lorawan.DevAddr(n))
+}
+
+type eui64Prefix lorawan.EUI64Prefix
+
+func (p eui64Prefix) value(any) (any, bool) {
+	return p, true
+}
+
+func (p eui64Prefix) match(v any) bool {
+	n, ok := unsigned(v)
+	return ok && lorawan.EUI64Prefix(p).Match(lorawan.EUI64(n))
+}
+
+type orExpr struct {
+	left, right Expr
+}
+
+func (e orExpr) Eval(v any) bool {
+	return e.left.Eval(v) || e.right.Eval(v)
+}
+
+type andExpr struct {
+	left, right Expr
+}
+
+func (e andExpr) Eval(v any) bool {
+	return e.left.Eval(v) && e.right.Eval(v)
+}
+
+type notExpr struct {
+	x Expr
+}
+
+func (e notExpr) Eval(v any) bool {
+	return !e.x.Eval(v)
+}
+
+type truthyExpr struct {
+	x operand
+}
+
+func (e truthyExpr) Eval(v any) bool {
+	x, ok := e.x.value(v)
+	if !ok {
+		return false
+	}
+	switch x := x.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case []any:
+		return len(x) > 0
+	}
+	if n, ok := number(x); ok {
+		return n.Sign() != 0
+	}
+	return true
+}
+
+type compareExpr struct {
+	op          string
+	left, right operand
+}
+
+func (e compareExpr) Eval(v any) bool {
+	x, xok := e.left.value(v)
+	y, yok := e.right.value(v)
+	if !xok || !yok {
+		return e.op == "!="
+	}
+	switch e.op {
+	case "==":
+		return equal(x, y)
+	case "!=":
+		return !equal(x, y)
+	}
+	c, ok := compare(x, y)
+	if !ok {
+		return false
+	}
+	switch e.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+type inExpr struct {
+	x   operand
+	set []operand
+}
+
+func (e inExpr) Eval(v any) bool {
+	x, ok := e.x.value(v)
+	if !ok {
+		return false
+	}
+	for _, o := range e.set {
+		y, ok := o.value(v)
+		if !ok {
+			continue
+		}
+		if m, ok := y.(interface{ match(any) bool }); ok {
+			if m.match(x) {
+				return true
+			}
+		} else if equal(x, y) {
+			return true
+		}
+	}
+	return false
+}
+
+// number returns the value as number. Strings are not numbers; 64-bit integers are decoded as json.Number by Decode.
+func number(v any) (*big.Float, bool) {
+	var s string
+	switch v := v.(type) {
+	case *big.Float:
+		return v, true
+	case json.Number:
+		s = string(v)
+	default:
+		return nil, false
+	}
+	f, _, err := big.ParseFloat(s, 0, 64, big.ToNearestEven)
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// unsigned returns the value as unsigned integer.
+func unsigned(v any) (uint64, bool) {
+	f, ok := number(v)
+	if !ok || !f.IsInt() {
+		return 0, false
+	}
+	n, acc := f.Uint64()
+	return n, acc == big.Exact
+}
+
+func isNumber(v any) bool {
+	switch v.(type) {
+	case *big.Float, json.Number:
+		return true
+	}
+	return false
+}
+
+func equal(x, y any) bool {
+	if isNumber(x) || isNumber(y) {
+		c, ok := compare(x, y)
+		return ok && c == 0
+	}
+	switch x := x.(type) {
+	case string:
+		y, ok := y.(string)
+		return ok && x == y
+	case bool:
+		y, ok := y.(bool)
+		return ok && x == y
+	}
+	return false
+}
+
+func compare(x, y any) (int, bool) {
+	if isNumber(x) || isNumber(y) {
+		a, aok := number(x)
+		b, bok := number(y)
+		if !aok || !bok {
+			return 0, false
+		}
+		return a.Cmp(b), true
+	}
+	if x, ok := x.(string); ok {
+		if y, ok := y.(string); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	return 0, false
+}
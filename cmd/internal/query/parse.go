@@ -0,0 +1,272 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package query
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"go.packetbroker.org/pb/pkg/lorawan"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q at position %d", t.text, t.pos+1)
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '/'
+}
+
+var symbols = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ","}
+
+func tokenize(s string) ([]token, error) {
+	var res []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("query: unterminated string at position %d", i+1)
+			}
+			res = append(res, token{kind: tokenString, text: s[i+1 : i+1+end], pos: i})
+			i += end + 2
+		case isWordChar(c) || c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			start := i
+			for i++; i < len(s) && isWordChar(s[i]); i++ {
+			}
+			res = append(res, token{kind: tokenWord, text: s[start:i], pos: start})
+		default:
+			found := false
+			for _, sym := range symbols {
+				if strings.HasPrefix(s[i:], sym) {
+					res = append(res, token{kind: tokenSymbol, text: sym, pos: i})
+					i += len(sym)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("query: unexpected %q at position %d", c, i+1)
+			}
+		}
+	}
+	return append(res, token{kind: tokenEOF, pos: len(s)}), nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(kind tokenKind, text string) bool {
+	if t := p.peek(); t.kind == kind && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(tokenSymbol, text) {
+		return fmt.Errorf("query: expected %q, got %s", text, p.peek())
+	}
+	return nil
+}
+
+// Parse parses the expression.
+func Parse(s string) (Expr, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{tokens: tokens}
+	res, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, fmt.Errorf("query: unexpected %s", t)
+	}
+	return res, nil
+}
+
+func (p *parser) parseOr() (Expr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenSymbol, "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept(tokenSymbol, "&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andExpr{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.accept(tokenSymbol, "!") {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{x}, nil
+	}
+	if p.accept(tokenSymbol, "(") {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return x, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (Expr, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept(tokenWord, "in") {
+		var set []operand
+		if p.accept(tokenSymbol, "[") {
+			for !p.accept(tokenSymbol, "]") {
+				if len(set) > 0 {
+					if err := p.expect(","); err != nil {
+						return nil, err
+					}
+				}
+				x, err := p.parseOperand()
+				if err != nil {
+					return nil, err
+				}
+				set = append(set, x)
+			}
+		} else {
+			x, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			set = append(set, x)
+		}
+		return inExpr{left, set}, nil
+	}
+	if t := p.peek(); t.kind == tokenSymbol {
+		switch t.text {
+		case "==", "!=", "<", "<=", ">", ">=":
+			p.next()
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return compareExpr{t.text, left, right}, nil
+		}
+	}
+	return truthyExpr{left}, nil
+}
+
+func (p *parser) parseOperand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return literal{t.text}, nil
+	case tokenWord:
+		switch {
+		case t.text == "true":
+			return literal{true}, nil
+		case t.text == "false":
+			return literal{false}, nil
+		case t.text == "in":
+			return nil, fmt.Errorf("query: unexpected %s", t)
+		case strings.Contains(t.text, "/"):
+			return parsePrefix(t)
+		case t.text[0] == '-' || t.text[0] >= '0' && t.text[0] <= '9':
+			f, _, err := big.ParseFloat(t.text, 0, 64, big.ToNearestEven)
+			if err != nil {
+				return nil, fmt.Errorf("query: invalid number %s: %w", t, err)
+			}
+			return literal{f}, nil
+		default:
+			return ParsePath(t.text), nil
+		}
+	}
+	return nil, fmt.Errorf("query: unexpected %s", t)
+}
+
+// parsePrefix parses a DevAddr prefix if the value has up to 8 hexadecimal digits, or an EUI-64 prefix otherwise.
+func parsePrefix(t token) (operand, error) {
+	if strings.Index(t.text, "/") <= 8 {
+		var prefix lorawan.DevAddrPrefix
+		if err := prefix.UnmarshalText([]byte(t.text)); err != nil {
+			return nil, fmt.Errorf("query: invalid DevAddr prefix %s: %w", t, err)
+		}
+		return devAddrPrefix(prefix), nil
+	}
+	var prefix lorawan.EUI64Prefix
+	if err := prefix.UnmarshalText([]byte(t.text)); err != nil {
+		return nil, fmt.Errorf("query: invalid EUI-64 prefix %s: %w", t, err)
+	}
+	return eui64Prefix(prefix), nil
+}
+
+// Quote returns the string as a string literal. Strings are not escaped, so the string cannot contain both single and
+// double quotes.
+func Quote(s string) string {
+	if strings.Contains(s, `"`) {
+		return "'" + s + "'"
+	}
+	return `"` + s + `"`
+}
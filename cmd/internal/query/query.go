@@ -0,0 +1,162 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package query implements a small expression language to filter and project routed messages in their JSON
+// representation.
+//
+// Expressions compare paths with literals, for example:
+//
+//	devAddr in 26AB0000/16 && fPort == 5
+//
+// Paths are dot-separated field names. Paths that are not found in the message are resolved in the scopes of the
+// message, so that devAddr is short for message.phyPayload.teaser.mac.devAddr. The operators are ==, !=, <, <=, >,
+// >=, in, !, && and ||, in decreasing order of precedence from comparison to logical or. Literals are numbers,
+// quoted strings, true, false, DevAddr and EUI-64 prefixes (26AB0000/16) and lists ([1, 2, 3]). A path on its own
+// is true if it is set and not zero, empty or false.
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// scopes are the paths in which paths are resolved, in order.
+var scopes = []Path{
+	nil,
+	{"message"},
+	{"message", "phyPayload", "teaser"},
+	{"message", "phyPayload", "teaser", "mac"},
+	{"message", "phyPayload", "teaser", "joinRequest"},
+}
+
+// Path is a path to a field.
+type Path []string
+
+// ParsePath parses a dot-separated path.
+func ParsePath(s string) Path {
+	return Path(strings.Split(s, "."))
+}
+
+// String implements fmt.Stringer.
+func (p Path) String() string {
+	return strings.Join(p, ".")
+}
+
+func (p Path) lookup(v any) (any, bool) {
+	for _, name := range p {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[name]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// Resolve returns the full path and the value of the path in the scopes of the value.
+func (p Path) Resolve(v any) (Path, any, bool) {
+	for _, scope := range scopes {
+		full := append(append(Path(nil), scope...), p...)
+		if res, ok := full.lookup(v); ok {
+			return full, res, true
+		}
+	}
+	return nil, nil, false
+}
+
+// Decode decodes JSON to a value that can be evaluated and projected. Numbers are decoded as json.Number.
+//
+// If md is not nil, the JSON is the protojson encoding of a message of the type. As protojson encodes 64-bit integers
+// as strings, these are decoded as json.Number too. Other strings are not numbers, even if they look like one.
+func Decode(data []byte, md protoreflect.MessageDescriptor) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	if md != nil {
+		decodeMessage(v, md)
+	}
+	return v, nil
+}
+
+// decodeMessage converts the 64-bit integers in the JSON object of the message type to json.Number.
+func decodeMessage(v any, md protoreflect.MessageDescriptor) {
+	m, ok := v.(map[string]any)
+	if !ok || md.FullName().Parent() == "google.protobuf" {
+		return
+	}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		x, ok := m[fd.JSONName()]
+		if !ok {
+			continue
+		}
+		switch {
+		case fd.IsMap():
+			if xm, ok := x.(map[string]any); ok {
+				for k, e := range xm {
+					xm[k] = decodeField(e, fd.MapValue())
+				}
+			}
+		case fd.IsList():
+			if xl, ok := x.([]any); ok {
+				for j, e := range xl {
+					xl[j] = decodeField(e, fd)
+				}
+			}
+		default:
+			m[fd.JSONName()] = decodeField(x, fd)
+		}
+	}
+}
+
+// decodeField returns the JSON value of the field, with 64-bit integers as json.Number.
+func decodeField(v any, fd protoreflect.FieldDescriptor) any {
+	switch fd.Kind() {
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if s, ok := v.(string); ok {
+			return json.Number(s)
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		switch fd.Message().FullName() {
+		case "google.protobuf.Int64Value", "google.protobuf.UInt64Value":
+			if s, ok := v.(string); ok {
+				return json.Number(s)
+			}
+		default:
+			decodeMessage(v, fd.Message())
+		}
+	}
+	return v
+}
+
+// Project returns the value with only the fields of the paths. Paths are resolved (see Path.Resolve) and the fields
+// are set by their full path. Paths that are not found are ignored.
+func Project(v any, paths []Path) map[string]any {
+	res := make(map[string]any)
+	for _, p := range paths {
+		full, val, ok := p.Resolve(v)
+		if !ok {
+			continue
+		}
+		m := res
+		for _, name := range full[:len(full)-1] {
+			next, ok := m[name].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				m[name] = next
+			}
+			m = next
+		}
+		m[full[len(full)-1]] = val
+	}
+	return res
+}
@@ -0,0 +1,187 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package query
+
+import (
+	"encoding/json"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const uplink = `{
+  "id": "01H",
+  "forwarderNetId": 19,
+  "forwarderTenantId": "tti",
+  "message": {
+    "gatewayId": {"eui": "1234605616436508552", "plain": "my-gateway"},
+    "gatewayRegion": "EU_863_870",
+    "frequency": "868100000",
+    "phyPayload": {
+      "teaser": {
+        "mac": {"confirmed": false, "devAddr": 648740864, "fPort": 5, "fCnt": 35449}
+      }
+    },
+    "gatewayMetadata": {"snr": -7.5}
+  }
+}`
+
+// uplinkDescriptor returns the descriptor of the uplink message type, with the 64-bit integer and string fields of
+// uplink.
+func uplinkDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	t.Helper()
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	fd, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+		Name:       proto.String("query_test.proto"),
+		Package:    proto.String("query.test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/wrappers.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name: proto.String("Uplink"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("message", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".query.test.Message"),
+				},
+			},
+			{
+				Name: proto.String("Message"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("gatewayId", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".query.test.GatewayId"),
+					field("frequency", 2, descriptorpb.FieldDescriptorProto_TYPE_UINT64, ""),
+				},
+			},
+			{
+				Name: proto.String("GatewayId"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("eui", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.UInt64Value"),
+					field("plain", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				},
+			},
+		},
+	}, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fd.Messages().ByName("Uplink")
+}
+
+func TestEval(t *testing.T) {
+	v, err := Decode([]byte(uplink), uplinkDescriptor(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		expr     string
+		expected bool
+	}{
+		{`devAddr in 26AB0000/16`, true},
+		{`devAddr in 26AC0000/16`, false},
+		{`devAddr in 26AB0000/16 && fPort == 5`, true},
+		{`devAddr in 26AB0000/16 && fPort == 6`, false},
+		{`fPort in [1, 2, 5]`, true},
+		{`fPort in [1, 2]`, false},
+		{`fPort != 5 || forwarderTenantId == "tti"`, true},
+		{`!(fPort == 5)`, false},
+		{`fCnt > 35000 && fCnt <= 35449 && fCnt >= 35449 && fCnt < 35450`, true},
+		{`forwarderNetId == 0x13`, true},
+		{`frequency == 868100000`, true},
+		{`gatewayId.eui in 1122334455667788/64`, true},
+		{`gatewayId.eui == 0x1122334455667789`, false},
+		{`gatewayId.plain == 'my-gateway'`, true},
+		{`gatewayRegion == "EU_863_870"`, true},
+		{`gatewayMetadata.snr < -5`, true},
+		{`message.phyPayload.teaser.mac.fPort == 5`, true},
+		{`mac && !joinRequest`, true},
+		{`confirmed`, false},
+		{`confirmed == false`, true},
+		{`unknown == 5`, false},
+		{`unknown != 5`, true},
+		{`forwarderTenantId > 5`, false},
+	} {
+		expr, err := Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("Parse %q: %v", tc.expr, err)
+		}
+		if res := expr.Eval(v); res != tc.expected {
+			t.Fatalf("Expected %q to be %v, got %v", tc.expr, tc.expected, res)
+		}
+	}
+}
+
+func TestDecodeStrings(t *testing.T) {
+	md := uplinkDescriptor(t)
+	for _, tc := range []struct {
+		json, expr string
+		expected   bool
+	}{
+		{`{"message": {"gatewayId": {"plain": "0x13"}}}`, `gatewayId.plain == 19`, false},
+		{`{"message": {"gatewayId": {"plain": "0x13"}}}`, `gatewayId.plain == "0x13"`, true},
+		{`{"message": {"gatewayId": {"plain": "19"}}}`, `gatewayId.plain > 5`, false},
+		{`{"message": {"gatewayId": {"eui": "19"}}}`, `gatewayId.eui == 0x13`, true},
+		{`{"message": {"frequency": "868100000"}}`, `frequency > 868000000`, true},
+	} {
+		v, err := Decode([]byte(tc.json), md)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expr, err := Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("Parse %q: %v", tc.expr, err)
+		}
+		if res := expr.Eval(v); res != tc.expected {
+			t.Fatalf("Expected %q to be %v on %s, got %v", tc.expr, tc.expected, tc.json, res)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`fPort ==`,
+		`(fPort == 5`,
+		`fPort == 5)`,
+		`fPort = 5`,
+		`fPort in [1 2]`,
+		`devAddr in 26AB0000/33`,
+		`name == "unterminated`,
+		`fPort == 5 fCnt == 1`,
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Fatalf("Expected error parsing %q", expr)
+		}
+	}
+}
+
+func TestProject(t *testing.T) {
+	v, err := Decode([]byte(uplink), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := Project(v, []Path{ParsePath("id"), ParsePath("devAddr"), ParsePath("fPort"), ParsePath("unknown")})
+	buf, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"id":"01H","message":{"phyPayload":{"teaser":{"mac":{"devAddr":648740864,"fPort":5}}}}}`
+	if string(buf) != expected {
+		t.Fatalf("Expected %s, got %s", expected, buf)
+	}
+}
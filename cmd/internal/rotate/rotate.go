@@ -0,0 +1,134 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package rotate provides a writer that writes to files in a directory and rotates the files by size and age.
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Config configures a Writer.
+type Config struct {
+	// Dir is the directory of the files. The directory is created if it does not exist.
+	Dir string
+	// Prefix is the prefix of the file names. The file names are the prefix, the time when the file is created and the
+	// extension.
+	Prefix string
+	// Ext is the extension of the file names, including the dot. With Gzip, .gz is appended.
+	Ext string
+	// MaxSize is the maximum size of a file in bytes before the file is rotated. If 0, files are not rotated by size.
+	// The size is the number of bytes written, before compression.
+	MaxSize int64
+	// MaxAge is the maximum age of a file before the file is rotated. If 0, files are not rotated by age.
+	MaxAge time.Duration
+	// Gzip compresses the files with gzip.
+	Gzip bool
+}
+
+// Writer writes to files in a directory. Files are rotated on write, so every call to Write is written to a single
+// file. Write complete records, such as messages, per call.
+type Writer struct {
+	Config
+	now func() time.Time
+
+	mu        sync.Mutex
+	f         *os.File
+	gz        *gzip.Writer
+	size      int64
+	createdAt time.Time
+}
+
+// New returns a new Writer. The first file is created on the first write.
+func New(conf Config) (*Writer, error) {
+	if err := os.MkdirAll(conf.Dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		Config: conf,
+		now:    time.Now,
+	}, nil
+}
+
+// Write writes p to the current file. If the current file exceeds the maximum size with p, or if the current file
+// exceeds the maximum age, the current file is closed and p is written to a new file.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	now := w.now()
+	if w.f != nil && (w.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.MaxSize ||
+		w.MaxAge > 0 && now.Sub(w.createdAt) >= w.MaxAge) {
+		if err := w.close(); err != nil {
+			return 0, err
+		}
+	}
+	if w.f == nil {
+		if err := w.create(now); err != nil {
+			return 0, err
+		}
+	}
+	var out io.Writer = w.f
+	if w.gz != nil {
+		out = w.gz
+	}
+	n, err := out.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// create creates a new file. The caller must hold the lock.
+func (w *Writer) create(now time.Time) error {
+	ext := w.Ext
+	if w.Gzip {
+		ext += ".gz"
+	}
+	base := w.Prefix + now.UTC().Format("20060102T150405.000Z")
+	for i := 0; ; i++ {
+		name := base + ext
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d%s", base, i, ext)
+		}
+		f, err := os.OpenFile(filepath.Join(w.Dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		w.f, w.size, w.createdAt = f, 0, now
+		if w.Gzip {
+			w.gz = gzip.NewWriter(f)
+		}
+		return nil
+	}
+}
+
+// close closes the current file. The caller must hold the lock.
+func (w *Writer) close() error {
+	if w.f == nil {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		err = w.gz.Close()
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	w.f, w.gz = nil, nil
+	return err
+}
+
+// Close closes the current file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.close()
+}
@@ -0,0 +1,111 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package rotate
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readFiles returns the contents of the files in the directory, sorted by name.
+func readFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	sort.Strings(names)
+	res := make([]string, len(names))
+	for i, name := range names {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r io.Reader = f
+		if strings.HasSuffix(name, ".gz") {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r = gz
+		}
+		buf, err := io.ReadAll(r)
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res[i] = string(buf)
+	}
+	return res
+}
+
+func TestWriter(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		conf     Config
+		writes   []string
+		interval time.Duration
+		expected []string
+	}{
+		{
+			name:     "Size",
+			conf:     Config{MaxSize: 8},
+			writes:   []string{"abc\n", "def\n", "ghi\n", "0123456789\n", "jkl\n"},
+			interval: time.Second,
+			expected: []string{"abc\ndef\n", "ghi\n", "0123456789\n", "jkl\n"},
+		},
+		{
+			name:     "Age",
+			conf:     Config{MaxAge: time.Minute},
+			writes:   []string{"abc\n", "def\n", "ghi\n", "jkl\n"},
+			interval: 30 * time.Second,
+			expected: []string{"abc\ndef\n", "ghi\njkl\n"},
+		},
+		{
+			name:     "Gzip",
+			conf:     Config{MaxSize: 8, Gzip: true},
+			writes:   []string{"abc\n", "def\n", "ghi\n"},
+			interval: time.Second,
+			expected: []string{"abc\ndef\n", "ghi\n"},
+		},
+		{
+			name:     "NoRotation",
+			writes:   []string{"abc\n", "def\n", "ghi\n"},
+			expected: []string{"abc\ndef\nghi\n"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.conf.Dir = filepath.Join(t.TempDir(), "out")
+			tc.conf.Prefix, tc.conf.Ext = "test-", ".txt"
+			w, err := New(tc.conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			w.now = func() time.Time { return now }
+			for _, s := range tc.writes {
+				if _, err := io.WriteString(w, s); err != nil {
+					t.Fatal(err)
+				}
+				now = now.Add(tc.interval)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			actual := readFiles(t, tc.conf.Dir)
+			if strings.Join(actual, "|") != strings.Join(tc.expected, "|") {
+				t.Fatalf("Expected files %q, got %q", tc.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	fileVersion = 1
+	saltLength  = 16
+	scryptN     = 1 << 15
+	scryptR     = 8
+	scryptP     = 1
+	keyLength   = 32
+)
+
+// DefaultFile returns the default path of the encrypted secrets file.
+// It returns an empty string if the user configuration directory cannot be determined.
+func DefaultFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pb", "secrets.enc")
+}
+
+type fileStore struct {
+	path       string
+	passphrase string
+}
+
+// File returns a store that keeps secrets in a file encrypted with the passphrase.
+// The file is encrypted with AES-256-GCM using a key derived from the passphrase with scrypt.
+// This store is intended for environments without a keyring, such as headless CI.
+func File(path, passphrase string) Store {
+	return &fileStore{
+		path:       path,
+		passphrase: passphrase,
+	}
+}
+
+type encryptedFile struct {
+	Version    int    `json:"version"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+func (s *fileStore) aead(salt []byte) (cipher.AEAD, error) {
+	if s.passphrase == "" {
+		return nil, errors.New("no passphrase")
+	}
+	key, err := scrypt.Key([]byte(s.passphrase), salt, scryptN, scryptR, scryptP, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s *fileStore) read() (map[string]string, error) {
+	buf, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return make(map[string]string), nil
+		}
+		return nil, err
+	}
+	var f encryptedFile
+	if err := json.Unmarshal(buf, &f); err != nil {
+		return nil, fmt.Errorf("read %q: %w", s.path, err)
+	}
+	if f.Version != fileVersion {
+		return nil, fmt.Errorf("read %q: unsupported version %d", s.path, f.Version)
+	}
+	aead, err := s.aead(f.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, f.Nonce, f.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt %q: invalid passphrase or corrupt file", s.path)
+	}
+	var secrets map[string]string
+	if err := json.Unmarshal(plaintext, &secrets); err != nil {
+		return nil, fmt.Errorf("read %q: %w", s.path, err)
+	}
+	if secrets == nil {
+		secrets = make(map[string]string)
+	}
+	return secrets, nil
+}
+
+func (s *fileStore) write(secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	f := encryptedFile{
+		Version: fileVersion,
+		Salt:    make([]byte, saltLength),
+	}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	aead, err := s.aead(f.Salt)
+	if err != nil {
+		return err
+	}
+	f.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(f.Nonce); err != nil {
+		return err
+	}
+	f.Ciphertext = aead.Seal(nil, f.Nonce, plaintext, nil)
+	buf, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func fileKey(service, key string) string {
+	return fmt.Sprintf("%s/%s", service, key)
+}
+
+func (s *fileStore) Get(service, key string) (string, error) {
+	secrets, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[fileKey(service, key)]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *fileStore) Set(service, key, value string) error {
+	secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+	secrets[fileKey(service, key)] = value
+	return s.write(secrets)
+}
+
+func (s *fileStore) Delete(service, key string) error {
+	secrets, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[fileKey(service, key)]; !ok {
+		return ErrNotFound
+	}
+	delete(secrets, fileKey(service, key))
+	return s.write(secrets)
+}
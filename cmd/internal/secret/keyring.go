@@ -0,0 +1,37 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package secret
+
+import (
+	"errors"
+
+	"github.com/zalando/go-keyring"
+)
+
+type keyringStore struct{}
+
+// Keyring returns a store that uses the keyring of the operating system.
+// This is the Secret Service on Linux, the Keychain on macOS and the Credential Manager on Windows.
+func Keyring() Store {
+	return keyringStore{}
+}
+
+func (keyringStore) Get(service, key string) (string, error) {
+	value, err := keyring.Get(service, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return "", ErrNotFound
+	}
+	return value, err
+}
+
+func (keyringStore) Set(service, key, value string) error {
+	return keyring.Set(service, key, value)
+}
+
+func (keyringStore) Delete(service, key string) error {
+	err := keyring.Delete(service, key)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package secret provides storage of secrets outside of the configuration file.
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned when a secret does not exist in the store.
+var ErrNotFound = errors.New("secret not found")
+
+// Store is a secret storage backend.
+type Store interface {
+	// Get returns the secret of the given service and key.
+	Get(service, key string) (string, error)
+	// Set stores the secret of the given service and key.
+	Set(service, key, value string) error
+	// Delete deletes the secret of the given service and key.
+	Delete(service, key string) error
+}
+
+// Reference refers to a secret in a backend.
+// The text representation is backend:service/key, for example keyring:pb/KZUCD5XAYT6EJ5BH.
+type Reference struct {
+	Backend,
+	Service,
+	Key string
+}
+
+func (r Reference) String() string {
+	return fmt.Sprintf("%s:%s/%s", r.Backend, r.Service, r.Key)
+}
+
+// ParseReference parses the reference.
+// It returns false if the value is not formatted as a reference.
+func ParseReference(s string) (Reference, bool) {
+	backend, rest, ok := strings.Cut(s, ":")
+	if !ok || backend == "" {
+		return Reference{}, false
+	}
+	service, key, ok := strings.Cut(rest, "/")
+	if !ok || service == "" || key == "" {
+		return Reference{}, false
+	}
+	return Reference{
+		Backend: backend,
+		Service: service,
+		Key:     key,
+	}, true
+}
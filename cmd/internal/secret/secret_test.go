@@ -0,0 +1,86 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package secret
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/zalando/go-keyring"
+)
+
+func TestParseReference(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		ref   Reference
+		ok    bool
+	}{
+		{
+			value: "keyring:pb/KZUCD5XAYT6EJ5BH",
+			ref:   Reference{Backend: "keyring", Service: "pb", Key: "KZUCD5XAYT6EJ5BH"},
+			ok:    true,
+		},
+		{
+			value: "file:pb/admin/iam-password",
+			ref:   Reference{Backend: "file", Service: "pb", Key: "admin/iam-password"},
+			ok:    true,
+		},
+		{value: "E67X5675UCQFTTJMUD73URQOLPA5VT4GBFLPCMUHZWK52ML5"},
+		{value: "keyring:pb"},
+		{value: "keyring:/key"},
+		{value: ":pb/key"},
+	} {
+		t.Run(tc.value, func(t *testing.T) {
+			ref, ok := ParseReference(tc.value)
+			if ok != tc.ok || ref != tc.ref {
+				t.Fatalf("expected %v %v, got %v %v", tc.ref, tc.ok, ref, ok)
+			}
+			if ok && ref.String() != tc.value {
+				t.Fatalf("expected %q, got %q", tc.value, ref.String())
+			}
+		})
+	}
+}
+
+func testStore(t *testing.T, store Store) {
+	if _, err := store.Get("pb", "KZUCD5XAYT6EJ5BH"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if err := store.Set("pb", "KZUCD5XAYT6EJ5BH", "secret"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	value, err := store.Get("pb", "KZUCD5XAYT6EJ5BH")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value != "secret" {
+		t.Fatalf("expected secret, got %q", value)
+	}
+	if err := store.Delete("pb", "KZUCD5XAYT6EJ5BH"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := store.Get("pb", "KZUCD5XAYT6EJ5BH"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	keyring.MockInit()
+	testStore(t, Keyring())
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pb", "secrets.enc")
+	testStore(t, File(path, "passphrase"))
+
+	if err := File(path, "passphrase").Set("pb", "C5232IFFX4UKEELB", "secret"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := File(path, "other").Get("pb", "C5232IFFX4UKEELB"); err == nil {
+		t.Fatal("expected error with invalid passphrase")
+	}
+	if err := File(path, "").Set("pb", "C5232IFFX4UKEELB", "secret"); err == nil {
+		t.Fatal("expected error without passphrase")
+	}
+}
@@ -4,14 +4,16 @@ package cmd
 
 import "github.com/spf13/cobra"
 
-var clusterCmd = &cobra.Command{
-	Use:               "cluster",
-	Aliases:           []string{"cluster", "c"},
-	Short:             "Manage Packet Broker clusters",
-	PersistentPreRunE: prerunConnect,
-	PersistentPostRun: postrunConnect,
-}
+func (st *state) newClusterCommand() *cobra.Command {
+	clusterCmd := &cobra.Command{
+		Use:               "cluster",
+		Aliases:           []string{"cluster", "c"},
+		Short:             "Manage Packet Broker clusters",
+		PersistentPreRunE: st.prerunConnect,
+		PersistentPostRun: st.postrunConnect,
+	}
+
+	clusterCmd.AddCommand(st.newClusterAPIKeyCommand())
 
-func init() {
-	rootCmd.AddCommand(clusterCmd)
+	return clusterCmd
 }
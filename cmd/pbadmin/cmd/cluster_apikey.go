@@ -4,24 +4,24 @@ package cmd
 
 import (
 	"fmt"
-	"os"
+	"io"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	iampbv2 "go.packetbroker.org/api/iam/v2"
 	"go.packetbroker.org/pb/cmd/internal/column"
 	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
-	"golang.org/x/term"
+	"go.packetbroker.org/pb/cmd/internal/printer"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-var (
-	clusterAPIKeyCmd = &cobra.Command{
+func (st *state) newClusterAPIKeyCommand() *cobra.Command {
+	clusterAPIKeyCmd := &cobra.Command{
 		Use:     "apikey",
 		Aliases: []string{"apikeys", "key", "keys"},
 		Short:   "Manage Packet Broker API keys for clusters",
 	}
-	clusterAPIKeyListCmd = &cobra.Command{
+	clusterAPIKeyListCmd := &cobra.Command{
 		Use:     "list",
 		Aliases: []string{"ls"},
 		Short:   "List API keys",
@@ -38,24 +38,26 @@ var (
 					req.ClusterId = wrapperspb.String(f.Value.String())
 				}
 			})
-			res, err := iampbv2.NewClusterAPIKeyVaultClient(conn).ListAPIKeys(ctx, req)
+			res, err := iampbv2.NewClusterAPIKeyVaultClient(st.conn).ListAPIKeys(st.ctx, req)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(tabout, "Key ID\tClusterID\tRights\tState\tLast Used\t")
-			for _, t := range res.Keys {
-				fmt.Fprintf(tabout, "%s\t%s\t%s\t%s\t%s\t\n",
-					t.GetKeyId(),
-					t.GetClusterId(),
-					column.Rights(t.GetRights()),
-					t.GetState(),
-					(*column.TimeSince)(t.GetAuthenticatedAt()),
-				)
-			}
-			return nil
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("apiKeys", res.Keys), func(w io.Writer) error {
+				fmt.Fprintln(w, "Key ID\tClusterID\tRights\tState\tLast Used\t")
+				for _, t := range res.Keys {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n",
+						t.GetKeyId(),
+						t.GetClusterId(),
+						column.Rights(t.GetRights()),
+						t.GetState(),
+						(*column.TimeSince)(t.GetAuthenticatedAt()),
+					)
+				}
+				return nil
+			})
 		},
 	}
-	clusterAPIKeyCreateCmd = &cobra.Command{
+	clusterAPIKeyCreateCmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create an API key",
 		Long: `Create an API key for a named cluster.
@@ -82,19 +84,19 @@ Rights:
 				Rights:    pbflag.GetAPIKeyRights(cmd.Flags()),
 			}
 			if promptKey, _ := cmd.Flags().GetBool("prompt-key"); promptKey {
-				fmt.Fprint(os.Stdout, "Secret key: ")
-				keyBuf, err := term.ReadPassword(int(os.Stdin.Fd()))
+				fmt.Fprint(st.Stdout, "Secret key: ")
+				key, err := st.readPassword()
 				if err != nil {
 					return err
 				}
-				req.Key = string(keyBuf)
+				req.Key = key
 			}
-			res, err := iampbv2.NewClusterAPIKeyVaultClient(conn).CreateAPIKey(ctx, req)
+			res, err := iampbv2.NewClusterAPIKeyVaultClient(st.conn).CreateAPIKey(st.ctx, req)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(os.Stderr, "Store the API key now in a secure place, as it cannot be retrieved later.")
-			return column.WriteKV(tabout,
+			fmt.Fprintln(st.Stderr, "Store the API key now in a secure place, as it cannot be retrieved later.")
+			return column.WriteKV(st.tabout,
 				"Key ID", res.Key.GetKeyId(),
 				"Secret Key", res.Key.GetKey(),
 				"Cluster ID", res.Key.GetClusterId(),
@@ -103,7 +105,7 @@ Rights:
 			)
 		},
 	}
-	clusterAPIKeyUpdateStateCmd = &cobra.Command{
+	clusterAPIKeyUpdateStateCmd := &cobra.Command{
 		Use:   "update-state",
 		Short: "Update the API key state",
 		Example: `
@@ -112,14 +114,14 @@ Rights:
 		RunE: func(cmd *cobra.Command, args []string) error {
 			keyID, _ := cmd.Flags().GetString("key-id")
 			state := pbflag.GetAPIKeyState(cmd.Flags(), "state")
-			_, err := iampbv2.NewClusterAPIKeyVaultClient(conn).UpdateAPIKeyState(ctx, &iampbv2.UpdateAPIKeyStateRequest{
+			_, err := iampbv2.NewClusterAPIKeyVaultClient(st.conn).UpdateAPIKeyState(st.ctx, &iampbv2.UpdateAPIKeyStateRequest{
 				KeyId: keyID,
 				State: state,
 			})
 			return err
 		},
 	}
-	clusterAPIKeyDeleteCmd = &cobra.Command{
+	clusterAPIKeyDeleteCmd := &cobra.Command{
 		Use:     "delete",
 		Aliases: []string{"rm"},
 		Short:   "Delete an API key",
@@ -128,16 +130,12 @@ Rights:
     $ pbadmin cluster apikey delete --key-id C5232IFFX4UKEELB`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			keyID, _ := cmd.Flags().GetString("key-id")
-			_, err := iampbv2.NewClusterAPIKeyVaultClient(conn).DeleteAPIKey(ctx, &iampbv2.APIKeyRequest{
+			_, err := iampbv2.NewClusterAPIKeyVaultClient(st.conn).DeleteAPIKey(st.ctx, &iampbv2.APIKeyRequest{
 				KeyId: keyID,
 			})
 			return err
 		},
 	}
-)
-
-func init() {
-	clusterCmd.AddCommand(clusterAPIKeyCmd)
 
 	clusterAPIKeyListCmd.Flags().String("cluster-id", "", "cluster ID")
 	clusterAPIKeyCmd.AddCommand(clusterAPIKeyListCmd)
@@ -153,4 +151,6 @@ func init() {
 
 	clusterAPIKeyDeleteCmd.Flags().String("key-id", "", "API key ID")
 	clusterAPIKeyCmd.AddCommand(clusterAPIKeyDeleteCmd)
+
+	return clusterAPIKeyCmd
 }
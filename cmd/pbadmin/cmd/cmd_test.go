@@ -0,0 +1,280 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/cmd/internal/cmdtest"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// execute runs pbadmin with the arguments against the test environment.
+func execute(t *testing.T, env *cmdtest.Env, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	cmd := NewRootCommand(Options{
+		Stdin:  strings.NewReader(""),
+		Stdout: &out,
+		Stderr: &errOut,
+		Logger: zap.NewNop(),
+	})
+	cmd.SetArgs(append(args, env.Args("iam")...))
+	err = cmd.Execute()
+	return out.String(), errOut.String(), err
+}
+
+func TestNetwork(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+
+	stdout, _, err := execute(t, env, "network", "create",
+		"--net-id", "000013",
+		"--name", "The Things Network",
+		"--dev-addr-blocks", "26011000/20=eu1,26012000/20=eu2",
+		"--listed",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Golden(t, "network_create", stdout)
+
+	if _, _, err := execute(t, env, "network", "create", "--net-id", "000009", "--name", "Senet"); err != nil {
+		t.Fatal(err)
+	}
+	stdout, _, err = execute(t, env, "network", "list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Golden(t, "network_list", stdout)
+
+	stdout, _, err = execute(t, env, "network", "list", "--name-contains", "things", "-o", "jsonpath={.networks[*].name}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "The Things Network\n" {
+		t.Fatalf("Unexpected output %q", stdout)
+	}
+
+	if _, _, err := execute(t, env, "network", "get", "--net-id", "000042"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+
+	if expected, actual := strings.Join([]string{
+		"/org.packetbroker.iam.v1.NetworkRegistry/CreateNetwork",
+		"/org.packetbroker.iam.v1.NetworkRegistry/CreateNetwork",
+		"/org.packetbroker.iam.v1.NetworkRegistry/ListNetworks",
+		"/org.packetbroker.iam.v1.NetworkRegistry/ListNetworks",
+		"/org.packetbroker.iam.v1.NetworkRegistry/GetNetwork",
+	}, "\n"), strings.Join(env.Methods(), "\n"); actual != expected {
+		t.Fatalf("Expected calls:\n%s\ngot:\n%s", expected, actual)
+	}
+}
+
+func TestConcurrentCommands(t *testing.T) {
+	envs := []*cmdtest.Env{cmdtest.NewEnv(t), cmdtest.NewEnv(t)}
+	for i, env := range envs {
+		env.Server.AddNetwork(&packetbroker.Network{NetId: uint32(i + 1), Name: fmt.Sprintf("Network %d", i+1)})
+	}
+
+	// Each command connects to the address of its own flags and configuration file.
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		i, env := i, envs[i%len(envs)]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stdout, _, err := execute(t, env, "network", "list", "-o", "jsonpath={.networks[*].name}")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if expected := fmt.Sprintf("Network %d\n", i%len(envs)+1); stdout != expected {
+				t.Errorf("Expected %q, got %q", expected, stdout)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestTenant(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		Name:  "The Things Network",
+	})
+
+	for _, args := range [][]string{
+		{"--tenant-id", "community", "--name", "The Things Network Community", "--dev-addr-blocks", "26011000/20"},
+		{"--tenant-id", "tti", "--name", "The Things Industries", "--listed"},
+	} {
+		if _, _, err := execute(t, env, append([]string{"network", "tenant", "create", "--net-id", "000013"}, args...)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, _, err := execute(t, env, "network", "tenant", "list", "--net-id", "000013")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Golden(t, "tenant_list", stdout)
+
+	if _, _, err := execute(t, env, "network", "tenant", "delete", "--net-id", "000013", "--tenant-id", "tti"); err != nil {
+		t.Fatal(err)
+	}
+	stdout, _, err = execute(t, env, "network", "tenant", "list", "--net-id", "000013", "-o", "jsonpath={.tenants[*].tenantId}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "community\n" {
+		t.Fatalf("Unexpected output %q", stdout)
+	}
+}
+
+func TestTenantAllocate(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+		},
+	})
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId: 0x9,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x12021000, Length: 20}},
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x12011000, Length: 20}},
+		},
+	})
+	for _, args := range [][]string{
+		{"--net-id", "000013", "--tenant-id", "community", "--dev-addr-blocks", "26000000/16"},
+		{"--net-id", "000009", "--tenant-id", "senet", "--dev-addr-blocks", "12011000/20"},
+	} {
+		if _, _, err := execute(t, env, append([]string{"network", "tenant", "create"}, args...)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The network's own DevAddr blocks cover the NetID, so only the tenant's block is in use.
+	stdout, _, err := execute(t, env, "network", "tenant", "allocate",
+		"--net-id", "000013", "--tenant-id", "tti", "--length", "16", "--count", "2",
+		"-o", "jsonpath={.devAddrBlocks[*].prefix.value}",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("%d %d\n", 0x26010000, 0x26020000); stdout != expected {
+		t.Fatalf("Expected %q, got %q", expected, stdout)
+	}
+
+	// Blocks are allocated within the network's DevAddr blocks.
+	stdout, _, err = execute(t, env, "network", "tenant", "allocate",
+		"--net-id", "000009", "--length", "20",
+		"-o", "jsonpath={.devAddrBlocks[*].prefix.value}",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("%d\n", 0x12021000); stdout != expected {
+		t.Fatalf("Expected %q, got %q", expected, stdout)
+	}
+	if _, _, err := execute(t, env, "network", "tenant", "allocate",
+		"--net-id", "000009", "--length", "20", "--count", "2",
+	); err == nil || !strings.Contains(err.Error(), "1 free DevAddr blocks") {
+		t.Fatalf("Expected not enough free blocks error, got %v", err)
+	}
+}
+
+func TestConfigProfiles(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	if err := os.WriteFile(env.ConfigFile, []byte(`profiles:
+  Prod:
+    client-id: "prod"
+  staging:
+    client-id: "staging"
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := execute(t, env, "config", "current-profile"); err == nil {
+		t.Fatal("Expected error without active profile")
+	}
+	if _, _, err := execute(t, env, "config", "use-profile", "unknown"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("Expected not found error, got %v", err)
+	}
+	if _, _, err := execute(t, env, "config", "use-profile", "Prod"); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, _, err := execute(t, env, "config", "current-profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "prod\n" {
+		t.Fatalf("Unexpected output %q", stdout)
+	}
+	stdout, _, err = execute(t, env, "config", "list-profiles")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "Current   Name      \n*         prod      \n          staging   \n"; stdout != expected {
+		t.Fatalf("Expected output %q, got %q", expected, stdout)
+	}
+
+	stdout, _, err = execute(t, env, "config", "current-profile", "--profile", "STAGING")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "staging\n" {
+		t.Fatalf("Unexpected output %q", stdout)
+	}
+}
+
+func TestNetworkAPIKeySave(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		Name:  "The Things Network",
+	})
+	if err := os.WriteFile(env.ConfigFile, []byte(`token-url: "https://example.com/token"
+profiles:
+  prod:
+    client-id: "old"
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, stderr, err := execute(t, env, "network", "apikey", "create", "--net-id", "000013", "--save",
+		"--secret-backend", "none", "--profile", "Prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Contains(t, stderr, "Saved API key to "+env.ConfigFile)
+
+	buf, err := os.ReadFile(env.ConfigFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		TokenURL string                       `yaml:"token-url"`
+		Profiles map[string]map[string]string `yaml:"profiles"`
+		Other    map[string]any               `yaml:",inline"`
+	}
+	if err := yaml.Unmarshal(buf, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	// Only the API key is written to the profile; the other settings in the file are preserved and the flags and
+	// settings of the profile are not written to the top level.
+	if cfg.TokenURL != "https://example.com/token" || len(cfg.Other) != 0 {
+		t.Fatalf("Unexpected top-level settings in configuration file:\n%s", buf)
+	}
+	if profile := cfg.Profiles["prod"]; len(profile) != 2 || profile["client-id"] == "old" || profile["client-secret"] == "" {
+		t.Fatalf("Expected API key in profile, got:\n%s", buf)
+	}
+}
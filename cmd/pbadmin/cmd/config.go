@@ -0,0 +1,122 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func (st *state) newConfigCommand() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage configuration profiles",
+		Long: `Manage configuration profiles
+
+Profiles are named sets of settings in the configuration file (.pb.yaml). Their
+settings take precedence over the top-level settings in the file, while flags
+and environment variables take precedence over the profile:
+
+  router-address: "eu.packetbroker.io:443"
+  current-profile: "network"
+  profiles:
+    network:
+      client-id: "KZUCD5XAYT6EJ5BH"
+      client-secret: "E67X5675UCQFTTJMUD73URQOLPA5VT4GBFLPCMUHZWK52ML5"
+    tenant-a:
+      client-id: "C5232IFFX4UKEELB"
+      client-secret: "KZUCD5XAYT6EJ5BHE67X5675UCQFTTJMUD73URQOLPA5VT4G"
+    admin:
+      iam-username: "admin"
+      iam-password: "admin"
+
+The active profile is selected with --profile, the PB_PROFILE environment
+variable or current-profile in the configuration file, in that order. Profile
+names are case-insensitive and are listed in lowercase.`,
+	}
+	configUseProfileCmd := &cobra.Command{
+		Use:   "use-profile NAME",
+		Short: "Set the current profile in the configuration file",
+		Example: `
+  Use the admin profile by default:
+    $ pbadmin config use-profile admin`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return st.config.UseProfile(args[0])
+		},
+	}
+	configListProfilesCmd := &cobra.Command{
+		Use:     "list-profiles",
+		Aliases: []string{"profiles"},
+		Short:   "List the profiles in the configuration file",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			active := st.config.ActiveProfile()
+			fmt.Fprintln(st.tabout, "Current\tName\t")
+			for _, name := range st.config.ListProfiles() {
+				var current string
+				if name == active {
+					current = "*"
+				}
+				fmt.Fprintf(st.tabout, "%s\t%s\t\n", current, name)
+			}
+			return nil
+		},
+	}
+	configCurrentProfileCmd := &cobra.Command{
+		Use:   "current-profile",
+		Short: "Show the active profile",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			active := st.config.ActiveProfile()
+			if active == "" {
+				return errors.New("no active profile")
+			}
+			fmt.Fprintln(st.tabout, active)
+			return nil
+		},
+	}
+	configSetSecretCmd := &cobra.Command{
+		Use:   "set-secret NAME",
+		Short: "Store a secret setting in the secret backend",
+		Long: `Store a secret setting in the secret backend
+
+The secret value is prompted and stored in the secret backend. The configuration
+file refers to the secret, for example:
+
+  iam-password: "keyring:pb/iam-password"
+
+By default, secrets are stored in the keyring of the operating system. In
+headless environments, use the encrypted file backend with a passphrase in the
+PB_SECRET_PASSPHRASE environment variable.`,
+		Example: `
+  Store the IAM password in the keyring:
+    $ pbadmin config set-secret iam-password
+
+  Store the OAuth 2.0 client secret of the admin profile in an encrypted file:
+    $ PB_SECRET_PASSPHRASE=... pbadmin config set-secret client-secret \
+      --profile admin --secret-backend file`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Fprintf(st.Stdout, "%s: ", args[0])
+			value, err := st.readPassword()
+			fmt.Fprintln(st.Stdout)
+			if err != nil {
+				return err
+			}
+			ref, err := st.config.SetSecret(args[0], value)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(st.Stderr, "Saved %s to %s\n", ref, st.config.ConfigFileUsed())
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(configUseProfileCmd)
+	configCmd.AddCommand(configListProfilesCmd)
+	configCmd.AddCommand(configCurrentProfileCmd)
+	configCmd.AddCommand(configSetSecretCmd)
+
+	return configCmd
+}
@@ -4,6 +4,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -11,43 +12,41 @@ import (
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/column"
 	"go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/sdk"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-var (
-	joinServerCmd = &cobra.Command{
+func (st *state) newJoinServerCommand() *cobra.Command {
+	joinServerCmd := &cobra.Command{
 		Use:               "join-server",
 		Aliases:           []string{"join-servers", "js"},
 		Short:             "Manage Packet Broker Join Servers",
-		PersistentPreRunE: prerunConnect,
-		PersistentPostRun: postrunConnect,
+		PersistentPreRunE: st.prerunConnect,
+		PersistentPostRun: st.postrunConnect,
 	}
-	joinServerListCmd = &cobra.Command{
+	joinServerListCmd := &cobra.Command{
 		Use:     "list",
 		Aliases: []string{"ls"},
 		Short:   "List Join Servers",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var (
-				offset          = uint32(0)
-				nameContains, _ = cmd.Flags().GetString("name-contains")
-			)
-			fmt.Fprintln(tabout, "  ID\tName\tJoinEUI Prefixes\tListed\tResolver\t")
-			for {
-				res, err := iampb.NewJoinServerRegistryClient(conn).ListJoinServers(ctx, &iampb.ListJoinServersRequest{
-					Offset:       offset,
-					NameContains: nameContains,
-				})
-				if err != nil {
-					return err
-				}
-				for _, t := range res.JoinServers {
+			nameContains, _ := cmd.Flags().GetString("name-contains")
+			joinServers, err := sdk.NewIAM(st.conn).ListAllJoinServers(st.ctx, &iampb.ListJoinServersRequest{
+				NameContains: nameContains,
+			}).All()
+			if err != nil {
+				return err
+			}
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("joinServers", joinServers), func(w io.Writer) error {
+				fmt.Fprintln(w, "  ID\tName\tJoinEUI Prefixes\tListed\tResolver\t")
+				for _, t := range joinServers {
 					var resolver string
 					if lookup := t.GetLookup(); lookup != nil {
 						resolver = (*column.Target)(lookup).String()
 					} else if fixed := t.GetFixed(); fixed != nil {
 						resolver = (*column.JoinServerFixedEndpoint)(fixed).String()
 					}
-					fmt.Fprintf(tabout, "%4d\t%s\t%s\t%s\t%s\t\n",
+					fmt.Fprintf(w, "%4d\t%s\t%s\t%s\t%s\t\n",
 						t.GetId(),
 						t.GetName(),
 						column.JoinEUIPrefixes(t.GetJoinEuiPrefixes()),
@@ -55,15 +54,11 @@ var (
 						resolver,
 					)
 				}
-				offset += uint32(len(res.JoinServers))
-				if len(res.JoinServers) == 0 || offset >= res.Total {
-					break
-				}
-			}
-			return nil
+				return nil
+			})
 		},
 	}
-	joinServerCreateCmd = &cobra.Command{
+	joinServerCreateCmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create a Join Server",
 		Example: `
@@ -112,16 +107,18 @@ var (
 					},
 				}
 			}
-			res, err := iampb.NewJoinServerRegistryClient(conn).CreateJoinServer(ctx, &iampb.CreateJoinServerRequest{
+			res, err := iampb.NewJoinServerRegistryClient(st.conn).CreateJoinServer(st.ctx, &iampb.CreateJoinServerRequest{
 				JoinServer: js,
 			})
 			if err != nil {
 				return err
 			}
-			return column.WriteJoinServer(tabout, res.JoinServer, false)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(res.JoinServer, func(w io.Writer) error {
+				return column.WriteJoinServer(w, res.JoinServer, false)
+			})
 		},
 	}
-	joinServerGetCmd = &cobra.Command{
+	joinServerGetCmd := &cobra.Command{
 		Use:   "get",
 		Short: "Get a Join Server",
 		Example: `
@@ -129,17 +126,19 @@ var (
     $ pbadmin join-server get --id 1`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			id, _ := cmd.Flags().GetUint32("id")
-			res, err := iampb.NewJoinServerRegistryClient(conn).GetJoinServer(ctx, &iampb.JoinServerRequest{
+			res, err := iampb.NewJoinServerRegistryClient(st.conn).GetJoinServer(st.ctx, &iampb.JoinServerRequest{
 				Id: id,
 			})
 			if err != nil {
 				return err
 			}
 			verbose, _ := cmd.Flags().GetBool("verbose")
-			return column.WriteJoinServer(tabout, res.JoinServer, verbose)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(res.JoinServer, func(w io.Writer) error {
+				return column.WriteJoinServer(w, res.JoinServer, verbose)
+			})
 		},
 	}
-	joinServerUpdateCmd = &cobra.Command{
+	joinServerUpdateCmd := &cobra.Command{
 		Use:     "update",
 		Aliases: []string{"up"},
 		Short:   "Update a Join Server",
@@ -175,11 +174,11 @@ var (
 				listed, _ := cmd.Flags().GetBool("listed")
 				req.Listed = wrapperspb.Bool(listed)
 			}
-			_, err := iampb.NewJoinServerRegistryClient(conn).UpdateJoinServer(ctx, req)
+			_, err := iampb.NewJoinServerRegistryClient(st.conn).UpdateJoinServer(st.ctx, req)
 			return err
 		},
 	}
-	joinServerUpdateTargetCmd = &cobra.Command{
+	joinServerUpdateTargetCmd := &cobra.Command{
 		Use:   "target",
 		Short: "Update a Join Server target",
 		Example: `
@@ -203,8 +202,8 @@ var (
       --root-cas-file ca.pem --tls-cert-file key.pem --tls-key-file key.pem`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			id, _ := cmd.Flags().GetUint32("id")
-			client := iampb.NewJoinServerRegistryClient(conn)
-			js, err := client.GetJoinServer(ctx, &iampb.JoinServerRequest{
+			client := iampb.NewJoinServerRegistryClient(st.conn)
+			js, err := client.GetJoinServer(st.ctx, &iampb.JoinServerRequest{
 				Id: id,
 			})
 			if err != nil {
@@ -231,11 +230,11 @@ var (
 					},
 				}
 			}
-			_, err = client.UpdateJoinServer(ctx, req)
+			_, err = client.UpdateJoinServer(st.ctx, req)
 			return err
 		},
 	}
-	joinServerDeleteCmd = &cobra.Command{
+	joinServerDeleteCmd := &cobra.Command{
 		Use:     "delete",
 		Aliases: []string{"rm"},
 		Short:   "Delete a Join Server",
@@ -244,23 +243,12 @@ var (
     $ pbadmin join-server delete --id 1`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			id, _ := cmd.Flags().GetUint32("id")
-			_, err := iampb.NewJoinServerRegistryClient(conn).DeleteJoinServer(ctx, &iampb.JoinServerRequest{
+			_, err := iampb.NewJoinServerRegistryClient(st.conn).DeleteJoinServer(st.ctx, &iampb.JoinServerRequest{
 				Id: id,
 			})
 			return err
 		},
 	}
-)
-
-func joinServerSettingsFlags() *flag.FlagSet {
-	flags := new(flag.FlagSet)
-	flags.String("name", "", "Join Server name")
-	flags.Bool("listed", false, "list Join Server in catalog")
-	return flags
-}
-
-func init() {
-	rootCmd.AddCommand(joinServerCmd)
 
 	joinServerListCmd.Flags().String("name-contains", "", "filter Join Servers by name")
 	joinServerCmd.AddCommand(joinServerListCmd)
@@ -290,4 +278,13 @@ func init() {
 
 	joinServerDeleteCmd.Flags().Uint32("id", 0, "unique identifier of the Join Server")
 	joinServerCmd.AddCommand(joinServerDeleteCmd)
+
+	return joinServerCmd
+}
+
+func joinServerSettingsFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.String("name", "", "Join Server name")
+	flags.Bool("listed", false, "list Join Server in catalog")
+	return flags
 }
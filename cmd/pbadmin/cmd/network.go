@@ -4,51 +4,48 @@ package cmd
 
 import (
 	"fmt"
-	"os"
+	"io"
 
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
-	"github.com/spf13/viper"
 	iampb "go.packetbroker.org/api/iam"
 	iampbv2 "go.packetbroker.org/api/iam/v2"
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/column"
 	"go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/sdk"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-var (
-	networkCmd = &cobra.Command{
+func (st *state) newNetworkCommand() *cobra.Command {
+	networkCmd := &cobra.Command{
 		Use:               "network",
 		Aliases:           []string{"networks", "nwk", "nwks", "n"},
 		Short:             "Manage Packet Broker networks",
-		PersistentPreRunE: prerunConnect,
-		PersistentPostRun: postrunConnect,
+		PersistentPreRunE: st.prerunConnect,
+		PersistentPostRun: st.postrunConnect,
 	}
-	networkListCmd = &cobra.Command{
+	networkListCmd := &cobra.Command{
 		Use:     "list",
 		Aliases: []string{"ls"},
 		Short:   "List networks",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var (
-				offset          = uint32(0)
-				nameContains, _ = cmd.Flags().GetString("name-contains")
-			)
-			fmt.Fprintln(tabout, "NetID\tAuthority\tName\tDevAddr Blocks\tListed\tTarget\tDelegated NetID\t")
-			for {
-				res, err := iampb.NewNetworkRegistryClient(conn).ListNetworks(ctx, &iampb.ListNetworksRequest{
-					Offset:       offset,
-					NameContains: nameContains,
-				})
-				if err != nil {
-					return err
-				}
-				for _, t := range res.Networks {
+			nameContains, _ := cmd.Flags().GetString("name-contains")
+			networks, err := sdk.NewIAM(st.conn).ListAllNetworks(st.ctx, &iampb.ListNetworksRequest{
+				NameContains: nameContains,
+			}).All()
+			if err != nil {
+				return err
+			}
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("networks", networks), func(w io.Writer) error {
+				fmt.Fprintln(w, "NetID\tAuthority\tName\tDevAddr Blocks\tListed\tTarget\tDelegated NetID\t")
+				for _, t := range networks {
 					var delegatedNetID *uint32
 					if val := t.GetDelegatedNetId(); val != nil {
 						delegatedNetID = &val.Value
 					}
-					fmt.Fprintf(tabout, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
 						packetbroker.NetID(t.GetNetId()),
 						t.Authority,
 						t.GetName(),
@@ -58,15 +55,11 @@ var (
 						(*packetbroker.NetID)(delegatedNetID),
 					)
 				}
-				offset += uint32(len(res.Networks))
-				if len(res.Networks) == 0 || offset >= res.Total {
-					break
-				}
-			}
-			return nil
+				return nil
+			})
 		},
 	}
-	networkCreateCmd = &cobra.Command{
+	networkCreateCmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create a network",
 		Example: `
@@ -102,7 +95,7 @@ var (
 			if netID, ok := pbflag.GetNetID(cmd.Flags(), "delegated"); ok {
 				delegatedNetID = wrapperspb.UInt32(uint32(netID))
 			}
-			res, err := iampb.NewNetworkRegistryClient(conn).CreateNetwork(ctx, &iampb.CreateNetworkRequest{
+			res, err := iampb.NewNetworkRegistryClient(st.conn).CreateNetwork(st.ctx, &iampb.CreateNetworkRequest{
 				Network: &packetbroker.Network{
 					NetId:                 uint32(netID),
 					Name:                  name,
@@ -117,10 +110,12 @@ var (
 			if err != nil {
 				return err
 			}
-			return column.WriteNetwork(tabout, res.Network, false)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(res.Network, func(w io.Writer) error {
+				return column.WriteNetwork(w, res.Network, false)
+			})
 		},
 	}
-	networkGetCmd = &cobra.Command{
+	networkGetCmd := &cobra.Command{
 		Use:   "get",
 		Short: "Get a network",
 		Example: `
@@ -128,17 +123,19 @@ var (
     $ pbadmin network get --net-id 000013`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			netID, _ := pbflag.GetNetID(cmd.Flags(), "")
-			res, err := iampb.NewNetworkRegistryClient(conn).GetNetwork(ctx, &iampb.NetworkRequest{
+			res, err := iampb.NewNetworkRegistryClient(st.conn).GetNetwork(st.ctx, &iampb.NetworkRequest{
 				NetId: uint32(netID),
 			})
 			if err != nil {
 				return err
 			}
 			verbose, _ := cmd.Flags().GetBool("verbose")
-			return column.WriteNetwork(tabout, res.Network, verbose)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(res.Network, func(w io.Writer) error {
+				return column.WriteNetwork(w, res.Network, verbose)
+			})
 		},
 	}
-	networkUpdateCmd = &cobra.Command{
+	networkUpdateCmd := &cobra.Command{
 		Use:     "update",
 		Aliases: []string{"up"},
 		Short:   "Update a network",
@@ -151,8 +148,8 @@ var (
       --dev-addr-blocks 26011000/20=eu1,26012000=eu2`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			netID, _ := pbflag.GetNetID(cmd.Flags(), "")
-			client := iampb.NewNetworkRegistryClient(conn)
-			nwk, err := client.GetNetwork(ctx, &iampb.NetworkRequest{
+			client := iampb.NewNetworkRegistryClient(st.conn)
+			nwk, err := client.GetNetwork(st.ctx, &iampb.NetworkRequest{
 				NetId: uint32(netID),
 			})
 			if err != nil {
@@ -160,7 +157,7 @@ var (
 			}
 			if cmd.Flags().Changed("listed") {
 				listed, _ := cmd.Flags().GetBool("listed")
-				_, err := client.UpdateNetworkListed(ctx, &iampb.UpdateNetworkListedRequest{
+				_, err := client.UpdateNetworkListed(st.ctx, &iampb.UpdateNetworkListedRequest{
 					NetId:  uint32(netID),
 					Listed: listed,
 				})
@@ -211,13 +208,13 @@ var (
 				any = true
 			}
 			if any {
-				_, err = client.UpdateNetwork(ctx, req)
+				_, err = client.UpdateNetwork(st.ctx, req)
 				return err
 			}
 			return nil
 		},
 	}
-	networkUpdateTargetCmd = &cobra.Command{
+	networkUpdateTargetCmd := &cobra.Command{
 		Use:   "target",
 		Short: "Update a network target",
 		Example: `
@@ -241,8 +238,8 @@ var (
       --root-cas-file ca.pem --tls-cert-file key.pem --tls-key-file key.pem`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			netID, _ := pbflag.GetNetID(cmd.Flags(), "")
-			client := iampb.NewNetworkRegistryClient(conn)
-			nwk, err := client.GetNetwork(ctx, &iampb.NetworkRequest{
+			client := iampb.NewNetworkRegistryClient(st.conn)
+			nwk, err := client.GetNetwork(st.ctx, &iampb.NetworkRequest{
 				NetId: uint32(netID),
 			})
 			if err != nil {
@@ -258,11 +255,11 @@ var (
 					Value: target,
 				},
 			}
-			_, err = client.UpdateNetwork(ctx, req)
+			_, err = client.UpdateNetwork(st.ctx, req)
 			return err
 		},
 	}
-	networkDeleteCmd = &cobra.Command{
+	networkDeleteCmd := &cobra.Command{
 		Use:     "delete",
 		Aliases: []string{"rm"},
 		Short:   "Delete a network",
@@ -271,13 +268,13 @@ var (
     $ pbadmin network delete --net-id 000013`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			netID, _ := pbflag.GetNetID(cmd.Flags(), "")
-			_, err := iampb.NewNetworkRegistryClient(conn).DeleteNetwork(ctx, &iampb.NetworkRequest{
+			_, err := iampb.NewNetworkRegistryClient(st.conn).DeleteNetwork(st.ctx, &iampb.NetworkRequest{
 				NetId: uint32(netID),
 			})
 			return err
 		},
 	}
-	networkDeleteTargetCmd = &cobra.Command{
+	networkDeleteTargetCmd := &cobra.Command{
 		Use:   "target",
 		Short: "Delete a network target",
 		Example: `
@@ -285,25 +282,26 @@ var (
     $ pbadmin network delete target --net-id 000013`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			netID, _ := pbflag.GetNetID(cmd.Flags(), "")
-			client := iampb.NewNetworkRegistryClient(conn)
+			client := iampb.NewNetworkRegistryClient(st.conn)
 			req := &iampb.UpdateNetworkRequest{
 				NetId: uint32(netID),
 				Target: &iampb.TargetValue{
 					Value: nil,
 				},
 			}
-			_, err := client.UpdateNetwork(ctx, req)
+			_, err := client.UpdateNetwork(st.ctx, req)
 			return err
 		},
 	}
-	networkInitCmd = &cobra.Command{
+	networkInitCmd := &cobra.Command{
 		Use:   "init",
 		Short: "Initialize network configuration",
 		Long: `Initialize network configuration
 
-This stores the router address and a newly requested network API key in a local
-configuration file (.pb.yaml). This configuration can be used by Packet Broker
-command-line interfaces.`,
+This stores the router address and a newly requested network API key in the
+configuration file, or in a local configuration file (.pb.yaml) if there is
+none. If a profile is active, the settings are stored in the profile. This
+configuration can be used by Packet Broker command-line interfaces.`,
 		Example: `
   Initialize configuration for a network:
     $ pbadmin network init --net-id 000013 \
@@ -344,7 +342,7 @@ Router addresses:
 				ClusterId: endpoint.ClusterID,
 				Rights:    pbflag.GetAPIKeyRights(cmd.Flags()),
 			}
-			res, err := iampbv2.NewNetworkAPIKeyVaultClient(conn).CreateAPIKey(ctx, req)
+			res, err := iampbv2.NewNetworkAPIKeyVaultClient(st.conn).CreateAPIKey(st.ctx, req)
 			if err != nil {
 				return err
 			}
@@ -352,16 +350,25 @@ Router addresses:
 			controlPlaneAddress, _ := cmd.Flags().GetString("controlplane-address")
 			reportsAddress, _ := cmd.Flags().GetString("reports-address")
 			routerAddress, _ := cmd.Flags().GetString("router-address")
-			viper.Set("controlplane-address", controlPlaneAddress)
-			viper.Set("reports-address", reportsAddress)
-			viper.Set("router-address", routerAddress)
-			viper.Set("client-id", res.Key.GetKeyId())
-			viper.Set("client-secret", res.Key.GetKey())
-			if err := viper.WriteConfigAs(".pb.yaml"); err != nil {
+			clientSecret, err := st.config.StoreSecret(res.Key.GetKeyId(), res.Key.GetKey())
+			if err != nil {
+				// The API key is created, so show the secret key as it cannot be retrieved later.
+				fmt.Fprintf(st.Stderr, "Store the secret key %s of API key %s now in a secure place, as it cannot be retrieved later.\n",
+					res.Key.GetKey(), res.Key.GetKeyId())
+				return err
+			}
+			path, err := st.config.SetSettings(map[string]string{
+				"controlplane-address": controlPlaneAddress,
+				"reports-address":      reportsAddress,
+				"router-address":       routerAddress,
+				"client-id":            res.Key.GetKeyId(),
+				"client-secret":        clientSecret,
+			})
+			if err != nil {
 				return err
 			}
-			fmt.Fprintln(os.Stderr, "Saved configuration to .pb.yaml")
-			return column.WriteKV(tabout,
+			fmt.Fprintf(st.Stderr, "Saved configuration to %s\n", path)
+			return column.WriteKV(st.tabout,
 				"NetID", endpoint.NetID.String(),
 				"Tenant ID", endpoint.ID,
 				"Cluster ID", endpoint.ClusterID,
@@ -375,18 +382,6 @@ Router addresses:
 			)
 		},
 	}
-)
-
-func networkSettingsFlags() *flag.FlagSet {
-	flags := new(flag.FlagSet)
-	flags.String("name", "", "network name")
-	flags.Bool("listed", false, "list network in catalog")
-	flags.AddFlagSet(pbflag.NetID("delegated"))
-	return flags
-}
-
-func init() {
-	rootCmd.AddCommand(networkCmd)
 
 	networkListCmd.Flags().String("name-contains", "", "filter networks by name")
 	networkCmd.AddCommand(networkListCmd)
@@ -435,4 +430,16 @@ func init() {
 	networkInitCmd.Flags().String("reports-address", "reports.packetbroker.net:443", `Packet Broker Reporter address "host[:port]"`)
 	networkInitCmd.Flags().String("router-address", "", `Packet Broker Router address "host[:port]"`)
 	networkCmd.AddCommand(networkInitCmd)
+	networkCmd.AddCommand(st.newNetworkAPIKeyCommand())
+	networkCmd.AddCommand(st.newNetworkTenantCommand())
+
+	return networkCmd
+}
+
+func networkSettingsFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.String("name", "", "network name")
+	flags.Bool("listed", false, "list network in catalog")
+	flags.AddFlagSet(pbflag.NetID("delegated"))
+	return flags
 }
@@ -4,25 +4,24 @@ package cmd
 
 import (
 	"fmt"
-	"os"
+	"io"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	iampbv2 "go.packetbroker.org/api/iam/v2"
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/column"
 	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
-	"golang.org/x/term"
+	"go.packetbroker.org/pb/cmd/internal/printer"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-var (
-	networkAPIKeyCmd = &cobra.Command{
+func (st *state) newNetworkAPIKeyCommand() *cobra.Command {
+	networkAPIKeyCmd := &cobra.Command{
 		Use:     "apikey",
 		Aliases: []string{"apikeys", "key", "keys"},
 		Short:   "Manage Packet Broker API keys for networks and tenants",
 	}
-	networkAPIKeyListCmd = &cobra.Command{
+	networkAPIKeyListCmd := &cobra.Command{
 		Use:     "list",
 		Aliases: []string{"ls"},
 		Short:   "List API keys",
@@ -54,26 +53,28 @@ var (
 			if hasClusterID {
 				req.ClusterId = wrapperspb.String(endpoint.ClusterID)
 			}
-			res, err := iampbv2.NewNetworkAPIKeyVaultClient(conn).ListAPIKeys(ctx, req)
+			res, err := iampbv2.NewNetworkAPIKeyVaultClient(st.conn).ListAPIKeys(st.ctx, req)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(tabout, "Key ID\tNetID\tTenant ID\tCluster ID\tRights\tState\tLast Used\t")
-			for _, t := range res.Keys {
-				fmt.Fprintf(tabout, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
-					t.GetKeyId(),
-					packetbroker.NetID(t.GetNetId()),
-					t.GetTenantId(),
-					t.GetClusterId(),
-					column.Rights(t.GetRights()),
-					t.GetState(),
-					(*column.TimeSince)(t.GetAuthenticatedAt()),
-				)
-			}
-			return nil
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("apiKeys", res.Keys), func(w io.Writer) error {
+				fmt.Fprintln(w, "Key ID\tNetID\tTenant ID\tCluster ID\tRights\tState\tLast Used\t")
+				for _, t := range res.Keys {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+						t.GetKeyId(),
+						packetbroker.NetID(t.GetNetId()),
+						t.GetTenantId(),
+						t.GetClusterId(),
+						column.Rights(t.GetRights()),
+						t.GetState(),
+						(*column.TimeSince)(t.GetAuthenticatedAt()),
+					)
+				}
+				return nil
+			})
 		},
 	}
-	networkAPIKeyCreateCmd = &cobra.Command{
+	networkAPIKeyCreateCmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create an API key",
 		Long: `Create an API key for a network or tenant, optionally for a named cluster.
@@ -116,28 +117,37 @@ Rights:
 				Rights:    pbflag.GetAPIKeyRights(cmd.Flags()),
 			}
 			if promptKey, _ := cmd.Flags().GetBool("prompt-key"); promptKey {
-				fmt.Fprint(os.Stdout, "Secret key: ")
-				keyBuf, err := term.ReadPassword(int(os.Stdin.Fd()))
+				fmt.Fprint(st.Stdout, "Secret key: ")
+				key, err := st.readPassword()
 				if err != nil {
 					return err
 				}
-				req.Key = string(keyBuf)
+				req.Key = key
 			}
-			res, err := iampbv2.NewNetworkAPIKeyVaultClient(conn).CreateAPIKey(ctx, req)
+			res, err := iampbv2.NewNetworkAPIKeyVaultClient(st.conn).CreateAPIKey(st.ctx, req)
 			if err != nil {
 				return err
 			}
 			if save, _ := cmd.Flags().GetBool("save"); save {
-				viper.Set("client-id", res.Key.GetKeyId())
-				viper.Set("client-secret", res.Key.GetKey())
-				if err := viper.SafeWriteConfig(); err != nil {
+				clientSecret, err := st.config.StoreSecret(res.Key.GetKeyId(), res.Key.GetKey())
+				if err != nil {
+					// The API key is created, so show the secret key as it cannot be retrieved later.
+					fmt.Fprintf(st.Stderr, "Store the secret key %s of API key %s now in a secure place, as it cannot be retrieved later.\n",
+						res.Key.GetKey(), res.Key.GetKeyId())
+					return err
+				}
+				path, err := st.config.SetSettings(map[string]string{
+					"client-id":     res.Key.GetKeyId(),
+					"client-secret": clientSecret,
+				})
+				if err != nil {
 					return err
 				}
-				fmt.Fprintln(os.Stderr, "Saved to API key to .pb.yaml")
+				fmt.Fprintf(st.Stderr, "Saved API key to %s\n", path)
 			} else {
-				fmt.Fprintln(os.Stderr, "Store the API key now in a secure place, as it cannot be retrieved later.")
+				fmt.Fprintln(st.Stderr, "Store the API key now in a secure place, as it cannot be retrieved later.")
 			}
-			return column.WriteKV(tabout,
+			return column.WriteKV(st.tabout,
 				"Key ID", res.Key.GetKeyId(),
 				"Secret Key", res.Key.GetKey(),
 				"NetID", packetbroker.NetID(res.Key.GetNetId()).String(),
@@ -148,7 +158,7 @@ Rights:
 			)
 		},
 	}
-	networkAPIKeyUpdateStateCmd = &cobra.Command{
+	networkAPIKeyUpdateStateCmd := &cobra.Command{
 		Use:   "update-state",
 		Short: "Update the API key state",
 		Example: `
@@ -157,14 +167,14 @@ Rights:
 		RunE: func(cmd *cobra.Command, args []string) error {
 			keyID, _ := cmd.Flags().GetString("key-id")
 			state := pbflag.GetAPIKeyState(cmd.Flags(), "state")
-			_, err := iampbv2.NewNetworkAPIKeyVaultClient(conn).UpdateAPIKeyState(ctx, &iampbv2.UpdateAPIKeyStateRequest{
+			_, err := iampbv2.NewNetworkAPIKeyVaultClient(st.conn).UpdateAPIKeyState(st.ctx, &iampbv2.UpdateAPIKeyStateRequest{
 				KeyId: keyID,
 				State: state,
 			})
 			return err
 		},
 	}
-	networkAPIKeyDeleteCmd = &cobra.Command{
+	networkAPIKeyDeleteCmd := &cobra.Command{
 		Use:     "delete",
 		Aliases: []string{"rm"},
 		Short:   "Delete an API key",
@@ -173,16 +183,12 @@ Rights:
     $ pbadmin network apikey delete --key-id C5232IFFX4UKEELB`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			keyID, _ := cmd.Flags().GetString("key-id")
-			_, err := iampbv2.NewNetworkAPIKeyVaultClient(conn).DeleteAPIKey(ctx, &iampbv2.APIKeyRequest{
+			_, err := iampbv2.NewNetworkAPIKeyVaultClient(st.conn).DeleteAPIKey(st.ctx, &iampbv2.APIKeyRequest{
 				KeyId: keyID,
 			})
 			return err
 		},
 	}
-)
-
-func init() {
-	networkCmd.AddCommand(networkAPIKeyCmd)
 
 	networkAPIKeyListCmd.Flags().AddFlagSet(pbflag.Endpoint(""))
 	networkAPIKeyCmd.AddCommand(networkAPIKeyListCmd)
@@ -190,7 +196,7 @@ func init() {
 	networkAPIKeyCreateCmd.Flags().AddFlagSet(pbflag.Endpoint(""))
 	networkAPIKeyCreateCmd.Flags().AddFlagSet(pbflag.APIKeyRights())
 	networkAPIKeyCreateCmd.Flags().Bool("prompt-key", false, "prompt custom secret key value")
-	networkAPIKeyCreateCmd.Flags().Bool("save", false, "save the API key to configuration")
+	networkAPIKeyCreateCmd.Flags().Bool("save", false, "save the API key to the configuration file (in the active profile, if any)")
 	networkAPIKeyCmd.AddCommand(networkAPIKeyCreateCmd)
 
 	networkAPIKeyUpdateStateCmd.Flags().String("key-id", "", "API key ID")
@@ -199,4 +205,6 @@ func init() {
 
 	networkAPIKeyDeleteCmd.Flags().String("key-id", "", "API key ID")
 	networkAPIKeyCmd.AddCommand(networkAPIKeyDeleteCmd)
+
+	return networkAPIKeyCmd
 }
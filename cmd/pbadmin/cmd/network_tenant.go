@@ -3,7 +3,9 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
 
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -11,39 +13,40 @@ import (
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/column"
 	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/lorawan"
+	"go.packetbroker.org/pb/pkg/sdk"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 )
 
-var (
-	networkTenantCmd = &cobra.Command{
+func (st *state) newNetworkTenantCommand() *cobra.Command {
+	networkTenantCmd := &cobra.Command{
 		Use:     "tenant",
 		Aliases: []string{"tenants", "tnt", "tnts", "t"},
 		Short:   "Manage Packet Broker tenants",
 	}
-	networkTenantListCmd = &cobra.Command{
+	networkTenantListCmd := &cobra.Command{
 		Use:     "list",
 		Aliases: []string{"ls"},
 		Short:   "List tenants",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				netID, _        = pbflag.GetNetID(cmd.Flags(), "")
-				offset          = uint32(0)
 				idContains, _   = cmd.Flags().GetString("id-contains")
 				nameContains, _ = cmd.Flags().GetString("name-contains")
 			)
-			fmt.Fprintln(tabout, "NetID\tTenant ID\tAuthority\tName\tDevAddr Blocks\tListed\tTarget\t")
-			for {
-				res, err := iampb.NewTenantRegistryClient(conn).ListTenants(ctx, &iampb.ListTenantsRequest{
-					NetId:            uint32(netID),
-					Offset:           offset,
-					TenantIdContains: idContains,
-					NameContains:     nameContains,
-				})
-				if err != nil {
-					return err
-				}
-				for _, t := range res.Tenants {
-					fmt.Fprintf(tabout, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+			tenants, err := sdk.NewIAM(st.conn).ListAllTenants(st.ctx, &iampb.ListTenantsRequest{
+				NetId:            uint32(netID),
+				TenantIdContains: idContains,
+				NameContains:     nameContains,
+			}).All()
+			if err != nil {
+				return err
+			}
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("tenants", tenants), func(w io.Writer) error {
+				fmt.Fprintln(w, "NetID\tTenant ID\tAuthority\tName\tDevAddr Blocks\tListed\tTarget\t")
+				for _, t := range tenants {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
 						packetbroker.NetID(t.GetNetId()),
 						t.GetTenantId(),
 						t.GetAuthority(),
@@ -53,15 +56,11 @@ var (
 						(*column.Target)(t.GetTarget()),
 					)
 				}
-				offset += uint32(len(res.Tenants))
-				if len(res.Tenants) == 0 || offset >= res.Total {
-					break
-				}
-			}
-			return nil
+				return nil
+			})
 		},
 	}
-	networkTenantCreateCmd = &cobra.Command{
+	networkTenantCreateCmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create a tenant",
 		Example: `
@@ -91,7 +90,7 @@ var (
 			if err := pbflag.ApplyToTarget(cmd.Flags(), "target", &target); err != nil {
 				return err
 			}
-			res, err := iampb.NewTenantRegistryClient(conn).CreateTenant(ctx, &iampb.CreateTenantRequest{
+			res, err := iampb.NewTenantRegistryClient(st.conn).CreateTenant(st.ctx, &iampb.CreateTenantRequest{
 				Tenant: &packetbroker.Tenant{
 					NetId:                 uint32(tenantID.NetID),
 					TenantId:              tenantID.ID,
@@ -106,10 +105,12 @@ var (
 			if err != nil {
 				return err
 			}
-			return column.WriteTenant(tabout, res.Tenant, false)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(res.Tenant, func(w io.Writer) error {
+				return column.WriteTenant(w, res.Tenant, false)
+			})
 		},
 	}
-	networkTenantGetCmd = &cobra.Command{
+	networkTenantGetCmd := &cobra.Command{
 		Use:   "get",
 		Short: "Get a tenant",
 		Example: `
@@ -117,7 +118,7 @@ var (
     $ pbadmin network tenant get --net-id 000013 --tenant-id tti`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			tenantID, _ := pbflag.GetTenantID(cmd.Flags(), "")
-			res, err := iampb.NewTenantRegistryClient(conn).GetTenant(ctx, &iampb.TenantRequest{
+			res, err := iampb.NewTenantRegistryClient(st.conn).GetTenant(st.ctx, &iampb.TenantRequest{
 				NetId:    uint32(tenantID.NetID),
 				TenantId: tenantID.ID,
 			})
@@ -125,10 +126,12 @@ var (
 				return err
 			}
 			verbose, _ := cmd.Flags().GetBool("verbose")
-			return column.WriteTenant(tabout, res.Tenant, verbose)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(res.Tenant, func(w io.Writer) error {
+				return column.WriteTenant(w, res.Tenant, verbose)
+			})
 		},
 	}
-	networkTenantUpdateCmd = &cobra.Command{
+	networkTenantUpdateCmd := &cobra.Command{
 		Use:     "update",
 		Aliases: []string{"up"},
 		Short:   "Update a tenant",
@@ -142,8 +145,8 @@ var (
       --dev-addr-blocks 26011000/20=eu1,26012000=eu2`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			tenantID, _ := pbflag.GetTenantID(cmd.Flags(), "")
-			client := iampb.NewTenantRegistryClient(conn)
-			tnt, err := client.GetTenant(ctx, &iampb.TenantRequest{
+			client := iampb.NewTenantRegistryClient(st.conn)
+			tnt, err := client.GetTenant(st.ctx, &iampb.TenantRequest{
 				NetId:    uint32(tenantID.NetID),
 				TenantId: tenantID.ID,
 			})
@@ -152,7 +155,7 @@ var (
 			}
 			if cmd.Flags().Changed("listed") {
 				listed, _ := cmd.Flags().GetBool("listed")
-				_, err := client.UpdateTenantListed(ctx, &iampb.UpdateTenantListedRequest{
+				_, err := client.UpdateTenantListed(st.ctx, &iampb.UpdateTenantListedRequest{
 					NetId:    uint32(tenantID.NetID),
 					TenantId: tenantID.ID,
 					Listed:   listed,
@@ -196,13 +199,13 @@ var (
 				any = true
 			}
 			if any {
-				_, err = client.UpdateTenant(ctx, req)
+				_, err = client.UpdateTenant(st.ctx, req)
 				return err
 			}
 			return nil
 		},
 	}
-	networkTenantUpdateTargetCmd = &cobra.Command{
+	networkTenantUpdateTargetCmd := &cobra.Command{
 		Use:   "target",
 		Short: "Update a tenant target",
 		Example: `
@@ -227,8 +230,8 @@ var (
       --root-cas-file ca.pem --tls-cert-file key.pem --tls-key-file key.pem`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			tenantID, _ := pbflag.GetTenantID(cmd.Flags(), "")
-			client := iampb.NewTenantRegistryClient(conn)
-			tnt, err := client.GetTenant(ctx, &iampb.TenantRequest{
+			client := iampb.NewTenantRegistryClient(st.conn)
+			tnt, err := client.GetTenant(st.ctx, &iampb.TenantRequest{
 				NetId:    uint32(tenantID.NetID),
 				TenantId: tenantID.ID,
 			})
@@ -246,11 +249,132 @@ var (
 					Value: target,
 				},
 			}
-			_, err = client.UpdateTenant(ctx, req)
+			_, err = client.UpdateTenant(st.ctx, req)
 			return err
 		},
 	}
-	networkTenantDeleteCmd = &cobra.Command{
+	networkTenantAllocateCmd := &cobra.Command{
+		Use:   "allocate",
+		Short: "Allocate DevAddr blocks to a tenant",
+		Long: `Allocate free DevAddr blocks of the given length within the DevAddr blocks of
+the network, or within the DevAddr range of the NetID if the network has no
+DevAddr blocks.
+
+The DevAddr blocks of all tenants of the network, including the tenant itself,
+are in use. The free blocks are allocated in ascending order. If a tenant ID is passed, the
+tenant is created with the allocated blocks, or the allocated blocks are added
+to the existing tenant.`,
+		Example: `
+  Show the first free /16 block:
+    $ pbadmin network tenant allocate --net-id 000013 --length 16
+
+  Allocate two /20 blocks to tenant and cluster:
+    $ pbadmin network tenant allocate --net-id 000013 --tenant-id tti \
+      --length 20 --count 2 --cluster-id eu1`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tenantID, ok := pbflag.GetTenantID(cmd.Flags(), "")
+			if !ok {
+				return errors.New("pass the NetID via --net-id")
+			}
+			length, _ := cmd.Flags().GetUint8("length")
+			count, _ := cmd.Flags().GetInt("count")
+			clusterID, _ := cmd.Flags().GetString("cluster-id")
+			within := lorawan.NetID(tenantID.NetID).DevAddrPrefix()
+			if length < within.Length || length > 32 {
+				return fmt.Errorf("length must be between %d and 32 for NetID %s", within.Length, tenantID.NetID)
+			}
+			if count < 1 {
+				return errors.New("count must be at least 1")
+			}
+
+			nwk, err := iampb.NewNetworkRegistryClient(st.conn).GetNetwork(st.ctx, &iampb.NetworkRequest{
+				NetId: uint32(tenantID.NetID),
+			})
+			if err != nil {
+				return err
+			}
+			// Tenants get DevAddr blocks from the network's own blocks, so these are not in use.
+			ranges := []lorawan.DevAddrPrefix{within}
+			if blocks := nwk.Network.GetDevAddrBlocks(); len(blocks) > 0 {
+				ranges = make([]lorawan.DevAddrPrefix, len(blocks))
+				for i, b := range blocks {
+					ranges[i] = lorawan.FromDevAddrPrefix(b.GetPrefix())
+				}
+				ranges = lorawan.AggregateDevAddrPrefixes(ranges)
+			}
+			var (
+				used    []lorawan.DevAddrPrefix
+				client  = iampb.NewTenantRegistryClient(st.conn)
+				current *packetbroker.Tenant
+				it      = sdk.NewIAM(st.conn).ListAllTenants(st.ctx, &iampb.ListTenantsRequest{
+					NetId: uint32(tenantID.NetID),
+				})
+			)
+			for it.Next() {
+				t := it.Value()
+				if tenantID.ID != "" && t.GetTenantId() == tenantID.ID {
+					current = t
+				}
+				for _, b := range t.GetDevAddrBlocks() {
+					used = append(used, lorawan.FromDevAddrPrefix(b.GetPrefix()))
+				}
+			}
+			if err := it.Err(); err != nil {
+				return err
+			}
+
+			var free []lorawan.DevAddrPrefix
+			for _, r := range ranges {
+				free = append(free, freeDevAddrPrefixes(r, used, length, count-len(free))...)
+			}
+			if len(free) < count {
+				return fmt.Errorf("%d free DevAddr blocks of length %d in %v, requested %d", len(free), length, ranges, count)
+			}
+			blocks := make([]*packetbroker.DevAddrBlock, len(free))
+			for i, p := range free {
+				blocks[i] = &packetbroker.DevAddrBlock{
+					Prefix:               p.Proto(),
+					HomeNetworkClusterId: clusterID,
+				}
+			}
+
+			switch {
+			case tenantID.ID == "":
+			case current == nil:
+				_, err := client.CreateTenant(st.ctx, &iampb.CreateTenantRequest{
+					Tenant: &packetbroker.Tenant{
+						NetId:         uint32(tenantID.NetID),
+						TenantId:      tenantID.ID,
+						DevAddrBlocks: blocks,
+					},
+				})
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(st.Stderr, "Created tenant %s\n", tenantID)
+			default:
+				_, err := client.UpdateTenant(st.ctx, &iampb.UpdateTenantRequest{
+					NetId:    uint32(tenantID.NetID),
+					TenantId: tenantID.ID,
+					DevAddrBlocks: &iampb.DevAddrBlocksValue{
+						Value: mergeDevAddrBlocks(current.DevAddrBlocks, blocks, nil),
+					},
+				})
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(st.Stderr, "Updated tenant %s\n", tenantID)
+			}
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("devAddrBlocks", blocks), func(w io.Writer) error {
+				fmt.Fprintln(w, "DevAddr Prefix\tCluster ID\t")
+				for _, b := range blocks {
+					fmt.Fprintf(w, "%08X/%d\t%s\t\n", b.Prefix.Value, b.Prefix.Length, b.HomeNetworkClusterId)
+				}
+				return nil
+			})
+		},
+	}
+	networkTenantDeleteCmd := &cobra.Command{
 		Use:     "delete",
 		Aliases: []string{"rm"},
 		Short:   "Delete a tenant",
@@ -259,22 +383,22 @@ var (
     $ pbadmin network tenant delete --net-id 000013 --tenant-id tti`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			tenantID, _ := pbflag.GetTenantID(cmd.Flags(), "")
-			_, err := iampb.NewTenantRegistryClient(conn).DeleteTenant(ctx, &iampb.TenantRequest{
+			_, err := iampb.NewTenantRegistryClient(st.conn).DeleteTenant(st.ctx, &iampb.TenantRequest{
 				NetId:    uint32(tenantID.NetID),
 				TenantId: tenantID.ID,
 			})
 			return err
 		},
 	}
-	networkTenantDeleteTargetCmd = &cobra.Command{
+	networkTenantDeleteTargetCmd := &cobra.Command{
 		Use:   "target",
 		Short: "Delete a tenant target",
 		Example: `
   Delete a tenant target:
-    $ pbadmin network delete target --net-id 000013 --tenant-id tti`,
+    $ pbadmin network tenant delete target --net-id 000013 --tenant-id tti`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			tenantID, _ := pbflag.GetTenantID(cmd.Flags(), "")
-			client := iampb.NewTenantRegistryClient(conn)
+			client := iampb.NewTenantRegistryClient(st.conn)
 			req := &iampb.UpdateTenantRequest{
 				NetId:    uint32(tenantID.NetID),
 				TenantId: tenantID.ID,
@@ -282,21 +406,10 @@ var (
 					Value: nil,
 				},
 			}
-			_, err := client.UpdateTenant(ctx, req)
+			_, err := client.UpdateTenant(st.ctx, req)
 			return err
 		},
 	}
-)
-
-func tenantSettingsFlags() *flag.FlagSet {
-	flags := new(flag.FlagSet)
-	flags.String("name", "", "tenant name")
-	flags.Bool("listed", false, "list tenant in catalog")
-	return flags
-}
-
-func init() {
-	networkCmd.AddCommand(networkTenantCmd)
 
 	networkTenantListCmd.Flags().AddFlagSet(pbflag.NetID(""))
 	networkTenantListCmd.Flags().String("id-contains", "", "filter tenants by ID")
@@ -325,6 +438,23 @@ func init() {
 	networkTenantUpdateCmd.AddCommand(networkTenantUpdateTargetCmd)
 	networkTenantCmd.AddCommand(networkTenantUpdateCmd)
 
+	networkTenantAllocateCmd.Flags().AddFlagSet(pbflag.TenantID(""))
+	networkTenantAllocateCmd.Flags().Uint8("length", 0, "length of the DevAddr blocks")
+	networkTenantAllocateCmd.Flags().Int("count", 1, "number of DevAddr blocks")
+	networkTenantAllocateCmd.Flags().String("cluster-id", "", "Home Network cluster ID of the DevAddr blocks")
+	networkTenantCmd.AddCommand(networkTenantAllocateCmd)
+
 	networkTenantDeleteCmd.Flags().AddFlagSet(pbflag.TenantID(""))
+	networkTenantDeleteTargetCmd.Flags().AddFlagSet(pbflag.TenantID(""))
+	networkTenantDeleteCmd.AddCommand(networkTenantDeleteTargetCmd)
 	networkTenantCmd.AddCommand(networkTenantDeleteCmd)
+
+	return networkTenantCmd
+}
+
+func tenantSettingsFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.String("name", "", "tenant name")
+	flags.Bool("listed", false, "list tenant in catalog")
+	return flags
 }
@@ -3,96 +3,164 @@
 package cmd
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"text/tabwriter"
 
-	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"go.packetbroker.org/pb/cmd/internal/config"
 	"go.packetbroker.org/pb/cmd/internal/gen"
 	"go.packetbroker.org/pb/cmd/internal/logging"
+	"go.packetbroker.org/pb/cmd/internal/printer"
 	"go.packetbroker.org/pb/pkg/client"
 	"go.uber.org/zap"
+	"golang.org/x/term"
 	"google.golang.org/grpc"
 )
 
-var (
+// Options configures the root command. Zero values use the standard streams, a logger writing to standard error and
+// client.DialContext.
+type Options struct {
+	// Stdin is the input of prompted secrets. If Stdin is a terminal, the input is not echoed.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Logger is the logger. If nil, a logger is created that writes to Stderr, considering the debug flag.
+	Logger *zap.Logger
+	// Dial dials Packet Broker. If nil, client.DialContext is used.
+	Dial client.DialFunc
+}
+
+type state struct {
+	Options
 	cfgFile string
 	debug   bool
 
-	ctx    = context.Background()
+	ctx    context.Context
+	config *config.Config
 	logger *zap.Logger
 	conn   *grpc.ClientConn
-	tabout = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-)
+	tabout *tabwriter.Writer
+}
 
-func prerunConnect(cmd *cobra.Command, args []string) error {
-	clientConf, err := config.AutomaticClient(ctx, "iam", config.BasicAuthIAM, "networks")
+func (st *state) preRun(cmd *cobra.Command, args []string) error {
+	st.ctx = cmd.Context()
+	var err error
+	if st.config, err = config.Init(st.cfgFile, cmd.Root().PersistentFlags()); err != nil {
+		return err
+	}
+	st.logger = st.Logger
+	if st.logger == nil {
+		st.logger = logging.NewLogger(st.Stderr, st.debug)
+	}
+	return nil
+}
+
+func (st *state) prerunConnect(cmd *cobra.Command, args []string) error {
+	if err := st.preRun(cmd, args); err != nil {
+		return err
+	}
+	clientConf, err := st.config.AutomaticClient(st.ctx, "iam", config.BasicAuthIAM, "networks")
 	if err != nil {
 		return err
 	}
-	conn, err = client.DialContext(ctx, logger, clientConf, 443)
+	st.conn, err = st.Dial(st.ctx, st.logger, clientConf, 443)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func postrunConnect(cmd *cobra.Command, args []string) {
-	conn.Close()
+func (st *state) postrunConnect(cmd *cobra.Command, args []string) {
+	st.conn.Close()
 }
 
-var rootCmd = &cobra.Command{
-	Use:          "pbadmin",
-	Short:        "pbadmin can be used to manage networks, tenants and API keys.",
-	SilenceUsage: true,
+// readPassword reads a line from Stdin. If Stdin is a terminal, the input is not echoed.
+func (st *state) readPassword() (string, error) {
+	if f, ok := st.Stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		buf, err := term.ReadPassword(int(f.Fd()))
+		return string(buf), err
+	}
+	line, err := bufio.NewReader(st.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
 }
 
-// Execute runs pbadmin.
-func Execute() {
-	logger = logging.GetLogger(debug)
-	defer logger.Sync()
-	defer tabout.Flush()
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+// flushOutput flushes the tabular output and the logger after running the command and its subcommands.
+func (st *state) flushOutput(cmd *cobra.Command) {
+	if run := cmd.RunE; run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			defer st.logger.Sync()
+			defer st.tabout.Flush()
+			return run(cmd, args)
+		}
+	}
+	for _, c := range cmd.Commands() {
+		st.flushOutput(c)
 	}
 }
 
-func init() {
-	cobra.OnInitialize(initConfig)
+// NewRootCommand returns a new pbadmin command. Each command has its own configuration, so commands can be executed
+// concurrently. A command must not be executed concurrently with itself.
+func NewRootCommand(opts Options) *cobra.Command {
+	if opts.Stdin == nil {
+		opts.Stdin = os.Stdin
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+	if opts.Dial == nil {
+		opts.Dial = client.DialContext
+	}
+	st := &state{
+		Options: opts,
+		ctx:     context.Background(),
+		tabout:  tabwriter.NewWriter(opts.Stdout, 0, 0, 3, ' ', 0),
+	}
+
+	rootCmd := &cobra.Command{
+		Use:               "pbadmin",
+		Short:             "pbadmin can be used to manage networks, tenants and API keys.",
+		SilenceUsage:      true,
+		PersistentPreRunE: st.preRun,
+	}
+	rootCmd.SetIn(opts.Stdin)
+	rootCmd.SetOut(opts.Stdout)
+	rootCmd.SetErr(opts.Stderr)
 
 	rootCmd.PersistentFlags().AddFlagSet(config.ClientFlags("iam", "iam.packetbroker.net:443"))
+	rootCmd.PersistentFlags().AddFlagSet(config.InsecureFlags())
 	rootCmd.PersistentFlags().AddFlagSet(config.BasicAuthClientFlags(config.BasicAuthIAM))
 	rootCmd.PersistentFlags().AddFlagSet(config.OAuth2ClientFlags())
+	rootCmd.PersistentFlags().AddFlagSet(config.SecretFlags())
+
+	rootCmd.PersistentFlags().AddFlagSet(printer.Flags())
+	rootCmd.PersistentFlags().AddFlagSet(config.ProfileFlags())
+	rootCmd.PersistentFlags().StringVar(&st.cfgFile, "config", "", "config file (default is .pb.yaml, $HOME/.pb.yaml)")
+	rootCmd.PersistentFlags().BoolVarP(&st.debug, "debug", "d", false, "debug mode")
 
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is .pb.yaml, $HOME/.pb.yaml)")
-	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug mode")
+	rootCmd.AddCommand(st.newClusterCommand())
+	rootCmd.AddCommand(st.newConfigCommand())
+	rootCmd.AddCommand(st.newJoinServerCommand())
+	rootCmd.AddCommand(st.newNetworkCommand())
+	rootCmd.AddCommand(gen.NewCommand())
 
-	rootCmd.AddCommand(gen.Cmd)
+	st.flushOutput(rootCmd)
+	return rootCmd
 }
 
-func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := homedir.Dir()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
-		viper.AddConfigPath(".")
-		viper.AddConfigPath(home)
-		viper.SetConfigName(".pb")
-		viper.SetConfigType("yaml")
+// Execute runs pbadmin.
+func Execute() {
+	if err := NewRootCommand(Options{}).Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("pb")
-	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-	viper.ReadInConfig()
 }
@@ -2,11 +2,14 @@
 
 package cmd
 
-import packetbroker "go.packetbroker.org/api/v3"
+import (
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/pkg/lorawan"
+)
 
 func mergeDevAddrBlocks(current, add, remove []*packetbroker.DevAddrBlock) []*packetbroker.DevAddrBlock {
 	equals := func(x, y *packetbroker.DevAddrBlock) bool {
-		return x.Prefix.Value == y.Prefix.Value && x.Prefix.Length == y.Prefix.Length
+		return lorawan.FromDevAddrPrefix(x.Prefix) == lorawan.FromDevAddrPrefix(y.Prefix)
 	}
 	for _, a := range add {
 		var found bool
@@ -36,3 +39,21 @@ func mergeDevAddrBlocks(current, add, remove []*packetbroker.DevAddrBlock) []*pa
 	}
 	return res
 }
+
+// freeDevAddrPrefixes returns at most count prefixes of the length within the prefix that do not overlap with the used
+// prefixes. The prefixes are returned in ascending order.
+func freeDevAddrPrefixes(within lorawan.DevAddrPrefix, used []lorawan.DevAddrPrefix, length uint8, count int) []lorawan.DevAddrPrefix {
+	var res []lorawan.DevAddrPrefix
+	for _, free := range lorawan.SubtractDevAddrPrefixes(within, used) {
+		if free.Length > length {
+			continue
+		}
+		for i := uint64(0); i < 1<<(length-free.Length) && len(res) < count; i++ {
+			res = append(res, lorawan.DevAddrPrefix{
+				Value:  free.Value | lorawan.DevAddr(i<<(32-length)),
+				Length: length,
+			})
+		}
+	}
+	return res
+}
@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/pkg/lorawan"
 )
 
 func TestMergeDevAddrBlocks(t *testing.T) {
@@ -89,3 +90,73 @@ func TestMergeDevAddrBlocks(t *testing.T) {
 		})
 	}
 }
+
+func TestFreeDevAddrPrefixes(t *testing.T) {
+	prefix := func(value lorawan.DevAddr, length uint8) lorawan.DevAddrPrefix {
+		return lorawan.DevAddrPrefix{Value: value, Length: length}
+	}
+	for i, tc := range []struct {
+		within   lorawan.DevAddrPrefix
+		used     []lorawan.DevAddrPrefix
+		length   uint8
+		count    int
+		expected []lorawan.DevAddr
+	}{
+		{
+			within:   prefix(0x26000000, 7),
+			length:   16,
+			count:    2,
+			expected: []lorawan.DevAddr{0x26000000, 0x26010000},
+		},
+		{
+			within: prefix(0x26000000, 7),
+			used: []lorawan.DevAddrPrefix{
+				prefix(0x26000000, 16),
+				prefix(0x26011000, 20),
+				prefix(0x48000000, 8),
+			},
+			length:   20,
+			count:    3,
+			expected: []lorawan.DevAddr{0x26010000, 0x26012000, 0x26013000},
+		},
+		{
+			within: prefix(0x26000000, 7),
+			used: []lorawan.DevAddrPrefix{
+				prefix(0x26000000, 8),
+				prefix(0x27000000, 9),
+			},
+			length:   16,
+			count:    1,
+			expected: []lorawan.DevAddr{0x27800000},
+		},
+		{
+			within: prefix(0x26000000, 7),
+			used: []lorawan.DevAddrPrefix{
+				prefix(0x26000000, 7),
+			},
+			length: 16,
+			count:  1,
+		},
+		{
+			within: prefix(0x27FFFFF0, 28),
+			used: []lorawan.DevAddrPrefix{
+				prefix(0x27FFFFFF, 32),
+			},
+			length:   31,
+			count:    10,
+			expected: []lorawan.DevAddr{0x27FFFFF0, 0x27FFFFF2, 0x27FFFFF4, 0x27FFFFF6, 0x27FFFFF8, 0x27FFFFFA, 0x27FFFFFC},
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res := freeDevAddrPrefixes(tc.within, tc.used, tc.length, tc.count)
+			if len(res) != len(tc.expected) {
+				t.Fatalf("expected %d prefixes, got %d", len(tc.expected), len(res))
+			}
+			for j, p := range res {
+				if p.Value != tc.expected[j] || p.Length != tc.length {
+					t.Fatalf("expected %s/%d at %d, got %s", tc.expected[j], tc.length, j, p)
+				}
+			}
+		})
+	}
+}
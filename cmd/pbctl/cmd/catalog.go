@@ -5,12 +5,15 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 
 	"github.com/spf13/cobra"
 	iampb "go.packetbroker.org/api/iam/v2"
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/column"
 	"go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/sdk"
 )
 
 type network interface {
@@ -24,22 +27,42 @@ type homeNetwork struct {
 	tenantID string
 }
 
-var (
-	catalogCmd = &cobra.Command{
+func writeNetworks(w io.Writer, networks []*packetbroker.NetworkOrTenant) error {
+	fmt.Fprintln(w, "NetID\tTenant ID\tName\tDevAddr Blocks\t")
+	for _, hn := range networks {
+		var row homeNetwork
+		if nwk := hn.GetNetwork(); nwk != nil {
+			row.network = nwk
+			row.tenantID = "-"
+		} else if tnt := hn.GetTenant(); tnt != nil {
+			row.network = tnt
+			row.tenantID = tnt.GetTenantId()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n",
+			packetbroker.NetID(row.GetNetId()),
+			row.tenantID,
+			row.GetName(),
+			column.DevAddrBlocks(row.GetDevAddrBlocks()),
+		)
+	}
+	return nil
+}
+
+func (st *state) newCatalogCommand() *cobra.Command {
+	catalogCmd := &cobra.Command{
 		Use:               "catalog",
 		Aliases:           []string{"cat"},
 		Short:             "Packet Broker catalog",
-		PersistentPreRunE: prerunConnect,
-		PersistentPostRun: postrunConnect,
+		PersistentPreRunE: st.prerunConnect,
+		PersistentPostRun: st.postrunConnect,
 	}
-	catalogNetworksCmd = &cobra.Command{
+	catalogNetworksCmd := &cobra.Command{
 		Use:     "networks",
 		Aliases: []string{"network", "ns"},
 		Short:   "Show Forwarders and Home Networks",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				tenantID, _       = pbflag.GetTenantID(cmd.Flags(), "")
-				offset            = uint32(0)
 				idContains, _     = cmd.Flags().GetString("id-contains")
 				nameContains, _   = cmd.Flags().GetString("name-contains")
 				policyTenantID, _ = pbflag.GetTenantID(cmd.Flags(), "policy")
@@ -54,51 +77,28 @@ var (
 					TenantId: policyTenantID.ID,
 				}
 			}
-			fmt.Fprintln(tabout, "NetID\tTenant ID\tName\tDevAddr Blocks\t")
-			for {
-				res, err := iampb.NewCatalogClient(iamConn).ListNetworks(ctx, &iampb.ListNetworksRequest{
-					NetId:            uint32(tenantID.NetID),
-					TenantId:         tenantID.ID,
-					Offset:           offset,
-					TenantIdContains: idContains,
-					NameContains:     nameContains,
-					PolicyReference:  policyRef,
-				})
-				if err != nil {
-					return err
-				}
-				for _, hn := range res.Networks {
-					var row homeNetwork
-					if nwk := hn.GetNetwork(); nwk != nil {
-						row.network = nwk
-						row.tenantID = "-"
-					} else if tnt := hn.GetTenant(); tnt != nil {
-						row.network = tnt
-						row.tenantID = tnt.GetTenantId()
-					}
-					fmt.Fprintf(tabout, "%s\t%s\t%s\t%s\t\n",
-						packetbroker.NetID(row.GetNetId()),
-						row.tenantID,
-						row.GetName(),
-						column.DevAddrBlocks(row.GetDevAddrBlocks()),
-					)
-				}
-				offset += uint32(len(res.Networks))
-				if len(res.Networks) == 0 || offset >= res.Total {
-					break
-				}
+			networks, err := sdk.NewIAM(st.iamConn).ListCatalogNetworks(st.ctx, &iampb.ListNetworksRequest{
+				NetId:            uint32(tenantID.NetID),
+				TenantId:         tenantID.ID,
+				TenantIdContains: idContains,
+				NameContains:     nameContains,
+				PolicyReference:  policyRef,
+			}).All()
+			if err != nil {
+				return err
 			}
-			return nil
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("networks", networks), func(w io.Writer) error {
+				return writeNetworks(w, networks)
+			})
 		},
 	}
-	catalogHomeNetworksCmd = &cobra.Command{
+	catalogHomeNetworksCmd := &cobra.Command{
 		Use:     "home-networks",
 		Aliases: []string{"home-network", "hns"},
 		Short:   "Show Home Networks",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				tenantID, _       = pbflag.GetTenantID(cmd.Flags(), "")
-				offset            = uint32(0)
 				idContains, _     = cmd.Flags().GetString("id-contains")
 				nameContains, _   = cmd.Flags().GetString("name-contains")
 				policyTenantID, _ = pbflag.GetTenantID(cmd.Flags(), "policy")
@@ -113,80 +113,46 @@ var (
 					TenantId: policyTenantID.ID,
 				}
 			}
-			fmt.Fprintln(tabout, "NetID\tTenant ID\tName\tDevAddr Blocks\t")
-			for {
-				res, err := iampb.NewCatalogClient(iamConn).ListHomeNetworks(ctx, &iampb.ListNetworksRequest{
-					NetId:            uint32(tenantID.NetID),
-					TenantId:         tenantID.ID,
-					Offset:           offset,
-					TenantIdContains: idContains,
-					NameContains:     nameContains,
-					PolicyReference:  policyRef,
-				})
-				if err != nil {
-					return err
-				}
-				for _, hn := range res.Networks {
-					var row homeNetwork
-					if nwk := hn.GetNetwork(); nwk != nil {
-						row.network = nwk
-						row.tenantID = "-"
-					} else if tnt := hn.GetTenant(); tnt != nil {
-						row.network = tnt
-						row.tenantID = tnt.GetTenantId()
-					}
-					fmt.Fprintf(tabout, "%s\t%s\t%s\t%s\t\n",
-						packetbroker.NetID(row.GetNetId()),
-						row.tenantID,
-						row.GetName(),
-						column.DevAddrBlocks(row.GetDevAddrBlocks()),
-					)
-				}
-				offset += uint32(len(res.Networks))
-				if len(res.Networks) == 0 || offset >= res.Total {
-					break
-				}
+			networks, err := sdk.NewIAM(st.iamConn).ListCatalogHomeNetworks(st.ctx, &iampb.ListNetworksRequest{
+				NetId:            uint32(tenantID.NetID),
+				TenantId:         tenantID.ID,
+				TenantIdContains: idContains,
+				NameContains:     nameContains,
+				PolicyReference:  policyRef,
+			}).All()
+			if err != nil {
+				return err
 			}
-			return nil
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("networks", networks), func(w io.Writer) error {
+				return writeNetworks(w, networks)
+			})
 		},
 	}
-	catalogJoinServersCmd = &cobra.Command{
+	catalogJoinServersCmd := &cobra.Command{
 		Use:     "join-servers",
 		Aliases: []string{"join-server", "js"},
 		Short:   "Show Join Servers",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			var (
-				offset          = uint32(0)
-				nameContains, _ = cmd.Flags().GetString("name-contains")
-			)
-			fmt.Fprintln(tabout, "  ID\tName\tJoinEUI Prefixes\t")
-			for {
-				res, err := iampb.NewCatalogClient(iamConn).ListJoinServers(ctx, &iampb.ListJoinServersRequest{
-					Offset:       offset,
-					NameContains: nameContains,
-				})
-				if err != nil {
-					return err
-				}
-				for _, js := range res.JoinServers {
-					fmt.Fprintf(tabout, "%4d\t%s\t%s\t\n",
+			nameContains, _ := cmd.Flags().GetString("name-contains")
+			joinServers, err := sdk.NewIAM(st.iamConn).ListCatalogJoinServers(st.ctx, &iampb.ListJoinServersRequest{
+				NameContains: nameContains,
+			}).All()
+			if err != nil {
+				return err
+			}
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("joinServers", joinServers), func(w io.Writer) error {
+				fmt.Fprintln(w, "  ID\tName\tJoinEUI Prefixes\t")
+				for _, js := range joinServers {
+					fmt.Fprintf(w, "%4d\t%s\t%s\t\n",
 						js.GetId(),
 						js.GetName(),
 						column.JoinEUIPrefixes(js.GetJoinEuiPrefixes()),
 					)
 				}
-				offset += uint32(len(res.JoinServers))
-				if len(res.JoinServers) == 0 || offset >= res.Total {
-					break
-				}
-			}
-			return nil
+				return nil
+			})
 		},
 	}
-)
-
-func init() {
-	rootCmd.AddCommand(catalogCmd)
 
 	catalogNetworksCmd.Flags().AddFlagSet(pbflag.TenantID(""))
 	catalogNetworksCmd.Flags().String("id-contains", "", "filter tenants by ID")
@@ -202,4 +168,6 @@ func init() {
 
 	catalogJoinServersCmd.Flags().String("name-contains", "", "filter Join Servers by name")
 	catalogCmd.AddCommand(catalogJoinServersCmd)
+
+	return catalogCmd
 }
@@ -0,0 +1,447 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	routingpb "go.packetbroker.org/api/routing"
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/cmd/internal/cmdtest"
+	"go.uber.org/zap"
+)
+
+// execute runs pbctl with the arguments and standard input against the test environment.
+func execute(t *testing.T, env *cmdtest.Env, stdin string, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	err = executeContext(context.Background(), env, strings.NewReader(stdin), &out, &errOut, args...)
+	return out.String(), errOut.String(), err
+}
+
+// executeContext runs pbctl with the arguments against the test environment until the context is done.
+func executeContext(ctx context.Context, env *cmdtest.Env, stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	cmd := NewRootCommand(Options{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+		Logger: zap.NewNop(),
+	})
+	cmd.SetArgs(append(args, env.Args("iam", "controlplane", "reports")...))
+	return cmd.ExecuteContext(ctx)
+}
+
+func addNetworks(env *cmdtest.Env) {
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId:  0x13,
+		Name:   "The Things Network",
+		Listed: true,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+		},
+	})
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId:  0x9,
+		Name:   "Senet",
+		Listed: true,
+	})
+	env.Server.AddTenant(&packetbroker.Tenant{
+		NetId:    0x13,
+		TenantId: "tti",
+		Name:     "The Things Industries",
+		Listed:   true,
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	addNetworks(env)
+
+	for _, args := range [][]string{
+		{"--forwarder-net-id", "000013", "--defaults", "--set-uplink", "JMASL", "--set-downlink", "JMA"},
+		{"--forwarder-net-id", "000009", "--defaults", "--set-uplink", "JM", "--set-downlink", "J"},
+		{"--forwarder-net-id", "000013", "--home-network-net-id", "000009", "--set-uplink", "JM", "--set-downlink", "JM"},
+	} {
+		if _, _, err := execute(t, env, "", append([]string{"policy", "set"}, args...)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stdout, _, err := execute(t, env, "", "policy", "list", "--defaults")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Golden(t, "policy_list_defaults", stdout)
+
+	stdout, _, err = execute(t, env, "", "policy", "list", "--home-network-net-id", "000009")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Golden(t, "policy_list_effective", stdout)
+
+	if _, _, err := execute(t, env, "", "policy", "set", "--home-network-net-id", "000009"); err == nil {
+		t.Fatal("Expected error without Forwarder")
+	}
+}
+
+func TestPolicyWatch(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	addNetworks(env)
+	if _, _, err := execute(t, env, "", "policy", "set", "--forwarder-net-id", "000013",
+		"--home-network-net-id", "000009", "--set-uplink", "JM", "--set-downlink", "JM"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"yaml", "csv", "template={{.forwarderNetId}}"} {
+		if _, _, err := execute(t, env, "", "policy", "list", "--forwarder-net-id", "000013", "--watch", "-o", format); err == nil {
+			t.Fatalf("Expected error with output format %q", format)
+		}
+	}
+
+	// watch runs the watch command and returns a function that waits for n lines of output, and a function that
+	// cancels the command and returns its error.
+	watch := func(t *testing.T, args ...string) (waitLines func(n int) []string, stop func() error) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		t.Cleanup(cancel)
+		out := new(cmdtest.Buffer)
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- executeContext(ctx, env, strings.NewReader(""), out, io.Discard,
+				append([]string{"policy", "list", "--watch", "--watch-interval", "10ms", "-o", "json"}, args...)...)
+		}()
+		waitLines = func(n int) []string {
+			t.Helper()
+			for ctx.Err() == nil {
+				if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) >= n && lines[0] != "" {
+					return lines
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			t.Fatalf("Expected %d lines, got %q", n, out.String())
+			return nil
+		}
+		stop = func() error {
+			cancel()
+			select {
+			case err := <-errCh:
+				return err
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected watch to stop when the context is canceled")
+				return nil
+			}
+		}
+		return waitLines, stop
+	}
+
+	t.Run("Forwarder", func(t *testing.T) {
+		waitLines, stop := watch(t, "--forwarder-net-id", "000013")
+		waitLines(1)
+		if _, _, err := execute(t, env, "", "policy", "delete", "--forwarder-net-id", "000013",
+			"--home-network-net-id", "000009"); err != nil {
+			t.Fatal(err)
+		}
+		lines := waitLines(2)
+		cmdtest.Contains(t, lines[1], `"homeNetworkNetId":9`, `"uplink":null`, `"downlink":null`)
+		if err := stop(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context canceled, got %v", err)
+		}
+	})
+
+	t.Run("HomeNetwork", func(t *testing.T) {
+		if _, _, err := execute(t, env, "", "policy", "set", "--forwarder-net-id", "000013", "--defaults",
+			"--set-uplink", "JMASL", "--set-downlink", "JMA"); err != nil {
+			t.Fatal(err)
+		}
+		env.Server.ResetCalls()
+		waitLines, stop := watch(t, "--home-network-net-id", "000009")
+		lines := waitLines(1)
+		cmdtest.Contains(t, lines[0], `"homeNetworkNetId":9`, `"signalQuality":true`)
+
+		for _, args := range [][]string{
+			{"--home-network-net-id", "000009", "--set-uplink", "JM", "--set-downlink", "JM"},
+			{"--home-network-net-id", "000009"},
+			{"--defaults"},
+		} {
+			cmd := "set"
+			if len(args) < 3 {
+				cmd = "delete"
+			}
+			if _, _, err := execute(t, env, "", append([]string{"policy", cmd, "--forwarder-net-id", "000013"}, args...)...); err != nil {
+				t.Fatal(err)
+			}
+			n := len(lines) + 1
+			lines = waitLines(n)
+		}
+		// The Home Network policy replaces the default policy, and the default policy applies again when the Home
+		// Network policy is deleted. When the default policy is deleted, there is no effective policy.
+		cmdtest.Contains(t, lines[1], `"homeNetworkNetId":9`, `"signalQuality":false`)
+		cmdtest.Contains(t, lines[2], `"homeNetworkNetId":9`, `"signalQuality":true`)
+		cmdtest.Contains(t, lines[3], `"homeNetworkNetId":9`, `"uplink":null`, `"downlink":null`)
+		if err := stop(); !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context canceled, got %v", err)
+		}
+
+		var effective int
+		for _, c := range env.Server.Calls() {
+			switch req := c.Request.(type) {
+			case *routingpb.ListEffectivePoliciesRequest:
+				effective++
+			case *routingpb.ListDefaultPoliciesRequest:
+				if req.UpdatedSince == nil {
+					t.Fatal("Expected default policies to be polled with the last seen update")
+				}
+			case *routingpb.ListHomeNetworkPoliciesRequest:
+				if req.UpdatedSince == nil {
+					t.Fatal("Expected Home Network policies to be polled with the last seen update")
+				}
+			}
+		}
+		if effective != 1 {
+			t.Fatalf("Expected effective policies to be listed once, got %d", effective)
+		}
+	})
+}
+
+func TestPolicyApply(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	addNetworks(env)
+	doc := `forwarder:
+  net-id: "000013"
+defaults:
+  uplink: JMASL
+  downlink: JMA
+home-networks:
+- net-id: "000009"
+  uplink: JM
+  downlink: JM
+`
+
+	stdout, stderr, err := execute(t, env, doc, "policy", "apply", "-f", "-", "--dry-run")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Golden(t, "policy_apply_plan", stdout)
+	if stderr != "" {
+		t.Fatalf("Unexpected standard error %q", stderr)
+	}
+
+	env.Server.ResetCalls()
+	_, stderr, err = execute(t, env, doc, "policy", "apply", "-f", "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr != "Applied 2 changes\n" {
+		t.Fatalf("Unexpected standard error %q", stderr)
+	}
+	cmdtest.Contains(t, strings.Join(env.Methods(), "\n"),
+		"/org.packetbroker.routing.v1.PolicyManager/SetDefaultPolicy",
+		"/org.packetbroker.routing.v1.PolicyManager/SetHomeNetworkPolicy",
+	)
+
+	_, stderr, err = execute(t, env, doc, "policy", "apply", "-f", "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr != "No changes\n" {
+		t.Fatalf("Unexpected standard error %q", stderr)
+	}
+
+	stdout, _, err = execute(t, env, "", "policy", "export", "--forwarder-net-id", "000013")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Golden(t, "policy_export", stdout)
+}
+
+func TestPolicyApplyPrune(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	addNetworks(env)
+	if _, _, err := execute(t, env, "", "policy", "set", "--forwarder-net-id", "000013",
+		"--home-network-net-id", "000009", "--set-uplink", "JM", "--set-downlink", "JM"); err != nil {
+		t.Fatal(err)
+	}
+	doc := `forwarder:
+  net-id: "000013"
+home-networks:
+- net-id: "000013"
+  tenant-id: tti
+  uplink: "-"
+  downlink: "-"
+`
+
+	_, stderr, err := execute(t, env, doc, "policy", "apply", "-f", "-", "--prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr != "Applied 2 changes\n" {
+		t.Fatalf("Unexpected standard error %q", stderr)
+	}
+	var (
+		forwarder = packetbroker.TenantID{NetID: 0x13}
+		senet     = packetbroker.TenantID{NetID: 0x9}
+		tti       = packetbroker.TenantID{NetID: 0x13, ID: "tti"}
+	)
+	if _, ok := env.Server.HomeNetworkPolicy(forwarder, senet); ok {
+		t.Fatal("Expected policy with 000009 to be deleted")
+	}
+	if p, ok := env.Server.HomeNetworkPolicy(forwarder, tti); !ok || p.Uplink == nil || p.Downlink == nil {
+		t.Fatalf("Expected policy with 000013/tti that allows nothing, got %v", p)
+	}
+	if _, ok := env.Server.DefaultPolicy(forwarder); ok {
+		t.Fatal("Unexpected default policy")
+	}
+
+	_, stderr, err = execute(t, env, doc, "policy", "apply", "-f", "-", "--prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr != "No changes\n" {
+		t.Fatalf("Unexpected standard error %q", stderr)
+	}
+}
+
+func TestPolicyDiff(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	addNetworks(env)
+	file := filepath.Join(t.TempDir(), "policies.yaml")
+	if err := os.WriteFile(file, []byte(`home-networks:
+- net-id: "000013"
+  tenant-id: tti
+  uplink: "-"
+  downlink: "-"
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The document denies all with 000013/tti, whereas the Forwarder has no policy.
+	stdout, _, err := execute(t, env, "", "policy", "diff", "--from-file", file, "--to-forwarder-net-id", "000013")
+	if !errors.Is(err, errPoliciesDiffer) {
+		t.Fatalf("Expected policies to differ, got %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(stdout), "\n"); len(lines) != 2 || !strings.HasPrefix(lines[1], "-") ||
+		!strings.Contains(lines[1], "tti") {
+		t.Fatalf("Expected removed policy with 000013/tti, got %q", stdout)
+	}
+
+	if _, _, err := execute(t, env, "", "policy", "set", "--forwarder-net-id", "000013",
+		"--home-network-net-id", "000013", "--home-network-tenant-id", "tti"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := execute(t, env, "", "policy", "diff", "--from-file", file, "--to-forwarder-net-id", "000013"); err != nil {
+		t.Fatalf("Expected no differences, got %v", err)
+	}
+
+	if _, _, err := execute(t, env, "", "policy", "diff", "--from-file", "-", "--to-file", "-"); err == nil || !strings.Contains(err.Error(), "stdin") {
+		t.Fatalf("Expected error reading both documents from stdin, got %v", err)
+	}
+}
+
+func TestGatewayVisibilityApply(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	addNetworks(env)
+	// Share with everyone except The Things Network.
+	doc := `forwarder:
+  net-id: "000013"
+  tenant-id: tti
+defaults: LoSt
+home-networks:
+- net-id: "000013"
+  visibility: "-"
+`
+
+	_, stderr, err := execute(t, env, doc, "gateway-visibility", "apply", "-f", "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr != "Applied 2 changes\n" {
+		t.Fatalf("Unexpected standard error %q", stderr)
+	}
+	forwarder := packetbroker.TenantID{NetID: 0x13, ID: "tti"}
+	if v, ok := env.Server.HomeNetworkVisibility(forwarder, packetbroker.TenantID{NetID: 0x13}); !ok || v.Location || v.Status {
+		t.Fatalf("Expected gateway visibility with 000013 that hides everything, got %v", v)
+	}
+
+	_, stderr, err = execute(t, env, doc, "gateway-visibility", "apply", "-f", "-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr != "No changes\n" {
+		t.Fatalf("Unexpected standard error %q", stderr)
+	}
+
+	stdout, _, err := execute(t, env, "", "gateway-visibility", "export", "--forwarder-net-id", "000013", "--forwarder-tenant-id", "tti")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Contains(t, stdout, "defaults: LoSt", `- net-id: "000013"`, `visibility: ""`)
+
+	// The gateway visibility with 000013 already hides everything, so pruning does not change anything.
+	_, stderr, err = execute(t, env, `forwarder:
+  net-id: "000013"
+  tenant-id: tti
+defaults: LoSt
+`, "gateway-visibility", "apply", "-f", "-", "--prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stderr != "No changes\n" {
+		t.Fatalf("Unexpected standard error %q", stderr)
+	}
+
+	for _, args := range [][]string{
+		{"list", "--forwarder-net-id", "000013", "--parallelism", "0"},
+		{"export", "--forwarder-net-id", "000013", "--parallelism", "-1"},
+		{"apply", "-f", "-", "--parallelism", "0"},
+	} {
+		if _, _, err := execute(t, env, "", append([]string{"gateway-visibility"}, args...)...); err == nil || !strings.Contains(err.Error(), "parallelism must be at least 1") {
+			t.Fatalf("Expected parallelism error for %v, got %v", args, err)
+		}
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	addNetworks(env)
+
+	stdout, _, err := execute(t, env, "", "catalog", "networks", "--net-id", "000013")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Golden(t, "catalog_networks", stdout)
+
+	stdout, _, err = execute(t, env, "", "catalog", "home-networks", "--net-id", "000013")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Golden(t, "catalog_home_networks", stdout)
+}
+
+func TestReportOutputFile(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	addNetworks(env)
+	dir := t.TempDir()
+
+	// -o used to be the shorthand of --output-file, so it is still accepted as output file.
+	outputFile := filepath.Join(dir, "report.json")
+	_, stderr, err := execute(t, env, "", "report", "routed-messages", "--net-id", "000013", "--today", "-o", outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmdtest.Contains(t, stderr, "deprecated", "--output-file")
+	if _, err := os.Stat(outputFile); err != nil {
+		t.Fatalf("Expected output file: %v", err)
+	}
+
+	if _, _, err := execute(t, env, "", "report", "routed-messages", "--net-id", "000013", "--today",
+		"-o", outputFile, "--output-file", filepath.Join(dir, "other.json")); err == nil {
+		t.Fatal("Expected error with both -o and --output-file")
+	}
+}
@@ -4,24 +4,32 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
+	"io"
 
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
+	iampb "go.packetbroker.org/api/iam/v2"
 	mappingpb "go.packetbroker.org/api/mapping/v2"
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/column"
 	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/sdk"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-var (
-	gatewayVisibilityCmd = &cobra.Command{
+func (st *state) newGatewayVisibilityCommand() *cobra.Command {
+	gatewayVisibilityCmd := &cobra.Command{
 		Use:               "gateway-visibility",
 		Aliases:           []string{"gwvis"},
 		Short:             "Manage Packet Broker gateway visibilities",
-		PersistentPreRunE: prerunConnect,
-		PersistentPostRun: postrunConnect,
+		PersistentPreRunE: st.prerunConnect,
+		PersistentPostRun: st.postrunConnect,
 	}
-	gatewayVisibilitySetCmd = &cobra.Command{
+	gatewayVisibilitySetCmd := &cobra.Command{
 		Use:   "set",
 		Short: "Set gateway visibility",
 		Long: `Set default gateway visibility of Forwarder, or a specific visibility
@@ -53,7 +61,7 @@ who may see their infrastructure.`,
     $ pbctl gateway-visibility set --forwarder-net-id 000013 \
       --home-network-net-id 000009 --set LoFp`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client := mappingpb.NewGatewayVisibilityManagerClient(cpConn)
+			client := mappingpb.NewGatewayVisibilityManagerClient(st.cpConn)
 			forwarderTenantID, _ := pbflag.GetTenantID(cmd.Flags(), "forwarder")
 			if forwarderTenantID.IsEmpty() {
 				return errors.New("pass your NetID (and tenant ID) via --forwarder-net-id (and --forwarder-tenant-id)")
@@ -64,7 +72,7 @@ who may see their infrastructure.`,
 			defaults, _ := cmd.Flags().GetBool("defaults")
 			var err error
 			if defaults {
-				_, err = client.SetDefaultVisibility(ctx, &mappingpb.SetGatewayVisibilityRequest{
+				_, err = client.SetDefaultVisibility(st.ctx, &mappingpb.SetGatewayVisibilityRequest{
 					Visibility: visibility,
 				})
 			} else {
@@ -74,17 +82,19 @@ who may see their infrastructure.`,
 				}
 				visibility.HomeNetworkNetId = uint32(homeNetworkTenantID.NetID)
 				visibility.HomeNetworkTenantId = homeNetworkTenantID.ID
-				_, err = client.SetHomeNetworkVisibility(ctx, &mappingpb.SetGatewayVisibilityRequest{
+				_, err = client.SetHomeNetworkVisibility(st.ctx, &mappingpb.SetGatewayVisibilityRequest{
 					Visibility: visibility,
 				})
 			}
 			if err != nil {
 				return err
 			}
-			return column.WriteVisibilities(tabout, defaults, visibility)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(visibility, func(w io.Writer) error {
+				return column.WriteVisibilities(w, defaults, visibility)
+			})
 		},
 	}
-	gatewayVisibilityGetCmd = &cobra.Command{
+	gatewayVisibilityGetCmd := &cobra.Command{
 		Use:   "get",
 		Short: "Get gateway visibility",
 		Example: `
@@ -100,7 +110,7 @@ who may see their infrastructure.`,
       --home-network-net-id 000009`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
-				client = mappingpb.NewGatewayVisibilityManagerClient(cpConn)
+				client = mappingpb.NewGatewayVisibilityManagerClient(st.cpConn)
 				res    *mappingpb.GetGatewayVisibilityResponse
 				err    error
 			)
@@ -110,7 +120,7 @@ who may see their infrastructure.`,
 			}
 			defaults, _ := cmd.Flags().GetBool("defaults")
 			if defaults {
-				res, err = client.GetDefaultVisibility(ctx, &mappingpb.GetDefaultGatewayVisibilityRequest{
+				res, err = client.GetDefaultVisibility(st.ctx, &mappingpb.GetDefaultGatewayVisibilityRequest{
 					ForwarderNetId:    uint32(forwarderTenantID.NetID),
 					ForwarderTenantId: forwarderTenantID.ID,
 				})
@@ -119,7 +129,7 @@ who may see their infrastructure.`,
 				if homeNetworkTenantID.IsEmpty() {
 					return errors.New("pass the Home Network NetID (and tenant ID) via --home-network-net-id (and --home-network-tenant-id)")
 				}
-				res, err = client.GetHomeNetworkVisibility(ctx, &mappingpb.GetHomeNetworkGatewayVisibilityRequest{
+				res, err = client.GetHomeNetworkVisibility(st.ctx, &mappingpb.GetHomeNetworkGatewayVisibilityRequest{
 					ForwarderNetId:      uint32(forwarderTenantID.NetID),
 					ForwarderTenantId:   forwarderTenantID.ID,
 					HomeNetworkNetId:    uint32(homeNetworkTenantID.NetID),
@@ -129,10 +139,47 @@ who may see their infrastructure.`,
 			if err != nil {
 				return err
 			}
-			return column.WriteVisibilities(tabout, defaults, res.Visibility)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(res.Visibility, func(w io.Writer) error {
+				return column.WriteVisibilities(w, defaults, res.Visibility)
+			})
 		},
 	}
-	gatewayVisibilityDeleteCmd = &cobra.Command{
+	gatewayVisibilityListCmd := &cobra.Command{
+		Use:     "list",
+		Aliases: []string{"ls"},
+		Short:   "List gateway visibilities with Home Networks",
+		Long: `List the gateway visibilities of a Forwarder with the Home Networks in the
+catalog. The gateway visibilities are retrieved concurrently.`,
+		Example: `
+  List gateway visibilities of Forwarder network:
+    $ pbctl gateway-visibility list --forwarder-net-id 000013
+
+  List gateway visibilities of Forwarder tenant, with 16 concurrent requests:
+    $ pbctl gateway-visibility list --forwarder-net-id 000013 \
+      --forwarder-tenant-id tti --parallelism 16`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			forwarderTenantID, _ := pbflag.GetTenantID(cmd.Flags(), "forwarder")
+			if forwarderTenantID.IsEmpty() {
+				return errors.New("pass your NetID (and tenant ID) via --forwarder-net-id (and --forwarder-tenant-id)")
+			}
+			parallelism, err := getParallelism(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			homeNetworks, _, err := st.listHomeNetworks(forwarderTenantID)
+			if err != nil {
+				return err
+			}
+			visibilities, err := st.listHomeNetworkVisibilities(mappingpb.NewGatewayVisibilityManagerClient(st.cpConn), forwarderTenantID, homeNetworks, parallelism)
+			if err != nil {
+				return err
+			}
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("visibilities", visibilities), func(w io.Writer) error {
+				return column.WriteVisibilities(w, false, visibilities...)
+			})
+		},
+	}
+	gatewayVisibilityDeleteCmd := &cobra.Command{
 		Use:     "delete",
 		Aliases: []string{"rm"},
 		Short:   "Delete a visibility",
@@ -148,7 +195,7 @@ who may see their infrastructure.`,
     $ pbctl gateway-visibility delete --forwarder-net-id 000013 \
       --home-network-net-id 000009`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client := mappingpb.NewGatewayVisibilityManagerClient(cpConn)
+			client := mappingpb.NewGatewayVisibilityManagerClient(st.cpConn)
 			forwarderTenantID, _ := pbflag.GetTenantID(cmd.Flags(), "forwarder")
 			if forwarderTenantID.IsEmpty() {
 				return errors.New("pass your NetID (and tenant ID) via --forwarder-net-id (and --forwarder-tenant-id)")
@@ -159,7 +206,7 @@ who may see their infrastructure.`,
 			}
 			var err error
 			if defaults, _ := cmd.Flags().GetBool("defaults"); defaults {
-				_, err = client.SetDefaultVisibility(ctx, &mappingpb.SetGatewayVisibilityRequest{
+				_, err = client.SetDefaultVisibility(st.ctx, &mappingpb.SetGatewayVisibilityRequest{
 					Visibility: visibility,
 				})
 			} else {
@@ -169,7 +216,7 @@ who may see their infrastructure.`,
 				}
 				visibility.HomeNetworkNetId = uint32(homeNetworkTenantID.NetID)
 				visibility.HomeNetworkTenantId = homeNetworkTenantID.ID
-				_, err = client.SetHomeNetworkVisibility(ctx, &mappingpb.SetGatewayVisibilityRequest{
+				_, err = client.SetHomeNetworkVisibility(st.ctx, &mappingpb.SetGatewayVisibilityRequest{
 					Visibility: visibility,
 				})
 			}
@@ -179,24 +226,8 @@ who may see their infrastructure.`,
 			return nil
 		},
 	}
-)
-
-func gatewayVisibilitySourceFlags() *flag.FlagSet {
-	flags := new(flag.FlagSet)
-	flags.AddFlagSet(pbflag.TenantID("forwarder"))
-	return flags
-}
-
-func gatewayVisibilityTargetFlags() *flag.FlagSet {
-	flags := new(flag.FlagSet)
-	flags.AddFlagSet(pbflag.TenantID("home-network"))
-	flags.Bool("defaults", false, "default gateway visibility")
-	return flags
-}
 
-func init() {
 	gatewayVisibilityCmd.PersistentFlags().AddFlagSet(gatewayVisibilitySourceFlags())
-	rootCmd.AddCommand(gatewayVisibilityCmd)
 
 	gatewayVisibilitySetCmd.Flags().AddFlagSet(gatewayVisibilityTargetFlags())
 	gatewayVisibilitySetCmd.Flags().AddFlagSet(pbflag.GatewayVisibility())
@@ -205,6 +236,103 @@ func init() {
 	gatewayVisibilityGetCmd.Flags().AddFlagSet(gatewayVisibilityTargetFlags())
 	gatewayVisibilityCmd.AddCommand(gatewayVisibilityGetCmd)
 
+	gatewayVisibilityListCmd.Flags().Int("parallelism", 8, "maximum number of concurrent requests")
+	gatewayVisibilityCmd.AddCommand(gatewayVisibilityListCmd)
+
 	gatewayVisibilityDeleteCmd.Flags().AddFlagSet(gatewayVisibilityTargetFlags())
 	gatewayVisibilityCmd.AddCommand(gatewayVisibilityDeleteCmd)
+	gatewayVisibilityCmd.AddCommand(st.newGatewayVisibilityApplyCommand())
+	gatewayVisibilityCmd.AddCommand(st.newGatewayVisibilityExportCommand())
+
+	return gatewayVisibilityCmd
+}
+
+// listHomeNetworks returns the Home Networks in the catalog of the Forwarder, with their names.
+func (st *state) listHomeNetworks(forwarder packetbroker.TenantID) ([]packetbroker.TenantID, map[packetbroker.TenantID]string, error) {
+	var (
+		ids   []packetbroker.TenantID
+		names = make(map[packetbroker.TenantID]string)
+		it    = sdk.NewIAM(st.iamConn).ListCatalogHomeNetworks(st.ctx, &iampb.ListNetworksRequest{
+			NetId:    uint32(forwarder.NetID),
+			TenantId: forwarder.ID,
+		})
+	)
+	for it.Next() {
+		var (
+			id   packetbroker.TenantID
+			name string
+		)
+		if nwk := it.Value().GetNetwork(); nwk != nil {
+			id, name = packetbroker.TenantID{NetID: packetbroker.NetID(nwk.GetNetId())}, nwk.GetName()
+		} else if tnt := it.Value().GetTenant(); tnt != nil {
+			id, name = packetbroker.RequestTenantID(tnt), tnt.GetName()
+		} else {
+			continue
+		}
+		ids = append(ids, id)
+		names[id] = name
+	}
+	if err := it.Err(); err != nil {
+		return nil, nil, err
+	}
+	return ids, names, nil
+}
+
+// listHomeNetworkVisibilities gets the gateway visibilities of the Forwarder with the Home Networks, with at most
+// parallelism concurrent requests. Home Networks without gateway visibility are omitted.
+func (st *state) listHomeNetworkVisibilities(client mappingpb.GatewayVisibilityManagerClient, forwarder packetbroker.TenantID, homeNetworks []packetbroker.TenantID, parallelism int) ([]*packetbroker.GatewayVisibility, error) {
+	visibilities := make([]*packetbroker.GatewayVisibility, len(homeNetworks))
+	g, gctx := errgroup.WithContext(st.ctx)
+	g.SetLimit(parallelism)
+	for i, hn := range homeNetworks {
+		i, hn := i, hn
+		g.Go(func() error {
+			res, err := client.GetHomeNetworkVisibility(gctx, &mappingpb.GetHomeNetworkGatewayVisibilityRequest{
+				ForwarderNetId:      uint32(forwarder.NetID),
+				ForwarderTenantId:   forwarder.ID,
+				HomeNetworkNetId:    uint32(hn.NetID),
+				HomeNetworkTenantId: hn.ID,
+			})
+			if status.Code(err) == codes.NotFound {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("get gateway visibility with %s: %w", hn, err)
+			}
+			visibilities[i] = res.Visibility
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	res := visibilities[:0]
+	for _, v := range visibilities {
+		if v != nil {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
+// getParallelism returns the maximum number of concurrent requests.
+func getParallelism(flags *flag.FlagSet) (int, error) {
+	parallelism, _ := flags.GetInt("parallelism")
+	if parallelism < 1 {
+		return 0, errors.New("parallelism must be at least 1")
+	}
+	return parallelism, nil
+}
+
+func gatewayVisibilitySourceFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.AddFlagSet(pbflag.TenantID("forwarder"))
+	return flags
+}
+
+func gatewayVisibilityTargetFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.AddFlagSet(pbflag.TenantID("home-network"))
+	flags.Bool("defaults", false, "default gateway visibility")
+	return flags
 }
@@ -0,0 +1,182 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	mappingpb "go.packetbroker.org/api/mapping/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/policydoc"
+)
+
+// readVisibilityDocument reads the gateway visibility document from the file, or from stdin if the file is -.
+func (st *state) readVisibilityDocument(name string) (*policydoc.VisibilityDocument, error) {
+	if name == "-" {
+		return policydoc.ReadVisibilities(st.Stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := policydoc.ReadVisibilities(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return doc, nil
+}
+
+// writeVisibilityPlan writes the changes as a table.
+func writeVisibilityPlan(w io.Writer, changes []policydoc.VisibilityChange) {
+	fmt.Fprintln(w, "\tHome Network\t\tVisibility\t")
+	for _, c := range changes {
+		var (
+			action   = "~"
+			from, to string
+		)
+		switch {
+		case c.From == nil:
+			action = "+"
+			to = *c.To
+		case c.To == nil:
+			action = "-"
+			from = *c.From
+		default:
+			from, to = *c.From, *c.To
+		}
+		if c.HomeNetwork == nil {
+			fmt.Fprintf(w, "%s\tdefaults\t\t", action)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t", action, c.HomeNetwork.NetID, c.HomeNetwork.ID)
+		}
+		fmt.Fprintf(w, "%s → %s\t\n", formatPolicyLetters(from), formatPolicyLetters(to))
+	}
+}
+
+func (st *state) newGatewayVisibilityApplyCommand() *cobra.Command {
+	gatewayVisibilityApplyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply gateway visibilities from a document",
+		Long: `Apply the default gateway visibility and the gateway visibilities with Home
+Networks of a Forwarder from a YAML or JSON document.
+
+The gateway visibilities in the document are compared with the current gateway
+visibilities with the Home Networks in the catalog. The plan of changes is
+printed before the changes are applied. Gateway visibilities with Home Networks
+that are not in the document are deleted only with --prune.
+
+A gateway visibility "-" hides everything, which is different from no gateway
+visibility: without a gateway visibility with a Home Network, the default
+gateway visibility applies. As gateway visibilities cannot be deleted, deleting
+a gateway visibility sets it to "-".
+
+The document uses the symbols Lo (location), Ap (antenna placement),
+Ac (antenna count), Ft (fine timestamps), Ci (contact information), St (status),
+Fp (frequency plan) and Pr (packet rates):
+
+  forwarder:
+    net-id: "000013"
+    tenant-id: tti
+  defaults: LoStFp
+  home-networks:
+  - net-id: "000009"
+    visibility: LoAcStFpPr
+  - net-id: C00123
+    tenant-id: tenant-b
+    visibility: "-"`,
+		Example: `
+  Show the changes without applying them:
+    $ pbctl gateway-visibility apply -f visibilities.yaml --dry-run
+
+  Apply the gateway visibilities and delete gateway visibilities with Home
+  Networks not in the document:
+    $ pbctl gateway-visibility apply -f visibilities.yaml --prune`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, _ := cmd.Flags().GetString("file")
+			if file == "" {
+				return errors.New("pass the document via --file")
+			}
+			parallelism, err := getParallelism(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			doc, err := st.readVisibilityDocument(file)
+			if err != nil {
+				return err
+			}
+			flagForwarder, _ := pbflag.GetTenantID(cmd.Flags(), "forwarder")
+			forwarder, err := documentForwarder(doc.Forwarder, flagForwarder)
+			if err != nil {
+				return err
+			}
+
+			client := mappingpb.NewGatewayVisibilityManagerClient(st.cpConn)
+			current, _, err := st.getVisibilityDocument(client, forwarder, parallelism)
+			if err != nil {
+				return err
+			}
+			prune, _ := cmd.Flags().GetBool("prune")
+			var changes []policydoc.VisibilityChange
+			for _, c := range policydoc.CompareVisibilities(current, doc) {
+				if !prune && !doc.Defines(c.HomeNetwork) {
+					continue
+				}
+				// Deleting a gateway visibility that hides everything does not change anything.
+				if c.To == nil && *c.From == "" {
+					continue
+				}
+				changes = append(changes, c)
+			}
+			if len(changes) == 0 {
+				fmt.Fprintln(st.Stderr, "No changes")
+				return nil
+			}
+			writeVisibilityPlan(st.tabout, changes)
+			if err := st.tabout.Flush(); err != nil {
+				return err
+			}
+			if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+				return nil
+			}
+
+			for _, c := range changes {
+				var (
+					to          string
+					homeNetwork packetbroker.TenantID
+				)
+				if c.To != nil {
+					to = *c.To
+				}
+				if c.HomeNetwork != nil {
+					homeNetwork = *c.HomeNetwork
+				}
+				req := &mappingpb.SetGatewayVisibilityRequest{
+					Visibility: policydoc.GatewayVisibility(to, forwarder, homeNetwork),
+				}
+				if c.HomeNetwork == nil {
+					_, err = client.SetDefaultVisibility(st.ctx, req)
+				} else {
+					_, err = client.SetHomeNetworkVisibility(st.ctx, req)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(st.Stderr, "Applied %d changes\n", len(changes))
+			return nil
+		},
+	}
+
+	gatewayVisibilityApplyCmd.Flags().StringP("file", "f", "", "YAML or JSON document with gateway visibilities (- for stdin)")
+	gatewayVisibilityApplyCmd.Flags().Bool("prune", false, "delete gateway visibilities with Home Networks that are not in the document")
+	gatewayVisibilityApplyCmd.Flags().Bool("dry-run", false, "print the changes without applying them")
+	gatewayVisibilityApplyCmd.Flags().Int("parallelism", 8, "maximum number of concurrent requests")
+
+	return gatewayVisibilityApplyCmd
+}
@@ -0,0 +1,108 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	mappingpb "go.packetbroker.org/api/mapping/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/policydoc"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// getVisibilityDocument returns the default gateway visibility and the gateway visibilities with the Home Networks in
+// the catalog of the Forwarder, with the names of the Home Networks. The default gateway visibility is nil if the
+// Forwarder has no default gateway visibility.
+func (st *state) getVisibilityDocument(client mappingpb.GatewayVisibilityManagerClient, forwarder packetbroker.TenantID, parallelism int) (*policydoc.VisibilityDocument, map[packetbroker.TenantID]string, error) {
+	network := policydoc.NewNetwork(forwarder)
+	doc := &policydoc.VisibilityDocument{
+		Forwarder:    &network,
+		HomeNetworks: []policydoc.HomeNetworkVisibility{},
+	}
+
+	res, err := client.GetDefaultVisibility(st.ctx, &mappingpb.GetDefaultGatewayVisibilityRequest{
+		ForwarderNetId:    uint32(forwarder.NetID),
+		ForwarderTenantId: forwarder.ID,
+	})
+	switch {
+	case status.Code(err) == codes.NotFound:
+	case err != nil:
+		return nil, nil, err
+	default:
+		defaults := policydoc.FromGatewayVisibility(res.Visibility)
+		doc.Defaults = &defaults
+	}
+
+	homeNetworks, names, err := st.listHomeNetworks(forwarder)
+	if err != nil {
+		return nil, nil, err
+	}
+	visibilities, err := st.listHomeNetworkVisibilities(client, forwarder, homeNetworks, parallelism)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, v := range visibilities {
+		doc.HomeNetworks = append(doc.HomeNetworks, policydoc.HomeNetworkVisibility{
+			Network: policydoc.NewNetwork(packetbroker.TenantID{
+				NetID: packetbroker.NetID(v.GetHomeNetworkNetId()),
+				ID:    v.GetHomeNetworkTenantId(),
+			}),
+			Visibility: policydoc.FromGatewayVisibility(v),
+		})
+	}
+	if err := doc.Normalize(); err != nil {
+		return nil, nil, err
+	}
+	return doc, names, nil
+}
+
+func (st *state) newGatewayVisibilityExportCommand() *cobra.Command {
+	gatewayVisibilityExportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export gateway visibilities to a document",
+		Long: `Export the default gateway visibility and the gateway visibilities with the
+Home Networks in the catalog of a Forwarder to a YAML or JSON document that can
+be applied with pbctl gateway-visibility apply.
+
+In YAML, the names of the networks and tenants are written as comments.`,
+		Example: `
+  Export the gateway visibilities of Forwarder network to YAML:
+    $ pbctl gateway-visibility export --forwarder-net-id 000013 > visibilities.yaml
+
+  Export the gateway visibilities of Forwarder tenant to JSON:
+    $ pbctl gateway-visibility export --forwarder-net-id 000013 \
+      --forwarder-tenant-id tti -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			forwarder, _ := pbflag.GetTenantID(cmd.Flags(), "forwarder")
+			if forwarder.IsEmpty() {
+				return errors.New("pass your NetID (and tenant ID) via --forwarder-net-id (and --forwarder-tenant-id)")
+			}
+			parallelism, err := getParallelism(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			doc, names, err := st.getVisibilityDocument(mappingpb.NewGatewayVisibilityManagerClient(st.cpConn), forwarder, parallelism)
+			if err != nil {
+				return err
+			}
+			switch output := printer.GetOutput(cmd.Flags()); output.Format {
+			case printer.JSON:
+				return doc.WriteJSON(st.Stdout)
+			case printer.Table, printer.YAML:
+				return doc.WriteYAML(st.Stdout, names)
+			default:
+				return fmt.Errorf("unsupported output format %q, use json or yaml", output)
+			}
+		},
+	}
+
+	gatewayVisibilityExportCmd.Flags().Int("parallelism", 8, "maximum number of concurrent requests")
+
+	return gatewayVisibilityExportCmd
+}
@@ -3,8 +3,13 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"sort"
+	"time"
 
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
@@ -12,18 +17,25 @@ import (
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/column"
 	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/cmd/internal/protojson"
+	"go.packetbroker.org/pb/pkg/sdk"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
-var (
-	policyCmd = &cobra.Command{
+func (st *state) newPolicyCommand() *cobra.Command {
+	policyCmd := &cobra.Command{
 		Use:               "policy",
 		Aliases:           []string{"policies", "po"},
 		Short:             "Manage Packet Broker routing policies",
-		PersistentPreRunE: prerunConnect,
-		PersistentPostRun: postrunConnect,
+		PersistentPreRunE: st.prerunConnect,
+		PersistentPostRun: st.postrunConnect,
 	}
-	policyListCmd = &cobra.Command{
+	policyListCmd := &cobra.Command{
 		Use:     "list",
 		Aliases: []string{"ls"},
 		Short:   "List policies",
@@ -46,73 +58,50 @@ var (
   List effective Forwarder policies for a Home Network tenant:
     $ pbctl policy list --home-network-net-id 000013 \
       --home-network-tenant-id tti
+
+  Watch policy changes of Forwarders that may affect a Home Network:
+    $ pbctl policy list --home-network-net-id 000013 --watch
+
+  Watch Home Network policy changes of a Forwarder network as NDJSON, where
+  deleted policies have no uplink and downlink:
+    $ pbctl policy list --forwarder-net-id 000013 --watch -o json
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
-				client                 = routingpb.NewPolicyManagerClient(cpConn)
 				policies               []*packetbroker.RoutingPolicy
 				defaults               bool
 				homeNetworkTenantID, _ = pbflag.GetTenantID(cmd.Flags(), "home-network")
+				forwarderTenantID, _   = pbflag.GetTenantID(cmd.Flags(), "forwarder")
+				watch, _               = cmd.Flags().GetBool("watch")
 			)
+			if watch {
+				if format := printer.GetOutput(cmd.Flags()).Format; format != printer.Table && format != printer.JSON {
+					return fmt.Errorf("unsupported output format %q with --watch, use table or json", format)
+				}
+			}
 			if homeNetworkTenantID.IsEmpty() {
-				var (
-					lastUpdatedAt        *timestamppb.Timestamp
-					forwarderTenantID, _ = pbflag.GetTenantID(cmd.Flags(), "forwarder")
-				)
 				defaults, _ = cmd.Flags().GetBool("defaults")
-				for {
-					var page []*packetbroker.RoutingPolicy
-					if defaults {
-						res, err := client.ListDefaultPolicies(ctx, &routingpb.ListDefaultPoliciesRequest{
-							UpdatedSince: lastUpdatedAt,
-						})
-						if err != nil {
-							return err
-						}
-						page = res.Policies
-					} else {
-						req := &routingpb.ListHomeNetworkPoliciesRequest{
-							UpdatedSince: lastUpdatedAt,
-						}
-						if !forwarderTenantID.IsEmpty() {
-							req.ForwarderNetId = uint32(forwarderTenantID.NetID)
-							req.ForwarderTenantId = forwarderTenantID.ID
-						}
-						res, err := client.ListHomeNetworkPolicies(ctx, req)
-						if err != nil {
-							return err
-						}
-						page = res.Policies
-					}
-					if len(page) == 0 {
-						break
-					}
-					policies = append(policies, page...)
-					lastUpdatedAt = page[len(page)-1].GetUpdatedAt()
+				var err error
+				policies, err = st.listPolicies(defaults, forwarderTenantID, nil)
+				if err != nil {
+					return err
 				}
 			} else {
-				offset := uint32(0)
-				for {
-					res, err := client.ListEffectivePolicies(ctx, &routingpb.ListEffectivePoliciesRequest{
-						HomeNetworkNetId:    uint32(homeNetworkTenantID.NetID),
-						HomeNetworkTenantId: homeNetworkTenantID.ID,
-						Offset:              offset,
-					})
-					if err != nil {
-						return err
-					}
-					policies = append(policies, res.Policies...)
-					offset += uint32(len(res.Policies))
-					if len(res.Policies) == 0 || offset >= res.Total {
-						break
-					}
+				var err error
+				policies, err = sdk.NewControlPlane(st.cpConn).ListEffectivePolicies(st.ctx, homeNetworkTenantID).All()
+				if err != nil {
+					return err
 				}
 			}
-			column.WritePolicies(tabout, defaults, policies...)
-			return nil
+			if watch {
+				return st.watchPolicies(cmd.Flags(), defaults, forwarderTenantID, homeNetworkTenantID, policies)
+			}
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("policies", policies), func(w io.Writer) error {
+				return column.WritePolicies(w, defaults, policies...)
+			})
 		},
 	}
-	policySetCmd = &cobra.Command{
+	policySetCmd := &cobra.Command{
 		Use:   "set",
 		Short: "Set a policy",
 		Long: `Set default routing policy of Forwarder, or a specific policy between a
@@ -146,7 +135,7 @@ may use their infrastructure.`,
     $ pbctl policy set --forwarder-net-id 000013 --home-network-net-id 000009 \
       --set-uplink JM --set-downlink JM`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client := routingpb.NewPolicyManagerClient(cpConn)
+			client := routingpb.NewPolicyManagerClient(st.cpConn)
 			forwarderTenantID, _ := pbflag.GetTenantID(cmd.Flags(), "forwarder")
 			if forwarderTenantID.IsEmpty() {
 				return errors.New("pass your NetID (and tenant ID) via --forwarder-net-id (and --forwarder-tenant-id)")
@@ -161,7 +150,7 @@ may use their infrastructure.`,
 			defaults, _ := cmd.Flags().GetBool("defaults")
 			var err error
 			if defaults {
-				_, err = client.SetDefaultPolicy(ctx, &routingpb.SetPolicyRequest{
+				_, err = client.SetDefaultPolicy(st.ctx, &routingpb.SetPolicyRequest{
 					Policy: policy,
 				})
 			} else {
@@ -171,17 +160,19 @@ may use their infrastructure.`,
 				}
 				policy.HomeNetworkNetId = uint32(homeNetworkTenantID.NetID)
 				policy.HomeNetworkTenantId = homeNetworkTenantID.ID
-				_, err = client.SetHomeNetworkPolicy(ctx, &routingpb.SetPolicyRequest{
+				_, err = client.SetHomeNetworkPolicy(st.ctx, &routingpb.SetPolicyRequest{
 					Policy: policy,
 				})
 			}
 			if err != nil {
 				return err
 			}
-			return column.WritePolicies(tabout, defaults, policy)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(policy, func(w io.Writer) error {
+				return column.WritePolicies(w, defaults, policy)
+			})
 		},
 	}
-	policyGetCmd = &cobra.Command{
+	policyGetCmd := &cobra.Command{
 		Use:   "get",
 		Short: "Get a policy",
 		Example: `
@@ -196,7 +187,7 @@ may use their infrastructure.`,
     $ pbctl policy get --forwarder-net-id 000013 --home-network-net-id 000009`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
-				client = routingpb.NewPolicyManagerClient(cpConn)
+				client = routingpb.NewPolicyManagerClient(st.cpConn)
 				res    *routingpb.GetPolicyResponse
 				err    error
 			)
@@ -206,7 +197,7 @@ may use their infrastructure.`,
 			}
 			defaults, _ := cmd.Flags().GetBool("defaults")
 			if defaults {
-				res, err = client.GetDefaultPolicy(ctx, &routingpb.GetDefaultPolicyRequest{
+				res, err = client.GetDefaultPolicy(st.ctx, &routingpb.GetDefaultPolicyRequest{
 					ForwarderNetId:    uint32(forwarderTenantID.NetID),
 					ForwarderTenantId: forwarderTenantID.ID,
 				})
@@ -215,7 +206,7 @@ may use their infrastructure.`,
 				if homeNetworkTenantID.IsEmpty() {
 					return errors.New("pass the Home Network NetID (and tenant ID) via --home-network-net-id (and --home-network-tenant-id)")
 				}
-				res, err = client.GetHomeNetworkPolicy(ctx, &routingpb.GetHomeNetworkPolicyRequest{
+				res, err = client.GetHomeNetworkPolicy(st.ctx, &routingpb.GetHomeNetworkPolicyRequest{
 					ForwarderNetId:      uint32(forwarderTenantID.NetID),
 					ForwarderTenantId:   forwarderTenantID.ID,
 					HomeNetworkNetId:    uint32(homeNetworkTenantID.NetID),
@@ -225,10 +216,12 @@ may use their infrastructure.`,
 			if err != nil {
 				return err
 			}
-			return column.WritePolicies(tabout, defaults, res.Policy)
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).Write(res.Policy, func(w io.Writer) error {
+				return column.WritePolicies(w, defaults, res.Policy)
+			})
 		},
 	}
-	policyDeleteCmd = &cobra.Command{
+	policyDeleteCmd := &cobra.Command{
 		Use:     "delete",
 		Aliases: []string{"rm"},
 		Short:   "Delete a policy",
@@ -243,7 +236,7 @@ may use their infrastructure.`,
   Delete policy between The Things Network (NetID 000013) and Senet (000009):
     $ pbctl policy delete --forwarder-net-id 000013 --home-network-net-id 000009`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			client := routingpb.NewPolicyManagerClient(cpConn)
+			client := routingpb.NewPolicyManagerClient(st.cpConn)
 			forwarderTenantID, _ := pbflag.GetTenantID(cmd.Flags(), "forwarder")
 			if forwarderTenantID.IsEmpty() {
 				return errors.New("pass your NetID (and tenant ID) via --forwarder-net-id (and --forwarder-tenant-id)")
@@ -254,7 +247,7 @@ may use their infrastructure.`,
 			}
 			var err error
 			if defaults, _ := cmd.Flags().GetBool("defaults"); defaults {
-				_, err = client.SetDefaultPolicy(ctx, &routingpb.SetPolicyRequest{
+				_, err = client.SetDefaultPolicy(st.ctx, &routingpb.SetPolicyRequest{
 					Policy: policy,
 				})
 			} else {
@@ -264,7 +257,7 @@ may use their infrastructure.`,
 				}
 				policy.HomeNetworkNetId = uint32(homeNetworkTenantID.NetID)
 				policy.HomeNetworkTenantId = homeNetworkTenantID.ID
-				_, err = client.SetHomeNetworkPolicy(ctx, &routingpb.SetPolicyRequest{
+				_, err = client.SetHomeNetworkPolicy(st.ctx, &routingpb.SetPolicyRequest{
 					Policy: policy,
 				})
 			}
@@ -274,80 +267,41 @@ may use their infrastructure.`,
 			return nil
 		},
 	}
-	policyNetworksCmd = &cobra.Command{
+	policyNetworksCmd := &cobra.Command{
 		Use:     "networks",
 		Aliases: []string{"network", "ns"},
 		Short:   "Show Forwarders and Home Networks with which a policy has been defined",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var (
 				tenantID, _     = pbflag.GetTenantID(cmd.Flags(), "")
-				offset          = uint32(0)
 				idContains, _   = cmd.Flags().GetString("id-contains")
 				nameContains, _ = cmd.Flags().GetString("name-contains")
 			)
 			if tenantID.IsEmpty() {
 				return errors.New("pass the NetID (and tenant ID) via --net-id (and --tenant-id)")
 			}
-			fmt.Fprintln(tabout, "NetID\tTenant ID\tName\tDevAddr Blocks\t")
-			for {
-				res, err := routingpb.NewPolicyManagerClient(cpConn).ListNetworksWithPolicy(ctx,
-					&routingpb.ListNetworksWithPolicyRequest{
-						NetId:            uint32(tenantID.NetID),
-						TenantId:         tenantID.ID,
-						Offset:           offset,
-						TenantIdContains: idContains,
-						NameContains:     nameContains,
-					},
-				)
-				if err != nil {
-					return err
-				}
-				for _, hn := range res.Networks {
-					var row homeNetwork
-					if nwk := hn.GetNetwork(); nwk != nil {
-						row.network = nwk
-						row.tenantID = "-"
-					} else if tnt := hn.GetTenant(); tnt != nil {
-						row.network = tnt
-						row.tenantID = tnt.GetTenantId()
-					}
-					fmt.Fprintf(tabout, "%s\t%s\t%s\t%s\t\n",
-						packetbroker.NetID(row.GetNetId()),
-						row.tenantID,
-						row.GetName(),
-						column.DevAddrBlocks(row.GetDevAddrBlocks()),
-					)
-				}
-				offset += uint32(len(res.Networks))
-				if len(res.Networks) == 0 || offset >= res.Total {
-					break
-				}
+			networks, err := sdk.NewControlPlane(st.cpConn).ListNetworksWithPolicy(st.ctx, &routingpb.ListNetworksWithPolicyRequest{
+				NetId:            uint32(tenantID.NetID),
+				TenantId:         tenantID.ID,
+				TenantIdContains: idContains,
+				NameContains:     nameContains,
+			}).All()
+			if err != nil {
+				return err
 			}
-			return nil
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("networks", networks), func(w io.Writer) error {
+				return writeNetworks(w, networks)
+			})
 		},
 	}
-)
-
-func policySourceFlags() *flag.FlagSet {
-	flags := new(flag.FlagSet)
-	flags.AddFlagSet(pbflag.TenantID("forwarder"))
-	return flags
-}
-
-func policyTargetFlags() *flag.FlagSet {
-	flags := new(flag.FlagSet)
-	flags.AddFlagSet(pbflag.TenantID("home-network"))
-	flags.Bool("defaults", false, "default policy")
-	return flags
-}
 
-func init() {
 	policyCmd.PersistentFlags().AddFlagSet(policySourceFlags())
-	rootCmd.AddCommand(policyCmd)
 
 	policyListCmd.Flags().String("id-contains", "", "filter tenants by ID")
 	policyListCmd.Flags().String("name-contains", "", "filter networks or tenants by name")
 	policyListCmd.Flags().AddFlagSet(policyTargetFlags())
+	policyListCmd.Flags().Bool("watch", false, "keep polling for policy updates and deletions (table or json output)")
+	policyListCmd.Flags().Duration("watch-interval", 30*time.Second, "polling interval with --watch")
 	policyCmd.AddCommand(policyListCmd)
 
 	policySetCmd.Flags().AddFlagSet(policyTargetFlags())
@@ -362,4 +316,238 @@ func init() {
 
 	policyNetworksCmd.Flags().AddFlagSet(pbflag.TenantID(""))
 	policyCmd.AddCommand(policyNetworksCmd)
+	policyCmd.AddCommand(st.newPolicyApplyCommand())
+	policyCmd.AddCommand(st.newPolicyDiffCommand())
+	policyCmd.AddCommand(st.newPolicyExportCommand())
+
+	return policyCmd
+}
+
+// listPolicies lists the default policies or, if defaults is false, the Home Network policies, updated after
+// updatedSince. If updatedSince is nil, all policies are listed.
+// If the Forwarder is not empty, only the Home Network policies of the Forwarder are listed.
+func (st *state) listPolicies(defaults bool, forwarder packetbroker.TenantID, updatedSince *timestamppb.Timestamp) ([]*packetbroker.RoutingPolicy, error) {
+	client := sdk.NewControlPlane(st.cpConn)
+	if defaults {
+		return client.ListDefaultPolicies(st.ctx, updatedSince).All()
+	}
+	return client.ListHomeNetworkPolicies(st.ctx, forwarder, updatedSince).All()
+}
+
+// lastUpdatedAt returns the most recent update timestamp of the policies, or nil if there are no policies.
+func lastUpdatedAt(policies []*packetbroker.RoutingPolicy) *timestamppb.Timestamp {
+	var res *timestamppb.Timestamp
+	for _, p := range policies {
+		if ts := p.GetUpdatedAt(); ts != nil && (res == nil || ts.AsTime().After(res.AsTime())) {
+			res = ts
+		}
+	}
+	return res
+}
+
+// listPolicyUpdates lists the policies that are updated after updatedSince. If the Home Network is empty, the
+// policies are listed like listPolicies. Otherwise, the default policies of all Forwarders and the Home Network
+// policies with the Home Network are listed, as these affect the effective policies of the Home Network. As the
+// Home Network policies cannot be listed by Home Network, the updated policies of all Forwarders are filtered.
+func (st *state) listPolicyUpdates(defaults bool, forwarder, homeNetwork packetbroker.TenantID, updatedSince *timestamppb.Timestamp) ([]*packetbroker.RoutingPolicy, error) {
+	if homeNetwork.IsEmpty() {
+		return st.listPolicies(defaults, forwarder, updatedSince)
+	}
+	res, err := st.listPolicies(true, packetbroker.TenantID{}, updatedSince)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := st.listPolicies(false, packetbroker.TenantID{}, updatedSince)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range policies {
+		if packetbroker.HomeNetworkTenantID(p) == homeNetwork {
+			res = append(res, p)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].GetUpdatedAt().AsTime().Before(res[j].GetUpdatedAt().AsTime())
+	})
+	return res, nil
+}
+
+// isDeletedPolicy returns whether the policy has no uplink and downlink, which is how deleted policies are listed.
+func isDeletedPolicy(p *packetbroker.RoutingPolicy) bool {
+	return p.GetUplink() == nil && p.GetDownlink() == nil
+}
+
+// getEffectivePolicy returns the effective policy of the Forwarder for the Home Network: the policy with the Home
+// Network, or the default policy of the Forwarder. If there is no effective policy, nil is returned.
+func (st *state) getEffectivePolicy(forwarder, homeNetwork packetbroker.TenantID) (*packetbroker.RoutingPolicy, error) {
+	client := routingpb.NewPolicyManagerClient(st.cpConn)
+	res, err := client.GetHomeNetworkPolicy(st.ctx, &routingpb.GetHomeNetworkPolicyRequest{
+		ForwarderNetId:      uint32(forwarder.NetID),
+		ForwarderTenantId:   forwarder.ID,
+		HomeNetworkNetId:    uint32(homeNetwork.NetID),
+		HomeNetworkTenantId: homeNetwork.ID,
+	})
+	switch {
+	case err == nil:
+		return res.GetPolicy(), nil
+	case status.Code(err) != codes.NotFound:
+		return nil, err
+	}
+	res, err = client.GetDefaultPolicy(st.ctx, &routingpb.GetDefaultPolicyRequest{
+		ForwarderNetId:    uint32(forwarder.NetID),
+		ForwarderTenantId: forwarder.ID,
+	})
+	switch {
+	case err == nil:
+		policy := res.GetPolicy()
+		policy.HomeNetworkNetId, policy.HomeNetworkTenantId = uint32(homeNetwork.NetID), homeNetwork.ID
+		return policy, nil
+	case status.Code(err) != codes.NotFound:
+		return nil, err
+	}
+	return nil, nil
+}
+
+// deletedPolicy returns the policy between the Forwarder and Home Network without uplink and downlink.
+func deletedPolicy(forwarder, homeNetwork packetbroker.TenantID) *packetbroker.RoutingPolicy {
+	return &packetbroker.RoutingPolicy{
+		ForwarderNetId:      uint32(forwarder.NetID),
+		ForwarderTenantId:   forwarder.ID,
+		HomeNetworkNetId:    uint32(homeNetwork.NetID),
+		HomeNetworkTenantId: homeNetwork.ID,
+	}
+}
+
+// effectivePolicyUpdates returns the effective policies of the Forwarders of the updated policies that changed
+// compared to the known effective policies, and the effective policies that no longer exist. The known effective
+// policies are updated.
+func (st *state) effectivePolicyUpdates(homeNetwork packetbroker.TenantID, known map[packetbroker.TenantID]*packetbroker.RoutingPolicy, policies []*packetbroker.RoutingPolicy) (updated, deleted []*packetbroker.RoutingPolicy, err error) {
+	var forwarders []packetbroker.TenantID
+	seen := make(map[packetbroker.TenantID]bool)
+	for _, p := range policies {
+		if forwarder := packetbroker.ForwarderTenantID(p); !seen[forwarder] {
+			seen[forwarder] = true
+			forwarders = append(forwarders, forwarder)
+		}
+	}
+	effective := make(map[packetbroker.TenantID]*packetbroker.RoutingPolicy, len(forwarders))
+	for _, forwarder := range forwarders {
+		p, err := st.getEffectivePolicy(forwarder, homeNetwork)
+		if err != nil {
+			return nil, nil, err
+		}
+		effective[forwarder] = p
+	}
+	for _, forwarder := range forwarders {
+		p, old := effective[forwarder], known[forwarder]
+		switch {
+		case p == nil && old != nil:
+			deleted = append(deleted, deletedPolicy(forwarder, homeNetwork))
+			delete(known, forwarder)
+		case p != nil && !proto.Equal(p, old):
+			updated = append(updated, p)
+			known[forwarder] = p
+		}
+	}
+	return updated, deleted, nil
+}
+
+// watchPolicies writes the policies and keeps polling for policy updates (see listPolicyUpdates) after the last seen
+// update timestamp, until the context is done. Tables are written without repeating the header. JSON is written as one
+// policy per line. Deleted policies are written as deleted in tables and without uplink and downlink in JSON.
+//
+// If the Home Network is not empty, the policies are the effective policies of the Home Network. The effective
+// policies are derived again only for the Forwarders of the updated policies.
+func (st *state) watchPolicies(flags *flag.FlagSet, defaults bool, forwarder, homeNetwork packetbroker.TenantID, policies []*packetbroker.RoutingPolicy) error {
+	writeTable := column.WritePolicies
+	write := func(policies, deleted []*packetbroker.RoutingPolicy) error {
+		if printer.GetOutput(flags).Format == printer.JSON {
+			for _, p := range append(policies, deleted...) {
+				buf, err := protojson.Marshal(p)
+				if err != nil {
+					return err
+				}
+				var line bytes.Buffer
+				if err := json.Compact(&line, buf); err != nil {
+					return err
+				}
+				line.WriteByte('\n')
+				if _, err := line.WriteTo(st.Stdout); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := writeTable(st.tabout, defaults, policies...); err != nil {
+			return err
+		}
+		writeTable = column.WritePolicyRows
+		if err := column.WriteDeletedPolicyRows(st.tabout, defaults, deleted...); err != nil {
+			return err
+		}
+		return st.tabout.Flush()
+	}
+	if err := write(policies, nil); err != nil {
+		return err
+	}
+
+	known := make(map[packetbroker.TenantID]*packetbroker.RoutingPolicy, len(policies))
+	if !homeNetwork.IsEmpty() {
+		for _, p := range policies {
+			known[packetbroker.ForwarderTenantID(p)] = p
+		}
+	}
+	interval, _ := flags.GetDuration("watch-interval")
+	updatedSince := lastUpdatedAt(policies)
+	for {
+		select {
+		case <-st.ctx.Done():
+			return st.ctx.Err()
+		case <-time.After(interval):
+		}
+		policies, err := st.listPolicyUpdates(defaults, forwarder, homeNetwork, updatedSince)
+		if err != nil {
+			st.logger.Warn("Failed to list policy updates", zap.Error(err))
+			continue
+		}
+		if len(policies) == 0 {
+			continue
+		}
+		var updated, deleted []*packetbroker.RoutingPolicy
+		if homeNetwork.IsEmpty() {
+			for _, p := range policies {
+				if isDeletedPolicy(p) {
+					deleted = append(deleted, p)
+				} else {
+					updated = append(updated, p)
+				}
+			}
+		} else {
+			updated, deleted, err = st.effectivePolicyUpdates(homeNetwork, known, policies)
+			if err != nil {
+				st.logger.Warn("Failed to get effective policies", zap.Error(err))
+				continue
+			}
+		}
+		updatedSince = lastUpdatedAt(policies)
+		if len(updated) == 0 && len(deleted) == 0 {
+			continue
+		}
+		if err := write(updated, deleted); err != nil {
+			return err
+		}
+	}
+}
+
+func policySourceFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.AddFlagSet(pbflag.TenantID("forwarder"))
+	return flags
+}
+
+func policyTargetFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.AddFlagSet(pbflag.TenantID("home-network"))
+	flags.Bool("defaults", false, "default policy")
+	return flags
 }
@@ -0,0 +1,237 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	routingpb "go.packetbroker.org/api/routing"
+	packetbroker "go.packetbroker.org/api/v3"
+	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/policydoc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// readPolicyDocument reads the policy document from the file, or from stdin if the file is -.
+func (st *state) readPolicyDocument(name string) (*policydoc.Document, error) {
+	if name == "-" {
+		return policydoc.Read(st.Stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	doc, err := policydoc.Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return doc, nil
+}
+
+// documentForwarder returns the Forwarder of the document and the flags.
+// If both are set, they must be equal.
+func documentForwarder(network *policydoc.Network, flagForwarder packetbroker.TenantID) (packetbroker.TenantID, error) {
+	if network == nil {
+		if flagForwarder.IsEmpty() {
+			return packetbroker.TenantID{}, errors.New("pass your NetID (and tenant ID) via --forwarder-net-id (and --forwarder-tenant-id) or in the document")
+		}
+		return flagForwarder, nil
+	}
+	docForwarder, err := network.Parse()
+	if err != nil {
+		return packetbroker.TenantID{}, err
+	}
+	if !flagForwarder.IsEmpty() && flagForwarder != docForwarder {
+		return packetbroker.TenantID{}, fmt.Errorf("forwarder %s in flags differs from forwarder %s in document",
+			policydoc.NewNetwork(flagForwarder), policydoc.NewNetwork(docForwarder))
+	}
+	return docForwarder, nil
+}
+
+// getPolicyDocument returns the default policy and the Home Network policies of the Forwarder.
+// The default policy is nil if the Forwarder has no default policy.
+func (st *state) getPolicyDocument(client routingpb.PolicyManagerClient, forwarder packetbroker.TenantID) (*policydoc.Document, error) {
+	network := policydoc.NewNetwork(forwarder)
+	doc := &policydoc.Document{
+		Forwarder:    &network,
+		HomeNetworks: []policydoc.HomeNetworkPolicy{},
+	}
+
+	res, err := client.GetDefaultPolicy(st.ctx, &routingpb.GetDefaultPolicyRequest{
+		ForwarderNetId:    uint32(forwarder.NetID),
+		ForwarderTenantId: forwarder.ID,
+	})
+	switch {
+	case status.Code(err) == codes.NotFound:
+	case err != nil:
+		return nil, err
+	default:
+		doc.Defaults = policydoc.FromRoutingPolicy(res.Policy)
+	}
+
+	policies, err := st.listPolicies(false, forwarder, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range policies {
+		policy := policydoc.FromRoutingPolicy(p)
+		if policy == nil {
+			continue
+		}
+		doc.HomeNetworks = append(doc.HomeNetworks, policydoc.HomeNetworkPolicy{
+			Network: policydoc.NewNetwork(packetbroker.HomeNetworkTenantID(p)),
+			Policy:  *policy,
+		})
+	}
+	if err := doc.Normalize(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+func formatPolicyLetters(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// writePolicyPlan writes the changes as a table.
+func writePolicyPlan(w io.Writer, changes []policydoc.Change) {
+	fmt.Fprintln(w, "\tHome Network\t\tUplink\tDownlink\t")
+	for _, c := range changes {
+		var (
+			action   = "~"
+			from, to policydoc.Policy
+		)
+		switch {
+		case c.From == nil:
+			action = "+"
+			to = *c.To
+		case c.To == nil:
+			action = "-"
+			from = *c.From
+		default:
+			from, to = *c.From, *c.To
+		}
+		if c.HomeNetwork == nil {
+			fmt.Fprintf(w, "%s\tdefaults\t\t", action)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t", action, c.HomeNetwork.NetID, c.HomeNetwork.ID)
+		}
+		fmt.Fprintf(w, "%s → %s\t%s → %s\t\n",
+			formatPolicyLetters(from.Uplink), formatPolicyLetters(to.Uplink),
+			formatPolicyLetters(from.Downlink), formatPolicyLetters(to.Downlink),
+		)
+	}
+}
+
+func (st *state) newPolicyApplyCommand() *cobra.Command {
+	policyApplyCmd := &cobra.Command{
+		Use:   "apply",
+		Short: "Apply policies from a document",
+		Long: `Apply the default routing policy and the routing policies with Home Networks
+of a Forwarder from a YAML or JSON document.
+
+The policies in the document are compared with the current policies. The plan
+of changes is printed before the changes are applied. Policies with Home
+Networks that are not in the document are deleted only with --prune.
+
+A policy with uplink and downlink "-" allows nothing, which is different from
+no policy: without a policy with a Home Network, the default policy applies.
+The document uses the letters of pbctl policy set:
+
+  forwarder:
+    net-id: "000013"
+    tenant-id: tti
+  defaults:
+    uplink: JMASL
+    downlink: JMA
+  home-networks:
+  - net-id: "000009"
+    uplink: JM
+    downlink: JM
+  - net-id: C00123
+    tenant-id: tenant-b
+    uplink: "-"
+    downlink: "-"`,
+		Example: `
+  Show the changes without applying them:
+    $ pbctl policy apply -f policies.yaml --dry-run
+
+  Apply the policies and delete policies with Home Networks not in the document:
+    $ pbctl policy apply -f policies.yaml --prune`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, _ := cmd.Flags().GetString("file")
+			if file == "" {
+				return errors.New("pass the document via --file")
+			}
+			doc, err := st.readPolicyDocument(file)
+			if err != nil {
+				return err
+			}
+			flagForwarder, _ := pbflag.GetTenantID(cmd.Flags(), "forwarder")
+			forwarder, err := documentForwarder(doc.Forwarder, flagForwarder)
+			if err != nil {
+				return err
+			}
+
+			client := routingpb.NewPolicyManagerClient(st.cpConn)
+			current, err := st.getPolicyDocument(client, forwarder)
+			if err != nil {
+				return err
+			}
+			prune, _ := cmd.Flags().GetBool("prune")
+			var changes []policydoc.Change
+			for _, c := range policydoc.Compare(current, doc) {
+				if prune || doc.Defines(c.HomeNetwork) {
+					changes = append(changes, c)
+				}
+			}
+			if len(changes) == 0 {
+				fmt.Fprintln(st.Stderr, "No changes")
+				return nil
+			}
+			writePolicyPlan(st.tabout, changes)
+			if err := st.tabout.Flush(); err != nil {
+				return err
+			}
+			if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+				return nil
+			}
+
+			for _, c := range changes {
+				var homeNetwork packetbroker.TenantID
+				if c.HomeNetwork != nil {
+					homeNetwork = *c.HomeNetwork
+				}
+				// A nil policy has no uplink and downlink, which deletes the policy.
+				req := &routingpb.SetPolicyRequest{
+					Policy: c.To.RoutingPolicy(forwarder, homeNetwork),
+				}
+				if c.HomeNetwork == nil {
+					_, err = client.SetDefaultPolicy(st.ctx, req)
+				} else {
+					_, err = client.SetHomeNetworkPolicy(st.ctx, req)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			fmt.Fprintf(st.Stderr, "Applied %d changes\n", len(changes))
+			return nil
+		},
+	}
+
+	policyApplyCmd.Flags().StringP("file", "f", "", "YAML or JSON document with policies (- for stdin)")
+	policyApplyCmd.Flags().Bool("prune", false, "delete policies with Home Networks that are not in the document")
+	policyApplyCmd.Flags().Bool("dry-run", false, "print the changes without applying them")
+
+	return policyApplyCmd
+}
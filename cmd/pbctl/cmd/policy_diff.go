@@ -0,0 +1,134 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	flag "github.com/spf13/pflag"
+	routingpb "go.packetbroker.org/api/routing"
+	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/policydoc"
+)
+
+var errPoliciesDiffer = errors.New("policies differ")
+
+// diffLetters writes a cell per letter: the symbol if both have the letter, +symbol if only to has it and -symbol if
+// only from has it.
+func diffLetters(w io.Writer, letters, from, to, symbol string) {
+	for _, r := range letters {
+		inFrom, inTo := strings.ContainsRune(from, r), strings.ContainsRune(to, r)
+		switch {
+		case inFrom && inTo:
+			fmt.Fprint(w, symbol)
+		case inTo:
+			fmt.Fprint(w, "+"+symbol)
+		case inFrom:
+			fmt.Fprint(w, "-"+symbol)
+		}
+		fmt.Fprint(w, "\t")
+	}
+}
+
+// writePolicyDiff writes the changes per flag as a table. Added policies are prefixed with + and removed policies
+// with -, so that an added or removed policy that allows nothing is shown as well.
+func writePolicyDiff(w io.Writer, changes []policydoc.Change) {
+	fmt.Fprintln(w, "\tHome Network\t\tJ\tM\tA\tS\tL\tJ\tM\tA\t")
+	for _, c := range changes {
+		var (
+			action   = "~"
+			from, to policydoc.Policy
+		)
+		switch {
+		case c.From == nil:
+			action = "+"
+			to = *c.To
+		case c.To == nil:
+			action = "-"
+			from = *c.From
+		default:
+			from, to = *c.From, *c.To
+		}
+		if c.HomeNetwork == nil {
+			fmt.Fprintf(w, "%s\tdefaults\t\t", action)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\t", action, c.HomeNetwork.NetID, c.HomeNetwork.ID)
+		}
+		diffLetters(w, "JMASL", from.Uplink, to.Uplink, "▲")
+		diffLetters(w, "JMA", from.Downlink, to.Downlink, "▼")
+		fmt.Fprintln(w)
+	}
+}
+
+// getPolicyDiffDocument returns the document from the file flag, or the policies of the Forwarder in the flags.
+func (st *state) getPolicyDiffDocument(client routingpb.PolicyManagerClient, flags *flag.FlagSet, fileFlag, actor string) (*policydoc.Document, error) {
+	if file, _ := flags.GetString(fileFlag); file != "" {
+		return st.readPolicyDocument(file)
+	}
+	forwarder, _ := pbflag.GetTenantID(flags, actor)
+	if forwarder.IsEmpty() {
+		return nil, fmt.Errorf("pass the NetID (and tenant ID) via --%s-net-id (and --%s-tenant-id) or a document via --%s", actor, actor, fileFlag)
+	}
+	return st.getPolicyDocument(client, forwarder)
+}
+
+func (st *state) newPolicyDiffCommand() *cobra.Command {
+	policyDiffCmd := &cobra.Command{
+		Use:   "diff",
+		Short: "Show differences in policies",
+		Long: `Show the differences in the default routing policy and the routing policies
+with Home Networks between two Forwarders, or between a Forwarder and a document
+exported with pbctl policy export.
+
+The differences are shown per Home Network and per policy letter. Added policies
+are prefixed with + and removed policies are prefixed with -. A policy that
+allows nothing differs from no policy, as without a policy with a Home Network,
+the default policy applies. The command exits with a non-zero exit code when the
+policies differ.`,
+		Example: `
+  Compare the policies of two tenants:
+    $ pbctl policy diff --forwarder-net-id 000013 --forwarder-tenant-id tenant-a \
+      --to-forwarder-net-id 000013 --to-forwarder-tenant-id tenant-b
+
+  Compare an exported document with the current policies:
+    $ pbctl policy diff --from-file policies.yaml --to-forwarder-net-id 000013
+
+  Compare two exported documents:
+    $ pbctl policy diff --from-file yesterday.yaml --to-file today.yaml`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fromFile, _ := cmd.Flags().GetString("from-file")
+			toFile, _ := cmd.Flags().GetString("to-file")
+			if fromFile == "-" && toFile == "-" {
+				return errors.New("read at most one of --from-file and --to-file from stdin")
+			}
+			client := routingpb.NewPolicyManagerClient(st.cpConn)
+			from, err := st.getPolicyDiffDocument(client, cmd.Flags(), "from-file", "forwarder")
+			if err != nil {
+				return err
+			}
+			to, err := st.getPolicyDiffDocument(client, cmd.Flags(), "to-file", "to-forwarder")
+			if err != nil {
+				return err
+			}
+			changes := policydoc.Compare(from, to)
+			if len(changes) == 0 {
+				return nil
+			}
+			writePolicyDiff(st.tabout, changes)
+			if err := st.tabout.Flush(); err != nil {
+				return err
+			}
+			return errPoliciesDiffer
+		},
+	}
+
+	policyDiffCmd.Flags().String("from-file", "", "YAML or JSON document to compare from (- for stdin)")
+	policyDiffCmd.Flags().AddFlagSet(pbflag.TenantID("to-forwarder"))
+	policyDiffCmd.Flags().String("to-file", "", "YAML or JSON document to compare to (- for stdin)")
+
+	return policyDiffCmd
+}
@@ -0,0 +1,81 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	iampb "go.packetbroker.org/api/iam/v2"
+	routingpb "go.packetbroker.org/api/routing"
+	packetbroker "go.packetbroker.org/api/v3"
+	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/sdk"
+)
+
+// listNetworkNames returns the names of the networks and tenants in the catalog.
+func (st *state) listNetworkNames(forwarder packetbroker.TenantID) (map[packetbroker.TenantID]string, error) {
+	var (
+		names = make(map[packetbroker.TenantID]string)
+		it    = sdk.NewIAM(st.iamConn).ListCatalogNetworks(st.ctx, &iampb.ListNetworksRequest{
+			NetId:    uint32(forwarder.NetID),
+			TenantId: forwarder.ID,
+		})
+	)
+	for it.Next() {
+		if nwk := it.Value().GetNetwork(); nwk != nil {
+			names[packetbroker.TenantID{NetID: packetbroker.NetID(nwk.GetNetId())}] = nwk.GetName()
+		} else if tnt := it.Value().GetTenant(); tnt != nil {
+			names[packetbroker.RequestTenantID(tnt)] = tnt.GetName()
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (st *state) newPolicyExportCommand() *cobra.Command {
+	policyExportCmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export policies to a document",
+		Long: `Export the default routing policy and the routing policies with Home Networks
+of a Forwarder to a YAML or JSON document that can be applied with
+pbctl policy apply.
+
+In YAML, the names of the networks and tenants are written as comments.`,
+		Example: `
+  Export the policies of Forwarder network to YAML:
+    $ pbctl policy export --forwarder-net-id 000013 > policies.yaml
+
+  Export the policies of Forwarder tenant to JSON:
+    $ pbctl policy export --forwarder-net-id 000013 --forwarder-tenant-id tti \
+      -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			forwarder, _ := pbflag.GetTenantID(cmd.Flags(), "forwarder")
+			if forwarder.IsEmpty() {
+				return errors.New("pass your NetID (and tenant ID) via --forwarder-net-id (and --forwarder-tenant-id)")
+			}
+			doc, err := st.getPolicyDocument(routingpb.NewPolicyManagerClient(st.cpConn), forwarder)
+			if err != nil {
+				return err
+			}
+			switch output := printer.GetOutput(cmd.Flags()); output.Format {
+			case printer.JSON:
+				return doc.WriteJSON(st.Stdout)
+			case printer.Table, printer.YAML:
+				names, err := st.listNetworkNames(forwarder)
+				if err != nil {
+					return err
+				}
+				return doc.WriteYAML(st.Stdout, names)
+			default:
+				return fmt.Errorf("unsupported output format %q, use json or yaml", output)
+			}
+		},
+	}
+
+	return policyExportCmd
+}
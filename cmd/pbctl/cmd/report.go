@@ -11,24 +11,24 @@ import (
 	"strings"
 
 	"github.com/spf13/cobra"
-	iampb "go.packetbroker.org/api/iam/v2"
 	reportingpb "go.packetbroker.org/api/reporting"
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/pbflag"
 	"go.packetbroker.org/pb/cmd/internal/protojson"
 	"go.packetbroker.org/pb/pkg/csv"
 	"go.packetbroker.org/pb/pkg/graph"
+	"go.packetbroker.org/pb/pkg/sdk"
 )
 
-var (
-	reportCmd = &cobra.Command{
+func (st *state) newReportCommand() *cobra.Command {
+	reportCmd := &cobra.Command{
 		Use:               "report",
 		Aliases:           []string{"reports"},
 		Short:             "Packet Broker report",
-		PersistentPreRunE: prerunConnect,
-		PersistentPostRun: postrunConnect,
+		PersistentPreRunE: st.prerunConnect,
+		PersistentPostRun: st.postrunConnect,
 	}
-	reportRoutedMessagesCmd = &cobra.Command{
+	reportRoutedMessagesCmd := &cobra.Command{
 		Use:     "routed-messages",
 		Aliases: []string{"routedmsgs"},
 		Short:   "Report routed messages",
@@ -38,7 +38,7 @@ var (
 			// Otherwise, the routed messages are either requested for the Forwarder or Home Network, or between the given
 			// Forwarder and Home Network.
 			var (
-				records                     []*reportingpb.RoutedMessagesRecord
+				reqs                        []*reportingpb.GetRoutedMessagesRequest
 				format                      = *cmd.Flags().Lookup("format").Value.(*reportFormat)
 				today, _                    = cmd.Flags().GetBool("today")
 				last30Days, _               = cmd.Flags().GetBool("last-30d")
@@ -110,42 +110,18 @@ var (
 				default:
 					return errors.New("specify either today, last 30 days or a period")
 				}
-				res, err := reportingpb.NewReporterClient(reportsConn).GetRoutedMessages(ctx, req)
-				if err != nil {
-					return err
-				}
-			nextRecord:
-				for _, nr := range res.Records {
-					fID, hnID := packetbroker.ForwarderTenantID(nr), packetbroker.HomeNetworkTenantID(nr)
-					for _, er := range records {
-						// Skip duplicate records.
-						if packetbroker.ForwarderTenantID(er) == fID && packetbroker.HomeNetworkTenantID(er) == hnID {
-							continue nextRecord
-						}
-					}
-					records = append(records, nr)
-				}
+				reqs = append(reqs, req)
+			}
+			records, err := sdk.NewReports(st.reportsConn).GetRoutedMessages(st.ctx, reqs...).All()
+			if err != nil {
+				return err
 			}
 			sort.Sort(byToForwarderHomeNetwork(records))
 
 			// List the listed networks so we can put the names in the report.
-			var (
-				networks      []*packetbroker.NetworkOrTenant
-				offset        uint32
-				catalogClient = iampb.NewCatalogClient(iamConn)
-			)
-			for {
-				res, err := catalogClient.ListNetworks(ctx, &iampb.ListNetworksRequest{
-					Offset: offset,
-				})
-				if err != nil {
-					return fmt.Errorf("list networks: %w", err)
-				}
-				networks = append(networks, res.Networks...)
-				if len(networks) >= int(res.Total) {
-					break
-				}
-				offset += uint32(len(res.Networks))
+			networks, err := sdk.NewIAM(st.iamConn).ListCatalogNetworks(st.ctx, nil).All()
+			if err != nil {
+				return fmt.Errorf("list networks: %w", err)
 			}
 			networkMap := make(map[packetbroker.TenantID]*packetbroker.NetworkOrTenant, len(networks))
 			for _, n := range networks {
@@ -158,14 +134,22 @@ var (
 			}
 
 			// Determine the output: a (temporary) file or stdout.
-			var output io.WriteCloser
-			if outputFile, _ := cmd.Flags().GetString("output-file"); outputFile != "" {
-				var err error
-				output, err = os.Create(outputFile)
+			var output io.Writer
+			outputFile, _ := cmd.Flags().GetString("output-file")
+			if cmd.Flags().Changed("output") {
+				if outputFile != "" {
+					return errors.New("-o is deprecated as shorthand of --output-file, specify only --output-file")
+				}
+				outputFile, _ = cmd.Flags().GetString("output")
+				fmt.Fprintln(st.Stderr, "Specifying the output file with -o is deprecated, use --output-file instead")
+			}
+			if outputFile != "" {
+				f, err := os.Create(outputFile)
 				if err != nil {
 					return fmt.Errorf("create file: %w", err)
 				}
-				defer output.Close()
+				defer f.Close()
+				output = f
 			} else if format.isImage() {
 				wd, _ := os.Getwd()
 				f, err := os.CreateTemp(wd, fmt.Sprintf("pbreport-*%s", format.ext()))
@@ -173,10 +157,10 @@ var (
 					return fmt.Errorf("create temporary file: %w", err)
 				}
 				defer f.Close()
-				fmt.Fprintf(os.Stderr, "Writing to %s\n", f.Name())
+				fmt.Fprintf(st.Stderr, "Writing to %s\n", f.Name())
 				output = f
 			} else {
-				output = os.Stdout
+				output = st.Stdout
 			}
 
 			// Write to the output.
@@ -198,9 +182,9 @@ var (
 					defer w.Close()
 					graph.WriteRoutedMessages(w, records, networkMap, highlight)
 				}()
-				if err := graph.RunDot(ctx, rd, output, string(format)); err != nil {
-					fmt.Fprintln(os.Stderr, "Running a Graphviz command failed. Is Graphviz installed?")
-					fmt.Fprintln(os.Stderr, "Download and install from https://graphviz.org/download/")
+				if err := graph.RunDot(st.ctx, rd, output, string(format)); err != nil {
+					fmt.Fprintln(st.Stderr, "Running a Graphviz command failed. Is Graphviz installed?")
+					fmt.Fprintln(st.Stderr, "Download and install from https://graphviz.org/download/")
 					return err
 				}
 				return nil
@@ -209,10 +193,6 @@ var (
 			}
 		},
 	}
-)
-
-func init() {
-	rootCmd.AddCommand(reportCmd)
 
 	reportRoutedMessagesCmd.Flags().AddFlagSet(pbflag.TenantID(""))
 	reportRoutedMessagesCmd.Flags().AddFlagSet(pbflag.TenantID("forwarder"))
@@ -224,6 +204,12 @@ func init() {
 	reportRoutedMessagesCmd.Flags().VarP(newReportFormat("json"), "format", "f",
 		fmt.Sprintf("format (%s)", strings.Join(reportFormats[:], ", ")),
 	)
-	reportRoutedMessagesCmd.Flags().StringP("output-file", "o", "", "output file")
+	reportRoutedMessagesCmd.Flags().String("output-file", "", "output file")
+	// -o used to be the shorthand of --output-file. It shadows the output format, which is not used by this command,
+	// so that existing scripts keep working.
+	reportRoutedMessagesCmd.Flags().StringP("output", "o", "", "output file (deprecated, use --output-file)")
+	reportRoutedMessagesCmd.Flags().MarkHidden("output")
 	reportCmd.AddCommand(reportRoutedMessagesCmd)
+
+	return reportCmd
 }
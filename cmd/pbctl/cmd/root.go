@@ -5,57 +5,87 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
-	"strings"
 	"text/tabwriter"
 
-	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	"go.packetbroker.org/pb/cmd/internal/config"
 	"go.packetbroker.org/pb/cmd/internal/gen"
 	"go.packetbroker.org/pb/cmd/internal/logging"
+	"go.packetbroker.org/pb/cmd/internal/printer"
 	"go.packetbroker.org/pb/pkg/client"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 )
 
-var (
+// Options configures the root command. Zero values use the standard streams, a logger writing to standard error and
+// client.DialContext.
+type Options struct {
+	// Stdin is the input of documents that are read from "-".
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Logger is the logger. If nil, a logger is created that writes to Stderr, considering the debug flag.
+	Logger *zap.Logger
+	// Dial dials Packet Broker. If nil, client.DialContext is used.
+	Dial client.DialFunc
+}
+
+type state struct {
+	Options
 	cfgFile string
 	debug   bool
 
-	ctx    = context.Background()
+	ctx    context.Context
+	config *config.Config
 	logger *zap.Logger
 	iamConn,
 	cpConn,
 	reportsConn *grpc.ClientConn
-	tabout = tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
-)
+	tabout *tabwriter.Writer
+}
 
-func prerunConnect(cmd *cobra.Command, args []string) error {
-	iamClientConf, err := config.OAuth2Client(ctx, "iam", "networks")
+func (st *state) preRun(cmd *cobra.Command, args []string) error {
+	st.ctx = cmd.Context()
+	var err error
+	if st.config, err = config.Init(st.cfgFile, cmd.Root().PersistentFlags()); err != nil {
+		return err
+	}
+	st.logger = st.Logger
+	if st.logger == nil {
+		st.logger = logging.NewLogger(st.Stderr, st.debug)
+	}
+	return nil
+}
+
+func (st *state) prerunConnect(cmd *cobra.Command, args []string) error {
+	if err := st.preRun(cmd, args); err != nil {
+		return err
+	}
+
+	iamClientConf, err := st.config.OAuth2Client(st.ctx, "iam", "networks")
 	if err != nil {
 		return err
 	}
-	iamConn, err = client.DialContext(ctx, logger, iamClientConf, 443)
+	st.iamConn, err = st.Dial(st.ctx, st.logger, iamClientConf, 443)
 	if err != nil {
 		return err
 	}
 
-	cpClientConf, err := config.OAuth2Client(ctx, "controlplane", "networks")
+	cpClientConf, err := st.config.OAuth2Client(st.ctx, "controlplane", "networks")
 	if err != nil {
 		return err
 	}
-	cpConn, err = client.DialContext(ctx, logger, cpClientConf, 443)
+	st.cpConn, err = st.Dial(st.ctx, st.logger, cpClientConf, 443)
 	if err != nil {
 		return err
 	}
 
-	reportsClientConf, err := config.OAuth2Client(ctx, "reports", "networks")
+	reportsClientConf, err := st.config.OAuth2Client(st.ctx, "reports", "networks")
 	if err != nil {
 		return err
 	}
-	reportsConn, err = client.DialContext(ctx, logger, reportsClientConf, 443)
+	st.reportsConn, err = st.Dial(st.ctx, st.logger, reportsClientConf, 443)
 	if err != nil {
 		return err
 	}
@@ -63,60 +93,85 @@ func prerunConnect(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func postrunConnect(cmd *cobra.Command, args []string) {
-	iamConn.Close()
-	cpConn.Close()
-	reportsConn.Close()
+func (st *state) postrunConnect(cmd *cobra.Command, args []string) {
+	st.iamConn.Close()
+	st.cpConn.Close()
+	st.reportsConn.Close()
 }
 
-var rootCmd = &cobra.Command{
-	Use:          "pbctl",
-	Short:        "pbctl can be used to manage routing policies and list routes.",
-	SilenceUsage: true,
+// flushOutput flushes the tabular output and the logger after running the command and its subcommands.
+func (st *state) flushOutput(cmd *cobra.Command) {
+	if run := cmd.RunE; run != nil {
+		cmd.RunE = func(cmd *cobra.Command, args []string) error {
+			defer st.logger.Sync()
+			defer st.tabout.Flush()
+			return run(cmd, args)
+		}
+	}
+	for _, c := range cmd.Commands() {
+		st.flushOutput(c)
+	}
 }
 
-// Execute runs pbctl.
-func Execute() {
-	logger = logging.GetLogger(debug)
-	defer logger.Sync()
-	defer tabout.Flush()
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+// NewRootCommand returns a new pbctl command. Each command has its own configuration, so commands can be executed
+// concurrently. A command must not be executed concurrently with itself.
+func NewRootCommand(opts Options) *cobra.Command {
+	if opts.Stdin == nil {
+		opts.Stdin = os.Stdin
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+	if opts.Dial == nil {
+		opts.Dial = client.DialContext
+	}
+	st := &state{
+		Options: opts,
+		ctx:     context.Background(),
+		tabout:  tabwriter.NewWriter(opts.Stdout, 0, 0, 3, ' ', 0),
 	}
-}
 
-func init() {
-	cobra.OnInitialize(initConfig)
+	rootCmd := &cobra.Command{
+		Use:               "pbctl",
+		Short:             "pbctl can be used to manage routing policies and list routes.",
+		SilenceUsage:      true,
+		PersistentPreRunE: st.preRun,
+	}
+	rootCmd.SetIn(opts.Stdin)
+	rootCmd.SetOut(opts.Stdout)
+	rootCmd.SetErr(opts.Stderr)
 
 	rootCmd.PersistentFlags().AddFlagSet(config.ClientFlags("iam", "iam.packetbroker.net:443"))
 	rootCmd.PersistentFlags().AddFlagSet(config.ClientFlags("controlplane", "cp.packetbroker.net:443"))
 	rootCmd.PersistentFlags().AddFlagSet(config.ClientFlags("reports", "reports.packetbroker.net:443"))
+	rootCmd.PersistentFlags().AddFlagSet(config.InsecureFlags())
 	rootCmd.PersistentFlags().AddFlagSet(config.OAuth2ClientFlags())
-
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pb.yaml, .pb.yaml)")
-	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug mode")
-
-	rootCmd.AddCommand(gen.Cmd)
+	rootCmd.PersistentFlags().AddFlagSet(config.SecretFileFlags())
+
+	rootCmd.PersistentFlags().AddFlagSet(printer.Flags())
+	rootCmd.PersistentFlags().AddFlagSet(config.ProfileFlags())
+	rootCmd.PersistentFlags().StringVar(&st.cfgFile, "config", "", "config file (default is $HOME/.pb.yaml, .pb.yaml)")
+	rootCmd.PersistentFlags().BoolVarP(&st.debug, "debug", "d", false, "debug mode")
+
+	rootCmd.AddCommand(st.newCatalogCommand())
+	rootCmd.AddCommand(st.newGatewayVisibilityCommand())
+	rootCmd.AddCommand(st.newPolicyCommand())
+	rootCmd.AddCommand(st.newReportCommand())
+	rootCmd.AddCommand(st.newRouteCommand())
+	rootCmd.AddCommand(st.newTargetsCommand())
+	rootCmd.AddCommand(gen.NewCommand())
+
+	st.flushOutput(rootCmd)
+	return rootCmd
 }
 
-func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := homedir.Dir()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
-		viper.AddConfigPath(".")
-		viper.AddConfigPath(home)
-		viper.SetConfigName(".pb")
-		viper.SetConfigType("yaml")
+// Execute runs pbctl.
+func Execute() {
+	if err := NewRootCommand(Options{}).Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
-
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("pb")
-	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-	viper.ReadInConfig()
 }
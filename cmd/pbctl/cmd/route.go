@@ -4,12 +4,14 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"sort"
 
 	"github.com/spf13/cobra"
-	routingpb "go.packetbroker.org/api/routing/v2"
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/column"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/sdk"
 )
 
 type sortRoutesByEndpoint []*packetbroker.DevAddrPrefixRoute
@@ -81,84 +83,77 @@ func (r sortJoinEUIPrefixRoutesByPrefix) Swap(i, j int) {
 	r[i], r[j] = r[j], r[i]
 }
 
-var routeCmd = &cobra.Command{
-	Use:               "route",
-	Aliases:           []string{"routes", "ro"},
-	Short:             "List Packet Broker routes",
-	SilenceUsage:      true,
-	PersistentPreRunE: prerunConnect,
-	PersistentPostRun: postrunConnect,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		var (
-			client        = routingpb.NewRoutesClient(cpConn)
-			offset        = uint32(0)
-			devAddrRoutes []*packetbroker.DevAddrPrefixRoute
+// writeDevAddrRoutes writes the uplink routes as a table.
+func writeDevAddrRoutes(w io.Writer, routes []*packetbroker.DevAddrPrefixRoute) {
+	fmt.Fprintln(w, "DevAddr Prefix\tNetID\tTenant ID\tCluster ID\tTarget\t")
+	for _, p := range routes {
+		fmt.Fprintf(w,
+			"%08X/%d\t%s\t%s\t%s\t%s\t\n",
+			p.GetPrefix().GetValue(),
+			p.GetPrefix().GetLength(),
+			packetbroker.NetID(p.GetNetId()),
+			p.GetTenantId(),
+			p.GetHomeNetworkClusterId(),
+			(*column.Target)(p.Target),
 		)
-		for {
-			res, err := client.ListUplinkRoutes(ctx, &routingpb.ListUplinkRoutesRequest{
-				Offset: offset,
-			})
+	}
+}
+
+// writeJoinEUIPrefixRoutes writes the join-request routes as a table.
+func writeJoinEUIPrefixRoutes(w io.Writer, routes []*packetbroker.JoinEUIPrefixRoute) {
+	fmt.Fprintln(w, "JoinEUI Prefix\tJoin Server ID\tResolver\t")
+	for _, p := range routes {
+		var resolver string
+		if lookup := p.GetLookup(); lookup != nil {
+			resolver = (*column.Target)(lookup).String()
+		} else if fixed := p.GetFixed(); fixed != nil {
+			resolver = (*column.JoinServerFixedEndpoint)(fixed).String()
+		}
+		fmt.Fprintf(w,
+			"%016X/%d\t%14d\t%s\t\n",
+			p.GetPrefix().GetValue(),
+			p.GetPrefix().GetLength(),
+			p.GetId(),
+			resolver,
+		)
+	}
+}
+
+func (st *state) newRouteCommand() *cobra.Command {
+	routeCmd := &cobra.Command{
+		Use:               "route",
+		Aliases:           []string{"routes", "ro"},
+		Short:             "List Packet Broker routes",
+		SilenceUsage:      true,
+		PersistentPreRunE: st.prerunConnect,
+		PersistentPostRun: st.postrunConnect,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := sdk.NewControlPlane(st.cpConn)
+			devAddrRoutes, err := client.ListUplinkRoutes(st.ctx).All()
 			if err != nil {
 				return err
 			}
-			devAddrRoutes = append(devAddrRoutes, res.Routes...)
-			offset += uint32(len(res.Routes))
-			if len(res.Routes) == 0 || offset >= res.Total {
-				break
-			}
-		}
-		sort.Sort(sortDevAddrRoutesByPrefix(devAddrRoutes))
-		fmt.Fprintln(tabout, "DevAddr Prefix\tNetID\tTenant ID\tCluster ID\tTarget\t")
-		for _, p := range devAddrRoutes {
-			fmt.Fprintf(tabout,
-				"%08X/%d\t%s\t%s\t%s\t%s\t\n",
-				p.GetPrefix().GetValue(),
-				p.GetPrefix().GetLength(),
-				packetbroker.NetID(p.GetNetId()),
-				p.GetTenantId(),
-				p.GetHomeNetworkClusterId(),
-				(*column.Target)(p.Target),
-			)
-		}
-		fmt.Fprintln(tabout)
-
-		offset = uint32(0)
-		var joinEUIPrefixRoutes []*packetbroker.JoinEUIPrefixRoute
-		for {
-			res, err := client.ListJoinRequestRoutes(ctx, &routingpb.ListJoinRequestRoutesRequest{
-				Offset: offset,
-			})
+			joinEUIPrefixRoutes, err := client.ListJoinRequestRoutes(st.ctx).All()
 			if err != nil {
 				return err
 			}
-			joinEUIPrefixRoutes = append(joinEUIPrefixRoutes, res.Routes...)
-			offset += uint32(len(res.Routes))
-			if len(res.Routes) == 0 || offset >= res.Total {
-				break
-			}
-		}
-		sort.Sort(sortJoinEUIPrefixRoutesByPrefix(joinEUIPrefixRoutes))
-		fmt.Fprintln(tabout, "JoinEUI Prefix\tJoin Server ID\tResolver\t")
-		for _, p := range joinEUIPrefixRoutes {
-			var resolver string
-			if lookup := p.GetLookup(); lookup != nil {
-				resolver = (*column.Target)(lookup).String()
-			} else if fixed := p.GetFixed(); fixed != nil {
-				resolver = (*column.JoinServerFixedEndpoint)(fixed).String()
+			sort.Sort(sortDevAddrRoutesByPrefix(devAddrRoutes))
+			sort.Sort(sortJoinEUIPrefixRoutesByPrefix(joinEUIPrefixRoutes))
+			writeTable := func(w io.Writer) error {
+				writeDevAddrRoutes(w, devAddrRoutes)
+				fmt.Fprintln(w)
+				writeJoinEUIPrefixRoutes(w, joinEUIPrefixRoutes)
+				return nil
 			}
-			fmt.Fprintf(tabout,
-				"%016X/%d\t%14d\t%s\t\n",
-				p.GetPrefix().GetValue(),
-				p.GetPrefix().GetLength(),
-				p.GetId(),
-				resolver,
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteLists(writeTable,
+				printer.NewList("uplinkRoutes", devAddrRoutes),
+				printer.NewList("joinRequestRoutes", joinEUIPrefixRoutes),
 			)
-		}
+		},
+	}
 
-		return nil
-	},
-}
+	routeCmd.AddCommand(st.newRouteCheckCommand())
+	routeCmd.AddCommand(st.newRouteLookupCommand())
 
-func init() {
-	rootCmd.AddCommand(routeCmd)
+	return routeCmd
 }
@@ -0,0 +1,266 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/spf13/cobra"
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/lorawan"
+	"go.packetbroker.org/pb/pkg/sdk"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// routePrefix is a DevAddr or JoinEUI prefix.
+type routePrefix[P any] interface {
+	comparable
+	Contains(P) bool
+	Overlaps(P) bool
+	String() string
+}
+
+// routeIssue is a problem in the route table.
+type routeIssue struct {
+	Kind        string
+	Prefix      string
+	Owner       string
+	OtherPrefix string
+	OtherOwner  string
+	Detail      string
+}
+
+const (
+	routeIssueOverlap    = "overlap"
+	routeIssueOutOfRange = "out-of-range"
+	routeIssueShadowed   = "shadowed"
+)
+
+func (i routeIssue) key() string {
+	return fmt.Sprintf("%s %s %s %s %s", i.Kind, i.Prefix, i.Owner, i.OtherPrefix, i.OtherOwner)
+}
+
+// routeIssueColumns are the fields of route issue messages.
+var routeIssueColumns = []string{"kind", "prefix", "owner", "otherPrefix", "otherOwner", "detail"}
+
+// message returns the issue as message for machine-readable output. All fields are set, so that the fields do not
+// depend on the kind of issue.
+func (i routeIssue) message() proto.Message {
+	fields := map[string]interface{}{
+		"kind":        i.Kind,
+		"prefix":      i.Prefix,
+		"owner":       i.Owner,
+		"otherPrefix": i.OtherPrefix,
+		"otherOwner":  i.OtherOwner,
+		"detail":      i.Detail,
+	}
+	res, err := structpb.NewStruct(fields)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+func routeIssueMessages(issues []routeIssue) []proto.Message {
+	res := make([]proto.Message, len(issues))
+	for i, issue := range issues {
+		res[i] = issue.message()
+	}
+	return res
+}
+
+// checkedRoute is a route with its prefix and owner for checking.
+type checkedRoute[P routePrefix[P]] struct {
+	prefix P
+	owner  string
+	// network and tenant identify the owner. Routes with the same network and an empty tenant do not conflict.
+	network, tenant string
+	// outOfRange is the reason why the prefix is out of range, if it is.
+	outOfRange string
+}
+
+func (r checkedRoute[P]) conflicts(o checkedRoute[P]) bool {
+	if r.network != o.network {
+		return true
+	}
+	return r.tenant != "" && o.tenant != "" && r.tenant != o.tenant
+}
+
+// checkRoutes returns the routes that are out of range, the overlapping routes that conflict and the routes that are
+// shadowed by more specific routes.
+func checkRoutes[P routePrefix[P]](routes []checkedRoute[P], subtract func(P, []P) []P) []routeIssue {
+	var (
+		issues []routeIssue
+		seen   = make(map[string]bool)
+	)
+	add := func(issue routeIssue) {
+		if key := issue.key(); !seen[key] {
+			seen[key] = true
+			issues = append(issues, issue)
+		}
+	}
+	for i, r := range routes {
+		if r.outOfRange != "" {
+			add(routeIssue{
+				Kind:   routeIssueOutOfRange,
+				Prefix: r.prefix.String(),
+				Owner:  r.owner,
+				Detail: r.outOfRange,
+			})
+		}
+
+		var moreSpecific []P
+		for j, o := range routes {
+			if i == j || !r.prefix.Overlaps(o.prefix) {
+				continue
+			}
+			if i < j && r.conflicts(o) {
+				add(routeIssue{
+					Kind:        routeIssueOverlap,
+					Prefix:      r.prefix.String(),
+					Owner:       r.owner,
+					OtherPrefix: o.prefix.String(),
+					OtherOwner:  o.owner,
+				})
+			}
+			if r.prefix.Contains(o.prefix) && r.prefix != o.prefix {
+				moreSpecific = append(moreSpecific, o.prefix)
+			}
+		}
+		if len(moreSpecific) > 0 && len(subtract(r.prefix, moreSpecific)) == 0 {
+			add(routeIssue{
+				Kind:   routeIssueShadowed,
+				Prefix: r.prefix.String(),
+				Owner:  r.owner,
+				Detail: fmt.Sprintf("covered by %d more specific routes", len(moreSpecific)),
+			})
+		}
+	}
+	return issues
+}
+
+// checkUplinkRoutes checks the uplink routes. Overlapping routes conflict when they are of different NetIDs, or of
+// different tenants of the same NetID. Routes of a network may overlap with routes of its tenants.
+func checkUplinkRoutes(routes []*packetbroker.DevAddrPrefixRoute) []routeIssue {
+	checked := make([]checkedRoute[lorawan.DevAddrPrefix], len(routes))
+	for i, r := range routes {
+		var (
+			netID  = lorawan.NetID(r.GetNetId())
+			prefix = lorawan.FromDevAddrPrefix(r.GetPrefix())
+		)
+		checked[i] = checkedRoute[lorawan.DevAddrPrefix]{
+			prefix:  prefix,
+			owner:   packetbroker.TenantID{NetID: packetbroker.NetID(netID), ID: r.GetTenantId()}.String(),
+			network: netID.String(),
+			tenant:  r.GetTenantId(),
+		}
+		if nwk := netID.DevAddrPrefix(); !nwk.Contains(prefix) {
+			checked[i].outOfRange = fmt.Sprintf("NetID %s has DevAddr prefix %s", netID, nwk)
+		}
+	}
+	return checkRoutes(checked, lorawan.SubtractDevAddrPrefixes)
+}
+
+// checkJoinRequestRoutes checks the join-request routes. Overlapping routes conflict when they are of different Join
+// Servers.
+func checkJoinRequestRoutes(routes []*packetbroker.JoinEUIPrefixRoute) []routeIssue {
+	checked := make([]checkedRoute[lorawan.EUI64Prefix], len(routes))
+	for i, r := range routes {
+		id := fmt.Sprintf("%d", r.GetId())
+		checked[i] = checkedRoute[lorawan.EUI64Prefix]{
+			prefix:  lorawan.FromJoinEUIPrefix(r.GetPrefix()),
+			owner:   "Join Server " + id,
+			network: id,
+		}
+	}
+	return checkRoutes(checked, lorawan.SubtractEUI64Prefixes)
+}
+
+// writeRouteIssues writes the issues as a table.
+func writeRouteIssues(w io.Writer, header string, issues []routeIssue) {
+	fmt.Fprintf(w, "Issue\t%s\tOwner\tOther Prefix\tOther Owner\tDetail\t\n", header)
+	for _, i := range issues {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", i.Kind, i.Prefix, i.Owner, i.OtherPrefix, i.OtherOwner, i.Detail)
+	}
+}
+
+func (st *state) newRouteCheckCommand() *cobra.Command {
+	routeCheckCmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check routes for conflicts",
+		Long: `Check the uplink and join-request routes for conflicts.
+
+The following issues are reported:
+
+  overlap       Overlapping prefixes of different owners. For uplink routes,
+                the owners are different NetIDs or different tenants of the
+                same NetID. Routes of a network may overlap with routes of its
+                tenants. For join-request routes, the owners are different Join
+                Servers.
+  out-of-range  Uplink routes with DevAddr prefixes outside the DevAddr range
+                of the NetID, per LoRaWAN NetID types 0 to 7.
+  shadowed      Routes that are entirely covered by more specific routes.
+
+The command exits with a non-zero exit code when there are issues.`,
+		Example: `
+  Check routes:
+    $ pbctl route check
+
+  Check routes and write the issues as JSON:
+    $ pbctl route check -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client := sdk.NewControlPlane(st.cpConn)
+			devAddrRoutes, err := client.ListUplinkRoutes(st.ctx).All()
+			if err != nil {
+				return err
+			}
+			joinEUIPrefixRoutes, err := client.ListJoinRequestRoutes(st.ctx).All()
+			if err != nil {
+				return err
+			}
+			sort.Sort(sortDevAddrRoutesByPrefix(devAddrRoutes))
+			sort.Sort(sortJoinEUIPrefixRoutesByPrefix(joinEUIPrefixRoutes))
+			var (
+				uplinkIssues      = checkUplinkRoutes(devAddrRoutes)
+				joinRequestIssues = checkJoinRequestRoutes(joinEUIPrefixRoutes)
+				total             = len(uplinkIssues) + len(joinRequestIssues)
+			)
+			if total == 0 && printer.GetOutput(cmd.Flags()).Format == printer.Table {
+				fmt.Fprintln(st.Stderr, "No issues")
+				return nil
+			}
+
+			writeTable := func(w io.Writer) error {
+				if len(uplinkIssues) > 0 {
+					writeRouteIssues(w, "DevAddr Prefix", uplinkIssues)
+				}
+				if len(uplinkIssues) > 0 && len(joinRequestIssues) > 0 {
+					fmt.Fprintln(w)
+				}
+				if len(joinRequestIssues) > 0 {
+					writeRouteIssues(w, "JoinEUI Prefix", joinRequestIssues)
+				}
+				return nil
+			}
+			if err := printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteLists(writeTable,
+				printer.List{Kind: "uplinkRouteIssues", Items: routeIssueMessages(uplinkIssues), Columns: routeIssueColumns},
+				printer.List{Kind: "joinRequestRouteIssues", Items: routeIssueMessages(joinRequestIssues), Columns: routeIssueColumns},
+			); err != nil {
+				return err
+			}
+			if total == 0 {
+				return nil
+			}
+			if err := st.tabout.Flush(); err != nil {
+				return err
+			}
+			return fmt.Errorf("found %d route issues", total)
+		},
+	}
+
+	return routeCheckCmd
+}
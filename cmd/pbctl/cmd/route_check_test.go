@@ -0,0 +1,61 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	packetbroker "go.packetbroker.org/api/v3"
+)
+
+func TestCheckUplinkRoutes(t *testing.T) {
+	route := func(value, length, netID uint32, tenantID string) *packetbroker.DevAddrPrefixRoute {
+		return &packetbroker.DevAddrPrefixRoute{
+			Prefix:   &packetbroker.DevAddrPrefix{Value: value, Length: length},
+			NetId:    netID,
+			TenantId: tenantID,
+		}
+	}
+	issues := checkUplinkRoutes([]*packetbroker.DevAddrPrefixRoute{
+		route(0x26000000, 7, 0x13, ""),
+		route(0x26AB0000, 16, 0x13, "tenant-a"),
+		route(0x26AB8000, 17, 0x13, "tenant-b"),
+		route(0x26AC0000, 16, 0x13, "tenant-c"),
+		route(0x26AC0000, 17, 0x13, "tenant-c"),
+		route(0x26AC8000, 17, 0x13, "tenant-c"),
+		route(0x26FF0000, 16, 0x09, ""),
+	})
+	var actual []string
+	for _, i := range issues {
+		actual = append(actual, i.key())
+	}
+	expected := []string{
+		"overlap 26000000/7 000013 26FF0000/16 000009",
+		"overlap 26AB0000/16 000013/tenant-a 26AB8000/17 000013/tenant-b",
+		"shadowed 26AC0000/16 000013/tenant-c  ",
+		"out-of-range 26FF0000/16 000009  ",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
+
+func TestCheckJoinRequestRoutes(t *testing.T) {
+	issues := checkJoinRequestRoutes([]*packetbroker.JoinEUIPrefixRoute{
+		{Prefix: &packetbroker.JoinEUIPrefix{Value: 0x70B3D50000000000, Length: 24}, Id: 1},
+		{Prefix: &packetbroker.JoinEUIPrefix{Value: 0x70B3D57ED0000000, Length: 36}, Id: 2},
+		{Prefix: &packetbroker.JoinEUIPrefix{Value: 0xEC656E0000000000, Length: 24}, Id: 3},
+		{Prefix: &packetbroker.JoinEUIPrefix{Value: 0xEC656E0000000000, Length: 24}, Id: 3},
+	})
+	var actual []string
+	for _, i := range issues {
+		actual = append(actual, i.key())
+	}
+	expected := []string{
+		"overlap 70B3D50000000000/24 Join Server 1 70B3D57ED0000000/36 Join Server 2",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %q, got %q", expected, actual)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/cmd/internal/column"
+	pbflag "go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/lorawan"
+	"go.packetbroker.org/pb/pkg/sdk"
+)
+
+// lookupUplinkRoutes returns the uplink routes that contain the DevAddr, most specific first.
+func lookupUplinkRoutes(routes []*packetbroker.DevAddrPrefixRoute, devAddr lorawan.DevAddr) []*packetbroker.DevAddrPrefixRoute {
+	var res []*packetbroker.DevAddrPrefixRoute
+	for _, r := range routes {
+		if lorawan.FromDevAddrPrefix(r.GetPrefix()).Match(devAddr) {
+			res = append(res, r)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if li, lj := res[i].GetPrefix().GetLength(), res[j].GetPrefix().GetLength(); li != lj {
+			return li > lj
+		}
+		return sortRoutesByEndpoint(res).Less(i, j)
+	})
+	return res
+}
+
+// lookupJoinRequestRoutes returns the join-request routes that contain the JoinEUI, most specific first.
+func lookupJoinRequestRoutes(routes []*packetbroker.JoinEUIPrefixRoute, joinEUI lorawan.EUI64) []*packetbroker.JoinEUIPrefixRoute {
+	var res []*packetbroker.JoinEUIPrefixRoute
+	for _, r := range routes {
+		if lorawan.FromJoinEUIPrefix(r.GetPrefix()).Match(joinEUI) {
+			res = append(res, r)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if li, lj := res[i].GetPrefix().GetLength(), res[j].GetPrefix().GetLength(); li != lj {
+			return li > lj
+		}
+		return res[i].GetId() < res[j].GetId()
+	})
+	return res
+}
+
+// matchKind returns longest for the most specific prefix length and covering for less specific prefixes.
+func matchKind(length, longest uint32) string {
+	if length == longest {
+		return "longest"
+	}
+	return "covering"
+}
+
+func (st *state) newRouteLookupCommand() *cobra.Command {
+	routeLookupCmd := &cobra.Command{
+		Use:   "lookup",
+		Short: "Look up routes of a DevAddr or JoinEUI",
+		Long: `Look up the uplink routes of a DevAddr and the join-request routes of a
+JoinEUI.
+
+The routes are matched on the longest prefix. The most specific routes are
+marked as longest, followed by the less specific routes that also cover the
+DevAddr or JoinEUI. The command exits with a non-zero exit code when there are
+no matching routes.`,
+		Example: `
+  Look up the network that owns a DevAddr:
+    $ pbctl route lookup --dev-addr 26AB1234
+
+  Look up the Join Server of a JoinEUI:
+    $ pbctl route lookup --join-eui 70B3D57ED0000001`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			devAddr, hasDevAddr := pbflag.GetDevAddr(cmd.Flags(), "dev-addr")
+			joinEUI, hasJoinEUI := pbflag.GetEUI64(cmd.Flags(), "join-eui")
+			if !hasDevAddr && !hasJoinEUI {
+				return errors.New("pass the DevAddr via --dev-addr or the JoinEUI via --join-eui")
+			}
+
+			var (
+				client              = sdk.NewControlPlane(st.cpConn)
+				devAddrRoutes       = []*packetbroker.DevAddrPrefixRoute{}
+				joinEUIPrefixRoutes = []*packetbroker.JoinEUIPrefixRoute{}
+				lists               []printer.List
+			)
+			if hasDevAddr {
+				routes, err := client.ListUplinkRoutes(st.ctx).All()
+				if err != nil {
+					return err
+				}
+				devAddrRoutes = lookupUplinkRoutes(routes, devAddr)
+				lists = append(lists, printer.NewList("uplinkRoutes", devAddrRoutes))
+			}
+			if hasJoinEUI {
+				routes, err := client.ListJoinRequestRoutes(st.ctx).All()
+				if err != nil {
+					return err
+				}
+				joinEUIPrefixRoutes = lookupJoinRequestRoutes(routes, joinEUI)
+				lists = append(lists, printer.NewList("joinRequestRoutes", joinEUIPrefixRoutes))
+			}
+
+			writeTable := func(w io.Writer) error {
+				if len(devAddrRoutes) > 0 {
+					longest := devAddrRoutes[0].GetPrefix().GetLength()
+					fmt.Fprintln(w, "Match\tDevAddr Prefix\tNetID\tTenant ID\tCluster ID\tTarget\t")
+					for _, p := range devAddrRoutes {
+						fmt.Fprintf(w,
+							"%s\t%08X/%d\t%s\t%s\t%s\t%s\t\n",
+							matchKind(p.GetPrefix().GetLength(), longest),
+							p.GetPrefix().GetValue(),
+							p.GetPrefix().GetLength(),
+							packetbroker.NetID(p.GetNetId()),
+							p.GetTenantId(),
+							p.GetHomeNetworkClusterId(),
+							(*column.Target)(p.Target),
+						)
+					}
+				}
+				if len(devAddrRoutes) > 0 && len(joinEUIPrefixRoutes) > 0 {
+					fmt.Fprintln(w)
+				}
+				if len(joinEUIPrefixRoutes) > 0 {
+					longest := joinEUIPrefixRoutes[0].GetPrefix().GetLength()
+					fmt.Fprintln(w, "Match\tJoinEUI Prefix\tJoin Server ID\tResolver\t")
+					for _, p := range joinEUIPrefixRoutes {
+						var resolver string
+						if lookup := p.GetLookup(); lookup != nil {
+							resolver = (*column.Target)(lookup).String()
+						} else if fixed := p.GetFixed(); fixed != nil {
+							resolver = (*column.JoinServerFixedEndpoint)(fixed).String()
+						}
+						fmt.Fprintf(w,
+							"%s\t%016X/%d\t%14d\t%s\t\n",
+							matchKind(p.GetPrefix().GetLength(), longest),
+							p.GetPrefix().GetValue(),
+							p.GetPrefix().GetLength(),
+							p.GetId(),
+							resolver,
+						)
+					}
+				}
+				return nil
+			}
+			if err := printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteLists(writeTable, lists...); err != nil {
+				return err
+			}
+
+			var notFound []string
+			if hasDevAddr && len(devAddrRoutes) == 0 {
+				notFound = append(notFound, fmt.Sprintf("DevAddr %s", devAddr))
+			}
+			if hasJoinEUI && len(joinEUIPrefixRoutes) == 0 {
+				notFound = append(notFound, fmt.Sprintf("JoinEUI %s", joinEUI))
+			}
+			if len(notFound) > 0 {
+				if err := st.tabout.Flush(); err != nil {
+					return err
+				}
+				return fmt.Errorf("no routes for %s", strings.Join(notFound, " and "))
+			}
+			return nil
+		},
+	}
+
+	routeLookupCmd.Flags().AddFlagSet(pbflag.DevAddr("dev-addr", "DevAddr to look up (hex)"))
+	routeLookupCmd.Flags().AddFlagSet(pbflag.EUI64("join-eui", "JoinEUI to look up (hex)"))
+
+	return routeLookupCmd
+}
@@ -0,0 +1,70 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"strconv"
+	"testing"
+
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/pkg/lorawan"
+)
+
+func TestLookupUplinkRoutes(t *testing.T) {
+	routes := []*packetbroker.DevAddrPrefixRoute{
+		{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}, NetId: 0x13},
+		{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26AB0000, Length: 16}, NetId: 0x13, TenantId: "tenant-b"},
+		{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26AB0000, Length: 16}, NetId: 0x13, TenantId: "tenant-a"},
+		{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26AC0000, Length: 16}, NetId: 0x13, TenantId: "tenant-c"},
+		{Prefix: &packetbroker.DevAddrPrefix{Value: 0x00000000, Length: 0}, NetId: 0x0},
+	}
+	for i, tc := range []struct {
+		devAddr  lorawan.DevAddr
+		expected []string
+	}{
+		{
+			devAddr:  0x26AB1234,
+			expected: []string{"tenant-a", "tenant-b", "", ""},
+		},
+		{
+			devAddr:  0x27FFFFFF,
+			expected: []string{"", ""},
+		},
+		{
+			devAddr:  0x48000001,
+			expected: []string{""},
+		},
+	} {
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			res := lookupUplinkRoutes(routes, tc.devAddr)
+			if len(res) != len(tc.expected) {
+				t.Fatalf("expected %d routes, got %d", len(tc.expected), len(res))
+			}
+			for j, r := range res {
+				if r.TenantId != tc.expected[j] {
+					t.Fatalf("expected tenant %q at %d, got %q", tc.expected[j], j, r.TenantId)
+				}
+			}
+			for j := 1; j < len(res); j++ {
+				if res[j].Prefix.Length > res[j-1].Prefix.Length {
+					t.Fatalf("expected most specific routes first")
+				}
+			}
+		})
+	}
+}
+
+func TestLookupJoinRequestRoutes(t *testing.T) {
+	routes := []*packetbroker.JoinEUIPrefixRoute{
+		{Prefix: &packetbroker.JoinEUIPrefix{Value: 0x70B3D57ED0000000, Length: 36}, Id: 2},
+		{Prefix: &packetbroker.JoinEUIPrefix{Value: 0x70B3D50000000000, Length: 24}, Id: 1},
+		{Prefix: &packetbroker.JoinEUIPrefix{Value: 0xEC656E0000000000, Length: 24}, Id: 3},
+	}
+	res := lookupJoinRequestRoutes(routes, 0x70B3D57ED0000001)
+	if len(res) != 2 || res[0].Id != 2 || res[1].Id != 1 {
+		t.Fatalf("unexpected routes %v", res)
+	}
+	if res := lookupJoinRequestRoutes(routes, 0x0000000000000001); len(res) != 0 {
+		t.Fatalf("expected no routes, got %v", res)
+	}
+}
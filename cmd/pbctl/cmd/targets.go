@@ -4,51 +4,41 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/spf13/cobra"
-	routingpb "go.packetbroker.org/api/routing/v2"
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/column"
+	"go.packetbroker.org/pb/cmd/internal/printer"
+	"go.packetbroker.org/pb/pkg/sdk"
 )
 
-var targetsCmd = &cobra.Command{
-	Use:               "targets",
-	Short:             "List Packet Broker targets",
-	SilenceUsage:      true,
-	PersistentPreRunE: prerunConnect,
-	PersistentPostRun: postrunConnect,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		var (
-			client  = routingpb.NewRoutesClient(cpConn)
-			offset  = uint32(0)
-			targets []*packetbroker.NetworkTarget
-		)
-		for {
-			res, err := client.ListNetworkTargets(ctx, &routingpb.ListNetworkTargetsRequest{
-				Offset: offset,
-			})
+func (st *state) newTargetsCommand() *cobra.Command {
+	targetsCmd := &cobra.Command{
+		Use:               "targets",
+		Short:             "List Packet Broker targets",
+		SilenceUsage:      true,
+		PersistentPreRunE: st.prerunConnect,
+		PersistentPostRun: st.postrunConnect,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			targets, err := sdk.NewControlPlane(st.cpConn).ListNetworkTargets(st.ctx).All()
 			if err != nil {
 				return err
 			}
-			targets = append(targets, res.Targets...)
-			offset += uint32(len(res.Targets))
-			if len(res.Targets) == 0 || offset >= res.Total {
-				break
-			}
-		}
-		fmt.Fprintln(tabout, "NetID\tTenant ID\tTarget\t")
-		for _, t := range targets {
-			fmt.Fprintf(tabout,
-				"%s\t%s\t%s\t\n",
-				packetbroker.NetID(t.GetNetId()),
-				t.GetTenantId(),
-				(*column.Target)(t.Target),
-			)
-		}
-		return nil
-	},
-}
+			return printer.New(cmd.Flags(), st.tabout, st.Stdout).WriteList(printer.NewList("targets", targets), func(w io.Writer) error {
+				fmt.Fprintln(w, "NetID\tTenant ID\tTarget\t")
+				for _, t := range targets {
+					fmt.Fprintf(w,
+						"%s\t%s\t%s\t\n",
+						packetbroker.NetID(t.GetNetId()),
+						t.GetTenantId(),
+						(*column.Target)(t.Target),
+					)
+				}
+				return nil
+			})
+		},
+	}
 
-func init() {
-	rootCmd.AddCommand(targetsCmd)
+	return targetsCmd
 }
@@ -0,0 +1,126 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	grpc_zap "github.com/grpc-ecosystem/go-grpc-middleware/logging/zap"
+	"github.com/spf13/cobra"
+	"go.packetbroker.org/pb/cmd/internal/gen"
+	"go.packetbroker.org/pb/cmd/internal/logging"
+	"go.packetbroker.org/pb/pkg/mock"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+)
+
+var (
+	debug bool
+
+	ctx    = context.Background()
+	logger *zap.Logger
+)
+
+var rootCmd = &cobra.Command{
+	Use:   "pbmock",
+	Short: "pbmock serves in-memory Packet Broker services for testing.",
+	Long: `pbmock serves in-memory implementations of the Packet Broker IAM, Control
+Plane, Reporter and Router services on a single address without TLS, and an
+OAuth 2.0 token endpoint that accepts any client credentials.
+
+Requests are not authenticated nor authorized. All data is lost when pbmock
+stops.`,
+	SilenceUsage: true,
+	Example: `
+  Serve the services and the token endpoint:
+    $ pbmock --address localhost:1912 --token-address localhost:8080
+
+  Use pbadmin with the mock server:
+    $ pbadmin network create --net-id 000013 --name "The Things Network" \
+      --insecure --iam-address localhost:1912 \
+      --iam-username admin --iam-password admin
+
+  Use pbctl with the mock server:
+    $ pbctl policy list --defaults --insecure \
+      --iam-address localhost:1912 --controlplane-address localhost:1912 \
+      --reports-address localhost:1912 \
+      --client-id test --client-secret test \
+      --token-url http://localhost:8080/token --token-cache-file ""`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger = logging.GetLogger(debug)
+		defer logger.Sync()
+
+		address, _ := cmd.Flags().GetString("address")
+		lis, err := net.Listen("tcp", address)
+		if err != nil {
+			return err
+		}
+		tokenAddress, _ := cmd.Flags().GetString("token-address")
+		tokenLis, err := net.Listen("tcp", tokenAddress)
+		if err != nil {
+			lis.Close()
+			return err
+		}
+
+		grpcServer := grpc.NewServer(
+			grpc.ChainUnaryInterceptor(grpc_zap.UnaryServerInterceptor(logger)),
+			grpc.ChainStreamInterceptor(grpc_zap.StreamServerInterceptor(logger)),
+		)
+		mock.NewServer().Register(grpcServer)
+
+		mux := http.NewServeMux()
+		mux.Handle("/token", mock.TokenHandler())
+		httpServer := &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		g, ctx := errgroup.WithContext(ctx)
+		g.Go(func() error {
+			return grpcServer.Serve(lis)
+		})
+		g.Go(func() error {
+			if err := httpServer.Serve(tokenLis); !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		})
+		g.Go(func() error {
+			<-ctx.Done()
+			grpcServer.Stop()
+			return httpServer.Close()
+		})
+		logger.Info("Serving",
+			zap.String("address", lis.Addr().String()),
+			zap.String("token_url", fmt.Sprintf("http://%s/token", tokenLis.Addr())),
+		)
+		return g.Wait()
+	},
+}
+
+// Execute runs pbmock.
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.Flags().String("address", "localhost:1912", `address to serve the services on "host:port"`)
+	rootCmd.Flags().String("token-address", "localhost:8080", `address to serve the token endpoint on "host:port"`)
+	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug mode")
+
+	rootCmd.AddCommand(gen.NewCommand())
+}
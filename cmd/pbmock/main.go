@@ -0,0 +1,9 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package main
+
+import "go.packetbroker.org/pb/cmd/pbmock/cmd"
+
+func main() {
+	cmd.Execute()
+}
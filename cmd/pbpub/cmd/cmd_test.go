@@ -0,0 +1,160 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	routingpb "go.packetbroker.org/api/routing"
+	"go.packetbroker.org/pb/cmd/internal/cmdtest"
+	"go.packetbroker.org/pb/pkg/client"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+)
+
+// execute runs pbpub with the arguments and standard input against the test environment.
+func execute(t *testing.T, env *cmdtest.Env, stdin string, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	cmd := NewRootCommand(Options{
+		Stdin:  strings.NewReader(stdin),
+		Stdout: &out,
+		Stderr: &errOut,
+		Logger: zap.NewNop(),
+	})
+	cmd.SetArgs(append(args, env.Args("router")...))
+	err = cmd.Execute()
+	return out.String(), errOut.String(), err
+}
+
+func TestPublishUplink(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	stdin := `{"frequency": 868100000, "phyPayload": {"teaser": {"mac": {"devAddr": 648675328}}}}
+{"frequency": 868300000, "phyPayload": {"teaser": {"mac": {"devAddr": 648675329}}}}
+`
+	if _, _, err := execute(t, env, stdin, "--forwarder-net-id", "000013", "--forwarder-tenant-id", "tti"); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := env.Server.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("Expected 2 calls, got %d", len(calls))
+	}
+	for i, frequency := range []uint64{868100000, 868300000} {
+		req, ok := calls[i].Request.(*routingpb.PublishUplinkMessageRequest)
+		if !ok {
+			t.Fatalf("Expected uplink message, got %s", calls[i].Method)
+		}
+		if req.ForwarderNetId != 0x13 || req.ForwarderTenantId != "tti" || req.GetMessage().GetFrequency() != frequency {
+			t.Fatalf("Unexpected request %v", req)
+		}
+	}
+}
+
+func TestPublishDownlink(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	if _, _, err := execute(t, env, `{"region": "EU_863_870"}`,
+		"--home-network-net-id", "000013", "--forwarder-net-id", "000009",
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := env.Server.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("Expected 1 call, got %d", len(calls))
+	}
+	req, ok := calls[0].Request.(*routingpb.PublishDownlinkMessageRequest)
+	if !ok {
+		t.Fatalf("Expected downlink message, got %s", calls[0].Method)
+	}
+	if req.HomeNetworkNetId != 0x13 || req.ForwarderNetId != 0x9 {
+		t.Fatalf("Unexpected request %v", req)
+	}
+}
+
+func TestPublishInvalid(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	if _, _, err := execute(t, env, `{"unknown": true}`, "--forwarder-net-id", "000013"); err == nil {
+		t.Fatal("Expected error with invalid message")
+	}
+	if _, _, err := execute(t, env, "", "--forwarder-cluster-id", "eu1"); err == nil {
+		t.Fatal("Expected error without role")
+	}
+}
+
+func TestPublishDial(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	var addresses []string
+	cmd := NewRootCommand(Options{
+		Stdin:  strings.NewReader(`{"frequency": 868100000}`),
+		Stdout: io.Discard,
+		Stderr: io.Discard,
+		Logger: zap.NewNop(),
+		Dial: func(ctx context.Context, logger *zap.Logger, conf *client.Config, defaultPort int) (*grpc.ClientConn, error) {
+			addresses = append(addresses, conf.Address)
+			return client.DialContext(ctx, logger, conf, defaultPort)
+		},
+	})
+	cmd.SetArgs(append([]string{"--forwarder-net-id", "000013"}, env.Args("router")...))
+	if err := cmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 1 || addresses[0] != env.Address {
+		t.Fatalf("Expected dial to %s, got %v", env.Address, addresses)
+	}
+	if n := len(env.Server.Calls()); n != 1 {
+		t.Fatalf("Expected 1 call, got %d", n)
+	}
+}
+
+func TestPublishMQTT(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	broker := cmdtest.NewMQTTBroker(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Publish to MQTT until pbpub publishes the message to Packet Broker.
+	go func() {
+		defer cancel()
+		for ctx.Err() == nil {
+			if len(env.Server.Calls()) > 0 {
+				return
+			}
+			if broker.Subscriptions() > 0 {
+				broker.Publish("gateways/gw1/up", []byte(`{"frequency": 868100000}`))
+				broker.Publish("gateways/gw1/up", []byte(`invalid`))
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	cmd := NewRootCommand(Options{
+		Stdout: io.Discard,
+		Stderr: io.Discard,
+		Logger: zap.NewNop(),
+	})
+	cmd.SetArgs(append([]string{
+		"--forwarder-net-id", "000013",
+		"--mqtt-server", broker.Address,
+		"--mqtt-topic", "gateways/+/up",
+	}, env.Args("router")...))
+	if err := cmd.ExecuteContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := env.Server.Calls()
+	if len(calls) == 0 {
+		t.Fatal("Expected a call")
+	}
+	req, ok := calls[0].Request.(*routingpb.PublishUplinkMessageRequest)
+	if !ok {
+		t.Fatalf("Expected uplink message, got %s", calls[0].Method)
+	}
+	if req.ForwarderNetId != 0x13 || req.GetMessage().GetFrequency() != 868100000 {
+		t.Fatalf("Unexpected request %v", req)
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	flag "github.com/spf13/pflag"
+	"go.packetbroker.org/pb/cmd/internal/mqttclient"
+	"go.packetbroker.org/pb/cmd/internal/protojson"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+func mqttFlags() *flag.FlagSet {
+	flags := mqttclient.Flags()
+	flags.String("mqtt-topic", "", "MQTT topic filter to subscribe to")
+	return flags
+}
+
+// readMQTT subscribes to the MQTT topic and publishes the JSON payloads until interrupted. Messages that are invalid
+// or that cannot be published are logged and skipped.
+func (st *state) readMQTT(flags *flag.FlagSet, conf mqttclient.Config, newMessage func() proto.Message, publish func(proto.Message) error) error {
+	topic, _ := flags.GetString("mqtt-topic")
+	if topic == "" {
+		return errors.New("no MQTT topic specified")
+	}
+	ctx, stop := signal.NotifyContext(st.ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// The subscription is made on every connect, as the session does not survive reconnects.
+	ch := make(chan mqtt.Message)
+	client, err := mqttclient.Connect(ctx, st.logger, conf, func(c mqtt.Client) {
+		token := c.Subscribe(topic, conf.QoS, func(_ mqtt.Client, m mqtt.Message) {
+			select {
+			case ch <- m:
+			case <-ctx.Done():
+			}
+		})
+		go func() {
+			<-token.Done()
+			if err := token.Error(); err != nil {
+				st.logger.Error("Failed to subscribe to MQTT topic", zap.String("topic", topic), zap.Error(err))
+				return
+			}
+			st.logger.Info("Subscribed to MQTT topic", zap.String("topic", topic))
+		}()
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Disconnect(250)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m := <-ch:
+			logger := st.logger.With(zap.String("topic", m.Topic()))
+			msg := newMessage()
+			if err := protojson.Unmarshal(m.Payload(), msg); err != nil {
+				logger.Warn("Invalid message", zap.Error(err))
+				continue
+			}
+			if err := publish(msg); err != nil {
+				logger.Warn("Failed to publish message", zap.Error(err))
+			}
+		}
+	}
+}
@@ -9,17 +9,15 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
 
-	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	flag "github.com/spf13/pflag"
-	"github.com/spf13/viper"
 	routingpb "go.packetbroker.org/api/routing"
 	packetbroker "go.packetbroker.org/api/v3"
 	"go.packetbroker.org/pb/cmd/internal/config"
 	"go.packetbroker.org/pb/cmd/internal/gen"
 	"go.packetbroker.org/pb/cmd/internal/logging"
+	"go.packetbroker.org/pb/cmd/internal/mqttclient"
 	"go.packetbroker.org/pb/cmd/internal/pbflag"
 	"go.packetbroker.org/pb/cmd/internal/protojson"
 	"go.packetbroker.org/pb/pkg/client"
@@ -27,23 +25,55 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
 )
 
-var (
+// Options configures the root command. Zero values use the standard streams, a logger writing to standard error and
+// client.DialContext.
+type Options struct {
+	// Stdin is the input of messages to publish.
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Logger is the logger. If nil, a logger is created that writes to Stderr, considering the debug flag.
+	Logger *zap.Logger
+	// Dial dials Packet Broker. If nil, client.DialContext is used.
+	Dial client.DialFunc
+}
+
+type state struct {
+	Options
 	cfgFile string
 	debug   bool
 
-	ctx     = context.Background()
+	ctx     context.Context
+	config  *config.Config
 	logger  *zap.Logger
 	conn    *grpc.ClientConn
 	decoder *json.Decoder
-)
+}
+
+// NewRootCommand returns a new pbpub command. Each command has its own configuration, so commands can be executed
+// concurrently. A command must not be executed concurrently with itself.
+func NewRootCommand(opts Options) *cobra.Command {
+	if opts.Stdin == nil {
+		opts.Stdin = os.Stdin
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+	if opts.Dial == nil {
+		opts.Dial = client.DialContext
+	}
+	st := &state{Options: opts}
 
-var rootCmd = &cobra.Command{
-	Use:          "pbpub",
-	Short:        "pbpub can be used to publish uplink and downlink messages.",
-	SilenceUsage: true,
-	Example: `
+	rootCmd := &cobra.Command{
+		Use:          "pbpub",
+		Short:        "pbpub can be used to publish uplink and downlink messages.",
+		SilenceUsage: true,
+		Example: `
   Publish uplink message as Forwarder:
 
     Publish as network:
@@ -82,59 +112,117 @@ var rootCmd = &cobra.Command{
         --home-network-cluster-id eu1 \
         --forwarder-net-id 000013 \
         --forwarder-tenant-id community \
-        --forwarder-cluster-id eu2 < downlink.json`,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		logger = logging.GetLogger(debug)
-		clientConf, err := config.OAuth2Client(ctx, "router", "networks")
-		if err != nil {
-			return err
-		}
-		conn, err = client.DialContext(ctx, logger, clientConf, 443)
-		if err != nil {
-			return err
-		}
-		decoder = json.NewDecoder(os.Stdin)
-		return nil
-	},
-	RunE: func(cmd *cobra.Command, args []string) error {
-		var (
-			forwarder, forwarderOK     = pbflag.GetEndpoint(cmd.Flags(), "forwarder")
-			homeNetwork, homeNetworkOK = pbflag.GetEndpoint(cmd.Flags(), "home-network")
-		)
-		switch {
-		case homeNetworkOK:
-			return asHomeNetwork(cmd.Flags(), forwarder, homeNetwork)
-		case forwarderOK:
-			return asForwarder(cmd.Flags(), forwarder)
-		}
-		return errors.New("no role specified")
-	},
-	PostRun: func(cmd *cobra.Command, args []string) {
-		logger.Sync()
-		conn.Close()
-	},
+        --forwarder-cluster-id eu2 < downlink.json
+
+  Publish uplink messages received from MQTT as Forwarder:
+    $ pbpub --forwarder-net-id 000013 \
+      --mqtt-server ssl://mqtt.example.com:8883 --mqtt-topic "gateways/+/up"`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := st.preRun(cmd, args); err != nil {
+				return err
+			}
+			clientConf, err := st.config.OAuth2Client(st.ctx, "router", "networks")
+			if err != nil {
+				return err
+			}
+			st.conn, err = st.Dial(st.ctx, st.logger, clientConf, 443)
+			if err != nil {
+				return err
+			}
+			st.decoder = json.NewDecoder(st.Stdin)
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				forwarder, forwarderOK     = pbflag.GetEndpoint(cmd.Flags(), "forwarder")
+				homeNetwork, homeNetworkOK = pbflag.GetEndpoint(cmd.Flags(), "home-network")
+			)
+			switch {
+			case homeNetworkOK:
+				return st.asHomeNetwork(cmd.Flags(), forwarder, homeNetwork)
+			case forwarderOK:
+				return st.asForwarder(cmd.Flags(), forwarder)
+			}
+			return errors.New("no role specified")
+		},
+		PostRun: func(cmd *cobra.Command, args []string) {
+			st.logger.Sync()
+			st.conn.Close()
+		},
+	}
+	rootCmd.SetIn(opts.Stdin)
+	rootCmd.SetOut(opts.Stdout)
+	rootCmd.SetErr(opts.Stderr)
+
+	rootCmd.PersistentFlags().AddFlagSet(config.ClientFlags("router", ""))
+	rootCmd.PersistentFlags().AddFlagSet(config.InsecureFlags())
+	rootCmd.PersistentFlags().AddFlagSet(config.OAuth2ClientFlags())
+	rootCmd.PersistentFlags().AddFlagSet(config.SecretFileFlags())
+
+	rootCmd.PersistentFlags().AddFlagSet(config.ProfileFlags())
+	rootCmd.PersistentFlags().StringVar(&st.cfgFile, "config", "", "config file (default is $HOME/.pb.yaml, .pb.yaml)")
+	rootCmd.PersistentFlags().BoolVarP(&st.debug, "debug", "d", false, "debug mode")
+
+	rootCmd.Flags().AddFlagSet(pbflag.Endpoint("forwarder"))
+	rootCmd.Flags().AddFlagSet(pbflag.Endpoint("home-network"))
+	rootCmd.Flags().AddFlagSet(pbflag.MessageType())
+	rootCmd.Flags().AddFlagSet(mqttFlags())
+
+	rootCmd.AddCommand(gen.NewCommand())
+	return rootCmd
+}
+
+func (st *state) preRun(cmd *cobra.Command, args []string) error {
+	st.ctx = cmd.Context()
+	var err error
+	if st.config, err = config.Init(st.cfgFile, cmd.Root().PersistentFlags()); err != nil {
+		return err
+	}
+	st.logger = st.Logger
+	if st.logger == nil {
+		st.logger = logging.NewLogger(st.Stderr, st.debug)
+	}
+	return nil
 }
 
-func asForwarder(flags *flag.FlagSet, forwarder packetbroker.Endpoint) error {
-	client := routingpb.NewForwarderDataClient(conn)
+// readMessages reads the messages from standard input, or from MQTT if an MQTT server is configured, and publishes
+// them.
+func (st *state) readMessages(flags *flag.FlagSet, newMessage func() proto.Message, publish func(proto.Message) error) error {
+	conf, ok, err := mqttclient.GetConfig(flags)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return st.readMQTT(flags, conf, newMessage, publish)
+	}
 	for {
 		select {
-		case <-ctx.Done():
+		case <-st.ctx.Done():
 			return nil
 		default:
 		}
 
-		msg := pbflag.NewForwarderMessage(flags)
-		if err := protojson.Decode(decoder, msg); err != nil {
+		msg := newMessage()
+		if err := protojson.Decode(st.decoder, msg); err != nil {
 			if !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
 				return err
 			}
 			return nil
 		}
+		if err := publish(msg); err != nil {
+			return err
+		}
+	}
+}
 
+func (st *state) asForwarder(flags *flag.FlagSet, forwarder packetbroker.Endpoint) error {
+	client := routingpb.NewForwarderDataClient(st.conn)
+	return st.readMessages(flags, func() proto.Message {
+		return pbflag.NewForwarderMessage(flags)
+	}, func(msg proto.Message) error {
 		switch msg := msg.(type) {
 		case *packetbroker.UplinkMessage:
-			res, err := client.Publish(ctx, &routingpb.PublishUplinkMessageRequest{
+			res, err := client.Publish(st.ctx, &routingpb.PublishUplinkMessageRequest{
 				ForwarderNetId:     uint32(forwarder.NetID),
 				ForwarderClusterId: forwarder.ClusterID,
 				ForwarderTenantId:  forwarder.TenantID.ID,
@@ -143,43 +231,32 @@ func asForwarder(flags *flag.FlagSet, forwarder packetbroker.Endpoint) error {
 			if err != nil {
 				return err
 			}
-			logger.Info("Published uplink message", zap.String("id", res.Id))
+			st.logger.Info("Published uplink message", zap.String("id", res.Id))
 
 		case *packetbroker.DownlinkMessageDeliveryStateChange:
 			msg.ForwarderNetId = uint32(forwarder.NetID)
 			msg.ForwarderClusterId = forwarder.ClusterID
 			msg.ForwarderTenantId = forwarder.TenantID.ID
-			_, err := client.ReportDownlinkMessageDeliveryState(ctx, &routingpb.DownlinkMessageDeliveryStateChangeRequest{
+			_, err := client.ReportDownlinkMessageDeliveryState(st.ctx, &routingpb.DownlinkMessageDeliveryStateChangeRequest{
 				StateChange: msg,
 			})
 			if err != nil {
 				return err
 			}
-			logger.Info("Published uplink message delivery state change")
+			st.logger.Info("Published uplink message delivery state change")
 		}
-	}
+		return nil
+	})
 }
 
-func asHomeNetwork(flags *flag.FlagSet, forwarder, homeNetwork packetbroker.Endpoint) error {
-	client := routingpb.NewHomeNetworkDataClient(conn)
-	for {
-		select {
-		case <-ctx.Done():
-			return nil
-		default:
-		}
-
-		msg := pbflag.NewHomeNetworkMessage(flags)
-		if err := protojson.Decode(decoder, msg); err != nil {
-			if !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
-				return err
-			}
-			return nil
-		}
-
+func (st *state) asHomeNetwork(flags *flag.FlagSet, forwarder, homeNetwork packetbroker.Endpoint) error {
+	client := routingpb.NewHomeNetworkDataClient(st.conn)
+	return st.readMessages(flags, func() proto.Message {
+		return pbflag.NewHomeNetworkMessage(flags)
+	}, func(msg proto.Message) error {
 		switch msg := msg.(type) {
 		case *packetbroker.DownlinkMessage:
-			res, err := client.Publish(ctx, &routingpb.PublishDownlinkMessageRequest{
+			res, err := client.Publish(st.ctx, &routingpb.PublishDownlinkMessageRequest{
 				HomeNetworkNetId:     uint32(homeNetwork.NetID),
 				HomeNetworkClusterId: homeNetwork.ClusterID,
 				HomeNetworkTenantId:  homeNetwork.TenantID.ID,
@@ -191,7 +268,7 @@ func asHomeNetwork(flags *flag.FlagSet, forwarder, homeNetwork packetbroker.Endp
 			if err != nil {
 				return err
 			}
-			logger.Info("Published downlink message", zap.String("id", res.Id))
+			st.logger.Info("Published downlink message", zap.String("id", res.Id))
 
 		case *packetbroker.UplinkMessageDeliveryStateChange:
 			msg.HomeNetworkNetId = uint32(homeNetwork.NetID)
@@ -200,58 +277,22 @@ func asHomeNetwork(flags *flag.FlagSet, forwarder, homeNetwork packetbroker.Endp
 			msg.ForwarderNetId = uint32(forwarder.NetID)
 			msg.ForwarderClusterId = forwarder.ClusterID
 			msg.ForwarderTenantId = forwarder.TenantID.ID
-			_, err := client.ReportUplinkMessageDeliveryState(ctx, &routingpb.UplinkMessageDeliveryStateChangeRequest{
+			_, err := client.ReportUplinkMessageDeliveryState(st.ctx, &routingpb.UplinkMessageDeliveryStateChangeRequest{
 				StateChange: msg,
 			})
 			if err != nil {
 				return err
 			}
-			logger.Info("Published uplink message delivery state change")
+			st.logger.Info("Published uplink message delivery state change")
 		}
-	}
+		return nil
+	})
 }
 
-// Execute runs pbctl.
+// Execute runs pbpub.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	if err := NewRootCommand(Options{}).Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
-
-func init() {
-	cobra.OnInitialize(initConfig)
-
-	rootCmd.PersistentFlags().AddFlagSet(config.ClientFlags("router", ""))
-	rootCmd.PersistentFlags().AddFlagSet(config.OAuth2ClientFlags())
-
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pb.yaml, .pb.yaml)")
-	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug mode")
-
-	rootCmd.Flags().AddFlagSet(pbflag.Endpoint("forwarder"))
-	rootCmd.Flags().AddFlagSet(pbflag.Endpoint("home-network"))
-	rootCmd.Flags().AddFlagSet(pbflag.MessageType())
-
-	rootCmd.AddCommand(gen.Cmd)
-}
-
-func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := homedir.Dir()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
-		viper.AddConfigPath(".")
-		viper.AddConfigPath(home)
-		viper.SetConfigName(".pb")
-		viper.SetConfigType("yaml")
-	}
-
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("pb")
-	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-	viper.ReadInConfig()
-}
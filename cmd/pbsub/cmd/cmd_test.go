@@ -0,0 +1,1056 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	routingpb "go.packetbroker.org/api/routing"
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/cmd/internal/cmdtest"
+	"go.packetbroker.org/pb/cmd/internal/protojson"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protodelim"
+)
+
+// execute runs pbsub with the arguments against the test environment. The subscription is canceled when publish
+// returns true. The publish function is called repeatedly with the output written so far, until it returns true.
+func execute(t *testing.T, env *cmdtest.Env, publish func(stdout string) bool, args ...string) (stdout, stderr string, err error) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, errOut := new(cmdtest.Buffer), new(cmdtest.Buffer)
+	go func() {
+		defer cancel()
+		for ctx.Err() == nil {
+			if publish(out.String()) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	cmd := NewRootCommand(Options{
+		Stdout: out,
+		Stderr: errOut,
+		Logger: zap.NewNop(),
+	})
+	cmd.SetArgs(append(args, env.Args("router")...))
+	err = cmd.ExecuteContext(ctx)
+	return out.String(), errOut.String(), err
+}
+
+// countMessages returns the number of JSON messages in the output.
+func countMessages(stdout string) int {
+	dec := json.NewDecoder(strings.NewReader(stdout))
+	n := 0
+	for {
+		var msg json.RawMessage
+		if err := dec.Decode(&msg); err != nil {
+			return n
+		}
+		n++
+	}
+}
+
+func TestSubscribeHomeNetwork(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+		},
+	})
+	client := routingpb.NewForwarderDataClient(env.Dial(t))
+
+	// Subscriptions may not be active yet when publishing, so keep publishing until a message is written.
+	stdout, _, err := execute(t, env, func(stdout string) bool {
+		if stdout != "" {
+			return true
+		}
+		_, err := client.Publish(context.Background(), &routingpb.PublishUplinkMessageRequest{
+			ForwarderNetId: 0x9,
+			Message: &packetbroker.UplinkMessage{
+				Frequency: 868100000,
+				PhyPayload: &packetbroker.UplinkMessage_PHYPayload{
+					Teaser: &packetbroker.PHYPayloadTeaser{
+						Payload: &packetbroker.PHYPayloadTeaser_Mac{
+							Mac: &packetbroker.PHYPayloadTeaser_MACPayloadTeaser{DevAddr: 0x26000001},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		return false
+	}, "--home-network-net-id", "000013", "--group", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := new(packetbroker.RoutedUplinkMessage)
+	if err := protojson.Decode(json.NewDecoder(strings.NewReader(stdout)), msg); err != nil {
+		t.Fatalf("Decode output: %v", err)
+	}
+	if msg.ForwarderNetId != 0x9 || msg.HomeNetworkNetId != 0x13 || msg.GetMessage().GetFrequency() != 868100000 {
+		t.Fatalf("Unexpected message %v", msg)
+	}
+
+	var req *routingpb.SubscribeHomeNetworkRequest
+	for _, c := range env.Server.Calls() {
+		if r, ok := c.Request.(*routingpb.SubscribeHomeNetworkRequest); ok {
+			req = r
+		}
+	}
+	if req == nil {
+		t.Fatal("Expected subscription as Home Network")
+	}
+	if req.HomeNetworkNetId != 0x13 || req.Group != "test" || len(req.Filters) != 2 {
+		t.Fatalf("Unexpected request %v", req)
+	}
+}
+
+func TestSubscribeForwarder(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	client := routingpb.NewHomeNetworkDataClient(env.Dial(t))
+
+	stdout, _, err := execute(t, env, func(stdout string) bool {
+		if stdout != "" {
+			return true
+		}
+		_, err := client.Publish(context.Background(), &routingpb.PublishDownlinkMessageRequest{
+			HomeNetworkNetId:  0x13,
+			ForwarderNetId:    0x9,
+			ForwarderTenantId: "tenant",
+			Message:           &packetbroker.DownlinkMessage{Region: packetbroker.Region_EU_863_870},
+		})
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		return false
+	}, "--forwarder-net-id", "000009", "--forwarder-tenant-id", "tenant", "--group", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := new(packetbroker.RoutedDownlinkMessage)
+	if err := protojson.Decode(json.NewDecoder(strings.NewReader(stdout)), msg); err != nil {
+		t.Fatalf("Decode output: %v", err)
+	}
+	if msg.ForwarderNetId != 0x9 || msg.ForwarderTenantId != "tenant" || msg.HomeNetworkNetId != 0x13 {
+		t.Fatalf("Unexpected message %v", msg)
+	}
+}
+
+func TestSubscribeFilters(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+		},
+	})
+	client := routingpb.NewForwarderDataClient(env.Dial(t))
+	publish := func(devAddr uint32) error {
+		_, err := client.Publish(context.Background(), &routingpb.PublishUplinkMessageRequest{
+			ForwarderNetId: 0x9,
+			Message: &packetbroker.UplinkMessage{
+				PhyPayload: &packetbroker.UplinkMessage_PHYPayload{
+					Teaser: &packetbroker.PHYPayloadTeaser{
+						Payload: &packetbroker.PHYPayloadTeaser_Mac{
+							Mac: &packetbroker.PHYPayloadTeaser_MACPayloadTeaser{DevAddr: devAddr},
+						},
+					},
+				},
+			},
+		})
+		return err
+	}
+
+	// Publish a message outside and a message inside the DevAddr prefix, and expect only the latter.
+	stdout, _, err := execute(t, env, func(stdout string) bool {
+		if stdout != "" {
+			return true
+		}
+		for _, devAddr := range []uint32{0x26010001, 0x26000001} {
+			if err := publish(devAddr); err != nil {
+				t.Error(err)
+				return true
+			}
+		}
+		return false
+	}, "--home-network-net-id", "000013", "--dev-addr-prefixes", "26000000/16", "--no-join-requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dec := json.NewDecoder(strings.NewReader(stdout))
+	for dec.More() {
+		msg := new(packetbroker.RoutedUplinkMessage)
+		if err := protojson.Decode(dec, msg); err != nil {
+			t.Fatalf("Decode output: %v", err)
+		}
+		if devAddr := msg.GetMessage().GetPhyPayload().GetTeaser().GetMac().GetDevAddr(); devAddr != 0x26000001 {
+			t.Fatalf("Unexpected DevAddr %08X", devAddr)
+		}
+	}
+
+	var req *routingpb.SubscribeHomeNetworkRequest
+	for _, c := range env.Server.Calls() {
+		if r, ok := c.Request.(*routingpb.SubscribeHomeNetworkRequest); ok {
+			req = r
+		}
+	}
+	if len(req.GetFilters()) != 1 || len(req.Filters[0].GetMac().GetDevAddrPrefixes()) != 1 {
+		t.Fatalf("Unexpected filters %v", req.GetFilters())
+	}
+
+	for _, args := range [][]string{
+		{"--home-network-net-id", "000013", "--no-mac-payloads", "--no-join-requests"},
+		{"--home-network-net-id", "000013", "--no-mac-payloads", "--dev-addr-prefixes", "26000000/16"},
+		{"--home-network-net-id", "000013", "--no-join-requests", "--join-eui-prefixes", "70B3D57ED0000000/40"},
+		{"--forwarder-net-id", "000009", "--no-join-requests"},
+	} {
+		if _, _, err := execute(t, env, func(string) bool { return true }, args...); err == nil {
+			t.Fatalf("Expected error with %v", args)
+		}
+	}
+}
+
+func TestSubscribeWhere(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+		},
+	})
+	client := routingpb.NewForwarderDataClient(env.Dial(t))
+	publish := func(forwarderNetID, devAddr, fPort uint32) error {
+		_, err := client.Publish(context.Background(), &routingpb.PublishUplinkMessageRequest{
+			ForwarderNetId: forwarderNetID,
+			Message: &packetbroker.UplinkMessage{
+				PhyPayload: &packetbroker.UplinkMessage_PHYPayload{
+					Teaser: &packetbroker.PHYPayloadTeaser{
+						Payload: &packetbroker.PHYPayloadTeaser_Mac{
+							Mac: &packetbroker.PHYPayloadTeaser_MACPayloadTeaser{DevAddr: devAddr, FPort: fPort},
+						},
+					},
+				},
+			},
+		})
+		return err
+	}
+
+	// Publish messages that do not match before the message that matches, and expect only the latter.
+	stdout, _, err := execute(t, env, func(stdout string) bool {
+		if stdout != "" {
+			return true
+		}
+		for _, args := range [][3]uint32{
+			{0x9, 0x26000001, 6},
+			{0x13, 0x26000001, 5},
+			{0x9, 0x26000001, 5},
+		} {
+			if err := publish(args[0], args[1], args[2]); err != nil {
+				t.Error(err)
+				return true
+			}
+		}
+		return false
+	}, "--home-network-net-id", "000013", "--format", "ndjson",
+		"--where", "devAddr in 26000000/16 && fPort == 5", "--forwarder-net-id-from", "000009",
+		"--fields", "forwarderNetId,devAddr,fPort",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var msg map[string]any
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			t.Fatalf("Decode output: %v", err)
+		}
+		expected := map[string]any{
+			"forwarderNetId": 9.0,
+			"message": map[string]any{
+				"phyPayload": map[string]any{
+					"teaser": map[string]any{
+						"mac": map[string]any{"devAddr": float64(0x26000001), "fPort": 5.0},
+					},
+				},
+			},
+		}
+		if !reflect.DeepEqual(msg, expected) {
+			t.Fatalf("Unexpected output %s", line)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"--where", "fPort =="},
+		{"--forwarder-net-id-from", "invalid"},
+		{"--fields", "id", "--format", "binary"},
+		{"--fields", "id", "--webhook-url", "http://localhost", "--webhook-format", "protobuf"},
+	} {
+		if _, _, err := execute(t, env, func(string) bool { return true }, append([]string{"--home-network-net-id", "000013"}, args...)...); err == nil {
+			t.Fatalf("Expected error with %v", args)
+		}
+	}
+}
+
+func TestSubscribeReportDeliveryState(t *testing.T) {
+	t.Run("HomeNetwork", func(t *testing.T) {
+		env := cmdtest.NewEnv(t)
+		env.Server.AddNetwork(&packetbroker.Network{
+			NetId: 0x13,
+			DevAddrBlocks: []*packetbroker.DevAddrBlock{
+				{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+			},
+		})
+		client := routingpb.NewForwarderDataClient(env.Dial(t))
+
+		// Keep publishing until the delivery state of a message is reported.
+		stdout, _, err := execute(t, env, func(stdout string) bool {
+			if len(env.Server.UplinkDeliveryStates()) > 0 {
+				return true
+			}
+			if stdout != "" {
+				return false
+			}
+			_, err := client.Publish(context.Background(), &routingpb.PublishUplinkMessageRequest{
+				ForwarderNetId: 0x9,
+				Message: &packetbroker.UplinkMessage{
+					PhyPayload: &packetbroker.UplinkMessage_PHYPayload{
+						Teaser: &packetbroker.PHYPayloadTeaser{
+							Payload: &packetbroker.PHYPayloadTeaser_Mac{
+								Mac: &packetbroker.PHYPayloadTeaser_MACPayloadTeaser{DevAddr: 0x26000001},
+							},
+						},
+					},
+				},
+			})
+			if err != nil {
+				t.Error(err)
+				return true
+			}
+			return false
+		}, "--home-network-net-id", "000013", "--report-delivery-state", "error:NOT_FOUND")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg := new(packetbroker.RoutedUplinkMessage)
+		if err := protojson.Decode(json.NewDecoder(strings.NewReader(stdout)), msg); err != nil {
+			t.Fatalf("Decode output: %v", err)
+		}
+		states := env.Server.UplinkDeliveryStates()
+		if len(states) == 0 {
+			t.Fatal("Expected a delivery state")
+		}
+		if s := states[0]; s.Id != msg.Id || s.ForwarderNetId != 0x9 || s.HomeNetworkNetId != 0x13 ||
+			s.GetError().GetValue() != packetbroker.UplinkMessageProcessingError_NOT_FOUND {
+			t.Fatalf("Unexpected delivery state %v of message %s", s, msg.Id)
+		}
+	})
+
+	t.Run("Forwarder", func(t *testing.T) {
+		env := cmdtest.NewEnv(t)
+		client := routingpb.NewHomeNetworkDataClient(env.Dial(t))
+
+		stdout, _, err := execute(t, env, func(stdout string) bool {
+			if len(env.Server.DownlinkDeliveryStates()) > 0 {
+				return true
+			}
+			if stdout != "" {
+				return false
+			}
+			_, err := client.Publish(context.Background(), &routingpb.PublishDownlinkMessageRequest{
+				HomeNetworkNetId: 0x13,
+				ForwarderNetId:   0x9,
+				Message:          &packetbroker.DownlinkMessage{Region: packetbroker.Region_EU_863_870},
+			})
+			if err != nil {
+				t.Error(err)
+				return true
+			}
+			return false
+		}, "--forwarder-net-id", "000009", "--report-delivery-state", "success")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg := new(packetbroker.RoutedDownlinkMessage)
+		if err := protojson.Decode(json.NewDecoder(strings.NewReader(stdout)), msg); err != nil {
+			t.Fatalf("Decode output: %v", err)
+		}
+		states := env.Server.DownlinkDeliveryStates()
+		if len(states) == 0 {
+			t.Fatal("Expected a delivery state")
+		}
+		if s := states[0]; s.Id != msg.Id || s.ForwarderNetId != 0x9 || s.HomeNetworkNetId != 0x13 || s.GetSuccess() == nil {
+			t.Fatalf("Unexpected delivery state %v of message %s", s, msg.Id)
+		}
+	})
+
+	t.Run("Where", func(t *testing.T) {
+		env := cmdtest.NewEnv(t)
+		env.Server.AddNetwork(&packetbroker.Network{
+			NetId: 0x13,
+			DevAddrBlocks: []*packetbroker.DevAddrBlock{
+				{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+			},
+		})
+		client := routingpb.NewForwarderDataClient(env.Dial(t))
+
+		// Publish a message that is filtered out before a message that is delivered, until a delivery state is reported.
+		stdout, _, err := execute(t, env, func(string) bool {
+			if len(env.Server.UplinkDeliveryStates()) > 0 {
+				return true
+			}
+			for _, fPort := range []uint32{6, 5} {
+				_, err := client.Publish(context.Background(), &routingpb.PublishUplinkMessageRequest{
+					ForwarderNetId: 0x9,
+					Message: &packetbroker.UplinkMessage{
+						PhyPayload: &packetbroker.UplinkMessage_PHYPayload{
+							Teaser: &packetbroker.PHYPayloadTeaser{
+								Payload: &packetbroker.PHYPayloadTeaser_Mac{
+									Mac: &packetbroker.PHYPayloadTeaser_MACPayloadTeaser{DevAddr: 0x26000001, FPort: fPort},
+								},
+							},
+						},
+					},
+				})
+				if err != nil {
+					t.Error(err)
+					return true
+				}
+			}
+			return false
+		}, "--home-network-net-id", "000013", "--format", "ndjson", "--where", "fPort == 5",
+			"--report-delivery-state", "success")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		delivered := make(map[string]bool)
+		for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+			msg := new(packetbroker.RoutedUplinkMessage)
+			if err := protojson.Unmarshal([]byte(line), msg); err != nil {
+				t.Fatalf("Decode output: %v", err)
+			}
+			delivered[msg.Id] = true
+		}
+		for _, s := range env.Server.UplinkDeliveryStates() {
+			if !delivered[s.Id] {
+				t.Fatalf("Unexpected delivery state of message %s that is filtered out", s.Id)
+			}
+		}
+	})
+
+	t.Run("Webhook", func(t *testing.T) {
+		env := cmdtest.NewEnv(t)
+		client := routingpb.NewHomeNetworkDataClient(env.Dial(t))
+		var (
+			mu       sync.Mutex
+			requests int
+		)
+		// Reject the first message, so that it is written to the dead-letter file, and accept the others.
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests++
+			if requests == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+		}))
+		defer server.Close()
+
+		deadLetterFile := filepath.Join(t.TempDir(), "dead-letter.ndjson")
+		_, _, err := execute(t, env, func(string) bool {
+			if len(env.Server.DownlinkDeliveryStates()) > 0 {
+				return true
+			}
+			_, err := client.Publish(context.Background(), &routingpb.PublishDownlinkMessageRequest{
+				HomeNetworkNetId: 0x13,
+				ForwarderNetId:   0x9,
+				Message:          &packetbroker.DownlinkMessage{Region: packetbroker.Region_EU_863_870},
+			})
+			if err != nil {
+				t.Error(err)
+				return true
+			}
+			return false
+		}, "--forwarder-net-id", "000009", "--webhook-url", server.URL, "--webhook-concurrency", "1",
+			"--webhook-dead-letter-file", deadLetterFile, "--report-delivery-state", "success")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf, err := os.ReadFile(deadLetterFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var letter struct {
+			Message json.RawMessage `json:"message"`
+		}
+		if err := json.NewDecoder(bytes.NewReader(buf)).Decode(&letter); err != nil {
+			t.Fatalf("Decode dead letter: %v", err)
+		}
+		undelivered := new(packetbroker.RoutedDownlinkMessage)
+		if err := protojson.Unmarshal(letter.Message, undelivered); err != nil {
+			t.Fatalf("Unmarshal dead letter message: %v", err)
+		}
+		states := env.Server.DownlinkDeliveryStates()
+		if len(states) == 0 {
+			t.Fatal("Expected a delivery state")
+		}
+		for _, s := range states {
+			if s.Id == undelivered.Id {
+				t.Fatalf("Unexpected delivery state of undelivered message %s", s.Id)
+			}
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		env := cmdtest.NewEnv(t)
+		for _, args := range [][]string{
+			{"--home-network-net-id", "000013", "--report-delivery-state", "failure"},
+			{"--home-network-net-id", "000013", "--report-delivery-state", "error:"},
+			{"--home-network-net-id", "000013", "--report-delivery-state", "error:TOO_LATE"},
+			{"--forwarder-net-id", "000009", "--report-delivery-state", "error:NOT_FOUND"},
+		} {
+			if _, _, err := execute(t, env, func(string) bool { return true }, args...); err == nil {
+				t.Fatalf("Expected error with %v", args)
+			}
+		}
+	})
+}
+
+func TestSubscribeReconnect(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	client := routingpb.NewHomeNetworkDataClient(env.Dial(t))
+	publish := func() error {
+		_, err := client.Publish(context.Background(), &routingpb.PublishDownlinkMessageRequest{
+			HomeNetworkNetId: 0x13,
+			ForwarderNetId:   0x9,
+			Message:          &packetbroker.DownlinkMessage{Region: packetbroker.Region_EU_863_870},
+		})
+		return err
+	}
+
+	// Interrupt the subscription after the first message and expect a message on the new subscription.
+	interrupted := false
+	stdout, _, err := execute(t, env, func(stdout string) bool {
+		switch n := countMessages(stdout); {
+		case n >= 2:
+			return true
+		case n == 1 && !interrupted:
+			env.Server.CloseSubscriptions(status.Error(codes.Unavailable, "router restarts"))
+			interrupted = true
+		}
+		if err := publish(); err != nil {
+			t.Error(err)
+			return true
+		}
+		return false
+	}, "--forwarder-net-id", "000009", "--group", "test", "--retry-min-backoff", "10ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countMessages(stdout); n < 2 {
+		t.Fatalf("Expected at least 2 messages, got %d", n)
+	}
+
+	var subscriptions int
+	for _, c := range env.Server.Calls() {
+		if req, ok := c.Request.(*routingpb.SubscribeForwarderRequest); ok {
+			if req.Group != "test" {
+				t.Fatalf("Expected group test, got %q", req.Group)
+			}
+			subscriptions++
+		}
+	}
+	if subscriptions < 2 {
+		t.Fatalf("Expected at least 2 subscriptions, got %d", subscriptions)
+	}
+}
+
+func TestSubscribeRestart(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	client := routingpb.NewHomeNetworkDataClient(env.Dial(t))
+	publish := func() error {
+		_, err := client.Publish(context.Background(), &routingpb.PublishDownlinkMessageRequest{
+			HomeNetworkNetId: 0x13,
+			ForwarderNetId:   0x9,
+			Message:          &packetbroker.DownlinkMessage{Region: packetbroker.Region_EU_863_870},
+		}, grpc.WaitForReady(true))
+		return err
+	}
+
+	// Restart the router after the first message, which cancels the subscription, and expect a message on the new
+	// subscription.
+	restarted := false
+	stdout, _, err := execute(t, env, func(stdout string) bool {
+		switch n := countMessages(stdout); {
+		case n >= 2:
+			return true
+		case n == 1 && !restarted:
+			if err := env.Restart(); err != nil {
+				t.Error(err)
+				return true
+			}
+			restarted = true
+		}
+		// Publishing fails while the router restarts.
+		if err := publish(); err != nil && status.Code(err) != codes.Unavailable {
+			t.Error(err)
+			return true
+		}
+		return false
+	}, "--forwarder-net-id", "000009", "--group", "test", "--retry-min-backoff", "10ms", "--resubscribe-on-close")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := countMessages(stdout); n < 2 {
+		t.Fatalf("Expected at least 2 messages, got %d", n)
+	}
+}
+
+func TestSubscribeMaxRetries(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		err      error
+		args     []string
+		expected codes.Code
+	}{
+		{
+			name:     "NoRetries",
+			err:      status.Error(codes.Unavailable, "router restarts"),
+			args:     []string{"--max-retries", "0"},
+			expected: codes.Unavailable,
+		},
+		{
+			name:     "Permanent",
+			err:      status.Error(codes.PermissionDenied, "no access"),
+			expected: codes.PermissionDenied,
+		},
+		{
+			name:     "Closed",
+			expected: codes.OK,
+		},
+		{
+			name:     "Canceled",
+			err:      status.Error(codes.Canceled, "router stops"),
+			expected: codes.OK,
+		},
+		{
+			name:     "CanceledNoRetries",
+			err:      status.Error(codes.Canceled, "router stops"),
+			args:     []string{"--resubscribe-on-close", "--max-retries", "0"},
+			expected: codes.OK,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			env := cmdtest.NewEnv(t)
+			client := routingpb.NewHomeNetworkDataClient(env.Dial(t))
+
+			// Close the subscription after the first message. The command should fail without resubscribing.
+			closed := false
+			_, _, err := execute(t, env, func(stdout string) bool {
+				if closed {
+					return false
+				}
+				if stdout != "" {
+					env.Server.CloseSubscriptions(tc.err)
+					closed = true
+					return false
+				}
+				_, err := client.Publish(context.Background(), &routingpb.PublishDownlinkMessageRequest{
+					HomeNetworkNetId: 0x13,
+					ForwarderNetId:   0x9,
+					Message:          &packetbroker.DownlinkMessage{Region: packetbroker.Region_EU_863_870},
+				})
+				if err != nil {
+					t.Error(err)
+					return true
+				}
+				return false
+			}, append([]string{"--forwarder-net-id", "000009", "--retry-min-backoff", "10ms"}, tc.args...)...)
+			if status.Code(err) != tc.expected {
+				t.Fatalf("Expected %v, got %v", tc.expected, err)
+			}
+			var subscriptions int
+			for _, c := range env.Server.Calls() {
+				if _, ok := c.Request.(*routingpb.SubscribeForwarderRequest); ok {
+					subscriptions++
+				}
+			}
+			if subscriptions != 1 {
+				t.Fatalf("Expected 1 subscription, got %d", subscriptions)
+			}
+		})
+	}
+}
+
+func TestSubscribeOutput(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	client := routingpb.NewHomeNetworkDataClient(env.Dial(t))
+	publish := func() bool {
+		_, err := client.Publish(context.Background(), &routingpb.PublishDownlinkMessageRequest{
+			HomeNetworkNetId: 0x13,
+			ForwarderNetId:   0x9,
+			Message:          &packetbroker.DownlinkMessage{Region: packetbroker.Region_EU_863_870},
+		})
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		return false
+	}
+
+	t.Run("Binary", func(t *testing.T) {
+		stdout, _, err := execute(t, env, func(stdout string) bool {
+			return stdout != "" || publish()
+		}, "--forwarder-net-id", "000009", "--format", "binary")
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := new(packetbroker.RoutedDownlinkMessage)
+		if err := protodelim.UnmarshalFrom(bufio.NewReader(strings.NewReader(stdout)), msg); err != nil {
+			t.Fatalf("Unmarshal output: %v", err)
+		}
+		if msg.HomeNetworkNetId != 0x13 || msg.GetMessage().GetRegion() != packetbroker.Region_EU_863_870 {
+			t.Fatalf("Unexpected message %v", msg)
+		}
+	})
+
+	t.Run("OutputDir", func(t *testing.T) {
+		dir := t.TempDir()
+		readFiles := func() string {
+			files, _ := filepath.Glob(filepath.Join(dir, "pbsub-*.ndjson"))
+			var res string
+			for _, f := range files {
+				buf, _ := os.ReadFile(f)
+				res += string(buf)
+			}
+			return res
+		}
+		stdout, _, err := execute(t, env, func(string) bool {
+			return readFiles() != "" || publish()
+		}, "--forwarder-net-id", "000009", "--format", "ndjson", "--output-dir", dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stdout != "" {
+			t.Fatalf("Unexpected standard output %q", stdout)
+		}
+		lines := strings.Split(strings.TrimSuffix(readFiles(), "\n"), "\n")
+		for _, line := range lines {
+			msg := new(packetbroker.RoutedDownlinkMessage)
+			if err := protojson.Unmarshal([]byte(line), msg); err != nil {
+				t.Fatalf("Unmarshal line %q: %v", line, err)
+			}
+			if msg.HomeNetworkNetId != 0x13 {
+				t.Fatalf("Unexpected message %v", msg)
+			}
+		}
+	})
+}
+
+func TestSubscribeWebhook(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	client := routingpb.NewHomeNetworkDataClient(env.Dial(t))
+	publish := func() bool {
+		_, err := client.Publish(context.Background(), &routingpb.PublishDownlinkMessageRequest{
+			HomeNetworkNetId: 0x13,
+			ForwarderNetId:   0x9,
+			Message:          &packetbroker.DownlinkMessage{Region: packetbroker.Region_EU_863_870},
+		})
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		return false
+	}
+
+	t.Run("Retry", func(t *testing.T) {
+		var (
+			mu        sync.Mutex
+			requests  int
+			delivered []*http.Request
+			bodies    [][]byte
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			requests++
+			if requests == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			delivered = append(delivered, r)
+			bodies = append(bodies, body)
+		}))
+		defer server.Close()
+
+		_, _, err := execute(t, env, func(string) bool {
+			mu.Lock()
+			n := len(delivered)
+			mu.Unlock()
+			return n > 0 || publish()
+		}, "--forwarder-net-id", "000009",
+			"--webhook-url", server.URL,
+			"--webhook-header", "Authorization: Bearer secret",
+			"--webhook-retry-min-backoff", "10ms",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if len(delivered) == 0 || requests < 2 {
+			t.Fatalf("Expected a delivery after retry, got %d deliveries in %d requests", len(delivered), requests)
+		}
+		if ct := delivered[0].Header.Get("Content-Type"); ct != "application/json" {
+			t.Fatalf("Unexpected content type %q", ct)
+		}
+		if auth := delivered[0].Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Fatalf("Unexpected authorization header %q", auth)
+		}
+		msg := new(packetbroker.RoutedDownlinkMessage)
+		if err := protojson.Unmarshal(bodies[0], msg); err != nil {
+			t.Fatalf("Unmarshal body: %v", err)
+		}
+		if msg.HomeNetworkNetId != 0x13 {
+			t.Fatalf("Unexpected message %v", msg)
+		}
+	})
+
+	t.Run("DeadLetter", func(t *testing.T) {
+		var (
+			mu           sync.Mutex
+			contentTypes []string
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			contentTypes = append(contentTypes, r.Header.Get("Content-Type"))
+			mu.Unlock()
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		deadLetterFile := filepath.Join(t.TempDir(), "dead-letter.ndjson")
+		_, _, err := execute(t, env, func(string) bool {
+			if buf, _ := os.ReadFile(deadLetterFile); len(buf) > 0 {
+				return true
+			}
+			return publish()
+		}, "--forwarder-net-id", "000009",
+			"--webhook-url", server.URL,
+			"--webhook-format", "protobuf",
+			"--webhook-dead-letter-file", deadLetterFile,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mu.Lock()
+		if contentTypes[0] != "application/x-protobuf" {
+			t.Fatalf("Unexpected content type %q", contentTypes[0])
+		}
+		mu.Unlock()
+		buf, err := os.ReadFile(deadLetterFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var letter struct {
+			Error   string          `json:"error"`
+			Message json.RawMessage `json:"message"`
+		}
+		if err := json.NewDecoder(bytes.NewReader(buf)).Decode(&letter); err != nil {
+			t.Fatalf("Decode dead letter: %v", err)
+		}
+		cmdtest.Contains(t, letter.Error, "400")
+		msg := new(packetbroker.RoutedDownlinkMessage)
+		if err := protojson.Unmarshal(letter.Message, msg); err != nil {
+			t.Fatalf("Unmarshal dead letter message: %v", err)
+		}
+		if msg.HomeNetworkNetId != 0x13 {
+			t.Fatalf("Unexpected message %v", msg)
+		}
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		var (
+			mu     sync.Mutex
+			bodies []string
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			mu.Lock()
+			bodies = append(bodies, string(body))
+			mu.Unlock()
+		}))
+		defer server.Close()
+
+		_, _, err := execute(t, env, func(string) bool {
+			mu.Lock()
+			n := len(bodies)
+			mu.Unlock()
+			return n > 0 || publish()
+		}, "--forwarder-net-id", "000009",
+			"--webhook-url", server.URL,
+			"--where", "homeNetworkNetId == 0x13",
+			"--fields", "homeNetworkNetId",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if expected := `{"homeNetworkNetId":19}`; bodies[0] != expected {
+			t.Fatalf("Expected body %s, got %s", expected, bodies[0])
+		}
+	})
+
+	t.Run("Hanging", func(t *testing.T) {
+		var (
+			mu       sync.Mutex
+			requests int
+			hang     = make(chan struct{})
+		)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			requests++
+			mu.Unlock()
+			select {
+			case <-r.Context().Done():
+			case <-hang:
+			}
+		}))
+		defer server.Close()
+		defer close(hang)
+
+		// Keep publishing while the only request slot is taken, so that messages wait for a slot on exit.
+		deadLetterFile := filepath.Join(t.TempDir(), "dead-letter.ndjson")
+		published := 0
+		start := time.Now()
+		_, _, err := execute(t, env, func(string) bool {
+			mu.Lock()
+			n := requests
+			mu.Unlock()
+			if n > 0 {
+				if published++; published > 10 {
+					return true
+				}
+			}
+			return publish()
+		}, "--forwarder-net-id", "000009",
+			"--webhook-url", server.URL,
+			"--webhook-concurrency", "1",
+			"--webhook-timeout", "20s",
+			"--webhook-drain-timeout", "100ms",
+			"--webhook-dead-letter-file", deadLetterFile,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Fatalf("Expected exit within the drain timeout, took %s", elapsed)
+		}
+
+		buf, err := os.ReadFile(deadLetterFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var errs []string
+		dec := json.NewDecoder(bytes.NewReader(buf))
+		for {
+			var letter struct {
+				Error string `json:"error"`
+			}
+			if err := dec.Decode(&letter); err != nil {
+				break
+			}
+			errs = append(errs, letter.Error)
+		}
+		if len(errs) < 2 {
+			t.Fatalf("Expected the pending and waiting messages as dead letters, got %q", errs)
+		}
+		cmdtest.Contains(t, strings.Join(errs, "\n"), "not delivered before exit")
+	})
+}
+
+func TestSubscribeMQTT(t *testing.T) {
+	env := cmdtest.NewEnv(t)
+	env.Server.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+		},
+	})
+	broker := cmdtest.NewMQTTBroker(t)
+	client := routingpb.NewForwarderDataClient(env.Dial(t))
+
+	stdout, _, err := execute(t, env, func(string) bool {
+		if len(broker.Messages()) > 0 {
+			return true
+		}
+		_, err := client.Publish(context.Background(), &routingpb.PublishUplinkMessageRequest{
+			ForwarderNetId:    0x9,
+			ForwarderTenantId: "tti",
+			Message: &packetbroker.UplinkMessage{
+				PhyPayload: &packetbroker.UplinkMessage_PHYPayload{
+					Teaser: &packetbroker.PHYPayloadTeaser{
+						Payload: &packetbroker.PHYPayloadTeaser_Mac{
+							Mac: &packetbroker.PHYPayloadTeaser_MACPayloadTeaser{DevAddr: 0x26000001},
+						},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return true
+		}
+		return false
+	}, "--home-network-net-id", "000013", "--mqtt-server", broker.Address, "--mqtt-qos", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "" {
+		t.Fatalf("Unexpected standard output %q", stdout)
+	}
+
+	msgs := broker.Messages()
+	if msgs[0].Topic != "pb/000009/tti/up" {
+		t.Fatalf("Unexpected topic %q", msgs[0].Topic)
+	}
+	msg := new(packetbroker.RoutedUplinkMessage)
+	if err := protojson.Unmarshal(msgs[0].Payload, msg); err != nil {
+		t.Fatalf("Unmarshal payload: %v", err)
+	}
+	if msg.ForwarderNetId != 0x9 || msg.HomeNetworkNetId != 0x13 {
+		t.Fatalf("Unexpected message %v", msg)
+	}
+}
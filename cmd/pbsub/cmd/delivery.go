@@ -0,0 +1,129 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// reportTimeout is the timeout of reporting a delivery state.
+const reportTimeout = 10 * time.Second
+
+// deliveryState is the delivery state to report for received messages: success or error:<code>. If empty, no
+// delivery state is reported. The delivery state is reported when the sink delivered the message, so messages that are
+// filtered out or cannot be delivered are not reported.
+type deliveryState string
+
+func (s deliveryState) String() string {
+	return string(s)
+}
+
+func (s *deliveryState) Set(v string) error {
+	if v != "" && v != "success" && (!strings.HasPrefix(v, "error:") || v == "error:") {
+		return fmt.Errorf("unrecognized delivery state %q: expect success or error:<code>", v)
+	}
+	*s = deliveryState(v)
+	return nil
+}
+
+func (s deliveryState) Type() string {
+	return "deliveryState"
+}
+
+// errorCode returns the error code, if the delivery state is an error.
+func (s deliveryState) errorCode() (string, bool) {
+	if !strings.HasPrefix(string(s), "error:") {
+		return "", false
+	}
+	return strings.ToUpper(strings.TrimPrefix(string(s), "error:")), true
+}
+
+// enumNames returns the names of the enum values in order of their numbers.
+func enumNames(names map[int32]string) string {
+	numbers := make([]int, 0, len(names))
+	for n := range names {
+		numbers = append(numbers, int(n))
+	}
+	sort.Ints(numbers)
+	res := make([]string, len(numbers))
+	for i, n := range numbers {
+		res[i] = names[int32(n)]
+	}
+	return strings.Join(res, ", ")
+}
+
+// uplinkStateChange returns a function that returns the delivery state change of a received uplink message.
+func (s deliveryState) uplinkStateChange() (func(*packetbroker.RoutedUplinkMessage) *packetbroker.UplinkMessageDeliveryStateChange, error) {
+	code, isError := s.errorCode()
+	var value packetbroker.UplinkMessageProcessingError
+	if isError {
+		n, ok := packetbroker.UplinkMessageProcessingError_value[code]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized uplink message processing error %q: expect one of %s",
+				code, enumNames(packetbroker.UplinkMessageProcessingError_name))
+		}
+		value = packetbroker.UplinkMessageProcessingError(n)
+	}
+	return func(msg *packetbroker.RoutedUplinkMessage) *packetbroker.UplinkMessageDeliveryStateChange {
+		res := &packetbroker.UplinkMessageDeliveryStateChange{
+			ForwarderNetId:       msg.ForwarderNetId,
+			ForwarderTenantId:    msg.ForwarderTenantId,
+			ForwarderClusterId:   msg.ForwarderClusterId,
+			HomeNetworkNetId:     msg.HomeNetworkNetId,
+			HomeNetworkTenantId:  msg.HomeNetworkTenantId,
+			HomeNetworkClusterId: msg.HomeNetworkClusterId,
+			Id:                   msg.Id,
+		}
+		if isError {
+			res.Result = &packetbroker.UplinkMessageDeliveryStateChange_Error{
+				Error: &packetbroker.UplinkMessageProcessingErrorValue{Value: value},
+			}
+		} else {
+			res.Result = &packetbroker.UplinkMessageDeliveryStateChange_Success{
+				Success: &emptypb.Empty{},
+			}
+		}
+		return res
+	}, nil
+}
+
+// downlinkStateChange returns a function that returns the delivery state change of a received downlink message.
+func (s deliveryState) downlinkStateChange() (func(*packetbroker.RoutedDownlinkMessage) *packetbroker.DownlinkMessageDeliveryStateChange, error) {
+	code, isError := s.errorCode()
+	var value packetbroker.DownlinkMessageProcessingError
+	if isError {
+		n, ok := packetbroker.DownlinkMessageProcessingError_value[code]
+		if !ok {
+			return nil, fmt.Errorf("unrecognized downlink message processing error %q: expect one of %s",
+				code, enumNames(packetbroker.DownlinkMessageProcessingError_name))
+		}
+		value = packetbroker.DownlinkMessageProcessingError(n)
+	}
+	return func(msg *packetbroker.RoutedDownlinkMessage) *packetbroker.DownlinkMessageDeliveryStateChange {
+		res := &packetbroker.DownlinkMessageDeliveryStateChange{
+			ForwarderNetId:       msg.ForwarderNetId,
+			ForwarderTenantId:    msg.ForwarderTenantId,
+			ForwarderClusterId:   msg.ForwarderClusterId,
+			HomeNetworkNetId:     msg.HomeNetworkNetId,
+			HomeNetworkTenantId:  msg.HomeNetworkTenantId,
+			HomeNetworkClusterId: msg.HomeNetworkClusterId,
+			Id:                   msg.Id,
+		}
+		if isError {
+			res.Result = &packetbroker.DownlinkMessageDeliveryStateChange_Error{
+				Error: &packetbroker.DownlinkMessageProcessingErrorValue{Value: value},
+			}
+		} else {
+			res.Result = &packetbroker.DownlinkMessageDeliveryStateChange_Success{
+				Success: &emptypb.Empty{},
+			}
+		}
+		return res
+	}, nil
+}
@@ -0,0 +1,99 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+
+	flag "github.com/spf13/pflag"
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/cmd/internal/pbflag"
+	"go.packetbroker.org/pb/pkg/lorawan"
+)
+
+var filterFlagNames = []string{
+	"dev-addr-prefixes",
+	"join-eui-prefixes",
+	"dev-eui-prefixes",
+	"no-mac-payloads",
+	"no-join-requests",
+}
+
+func filterFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.AddFlagSet(pbflag.DevAddrPrefixes("dev-addr-prefixes", "subscribe to MAC payloads with DevAddr prefixes (default all)"))
+	flags.AddFlagSet(pbflag.EUI64Prefixes("join-eui-prefixes", "subscribe to join-requests with JoinEUI prefixes (default all)"))
+	flags.AddFlagSet(pbflag.EUI64Prefixes("dev-eui-prefixes", "subscribe to join-requests with DevEUI prefixes (default all)"))
+	flags.Bool("no-mac-payloads", false, "do not subscribe to MAC payloads")
+	flags.Bool("no-join-requests", false, "do not subscribe to join-requests")
+	return flags
+}
+
+// filtersChanged returns whether any of the filter flags is set.
+func filtersChanged(flags *flag.FlagSet) bool {
+	for _, name := range filterFlagNames {
+		if flags.Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// routingFilters returns the routing filters of a Home Network subscription configured by the flags.
+// Join-requests match if both the JoinEUI and the DevEUI match any of the respective prefixes.
+func routingFilters(flags *flag.FlagSet) ([]*packetbroker.RoutingFilter, error) {
+	var (
+		devAddrPrefixes   = pbflag.GetDevAddrPrefixes(flags, "dev-addr-prefixes")
+		joinEUIPrefixes   = pbflag.GetEUI64Prefixes(flags, "join-eui-prefixes")
+		devEUIPrefixes    = pbflag.GetEUI64Prefixes(flags, "dev-eui-prefixes")
+		noMACPayloads, _  = flags.GetBool("no-mac-payloads")
+		noJoinRequests, _ = flags.GetBool("no-join-requests")
+	)
+	switch {
+	case noMACPayloads && noJoinRequests:
+		return nil, errors.New("cannot exclude both MAC payloads and join-requests")
+	case noMACPayloads && len(devAddrPrefixes) > 0:
+		return nil, errors.New("cannot filter DevAddr prefixes when excluding MAC payloads")
+	case noJoinRequests && (len(joinEUIPrefixes) > 0 || len(devEUIPrefixes) > 0):
+		return nil, errors.New("cannot filter JoinEUI and DevEUI prefixes when excluding join-requests")
+	}
+
+	var res []*packetbroker.RoutingFilter
+	if !noMACPayloads {
+		mac := &packetbroker.RoutingFilter_MACPayload{}
+		for _, p := range devAddrPrefixes {
+			mac.DevAddrPrefixes = append(mac.DevAddrPrefixes, p.Proto())
+		}
+		res = append(res, &packetbroker.RoutingFilter{
+			Message: &packetbroker.RoutingFilter_Mac{
+				Mac: mac,
+			},
+		})
+	}
+	if !noJoinRequests {
+		// An empty prefix matches all EUIs.
+		if len(joinEUIPrefixes) == 0 {
+			joinEUIPrefixes = make([]lorawan.EUI64Prefix, 1)
+		}
+		if len(devEUIPrefixes) == 0 {
+			devEUIPrefixes = make([]lorawan.EUI64Prefix, 1)
+		}
+		joinRequest := &packetbroker.RoutingFilter_JoinRequest{}
+		for _, joinEUI := range joinEUIPrefixes {
+			for _, devEUI := range devEUIPrefixes {
+				joinRequest.EuiPrefixes = append(joinRequest.EuiPrefixes, &packetbroker.RoutingFilter_JoinRequest_EUIPrefixes{
+					JoinEui:       uint64(joinEUI.Value),
+					JoinEuiLength: uint32(joinEUI.Length),
+					DevEui:        uint64(devEUI.Value),
+					DevEuiLength:  uint32(devEUI.Length),
+				})
+			}
+		}
+		res = append(res, &packetbroker.RoutingFilter{
+			Message: &packetbroker.RoutingFilter_JoinRequest_{
+				JoinRequest: joinRequest,
+			},
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,95 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"go.packetbroker.org/pb/cmd/internal/protojson"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+)
+
+type outputFormat string
+
+var outputFormats = [...]string{
+	"json",
+	"ndjson",
+	"binary",
+}
+
+func newOutputFormat(defaultValue string) *outputFormat {
+	f := outputFormat(defaultValue)
+	return &f
+}
+
+func (f outputFormat) String() string {
+	return string(f)
+}
+
+func (f *outputFormat) Set(s string) error {
+	for _, sf := range outputFormats {
+		if sf == s {
+			*f = outputFormat(s)
+			return nil
+		}
+	}
+	return fmt.Errorf("unrecognized format %q", s)
+}
+
+func (f outputFormat) Type() string {
+	return "outputFormat"
+}
+
+func (f outputFormat) ext() string {
+	if f == "binary" {
+		return ".bin"
+	}
+	return "." + string(f)
+}
+
+// encode encodes the message, including the separator with the next message. JSON messages end with a newline and
+// binary messages are prefixed with their length as varint.
+func (f outputFormat) encode(msg proto.Message) ([]byte, error) {
+	var (
+		buf []byte
+		err error
+	)
+	switch f {
+	case "json":
+		buf, err = protojson.Marshal(msg)
+	case "ndjson":
+		buf, err = protojson.MarshalCompact(msg)
+	case "binary":
+		var b bytes.Buffer
+		if _, err := (protodelim.MarshalOptions{MarshalOptions: proto.MarshalOptions{AllowPartial: true}}).MarshalTo(&b, msg); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", f)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '\n'), nil
+}
+
+// encodeJSON encodes the message that is encoded as compact JSON, including the separator with the next message.
+func (f outputFormat) encodeJSON(buf []byte) ([]byte, error) {
+	switch f {
+	case "json":
+		var b bytes.Buffer
+		if err := json.Indent(&b, buf, "", "  "); err != nil {
+			return nil, err
+		}
+		b.WriteByte('\n')
+		return b.Bytes(), nil
+	case "ndjson":
+		return append(buf[:len(buf):len(buf)], '\n'), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q for JSON", f)
+	}
+}
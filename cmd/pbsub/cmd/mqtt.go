@@ -0,0 +1,127 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	flag "github.com/spf13/pflag"
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/cmd/internal/mqttclient"
+	"go.packetbroker.org/pb/cmd/internal/protojson"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// mqttDrainTimeout is the time to wait for pending MQTT publications on close.
+const mqttDrainTimeout = 10 * time.Second
+
+func mqttFlags() *flag.FlagSet {
+	flags := mqttclient.Flags()
+	flags.String("mqtt-uplink-topic", "pb/{forwarderNetId}/{forwarderTenantId}/up", "MQTT topic template of uplink messages")
+	flags.String("mqtt-downlink-topic", "pb/{homeNetworkNetId}/{homeNetworkTenantId}/down", "MQTT topic template of downlink messages")
+	return flags
+}
+
+// routedMessage is a routed uplink or downlink message.
+type routedMessage interface {
+	proto.Message
+	GetId() string
+	GetForwarderNetId() uint32
+	GetForwarderTenantId() string
+	GetForwarderClusterId() string
+	GetHomeNetworkNetId() uint32
+	GetHomeNetworkTenantId() string
+	GetHomeNetworkClusterId() string
+}
+
+// expandTopic replaces the placeholders in the topic template with the fields of the message.
+func expandTopic(template string, msg routedMessage) string {
+	return strings.NewReplacer(
+		"{id}", msg.GetId(),
+		"{forwarderNetId}", packetbroker.NetID(msg.GetForwarderNetId()).String(),
+		"{forwarderTenantId}", msg.GetForwarderTenantId(),
+		"{forwarderClusterId}", msg.GetForwarderClusterId(),
+		"{homeNetworkNetId}", packetbroker.NetID(msg.GetHomeNetworkNetId()).String(),
+		"{homeNetworkTenantId}", msg.GetHomeNetworkTenantId(),
+		"{homeNetworkClusterId}", msg.GetHomeNetworkClusterId(),
+	).Replace(template)
+}
+
+// mqttSink publishes the messages as JSON to an MQTT server.
+type mqttSink struct {
+	logger        *zap.Logger
+	client        mqtt.Client
+	qos           byte
+	uplinkTopic   string
+	downlinkTopic string
+	wg            sync.WaitGroup
+}
+
+// newMQTTSink connects to the MQTT server configured by the flags.
+func (st *state) newMQTTSink(flags *flag.FlagSet, conf mqttclient.Config) (*mqttSink, error) {
+	var (
+		uplinkTopic, _   = flags.GetString("mqtt-uplink-topic")
+		downlinkTopic, _ = flags.GetString("mqtt-downlink-topic")
+	)
+	client, err := mqttclient.Connect(st.ctx, st.logger, conf, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &mqttSink{
+		logger:        st.logger,
+		client:        client,
+		qos:           conf.QoS,
+		uplinkTopic:   uplinkTopic,
+		downlinkTopic: downlinkTopic,
+	}, nil
+}
+
+func (s *mqttSink) Write(msg proto.Message, done func(error)) error {
+	var topic string
+	switch msg := msg.(type) {
+	case *packetbroker.RoutedUplinkMessage:
+		topic = expandTopic(s.uplinkTopic, msg)
+	case *packetbroker.RoutedDownlinkMessage:
+		topic = expandTopic(s.downlinkTopic, msg)
+	default:
+		return nil
+	}
+	payload, err := protojson.MarshalCompact(msg)
+	if err != nil {
+		return err
+	}
+	token := s.client.Publish(topic, s.qos, false, payload)
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		<-token.Done()
+		err := token.Error()
+		if err != nil {
+			s.logger.Warn("Failed to publish to MQTT", zap.String("topic", topic), zap.Error(err))
+		}
+		if done != nil {
+			done(err)
+		}
+	}()
+	return nil
+}
+
+// Close waits for the pending publications and disconnects.
+func (s *mqttSink) Close() error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(mqttDrainTimeout):
+		s.logger.Warn("Drain timeout, discard pending MQTT publications")
+	}
+	s.client.Disconnect(250)
+	return nil
+}
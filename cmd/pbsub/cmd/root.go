@@ -8,39 +8,104 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"strings"
+	"os/signal"
+	"syscall"
 
-	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 	routingpb "go.packetbroker.org/api/routing"
 	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/cmd/internal/backoff"
 	"go.packetbroker.org/pb/cmd/internal/config"
 	"go.packetbroker.org/pb/cmd/internal/gen"
 	"go.packetbroker.org/pb/cmd/internal/logging"
 	"go.packetbroker.org/pb/cmd/internal/pbflag"
-	"go.packetbroker.org/pb/cmd/internal/protojson"
 	"go.packetbroker.org/pb/pkg/client"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
 )
 
-var (
+// Options configures the root command. Zero values use the standard streams, a logger writing to standard error and
+// client.DialContext.
+type Options struct {
+	Stdin          io.Reader
+	Stdout, Stderr io.Writer
+	// Logger is the logger. If nil, a logger is created that writes to Stderr, considering the debug flag.
+	Logger *zap.Logger
+	// Dial dials Packet Broker. If nil, client.DialContext is used.
+	Dial client.DialFunc
+}
+
+type state struct {
+	Options
 	cfgFile string
 	debug   bool
+	retry   retryOptions
+	report  deliveryState
 
-	ctx    = context.Background()
+	ctx    context.Context
+	config *config.Config
 	logger *zap.Logger
 	conn   *grpc.ClientConn
-)
 
-var rootCmd = &cobra.Command{
-	Use:          "pbsub",
-	Short:        "pbsub can be used to subscribe to uplink and downlink messages.",
-	SilenceUsage: true,
-	Example: `
+	// reportCtx is the context of delivery state reports. Unlike ctx, it is not done on interrupt, so that messages
+	// that are delivered while the sink is closed are reported as well.
+	reportCtx context.Context
+}
+
+// NewRootCommand returns a new pbsub command. Each command has its own configuration, so commands can be executed
+// concurrently. A command must not be executed concurrently with itself.
+func NewRootCommand(opts Options) *cobra.Command {
+	if opts.Stdin == nil {
+		opts.Stdin = os.Stdin
+	}
+	if opts.Stdout == nil {
+		opts.Stdout = os.Stdout
+	}
+	if opts.Stderr == nil {
+		opts.Stderr = os.Stderr
+	}
+	if opts.Dial == nil {
+		opts.Dial = client.DialContext
+	}
+	st := &state{
+		Options: opts,
+		retry: retryOptions{
+			backoff: backoff.Default,
+		},
+	}
+
+	rootCmd := &cobra.Command{
+		Use:   "pbsub",
+		Short: "pbsub can be used to subscribe to uplink and downlink messages.",
+		Long: `pbsub can be used to subscribe to uplink and downlink messages.
+
+Messages are written to standard output, to files in an output directory, to a
+webhook or to an MQTT server.
+
+MQTT topic templates support the placeholders {id}, {forwarderNetId},
+{forwarderTenantId}, {forwarderClusterId}, {homeNetworkNetId},
+{homeNetworkTenantId} and {homeNetworkClusterId}.
+
+Messages can be filtered with --where, which takes an expression on the JSON
+representation of messages:
+
+  devAddr in 26AB0000/16 && fPort == 5
+  forwarderTenantId == "tti" || !(frequency < 868000000)
+
+Paths are dot-separated field names, like message.frequency. Paths are also
+resolved in message, message.phyPayload.teaser and the mac and joinRequest
+teasers, so devAddr is short for message.phyPayload.teaser.mac.devAddr.
+Comparison operators are ==, !=, <, <=, >, >= and in. Logical operators are
+!, && and ||, in decreasing order of precedence. Use parentheses to group.
+Literals are numbers (5, -7.5, 0x13), quoted strings, true and false. The in
+operator matches a DevAddr or EUI-64 prefix (26AB0000/16) or a list of
+literals ([1, 2, 3]). A path on its own is true if it is set and not zero,
+empty or false. Comparisons with missing paths are false, except !=. 64-bit
+integers compare as numbers; other string fields never equal numbers.
+
+--fields selects the paths that are written, like id,devAddr,fPort.`,
+		SilenceUsage: true,
+		Example: `
   Subscribe as Forwarder:
 
     Subscribe as network:
@@ -69,147 +134,234 @@ var rootCmd = &cobra.Command{
 
     Subscribe as named cluster in tenant:
       $ pbsub --home-network-net-id 000013 --home-network-tenant-id community \
-        --home-network-cluster-id eu1`,
-	PreRunE: func(cmd *cobra.Command, args []string) error {
-		logger = logging.GetLogger(debug)
-		clientConf, err := config.OAuth2Client(ctx, "router", "networks")
-		if err != nil {
-			return err
-		}
-		conn, err = client.DialContext(ctx, logger, clientConf, 443)
-		if err != nil {
-			return err
-		}
-		return nil
-	},
-	RunE: func(cmd *cobra.Command, args []string) error {
-		var (
-			forwarder, forwarderOK     = pbflag.GetEndpoint(cmd.Flags(), "forwarder")
-			homeNetwork, homeNetworkOK = pbflag.GetEndpoint(cmd.Flags(), "home-network")
-		)
-		group, _ := cmd.Flags().GetString("group")
-		switch {
-		case forwarderOK:
-			return asForwarder(forwarder, group)
-		case homeNetworkOK:
-			return asHomeNetwork(homeNetwork, group)
-		}
-		return errors.New("no role specified")
-	},
-	PostRun: func(cmd *cobra.Command, args []string) {
-		logger.Sync()
-		conn.Close()
-	},
+        --home-network-cluster-id eu1
+
+  Subscribe as Home Network to MAC payloads of a DevAddr prefix only:
+    $ pbsub --home-network-net-id 000013 --group test \
+      --dev-addr-prefixes 26AB0000/16 --no-join-requests
+
+  Subscribe as Home Network to join-requests of a JoinEUI prefix only:
+    $ pbsub --home-network-net-id 000013 --group test \
+      --join-eui-prefixes 70B3D57ED0000000/40 --no-mac-payloads
+
+  Write the DevAddr and FCnt of uplink messages on FPort 5 from a gateway:
+    $ pbsub --home-network-net-id 000013 --group debug \
+      --where "devAddr in 26AB0000/16 && fPort == 5" --gateway-id my-gateway \
+      --fields forwarderNetId,devAddr,fCnt --format ndjson
+
+  Write messages as newline-delimited JSON to hourly gzip files:
+    $ pbsub --home-network-net-id 000013 --group archive --format ndjson \
+      --output-dir messages --rotate-interval 1h --gzip
+
+  Post messages to a webhook, with an authorization header:
+    $ pbsub --home-network-net-id 000013 --group webhook \
+      --webhook-url https://example.com/uplink \
+      --webhook-header "Authorization: Bearer secret" \
+      --webhook-dead-letter-file failed.ndjson
+
+  Publish uplink messages to an MQTT server, with a topic per Forwarder:
+    $ pbsub --home-network-net-id 000013 --group mqtt \
+      --mqtt-server ssl://mqtt.example.com:8883 --mqtt-qos 1 \
+      --mqtt-uplink-topic "pb/{forwarderNetId}/{forwarderTenantId}/up"
+
+  Report received uplink messages as not found, to produce delivery states:
+    $ pbsub --home-network-net-id 000013 --group staging \
+      --report-delivery-state error:NOT_FOUND
+
+  Resubscribe at most 10 times in a row when the subscription fails:
+    $ pbsub --home-network-net-id 000013 --group debug --max-retries 10
+
+  Stay subscribed when the router is redeployed:
+    $ pbsub --home-network-net-id 000013 --group debug --resubscribe-on-close`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := st.preRun(cmd, args); err != nil {
+				return err
+			}
+			clientConf, err := st.config.OAuth2Client(st.ctx, "router", "networks")
+			if err != nil {
+				return err
+			}
+			st.conn, err = st.Dial(st.ctx, st.logger, clientConf, 443)
+			if err != nil {
+				return err
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) (err error) {
+			var (
+				forwarder, forwarderOK     = pbflag.GetEndpoint(cmd.Flags(), "forwarder")
+				homeNetwork, homeNetworkOK = pbflag.GetEndpoint(cmd.Flags(), "home-network")
+			)
+			if !forwarderOK && !homeNetworkOK {
+				return errors.New("no role specified")
+			}
+			group, _ := cmd.Flags().GetString("group")
+			var filters []*packetbroker.RoutingFilter
+			if forwarderOK {
+				if filtersChanged(cmd.Flags()) {
+					return errors.New("filters apply to Home Network subscriptions only")
+				}
+			} else if filters, err = routingFilters(cmd.Flags()); err != nil {
+				return err
+			}
+
+			// Stop the subscription on interrupt, so that the sink is closed.
+			ctx, stop := signal.NotifyContext(st.ctx, os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			st.ctx, st.reportCtx = ctx, st.ctx
+
+			output, err := st.newSink(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			// Closing the sink delivers pending messages, so the error is returned if the subscription succeeded.
+			defer func() {
+				if cerr := output.Close(); err == nil {
+					err = cerr
+				}
+			}()
+			sink, err := newQuerySink(cmd.Flags(), output)
+			if err != nil {
+				return err
+			}
+
+			if forwarderOK {
+				return st.asForwarder(forwarder, group, sink)
+			}
+			return st.asHomeNetwork(homeNetwork, group, filters, sink)
+		},
+		PostRun: func(cmd *cobra.Command, args []string) {
+			st.logger.Sync()
+			st.conn.Close()
+		},
+	}
+	rootCmd.SetIn(opts.Stdin)
+	rootCmd.SetOut(opts.Stdout)
+	rootCmd.SetErr(opts.Stderr)
+
+	rootCmd.PersistentFlags().AddFlagSet(config.ClientFlags("router", ""))
+	rootCmd.PersistentFlags().AddFlagSet(config.InsecureFlags())
+	rootCmd.PersistentFlags().AddFlagSet(config.OAuth2ClientFlags())
+	rootCmd.PersistentFlags().AddFlagSet(config.SecretFileFlags())
+
+	rootCmd.PersistentFlags().AddFlagSet(config.ProfileFlags())
+	rootCmd.PersistentFlags().StringVar(&st.cfgFile, "config", "", "config file (default is $HOME/.pb.yaml, .pb.yaml)")
+	rootCmd.PersistentFlags().BoolVarP(&st.debug, "debug", "d", false, "debug mode")
+
+	rootCmd.Flags().AddFlagSet(pbflag.Endpoint("forwarder"))
+	rootCmd.Flags().AddFlagSet(pbflag.Endpoint("home-network"))
+	rootCmd.Flags().String("group", "", "subscription group")
+	rootCmd.Flags().AddFlagSet(filterFlags())
+	rootCmd.Flags().AddFlagSet(whereFlags())
+	rootCmd.Flags().AddFlagSet(sinkFlags())
+	rootCmd.Flags().AddFlagSet(webhookFlags())
+	rootCmd.Flags().AddFlagSet(mqttFlags())
+	rootCmd.Flags().Var(&st.report, "report-delivery-state", "report the delivery state of delivered messages (success, error:<code>)")
+	rootCmd.Flags().IntVar(&st.retry.maxRetries, "max-retries", -1, "maximum number of consecutive attempts to resubscribe when the subscription fails (-1 is unlimited)")
+	rootCmd.Flags().BoolVar(&st.retry.onClose, "resubscribe-on-close", false, "resubscribe when the router closes or cancels the subscription, i.e. when it is redeployed (default is to exit)")
+	rootCmd.Flags().DurationVar(&st.retry.backoff.Min, "retry-min-backoff", st.retry.backoff.Min, "backoff of the first attempt to resubscribe")
+	rootCmd.Flags().DurationVar(&st.retry.backoff.Max, "retry-max-backoff", st.retry.backoff.Max, "maximum backoff between attempts to resubscribe")
+
+	rootCmd.AddCommand(gen.NewCommand())
+	return rootCmd
+}
+
+func (st *state) preRun(cmd *cobra.Command, args []string) error {
+	st.ctx = cmd.Context()
+	var err error
+	if st.config, err = config.Init(st.cfgFile, cmd.Root().PersistentFlags()); err != nil {
+		return err
+	}
+	st.logger = st.Logger
+	if st.logger == nil {
+		st.logger = logging.NewLogger(st.Stderr, st.debug)
+	}
+	return nil
 }
 
-func asForwarder(forwarder packetbroker.Endpoint, group string) error {
-	client := routingpb.NewForwarderDataClient(conn)
-	stream, err := client.Subscribe(ctx, &routingpb.SubscribeForwarderRequest{
+func (st *state) asForwarder(forwarder packetbroker.Endpoint, group string, sink sink) error {
+	client := routingpb.NewForwarderDataClient(st.conn)
+	var stateChange func(*packetbroker.RoutedDownlinkMessage) *packetbroker.DownlinkMessageDeliveryStateChange
+	if st.report != "" {
+		var err error
+		if stateChange, err = st.report.downlinkStateChange(); err != nil {
+			return err
+		}
+	}
+	req := &routingpb.SubscribeForwarderRequest{
 		ForwarderNetId:     uint32(forwarder.NetID),
 		ForwarderClusterId: forwarder.ClusterID,
 		ForwarderTenantId:  forwarder.TenantID.ID,
 		Group:              group,
-	})
-	if err != nil {
-		return err
 	}
-	for {
-		msg, err := stream.Recv()
-		if err != nil {
-			if !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
-				return err
+	return subscribe(st, func(ctx context.Context) (recvStream[*packetbroker.RoutedDownlinkMessage], error) {
+		return client.Subscribe(ctx, req)
+	}, func(msg *packetbroker.RoutedDownlinkMessage) error {
+		var done func(error)
+		if stateChange != nil {
+			done = func(err error) {
+				if err != nil {
+					st.logger.Debug("Not reporting delivery state of undelivered downlink message", zap.String("id", msg.Id))
+					return
+				}
+				ctx, cancel := context.WithTimeout(st.reportCtx, reportTimeout)
+				defer cancel()
+				_, err = client.ReportDownlinkMessageDeliveryState(ctx, &routingpb.DownlinkMessageDeliveryStateChangeRequest{
+					StateChange: stateChange(msg),
+				})
+				if err != nil {
+					st.logger.Warn("Failed to report downlink message delivery state", zap.String("id", msg.Id), zap.Error(err))
+				}
 			}
-			return nil
 		}
-		if err := protojson.Write(os.Stdout, msg); err != nil {
-			return err
-		}
-	}
+		return sink.Write(msg, done)
+	})
 }
 
-func asHomeNetwork(homeNetwork packetbroker.Endpoint, group string) error {
-	// Subscribe to all MAC payload and join-requests.
-	filters := []*packetbroker.RoutingFilter{
-		{
-			Message: &packetbroker.RoutingFilter_Mac{
-				Mac: &packetbroker.RoutingFilter_MACPayload{},
-			},
-		},
-		{
-			Message: &packetbroker.RoutingFilter_JoinRequest_{
-				JoinRequest: &packetbroker.RoutingFilter_JoinRequest{
-					EuiPrefixes: []*packetbroker.RoutingFilter_JoinRequest_EUIPrefixes{{}},
-				},
-			},
-		},
+func (st *state) asHomeNetwork(homeNetwork packetbroker.Endpoint, group string, filters []*packetbroker.RoutingFilter, sink sink) error {
+	client := routingpb.NewHomeNetworkDataClient(st.conn)
+	var stateChange func(*packetbroker.RoutedUplinkMessage) *packetbroker.UplinkMessageDeliveryStateChange
+	if st.report != "" {
+		var err error
+		if stateChange, err = st.report.uplinkStateChange(); err != nil {
+			return err
+		}
 	}
-
-	client := routingpb.NewHomeNetworkDataClient(conn)
-	stream, err := client.Subscribe(ctx, &routingpb.SubscribeHomeNetworkRequest{
+	req := &routingpb.SubscribeHomeNetworkRequest{
 		HomeNetworkNetId:     uint32(homeNetwork.NetID),
 		HomeNetworkClusterId: homeNetwork.ClusterID,
 		HomeNetworkTenantId:  homeNetwork.TenantID.ID,
 		Group:                group,
 		Filters:              filters,
-	})
-	if err != nil {
-		return err
 	}
-	for {
-		msg, err := stream.Recv()
-		if err != nil {
-			if !errors.Is(err, io.EOF) && status.Code(err) != codes.Canceled {
-				return err
+	return subscribe(st, func(ctx context.Context) (recvStream[*packetbroker.RoutedUplinkMessage], error) {
+		return client.Subscribe(ctx, req)
+	}, func(msg *packetbroker.RoutedUplinkMessage) error {
+		var done func(error)
+		if stateChange != nil {
+			done = func(err error) {
+				if err != nil {
+					st.logger.Debug("Not reporting delivery state of undelivered uplink message", zap.String("id", msg.Id))
+					return
+				}
+				ctx, cancel := context.WithTimeout(st.reportCtx, reportTimeout)
+				defer cancel()
+				_, err = client.ReportUplinkMessageDeliveryState(ctx, &routingpb.UplinkMessageDeliveryStateChangeRequest{
+					StateChange: stateChange(msg),
+				})
+				if err != nil {
+					st.logger.Warn("Failed to report uplink message delivery state", zap.String("id", msg.Id), zap.Error(err))
+				}
 			}
-			return nil
 		}
-		if err := protojson.Write(os.Stdout, msg); err != nil {
-			return err
-		}
-	}
+		return sink.Write(msg, done)
+	})
 }
 
-// Execute runs pbctl.
+// Execute runs pbsub.
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	if err := NewRootCommand(Options{}).Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
 }
-
-func init() {
-	cobra.OnInitialize(initConfig)
-
-	rootCmd.PersistentFlags().AddFlagSet(config.ClientFlags("router", ""))
-	rootCmd.PersistentFlags().AddFlagSet(config.OAuth2ClientFlags())
-
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pb.yaml, .pb.yaml)")
-	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "d", false, "debug mode")
-
-	rootCmd.Flags().AddFlagSet(pbflag.Endpoint("forwarder"))
-	rootCmd.Flags().AddFlagSet(pbflag.Endpoint("home-network"))
-	rootCmd.Flags().String("group", "", "subscription group")
-
-	rootCmd.AddCommand(gen.Cmd)
-}
-
-func initConfig() {
-	if cfgFile != "" {
-		viper.SetConfigFile(cfgFile)
-	} else {
-		home, err := homedir.Dir()
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			os.Exit(1)
-		}
-		viper.AddConfigPath(".")
-		viper.AddConfigPath(home)
-		viper.SetConfigName(".pb")
-		viper.SetConfigType("yaml")
-	}
-
-	viper.AutomaticEnv()
-	viper.SetEnvPrefix("pb")
-	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
-	viper.ReadInConfig()
-}
@@ -0,0 +1,137 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"go.packetbroker.org/pb/cmd/internal/mqttclient"
+	"go.packetbroker.org/pb/cmd/internal/rotate"
+	"google.golang.org/protobuf/proto"
+)
+
+// sink receives the subscribed messages.
+type sink interface {
+	// Write writes the message. If done is not nil, it is called when the message is delivered, with a nil error, or
+	// when the message cannot be delivered, with the error. Sinks may deliver messages asynchronously, so done may be
+	// called after Write returns, from another goroutine. done is not called if Write returns an error, or if the
+	// message is filtered out.
+	Write(msg proto.Message, done func(error)) error
+	Close() error
+}
+
+// jsonWriter is implemented by sinks that can write messages that are already encoded as compact JSON, so that the
+// messages are not encoded again.
+type jsonWriter interface {
+	// writesJSON returns whether the sink writes messages as JSON. If false, WriteJSON must not be called.
+	writesJSON() bool
+	// WriteJSON writes the message that is encoded as compact JSON. See sink.Write for done.
+	WriteJSON(buf []byte, done func(error)) error
+}
+
+// writerSink writes the encoded messages to a writer.
+type writerSink struct {
+	w      io.Writer
+	closer io.Closer
+	format outputFormat
+}
+
+func (s *writerSink) Write(msg proto.Message, done func(error)) error {
+	buf, err := s.format.encode(msg)
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(buf); err != nil {
+		return err
+	}
+	if done != nil {
+		done(nil)
+	}
+	return nil
+}
+
+func (s *writerSink) writesJSON() bool {
+	return s.format == "json" || s.format == "ndjson"
+}
+
+func (s *writerSink) WriteJSON(buf []byte, done func(error)) error {
+	buf, err := s.format.encodeJSON(buf)
+	if err != nil {
+		return err
+	}
+	if _, err := s.w.Write(buf); err != nil {
+		return err
+	}
+	if done != nil {
+		done(nil)
+	}
+	return nil
+}
+
+func (s *writerSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+func sinkFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.Var(newOutputFormat("json"), "format", fmt.Sprintf("output format (%s)", strings.Join(outputFormats[:], ", ")))
+	flags.String("output-dir", "", "write to files in the directory instead of standard output")
+	flags.Int64("rotate-size", 100, "maximum file size in MB before rotating files in the output directory (0 is unlimited)")
+	flags.Duration("rotate-interval", time.Hour, "maximum file age before rotating files in the output directory (0 is unlimited)")
+	flags.Bool("gzip", false, "compress files in the output directory with gzip")
+	return flags
+}
+
+// newSink returns the sink configured by the flags. The caller must close the sink.
+func (st *state) newSink(flags *flag.FlagSet) (sink, error) {
+	var (
+		format            = *flags.Lookup("format").Value.(*outputFormat)
+		outputDir, _      = flags.GetString("output-dir")
+		rotateSize, _     = flags.GetInt64("rotate-size")
+		rotateInterval, _ = flags.GetDuration("rotate-interval")
+		gzip, _           = flags.GetBool("gzip")
+		webhookURL, _     = flags.GetString("webhook-url")
+	)
+	mqttConf, mqttOK, err := mqttclient.GetConfig(flags)
+	if err != nil {
+		return nil, err
+	}
+	n := 0
+	for _, ok := range []bool{outputDir != "", webhookURL != "", mqttOK} {
+		if ok {
+			n++
+		}
+	}
+	if n > 1 {
+		return nil, errors.New("specify at most one of output directory, webhook and MQTT server")
+	}
+	switch {
+	case webhookURL != "":
+		return st.newWebhookSink(flags)
+	case mqttOK:
+		return st.newMQTTSink(flags, mqttConf)
+	}
+	if outputDir == "" {
+		return &writerSink{w: st.Stdout, format: format}, nil
+	}
+	w, err := rotate.New(rotate.Config{
+		Dir:     outputDir,
+		Prefix:  "pbsub-",
+		Ext:     format.ext(),
+		MaxSize: rotateSize << 20,
+		MaxAge:  rotateInterval,
+		Gzip:    gzip,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("output directory: %w", err)
+	}
+	return &writerSink{w: w, closer: w, format: format}, nil
+}
@@ -0,0 +1,112 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"go.packetbroker.org/pb/cmd/internal/backoff"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// retryOptions configures resubscribing when a subscription fails.
+type retryOptions struct {
+	// maxRetries is the maximum number of consecutive attempts to resubscribe. If negative, there is no maximum.
+	maxRetries int
+	// onClose is whether to resubscribe when the router closes the subscription.
+	onClose bool
+	backoff backoff.Backoff
+}
+
+// recvStream is a stream of subscribed messages.
+type recvStream[T any] interface {
+	Recv() (T, error)
+}
+
+// isClosed returns whether the router closed the subscription: the stream ended, or the router canceled the
+// subscription, for example when it stops gracefully.
+func isClosed(err error) bool {
+	return errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled
+}
+
+// isTemporary returns whether the subscription error is temporary, i.e. when the router is unavailable or restarts.
+func isTemporary(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.Unknown, codes.Internal, codes.Aborted, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	}
+	return false
+}
+
+// subscribe opens the subscription and handles the received messages until the context is done or the router closes
+// the subscription.
+//
+// When the subscription fails with a temporary error, subscribe resubscribes with exponential backoff. The number of
+// attempts resets when a message is received or when the subscription lasted at least the maximum backoff.
+// When the router closes the subscription, subscribe returns without error, unless resubscribing on close is enabled.
+// Then, the subscription is resumed like on a temporary error, and subscribe returns without error when the attempts
+// run out.
+// The request is reused, so that shared subscriptions resume in the same group.
+func subscribe[T any](st *state, open func(context.Context) (recvStream[T], error), handle func(T) error) error {
+	var (
+		attempt        int
+		interruptedAt  time.Time
+		lastReceivedAt time.Time
+	)
+	for {
+		subscribedAt := time.Now()
+		stream, err := open(st.ctx)
+		if err == nil && attempt > 0 {
+			st.logger.Info("Resubscribed",
+				zap.Int("attempt", attempt),
+				zap.Duration("gap", subscribedAt.Sub(interruptedAt)),
+				zap.Time("last_received_at", lastReceivedAt),
+			)
+		}
+		received := false
+		for err == nil {
+			var msg T
+			if msg, err = stream.Recv(); err != nil {
+				break
+			}
+			received, lastReceivedAt = true, time.Now()
+			if err := handle(msg); err != nil {
+				return err
+			}
+		}
+		closed := isClosed(err)
+		if st.ctx.Err() != nil || closed && !st.retry.onClose {
+			return nil
+		}
+		if !closed && !isTemporary(err) {
+			return err
+		}
+		if received || time.Since(subscribedAt) >= st.retry.backoff.Max {
+			attempt = 0
+		}
+		if st.retry.maxRetries >= 0 && attempt >= st.retry.maxRetries {
+			if closed {
+				return nil
+			}
+			return fmt.Errorf("subscribe after %d retries: %w", attempt, err)
+		}
+		if attempt == 0 {
+			interruptedAt = time.Now()
+		}
+		st.logger.Warn("Subscription interrupted, resubscribing",
+			zap.Error(err),
+			zap.Int("attempt", attempt+1),
+			zap.Time("last_received_at", lastReceivedAt),
+		)
+		if err := st.retry.backoff.Wait(st.ctx, attempt); err != nil {
+			return nil
+		}
+		attempt++
+	}
+}
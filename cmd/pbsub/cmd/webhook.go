@@ -0,0 +1,284 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"go.packetbroker.org/pb/cmd/internal/backoff"
+	"go.packetbroker.org/pb/cmd/internal/protojson"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+func webhookFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.String("webhook-url", "", "POST messages to the URL instead of writing to standard output")
+	flags.String("webhook-format", "json", "webhook message format (json, protobuf)")
+	flags.StringArray("webhook-header", nil, `webhook request header as "Name: Value" (can be repeated)`)
+	flags.Int("webhook-concurrency", 4, "maximum number of concurrent webhook requests")
+	flags.Duration("webhook-timeout", 10*time.Second, "timeout of a webhook request")
+	flags.Int("webhook-max-retries", 5, "maximum number of retries of a webhook request")
+	flags.Duration("webhook-retry-min-backoff", backoff.Default.Min, "backoff of the first retry of a webhook request")
+	flags.String("webhook-dead-letter-file", "", "append messages that cannot be delivered to the file")
+	flags.Duration("webhook-drain-timeout", 30*time.Second, "time to wait for pending webhook requests on exit")
+	return flags
+}
+
+// webhookStatusError is the error of a webhook response without success status.
+type webhookStatusError struct {
+	code int
+}
+
+func (e *webhookStatusError) Error() string {
+	return fmt.Sprintf("webhook responded with status %d %s", e.code, http.StatusText(e.code))
+}
+
+// isRetryable returns whether the webhook request can be retried. Requests are retried on network errors, when the
+// webhook is rate limited and on server errors.
+func (e *webhookStatusError) isRetryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// webhookSink posts the messages to a webhook. Messages are posted concurrently; Write blocks when the maximum number
+// of concurrent requests is reached, until the subscription stops. Messages that cannot be delivered are written to the
+// dead-letter file.
+type webhookSink struct {
+	logger       *zap.Logger
+	client       *http.Client
+	url          string
+	header       http.Header
+	protobuf     bool
+	maxRetries   int
+	backoff      backoff.Backoff
+	drainTimeout time.Duration
+
+	// subscriptionCtx is done when the subscription stops.
+	subscriptionCtx context.Context
+	ctx             context.Context
+	cancel          context.CancelFunc
+	sem             chan struct{}
+	wg              sync.WaitGroup
+
+	deadLetterMu sync.Mutex
+	deadLetter   *os.File
+}
+
+// newWebhookSink returns the webhook sink configured by the flags.
+func (st *state) newWebhookSink(flags *flag.FlagSet) (*webhookSink, error) {
+	var (
+		url, _            = flags.GetString("webhook-url")
+		format, _         = flags.GetString("webhook-format")
+		headers, _        = flags.GetStringArray("webhook-header")
+		concurrency, _    = flags.GetInt("webhook-concurrency")
+		timeout, _        = flags.GetDuration("webhook-timeout")
+		maxRetries, _     = flags.GetInt("webhook-max-retries")
+		minBackoff, _     = flags.GetDuration("webhook-retry-min-backoff")
+		deadLetterFile, _ = flags.GetString("webhook-dead-letter-file")
+		drainTimeout, _   = flags.GetDuration("webhook-drain-timeout")
+		header            = make(http.Header)
+		retryBackoff      = backoff.Default
+	)
+	if format != "json" && format != "protobuf" {
+		return nil, fmt.Errorf("unsupported webhook format %q", format)
+	}
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid webhook header %q", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	if concurrency < 1 {
+		return nil, errors.New("webhook concurrency must be at least 1")
+	}
+	retryBackoff.Min = minBackoff
+	s := &webhookSink{
+		logger:          st.logger.With(zap.String("webhook_url", url)),
+		client:          &http.Client{Timeout: timeout},
+		url:             url,
+		header:          header,
+		protobuf:        format == "protobuf",
+		maxRetries:      maxRetries,
+		backoff:         retryBackoff,
+		drainTimeout:    drainTimeout,
+		subscriptionCtx: st.ctx,
+		sem:             make(chan struct{}, concurrency),
+	}
+	if deadLetterFile != "" {
+		f, err := os.OpenFile(deadLetterFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open dead-letter file: %w", err)
+		}
+		s.deadLetter = f
+	}
+	// Requests are not canceled when the subscription stops, so that pending messages are delivered on Close.
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	return s, nil
+}
+
+func (s *webhookSink) Write(msg proto.Message, done func(error)) error {
+	if !s.protobuf {
+		buf, err := protojson.MarshalCompact(msg)
+		if err != nil {
+			return err
+		}
+		return s.WriteJSON(buf, done)
+	}
+	body, err := proto.MarshalOptions{AllowPartial: true}.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return s.write(body, "application/x-protobuf", func() ([]byte, error) {
+		return protojson.MarshalCompact(msg)
+	}, done)
+}
+
+func (s *webhookSink) writesJSON() bool {
+	return !s.protobuf
+}
+
+func (s *webhookSink) WriteJSON(buf []byte, done func(error)) error {
+	return s.write(buf, "application/json", func() ([]byte, error) {
+		return buf, nil
+	}, done)
+}
+
+// write posts the body in a request slot. If the body cannot be delivered, the message, as encoded by deadLetter, is
+// written to the dead-letter file.
+func (s *webhookSink) write(body []byte, contentType string, deadLetter func() ([]byte, error), done func(error)) error {
+	select {
+	case s.sem <- struct{}{}:
+	case <-s.subscriptionCtx.Done():
+		// Do not wait for a pending request when the subscription stops, so that the drain timeout applies.
+		err := fmt.Errorf("not delivered before exit: %w", s.subscriptionCtx.Err())
+		s.logger.Warn("Failed to deliver message", zap.Error(err))
+		s.writeDeadLetter(deadLetter, err)
+		if done != nil {
+			done(err)
+		}
+		return nil
+	}
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			<-s.sem
+			s.wg.Done()
+		}()
+		err := s.deliver(body, contentType)
+		if err != nil {
+			s.logger.Warn("Failed to deliver message", zap.Error(err))
+			s.writeDeadLetter(deadLetter, err)
+		}
+		if done != nil {
+			done(err)
+		}
+	}()
+	return nil
+}
+
+// deliver posts the body to the webhook, with retries.
+func (s *webhookSink) deliver(body []byte, contentType string) error {
+	for attempt := 0; ; attempt++ {
+		err := s.post(body, contentType)
+		if err == nil {
+			return nil
+		}
+		var statusErr *webhookStatusError
+		if errors.As(err, &statusErr) && !statusErr.isRetryable() || s.ctx.Err() != nil || attempt >= s.maxRetries {
+			return err
+		}
+		s.logger.Debug("Retry webhook request", zap.Error(err), zap.Int("attempt", attempt+1))
+		if err := s.backoff.Wait(s.ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *webhookSink) post(body []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = s.header.Clone()
+	req.Header.Set("Content-Type", contentType)
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return &webhookStatusError{code: res.StatusCode}
+	}
+	return nil
+}
+
+// deadLetter is a message that cannot be delivered, as written to the dead-letter file.
+type deadLetter struct {
+	Time    time.Time       `json:"time"`
+	Error   string          `json:"error"`
+	Message json.RawMessage `json:"message"`
+}
+
+// writeDeadLetter appends the message, as encoded to JSON by encode, to the dead-letter file as a line of JSON.
+func (s *webhookSink) writeDeadLetter(encode func() ([]byte, error), deliverErr error) {
+	if s.deadLetter == nil {
+		return
+	}
+	raw, err := encode()
+	if err != nil {
+		s.logger.Error("Failed to marshal dead letter", zap.Error(err))
+		return
+	}
+	buf, err := json.Marshal(deadLetter{
+		Time:    time.Now().UTC(),
+		Error:   deliverErr.Error(),
+		Message: raw,
+	})
+	if err != nil {
+		s.logger.Error("Failed to marshal dead letter", zap.Error(err))
+		return
+	}
+	s.deadLetterMu.Lock()
+	defer s.deadLetterMu.Unlock()
+	if _, err := s.deadLetter.Write(append(buf, '\n')); err != nil {
+		s.logger.Error("Failed to write dead letter", zap.Error(err))
+	}
+}
+
+// Close waits for the pending requests. Requests that are pending after the drain timeout are canceled.
+func (s *webhookSink) Close() error {
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(s.drainTimeout):
+		s.logger.Warn("Drain timeout, cancel pending webhook requests")
+		s.cancel()
+		<-done
+	}
+	s.cancel()
+	s.deadLetterMu.Lock()
+	defer s.deadLetterMu.Unlock()
+	if s.deadLetter == nil {
+		return nil
+	}
+	err := s.deadLetter.Close()
+	s.deadLetter = nil
+	return err
+}
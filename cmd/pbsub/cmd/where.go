@@ -0,0 +1,114 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	flag "github.com/spf13/pflag"
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/cmd/internal/protojson"
+	"go.packetbroker.org/pb/cmd/internal/query"
+	"go.packetbroker.org/pb/pkg/lorawan"
+	"google.golang.org/protobuf/proto"
+)
+
+func whereFlags() *flag.FlagSet {
+	flags := new(flag.FlagSet)
+	flags.String("where", "", "output only messages that match the expression")
+	flags.String("gateway-id", "", "output only uplink messages received by the gateway ID or EUI")
+	flags.String("forwarder-net-id-from", "", "output only messages from the Forwarder NetID")
+	flags.StringSlice("fields", nil, "output only the fields of the paths, e.g. id,devAddr,fPort")
+	return flags
+}
+
+// querySink writes the messages that match the expressions to the sink, optionally projected to fields.
+type querySink struct {
+	sink
+	exprs  []query.Expr
+	fields []query.Path
+}
+
+// newQuerySink returns a sink that filters and projects the messages as configured by the flags. If there are no
+// expressions and fields, the sink is returned as is.
+func newQuerySink(flags *flag.FlagSet, s sink) (sink, error) {
+	var (
+		where, _     = flags.GetString("where")
+		gatewayID, _ = flags.GetString("gateway-id")
+		netIDFrom, _ = flags.GetString("forwarder-net-id-from")
+		fields, _    = flags.GetStringSlice("fields")
+	)
+	var exprs []string
+	if where != "" {
+		exprs = append(exprs, where)
+	}
+	if gatewayID != "" {
+		expr := fmt.Sprintf("gatewayId.plain == %s", query.Quote(gatewayID))
+		var eui lorawan.EUI64
+		if err := eui.UnmarshalText([]byte(gatewayID)); err == nil {
+			expr += fmt.Sprintf(" || gatewayId.eui == %d", uint64(eui))
+		}
+		exprs = append(exprs, expr)
+	}
+	if netIDFrom != "" {
+		var netID packetbroker.NetID
+		if err := netID.UnmarshalText([]byte(netIDFrom)); err != nil {
+			return nil, fmt.Errorf("invalid Forwarder NetID: %w", err)
+		}
+		exprs = append(exprs, fmt.Sprintf("forwarderNetId == %d", uint32(netID)))
+	}
+	if len(exprs) == 0 && len(fields) == 0 {
+		return s, nil
+	}
+
+	res := &querySink{sink: s}
+	for _, e := range exprs {
+		expr, err := query.Parse(e)
+		if err != nil {
+			return nil, err
+		}
+		res.exprs = append(res.exprs, expr)
+	}
+	if len(fields) > 0 {
+		if mqttServer, _ := flags.GetString("mqtt-server"); mqttServer != "" {
+			return nil, errors.New("fields are not supported with MQTT, as topics are derived from the routed message")
+		}
+		if w, ok := s.(jsonWriter); !ok || !w.writesJSON() {
+			return nil, errors.New("fields require JSON output")
+		}
+		for _, f := range fields {
+			res.fields = append(res.fields, query.ParsePath(f))
+		}
+	}
+	return res, nil
+}
+
+// Write writes the message if it matches the expressions. The message is encoded to JSON once; sinks that write JSON
+// reuse the encoded message or projection.
+func (s *querySink) Write(msg proto.Message, done func(error)) error {
+	buf, err := protojson.MarshalCompact(msg)
+	if err != nil {
+		return err
+	}
+	v, err := query.Decode(buf, msg.ProtoReflect().Descriptor())
+	if err != nil {
+		return err
+	}
+	for _, expr := range s.exprs {
+		if !expr.Eval(v) {
+			return nil
+		}
+	}
+	w, ok := s.sink.(jsonWriter)
+	if !ok || !w.writesJSON() {
+		return s.sink.Write(msg, done)
+	}
+	if len(s.fields) > 0 {
+		if buf, err = json.Marshal(query.Project(v, s.fields)); err != nil {
+			return err
+		}
+	}
+	return w.WriteJSON(buf, done)
+}
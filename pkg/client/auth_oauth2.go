@@ -37,9 +37,28 @@ func (c *clientCredentials) RequireTransportSecurity() bool {
 	return !c.insecure
 }
 
+// OAuth2Option configures OAuth2 client credentials.
+type OAuth2Option func(*oauth2Options)
+
+type oauth2Options struct {
+	tokenCacheFile string
+}
+
+// WithTokenCacheFile caches tokens in the given file, so that tokens can be reused by subsequent processes.
+// See FileTokenSource. Caching is disabled if the path is empty.
+func WithTokenCacheFile(path string) OAuth2Option {
+	return func(opts *oauth2Options) {
+		opts.tokenCacheFile = path
+	}
+}
+
 // OAuth2 returns per RPC client credentials using the OAuth Client Credentials flow.
 // The token is being refreshed in the background.
-func OAuth2(ctx context.Context, tokenURL, clientID, clientSecret, audience string, scopes []string, insecure bool) credentials.PerRPCCredentials {
+func OAuth2(ctx context.Context, tokenURL, clientID, clientSecret, audience string, scopes []string, insecure bool, opts ...OAuth2Option) credentials.PerRPCCredentials {
+	var options oauth2Options
+	for _, opt := range opts {
+		opt(&options)
+	}
 	config := clientcredentials.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
@@ -50,9 +69,17 @@ func OAuth2(ctx context.Context, tokenURL, clientID, clientSecret, audience stri
 			"audience": []string{audience},
 		},
 	}
+	tokenSource := config.TokenSource(ctx)
+	if options.tokenCacheFile != "" {
+		tokenSource = oauth2.ReuseTokenSource(nil, FileTokenSource(tokenSource, options.tokenCacheFile, TokenCacheKey{
+			TokenURL: tokenURL,
+			ClientID: clientID,
+			Audience: audience,
+			Scopes:   scopes,
+		}))
+	}
 	return &clientCredentials{
-		// TODO: Cache tokens on disk (https://github.com/packetbroker/pb/issues/6)
-		tokenSource: config.TokenSource(ctx),
+		tokenSource: tokenSource,
 		insecure:    insecure,
 	}
 }
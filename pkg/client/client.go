@@ -48,6 +48,9 @@ func appendDefaultPort(target string, port int) (string, error) {
 	return target, nil
 }
 
+// DialFunc dials a Packet Broker service using the given configuration. DialContext is a DialFunc.
+type DialFunc func(ctx context.Context, logger *zap.Logger, config *Config, defaultPort int) (*grpc.ClientConn, error)
+
 // DialContext dials a Packet Broker service using the given configuration.
 func DialContext(ctx context.Context, logger *zap.Logger, config *Config, defaultPort int) (*grpc.ClientConn, error) {
 	timeout := config.DialTimeout
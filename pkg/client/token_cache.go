@@ -0,0 +1,166 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	tokenCacheExpiryDelta   = time.Minute
+	tokenCacheLockTimeout   = 5 * time.Second
+	tokenCacheLockRetry     = 10 * time.Millisecond
+	tokenCacheLockStaleTime = 30 * time.Second
+)
+
+// DefaultTokenCacheFile returns the default path of the OAuth 2.0 token cache file.
+// It returns an empty string if the user cache directory cannot be determined.
+func DefaultTokenCacheFile() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "pb", "tokens.json")
+}
+
+// TokenCacheKey identifies a cached token.
+type TokenCacheKey struct {
+	TokenURL,
+	ClientID,
+	Audience string
+	Scopes []string
+}
+
+func (k TokenCacheKey) String() string {
+	scopes := append([]string(nil), k.Scopes...)
+	sort.Strings(scopes)
+	h := sha256.New()
+	for _, v := range []string{k.TokenURL, k.ClientID, k.Audience, strings.Join(scopes, " ")} {
+		fmt.Fprintf(h, "%d:%s;", len(v), v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type fileTokenSource struct {
+	source oauth2.TokenSource
+	path   string
+	key    string
+}
+
+// FileTokenSource returns a token source that caches the tokens of the given source in a file.
+// Tokens are shared between processes using the same file and key, and are reused until shortly before they expire.
+// Access to the file is serialized with a lock file, so that concurrent processes do not corrupt the cache.
+// If the cache cannot be used, the token is retrieved from the source directly.
+func FileTokenSource(source oauth2.TokenSource, path string, key TokenCacheKey) oauth2.TokenSource {
+	return &fileTokenSource{
+		source: source,
+		path:   path,
+		key:    key.String(),
+	}
+}
+
+func (s *fileTokenSource) Token() (*oauth2.Token, error) {
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return s.source.Token()
+	}
+	defer unlock()
+
+	tokens, err := readTokenCache(s.path)
+	if err != nil {
+		tokens = make(map[string]*oauth2.Token)
+	}
+	if token, ok := tokens[s.key]; ok && token.AccessToken != "" &&
+		(token.Expiry.IsZero() || time.Until(token.Expiry) > tokenCacheExpiryDelta) {
+		return token, nil
+	}
+
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for k, t := range tokens {
+		if !t.Expiry.IsZero() && t.Expiry.Before(now) {
+			delete(tokens, k)
+		}
+	}
+	tokens[s.key] = token
+	// Failing to write the cache is not fatal; the token is retrieved again next time.
+	writeTokenCache(s.path, tokens)
+	return token, nil
+}
+
+func readTokenCache(path string) (map[string]*oauth2.Token, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var tokens map[string]*oauth2.Token
+	if err := json.Unmarshal(buf, &tokens); err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		tokens = make(map[string]*oauth2.Token)
+	}
+	return tokens, nil
+}
+
+func writeTokenCache(path string, tokens map[string]*oauth2.Token) error {
+	buf, err := json.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// lockFile acquires an exclusive lock by creating the given lock file.
+// Lock files that are older than tokenCacheLockStaleTime are considered stale and are removed.
+func lockFile(path string) (unlock func(), err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(tokenCacheLockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > tokenCacheLockStaleTime {
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("client: lock %q: timeout", path)
+		}
+		time.Sleep(tokenCacheLockRetry)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type countingTokenSource struct {
+	calls  int
+	expiry time.Duration
+}
+
+func (s *countingTokenSource) Token() (*oauth2.Token, error) {
+	s.calls++
+	return &oauth2.Token{
+		AccessToken: "token",
+		TokenType:   "Bearer",
+		Expiry:      time.Now().Add(s.expiry),
+	}, nil
+}
+
+func TestFileTokenSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pb", "tokens.json")
+	key := TokenCacheKey{
+		TokenURL: DefaultTokenURL,
+		ClientID: "KZUCD5XAYT6EJ5BH",
+		Audience: "iam.packetbroker.net",
+		Scopes:   []string{"networks"},
+	}
+
+	valid := &countingTokenSource{expiry: time.Hour}
+	for i := 0; i < 3; i++ {
+		// Every iteration simulates a new process using the same cache file.
+		if _, err := FileTokenSource(valid, path, key).Token(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if valid.calls != 1 {
+		t.Fatalf("expected 1 token request, got %d", valid.calls)
+	}
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if perm := info.Mode().Perm(); perm != 0o600 {
+			t.Fatalf("expected file permissions 0600, got %o", perm)
+		}
+	}
+
+	other := key
+	other.Scopes = []string{"networks", "other"}
+	otherSource := &countingTokenSource{expiry: time.Hour}
+	if _, err := FileTokenSource(otherSource, path, other).Token(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if otherSource.calls != 1 {
+		t.Fatalf("expected 1 token request for other key, got %d", otherSource.calls)
+	}
+
+	expiring := &countingTokenSource{expiry: 10 * time.Second}
+	expiringKey := key
+	expiringKey.ClientID = "C5232IFFX4UKEELB"
+	for i := 0; i < 2; i++ {
+		if _, err := FileTokenSource(expiring, path, expiringKey).Token(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if expiring.calls != 2 {
+		t.Fatalf("expected 2 token requests for expiring token, got %d", expiring.calls)
+	}
+}
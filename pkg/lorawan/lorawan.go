@@ -0,0 +1,138 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package lorawan implements LoRaWAN identifiers and arithmetic on DevAddr and EUI-64 prefixes.
+package lorawan
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+)
+
+// parseHex parses exactly size/4 hexadecimal digits.
+func parseHex(s string, size int) (uint64, error) {
+	if len(s) != size/4 {
+		return 0, fmt.Errorf("invalid length %d of %q, expected %d hexadecimal digits", len(s), s, size/4)
+	}
+	v, err := strconv.ParseUint(s, 16, size)
+	if err != nil {
+		return 0, fmt.Errorf("invalid hexadecimal %q", s)
+	}
+	return v, nil
+}
+
+// NetID is a LoRa Alliance NetID.
+type NetID uint32
+
+// nwkIDBits is the number of NwkID bits per NetID type.
+var nwkIDBits = [...]uint8{6, 6, 9, 11, 12, 13, 15, 17}
+
+// Type returns the NetID type, which is 0 to 7.
+func (n NetID) Type() uint8 {
+	return uint8(n>>21) & 0x7
+}
+
+// ID returns the NetID specific ID, which are the 21 LSBs of the NetID.
+func (n NetID) ID() uint32 {
+	return uint32(n) & 0x1FFFFF
+}
+
+// NwkIDBits returns the number of bits of the NwkID.
+func (n NetID) NwkIDBits() uint8 {
+	return nwkIDBits[n.Type()]
+}
+
+// NwkID returns the NwkID, which are the LSBs of the NetID that are encoded in the DevAddr.
+func (n NetID) NwkID() uint32 {
+	return uint32(n) & (1<<n.NwkIDBits() - 1)
+}
+
+// DevAddrPrefix returns the DevAddr prefix of the NetID.
+// The DevAddr prefix consists of the type prefix, which is as many 1 bits as the NetID type followed by a 0 bit, and
+// the NwkID.
+func (n NetID) DevAddrPrefix() DevAddrPrefix {
+	var (
+		typeBits  = n.Type() + 1
+		typeValue = uint32(1<<n.Type()-1) << 1
+		length    = typeBits + n.NwkIDBits()
+	)
+	return DevAddrPrefix{
+		Value:  DevAddr(typeValue<<(32-typeBits) | n.NwkID()<<(32-length)),
+		Length: length,
+	}
+}
+
+// String implements fmt.Stringer.
+func (n NetID) String() string {
+	return fmt.Sprintf("%06X", uint32(n))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (n NetID) MarshalText() ([]byte, error) {
+	return []byte(n.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. The NetID must be 6 hexadecimal digits.
+func (n *NetID) UnmarshalText(text []byte) error {
+	v, err := parseHex(string(text), 24)
+	if err != nil {
+		return err
+	}
+	*n = NetID(v)
+	return nil
+}
+
+// DevAddr is a LoRaWAN device address.
+type DevAddr uint32
+
+// NetIDType returns the NetID type of the DevAddr. The DevAddr is invalid if it starts with eight 1 bits.
+func (d DevAddr) NetIDType() (uint8, bool) {
+	t := bits.LeadingZeros32(^uint32(d))
+	if t > 7 {
+		return 0, false
+	}
+	return uint8(t), true
+}
+
+// String implements fmt.Stringer.
+func (d DevAddr) String() string {
+	return fmt.Sprintf("%08X", uint32(d))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d DevAddr) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. The DevAddr must be 8 hexadecimal digits.
+func (d *DevAddr) UnmarshalText(text []byte) error {
+	v, err := parseHex(string(text), 32)
+	if err != nil {
+		return err
+	}
+	*d = DevAddr(v)
+	return nil
+}
+
+// EUI64 is a 64-bit extended unique identifier, such as a DevEUI or a JoinEUI.
+type EUI64 uint64
+
+// String implements fmt.Stringer.
+func (e EUI64) String() string {
+	return fmt.Sprintf("%016X", uint64(e))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e EUI64) MarshalText() ([]byte, error) {
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. The EUI-64 must be 16 hexadecimal digits.
+func (e *EUI64) UnmarshalText(text []byte) error {
+	v, err := parseHex(string(text), 64)
+	if err != nil {
+		return err
+	}
+	*e = EUI64(v)
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package lorawan
+
+import "testing"
+
+func TestNetID(t *testing.T) {
+	for _, tc := range []struct {
+		netID     NetID
+		netIDType uint8
+		id        uint32
+		nwkID     uint32
+		prefix    string
+	}{
+		{netID: 0x000000, netIDType: 0, id: 0x000000, nwkID: 0x00, prefix: "00000000/7"},
+		{netID: 0x000013, netIDType: 0, id: 0x000013, nwkID: 0x13, prefix: "26000000/7"},
+		{netID: 0x00003F, netIDType: 0, id: 0x00003F, nwkID: 0x3F, prefix: "7E000000/7"},
+		{netID: 0x200009, netIDType: 1, id: 0x000009, nwkID: 0x09, prefix: "89000000/8"},
+		{netID: 0x400123, netIDType: 2, id: 0x000123, nwkID: 0x123, prefix: "D2300000/12"},
+		{netID: 0x6000FF, netIDType: 3, id: 0x0000FF, nwkID: 0xFF, prefix: "E1FE0000/15"},
+		{netID: 0x800ABC, netIDType: 4, id: 0x000ABC, nwkID: 0xABC, prefix: "F55E0000/17"},
+		{netID: 0xA01234, netIDType: 5, id: 0x001234, nwkID: 0x1234, prefix: "FA468000/19"},
+		{netID: 0xC00123, netIDType: 6, id: 0x000123, nwkID: 0x123, prefix: "FC048C00/22"},
+		{netID: 0xE1FFFF, netIDType: 7, id: 0x01FFFF, nwkID: 0x1FFFF, prefix: "FEFFFF80/25"},
+	} {
+		t.Run(tc.netID.String(), func(t *testing.T) {
+			if v := tc.netID.Type(); v != tc.netIDType {
+				t.Errorf("expected type %d, got %d", tc.netIDType, v)
+			}
+			if v := tc.netID.ID(); v != tc.id {
+				t.Errorf("expected ID %X, got %X", tc.id, v)
+			}
+			if v := tc.netID.NwkID(); v != tc.nwkID {
+				t.Errorf("expected NwkID %X, got %X", tc.nwkID, v)
+			}
+			prefix := tc.netID.DevAddrPrefix()
+			if v := prefix.String(); v != tc.prefix {
+				t.Errorf("expected DevAddr prefix %s, got %s", tc.prefix, v)
+			}
+			if v, ok := prefix.Value.NetIDType(); !ok || v != tc.netIDType {
+				t.Errorf("expected DevAddr NetID type %d, got %d (%v)", tc.netIDType, v, ok)
+			}
+		})
+	}
+}
+
+func TestDevAddrNetIDType(t *testing.T) {
+	for _, tc := range []struct {
+		devAddr   DevAddr
+		netIDType uint8
+		ok        bool
+	}{
+		{devAddr: 0x00000000, netIDType: 0, ok: true},
+		{devAddr: 0x7FFFFFFF, netIDType: 0, ok: true},
+		{devAddr: 0x80000000, netIDType: 1, ok: true},
+		{devAddr: 0xFE000000, netIDType: 7, ok: true},
+		{devAddr: 0xFF000000, ok: false},
+		{devAddr: 0xFFFFFFFF, ok: false},
+	} {
+		t.Run(tc.devAddr.String(), func(t *testing.T) {
+			netIDType, ok := tc.devAddr.NetIDType()
+			if ok != tc.ok || netIDType != tc.netIDType {
+				t.Fatalf("expected %d (%v), got %d (%v)", tc.netIDType, tc.ok, netIDType, ok)
+			}
+		})
+	}
+}
+
+func TestUnmarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		text     string
+		value    interface{ UnmarshalText([]byte) error }
+		expected string
+		err      bool
+	}{
+		{name: "NetID", text: "000013", value: new(NetID), expected: "000013"},
+		{name: "NetIDLowercase", text: "c00123", value: new(NetID), expected: "C00123"},
+		{name: "NetIDTooShort", text: "13", value: new(NetID), err: true},
+		{name: "NetIDTooLong", text: "00000013", value: new(NetID), err: true},
+		{name: "NetIDInvalid", text: "00001G", value: new(NetID), err: true},
+		{name: "DevAddr", text: "26ab1234", value: new(DevAddr), expected: "26AB1234"},
+		{name: "DevAddrTooShort", text: "26AB12", value: new(DevAddr), err: true},
+		{name: "EUI64", text: "70B3D57ED0000001", value: new(EUI64), expected: "70B3D57ED0000001"},
+		{name: "EUI64TooShort", text: "70B3D57E", value: new(EUI64), err: true},
+		{name: "EUI64Invalid", text: "70B3D57ED000000X", value: new(EUI64), err: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.value.UnmarshalText([]byte(tc.text))
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if s := tc.value.(interface{ String() string }).String(); s != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, s)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package lorawan
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	packetbroker "go.packetbroker.org/api/v3"
+)
+
+// prefix is a prefix of an identifier of size bits.
+type prefix struct {
+	value        uint64
+	length, size uint8
+}
+
+func (p prefix) hostMask() uint64 {
+	return (^uint64(0) >> (64 - p.size)) >> p.length
+}
+
+func (p prefix) first() uint64 {
+	return p.value &^ p.hostMask()
+}
+
+func (p prefix) last() uint64 {
+	return p.first() | p.hostMask()
+}
+
+func (p prefix) contains(q prefix) bool {
+	return q.length >= p.length && q.value&^p.hostMask() == p.first()
+}
+
+func (p prefix) overlaps(q prefix) bool {
+	return p.contains(q) || q.contains(p)
+}
+
+// halves returns the two prefixes that are one bit more specific. The prefix must not be of full length.
+func (p prefix) halves() (prefix, prefix) {
+	lo := prefix{value: p.first(), length: p.length + 1, size: p.size}
+	hi := lo
+	hi.value |= lo.hostMask() + 1
+	return lo, hi
+}
+
+func (p prefix) String() string {
+	return fmt.Sprintf("%0*X/%d", p.size/4, p.value, p.length)
+}
+
+// subtract returns the prefixes that cover p except the removed prefixes, in ascending order.
+func subtract(p prefix, remove []prefix) []prefix {
+	var overlapping []prefix
+	for _, r := range remove {
+		if r.contains(p) {
+			return nil
+		}
+		if p.contains(r) {
+			overlapping = append(overlapping, r)
+		}
+	}
+	if len(overlapping) == 0 {
+		return []prefix{p}
+	}
+	lo, hi := p.halves()
+	return append(subtract(lo, overlapping), subtract(hi, overlapping)...)
+}
+
+// aggregate returns the smallest set of prefixes that cover the prefixes, in ascending order.
+func aggregate(prefixes []prefix) []prefix {
+	sorted := make([]prefix, len(prefixes))
+	for i, p := range prefixes {
+		p.value = p.first()
+		sorted[i] = p
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].value != sorted[j].value {
+			return sorted[i].value < sorted[j].value
+		}
+		return sorted[i].length < sorted[j].length
+	})
+	res := make([]prefix, 0, len(sorted))
+	for _, p := range sorted {
+		if n := len(res); n > 0 && res[n-1].contains(p) {
+			continue
+		}
+		res = append(res, p)
+		for n := len(res); n >= 2; n = len(res) {
+			a, b := res[n-2], res[n-1]
+			if a.length != b.length || a.length == 0 {
+				break
+			}
+			parent := prefix{value: a.value, length: a.length - 1, size: a.size}
+			if parent.first() != a.value {
+				break
+			}
+			if lo, hi := parent.halves(); lo != a || hi != b {
+				break
+			}
+			res = append(res[:n-2], parent)
+		}
+	}
+	return res
+}
+
+// parsePrefix parses a prefix in the format value/length. If the length is omitted, the prefix is the full size.
+func parsePrefix(s string, size int) (prefix, error) {
+	value, length := s, strconv.Itoa(size)
+	if i := strings.IndexByte(s, '/'); i >= 0 {
+		value, length = s[:i], s[i+1:]
+	}
+	v, err := parseHex(value, size)
+	if err != nil {
+		return prefix{}, err
+	}
+	l, err := strconv.ParseUint(length, 10, 8)
+	if err != nil || l > uint64(size) {
+		return prefix{}, fmt.Errorf("invalid prefix length %q, expected 0 to %d", length, size)
+	}
+	p := prefix{value: v, length: uint8(l), size: uint8(size)}
+	p.value = p.first()
+	return p, nil
+}
+
+// DevAddrPrefix is a DevAddr prefix.
+type DevAddrPrefix struct {
+	Value  DevAddr
+	Length uint8
+}
+
+// FromDevAddrPrefix converts the Packet Broker DevAddr prefix.
+func FromDevAddrPrefix(p *packetbroker.DevAddrPrefix) DevAddrPrefix {
+	return DevAddrPrefix{Value: DevAddr(p.GetValue()), Length: uint8(p.GetLength())}
+}
+
+// Proto returns the Packet Broker DevAddr prefix.
+func (p DevAddrPrefix) Proto() *packetbroker.DevAddrPrefix {
+	return &packetbroker.DevAddrPrefix{Value: uint32(p.Value), Length: uint32(p.Length)}
+}
+
+func (p DevAddrPrefix) prefix() prefix {
+	return prefix{value: uint64(p.Value), length: p.Length, size: 32}
+}
+
+func devAddrPrefix(p prefix) DevAddrPrefix {
+	return DevAddrPrefix{Value: DevAddr(p.value), Length: p.length}
+}
+
+// First returns the first DevAddr of the prefix.
+func (p DevAddrPrefix) First() DevAddr {
+	return DevAddr(p.prefix().first())
+}
+
+// Last returns the last DevAddr of the prefix.
+func (p DevAddrPrefix) Last() DevAddr {
+	return DevAddr(p.prefix().last())
+}
+
+// Match returns whether the prefix contains the DevAddr.
+func (p DevAddrPrefix) Match(d DevAddr) bool {
+	return p.prefix().contains(prefix{value: uint64(d), length: 32, size: 32})
+}
+
+// Contains returns whether the prefix is equal to or less specific than the other prefix and contains it.
+func (p DevAddrPrefix) Contains(other DevAddrPrefix) bool {
+	return p.prefix().contains(other.prefix())
+}
+
+// Overlaps returns whether either prefix contains the other.
+func (p DevAddrPrefix) Overlaps(other DevAddrPrefix) bool {
+	return p.prefix().overlaps(other.prefix())
+}
+
+// String implements fmt.Stringer.
+func (p DevAddrPrefix) String() string {
+	return p.prefix().String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p DevAddrPrefix) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. The format is 26AB0000/16. If the length is omitted, the length
+// is 32. Bits beyond the length are cleared.
+func (p *DevAddrPrefix) UnmarshalText(text []byte) error {
+	res, err := parsePrefix(string(text), 32)
+	if err != nil {
+		return err
+	}
+	*p = devAddrPrefix(res)
+	return nil
+}
+
+// SubtractDevAddrPrefixes returns the smallest set of prefixes that cover the prefix except the removed prefixes.
+// The result is in ascending order.
+func SubtractDevAddrPrefixes(from DevAddrPrefix, remove []DevAddrPrefix) []DevAddrPrefix {
+	ps := make([]prefix, len(remove))
+	for i, r := range remove {
+		ps[i] = r.prefix()
+	}
+	res := subtract(from.prefix(), ps)
+	out := make([]DevAddrPrefix, len(res))
+	for i, r := range res {
+		out[i] = devAddrPrefix(r)
+	}
+	return out
+}
+
+// AggregateDevAddrPrefixes returns the smallest set of prefixes that cover the same DevAddrs as the prefixes.
+// The result is in ascending order.
+func AggregateDevAddrPrefixes(prefixes []DevAddrPrefix) []DevAddrPrefix {
+	ps := make([]prefix, len(prefixes))
+	for i, p := range prefixes {
+		ps[i] = p.prefix()
+	}
+	res := aggregate(ps)
+	out := make([]DevAddrPrefix, len(res))
+	for i, r := range res {
+		out[i] = devAddrPrefix(r)
+	}
+	return out
+}
+
+// EUI64Prefix is an EUI-64 prefix, such as a JoinEUI prefix.
+type EUI64Prefix struct {
+	Value  EUI64
+	Length uint8
+}
+
+// FromJoinEUIPrefix converts the Packet Broker JoinEUI prefix.
+func FromJoinEUIPrefix(p *packetbroker.JoinEUIPrefix) EUI64Prefix {
+	return EUI64Prefix{Value: EUI64(p.GetValue()), Length: uint8(p.GetLength())}
+}
+
+// Proto returns the Packet Broker JoinEUI prefix.
+func (p EUI64Prefix) Proto() *packetbroker.JoinEUIPrefix {
+	return &packetbroker.JoinEUIPrefix{Value: uint64(p.Value), Length: uint32(p.Length)}
+}
+
+func (p EUI64Prefix) prefix() prefix {
+	return prefix{value: uint64(p.Value), length: p.Length, size: 64}
+}
+
+func eui64Prefix(p prefix) EUI64Prefix {
+	return EUI64Prefix{Value: EUI64(p.value), Length: p.length}
+}
+
+// First returns the first EUI-64 of the prefix.
+func (p EUI64Prefix) First() EUI64 {
+	return EUI64(p.prefix().first())
+}
+
+// Last returns the last EUI-64 of the prefix.
+func (p EUI64Prefix) Last() EUI64 {
+	return EUI64(p.prefix().last())
+}
+
+// Match returns whether the prefix contains the EUI-64.
+func (p EUI64Prefix) Match(e EUI64) bool {
+	return p.prefix().contains(prefix{value: uint64(e), length: 64, size: 64})
+}
+
+// Contains returns whether the prefix is equal to or less specific than the other prefix and contains it.
+func (p EUI64Prefix) Contains(other EUI64Prefix) bool {
+	return p.prefix().contains(other.prefix())
+}
+
+// Overlaps returns whether either prefix contains the other.
+func (p EUI64Prefix) Overlaps(other EUI64Prefix) bool {
+	return p.prefix().overlaps(other.prefix())
+}
+
+// String implements fmt.Stringer.
+func (p EUI64Prefix) String() string {
+	return p.prefix().String()
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p EUI64Prefix) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. The format is 70B3D57ED0000000/36. If the length is omitted,
+// the length is 64. Bits beyond the length are cleared.
+func (p *EUI64Prefix) UnmarshalText(text []byte) error {
+	res, err := parsePrefix(string(text), 64)
+	if err != nil {
+		return err
+	}
+	*p = eui64Prefix(res)
+	return nil
+}
+
+// SubtractEUI64Prefixes returns the smallest set of prefixes that cover the prefix except the removed prefixes.
+// The result is in ascending order.
+func SubtractEUI64Prefixes(from EUI64Prefix, remove []EUI64Prefix) []EUI64Prefix {
+	ps := make([]prefix, len(remove))
+	for i, r := range remove {
+		ps[i] = r.prefix()
+	}
+	res := subtract(from.prefix(), ps)
+	out := make([]EUI64Prefix, len(res))
+	for i, r := range res {
+		out[i] = eui64Prefix(r)
+	}
+	return out
+}
+
+// AggregateEUI64Prefixes returns the smallest set of prefixes that cover the same EUI-64s as the prefixes.
+// The result is in ascending order.
+func AggregateEUI64Prefixes(prefixes []EUI64Prefix) []EUI64Prefix {
+	ps := make([]prefix, len(prefixes))
+	for i, p := range prefixes {
+		ps[i] = p.prefix()
+	}
+	res := aggregate(ps)
+	out := make([]EUI64Prefix, len(res))
+	for i, r := range res {
+		out[i] = eui64Prefix(r)
+	}
+	return out
+}
@@ -0,0 +1,324 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package lorawan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func devAddrPrefixes(t *testing.T, ss ...string) []DevAddrPrefix {
+	t.Helper()
+	res := make([]DevAddrPrefix, len(ss))
+	for i, s := range ss {
+		if err := res[i].UnmarshalText([]byte(s)); err != nil {
+			t.Fatalf("invalid prefix %q: %v", s, err)
+		}
+	}
+	return res
+}
+
+func eui64Prefixes(t *testing.T, ss ...string) []EUI64Prefix {
+	t.Helper()
+	res := make([]EUI64Prefix, len(ss))
+	for i, s := range ss {
+		if err := res[i].UnmarshalText([]byte(s)); err != nil {
+			t.Fatalf("invalid prefix %q: %v", s, err)
+		}
+	}
+	return res
+}
+
+func joinPrefixes[T interface{ String() string }](ps []T) string {
+	ss := make([]string, len(ps))
+	for i, p := range ps {
+		ss[i] = p.String()
+	}
+	return strings.Join(ss, ",")
+}
+
+func TestDevAddrPrefixUnmarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		expected DevAddrPrefix
+		err      bool
+	}{
+		{text: "26000000/7", expected: DevAddrPrefix{Value: 0x26000000, Length: 7}},
+		{text: "26ab0000/16", expected: DevAddrPrefix{Value: 0x26AB0000, Length: 16}},
+		{text: "26AB1234/16", expected: DevAddrPrefix{Value: 0x26AB0000, Length: 16}},
+		{text: "26AB1234", expected: DevAddrPrefix{Value: 0x26AB1234, Length: 32}},
+		{text: "FFFFFFFF/0", expected: DevAddrPrefix{Value: 0, Length: 0}},
+		{text: "26AB1234/33", err: true},
+		{text: "26AB1234/", err: true},
+		{text: "26AB1234/-1", err: true},
+		{text: "26AB/16", err: true},
+		{text: "", err: true},
+	} {
+		t.Run(tc.text, func(t *testing.T) {
+			var p DevAddrPrefix
+			err := p.UnmarshalText([]byte(tc.text))
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, p)
+			}
+			text, _ := p.MarshalText()
+			var q DevAddrPrefix
+			if err := q.UnmarshalText(text); err != nil || q != p {
+				t.Fatalf("round trip of %s failed: %s (%v)", p, q, err)
+			}
+		})
+	}
+}
+
+func TestEUI64PrefixUnmarshalText(t *testing.T) {
+	for _, tc := range []struct {
+		text     string
+		expected EUI64Prefix
+		err      bool
+	}{
+		{text: "70B3D57ED0000000/36", expected: EUI64Prefix{Value: 0x70B3D57ED0000000, Length: 36}},
+		{text: "70B3D57ED0000001/36", expected: EUI64Prefix{Value: 0x70B3D57ED0000000, Length: 36}},
+		{text: "70B3D57ED0000001", expected: EUI64Prefix{Value: 0x70B3D57ED0000001, Length: 64}},
+		{text: "0000000000000000/0", expected: EUI64Prefix{}},
+		{text: "70B3D57ED0000000/65", err: true},
+		{text: "70B3D57E/32", err: true},
+	} {
+		t.Run(tc.text, func(t *testing.T) {
+			var p EUI64Prefix
+			err := p.UnmarshalText([]byte(tc.text))
+			if tc.err {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if p != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestDevAddrPrefixContainment(t *testing.T) {
+	for _, tc := range []struct {
+		p, q               string
+		contains, overlaps bool
+	}{
+		{p: "26000000/7", q: "26AB0000/16", contains: true, overlaps: true},
+		{p: "26AB0000/16", q: "26000000/7", contains: false, overlaps: true},
+		{p: "26AB0000/16", q: "26AB0000/16", contains: true, overlaps: true},
+		{p: "26AB0000/16", q: "26AC0000/16", contains: false, overlaps: false},
+		{p: "00000000/0", q: "FFFFFFFF/32", contains: true, overlaps: true},
+		{p: "26000000/7", q: "28000000/7", contains: false, overlaps: false},
+		{p: "27FFFFFF/32", q: "27FFFFFF/32", contains: true, overlaps: true},
+	} {
+		t.Run(tc.p+"-"+tc.q, func(t *testing.T) {
+			ps := devAddrPrefixes(t, tc.p, tc.q)
+			if v := ps[0].Contains(ps[1]); v != tc.contains {
+				t.Errorf("expected contains %v, got %v", tc.contains, v)
+			}
+			if v := ps[0].Overlaps(ps[1]); v != tc.overlaps {
+				t.Errorf("expected overlaps %v, got %v", tc.overlaps, v)
+			}
+		})
+	}
+}
+
+func TestDevAddrPrefixMatch(t *testing.T) {
+	p := DevAddrPrefix{Value: 0x26AB0000, Length: 16}
+	if p.First() != 0x26AB0000 || p.Last() != 0x26ABFFFF {
+		t.Fatalf("unexpected range %s to %s", p.First(), p.Last())
+	}
+	for _, tc := range []struct {
+		devAddr DevAddr
+		match   bool
+	}{
+		{devAddr: 0x26AB0000, match: true},
+		{devAddr: 0x26AB1234, match: true},
+		{devAddr: 0x26ABFFFF, match: true},
+		{devAddr: 0x26AAFFFF, match: false},
+		{devAddr: 0x26AC0000, match: false},
+	} {
+		if v := p.Match(tc.devAddr); v != tc.match {
+			t.Errorf("%s: expected match %v, got %v", tc.devAddr, tc.match, v)
+		}
+	}
+	if all := (DevAddrPrefix{}); !all.Match(0xFFFFFFFF) || all.Last() != 0xFFFFFFFF {
+		t.Fatal("expected zero length prefix to match all")
+	}
+}
+
+func TestEUI64PrefixMatch(t *testing.T) {
+	p := EUI64Prefix{Value: 0x70B3D57ED0000000, Length: 36}
+	if p.First() != 0x70B3D57ED0000000 || p.Last() != 0x70B3D57EDFFFFFFF {
+		t.Fatalf("unexpected range %s to %s", p.First(), p.Last())
+	}
+	if !p.Match(0x70B3D57ED0000001) || p.Match(0x70B3D57EE0000000) {
+		t.Fatal("unexpected match")
+	}
+	if all := (EUI64Prefix{}); !all.Match(0xFFFFFFFFFFFFFFFF) || all.Last() != 0xFFFFFFFFFFFFFFFF {
+		t.Fatal("expected zero length prefix to match all")
+	}
+}
+
+func TestSubtractDevAddrPrefixes(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		from     string
+		remove   []string
+		expected string
+	}{
+		{
+			name:     "None",
+			from:     "26000000/7",
+			expected: "26000000/7",
+		},
+		{
+			name:     "Disjoint",
+			from:     "26000000/7",
+			remove:   []string{"28000000/8"},
+			expected: "26000000/7",
+		},
+		{
+			name:     "All",
+			from:     "26AB0000/16",
+			remove:   []string{"26000000/7"},
+			expected: "",
+		},
+		{
+			name:     "Equal",
+			from:     "26AB0000/16",
+			remove:   []string{"26AB0000/16"},
+			expected: "",
+		},
+		{
+			name:     "Half",
+			from:     "26000000/7",
+			remove:   []string{"26000000/8"},
+			expected: "27000000/8",
+		},
+		{
+			name:     "Single",
+			from:     "26000000/30",
+			remove:   []string{"26000001/32"},
+			expected: "26000000/32,26000002/31",
+		},
+		{
+			name:   "Multiple",
+			from:   "26000000/12",
+			remove: []string{"26000000/16", "26020000/16", "260F0000/16"},
+			expected: strings.Join([]string{
+				"26010000/16",
+				"26030000/16",
+				"26040000/14",
+				"26080000/14",
+				"260C0000/15",
+				"260E0000/16",
+			}, ","),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			from := devAddrPrefixes(t, tc.from)[0]
+			res := SubtractDevAddrPrefixes(from, devAddrPrefixes(t, tc.remove...))
+			if actual := joinPrefixes(res); actual != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSubtractEUI64Prefixes(t *testing.T) {
+	res := SubtractEUI64Prefixes(EUI64Prefix{}, eui64Prefixes(t, "8000000000000000/1", "0000000000000000/2"))
+	if actual, expected := joinPrefixes(res), "4000000000000000/2"; actual != expected {
+		t.Fatalf("expected %s, got %s", expected, actual)
+	}
+}
+
+func TestAggregateDevAddrPrefixes(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		prefixes []string
+		expected string
+	}{
+		{
+			name: "Empty",
+		},
+		{
+			name:     "Siblings",
+			prefixes: []string{"27000000/8", "26000000/8"},
+			expected: "26000000/7",
+		},
+		{
+			name:     "Contained",
+			prefixes: []string{"26AB0000/16", "26000000/7", "26AB1200/24"},
+			expected: "26000000/7",
+		},
+		{
+			name:     "Duplicates",
+			prefixes: []string{"26AB0000/16", "26AB0000/16"},
+			expected: "26AB0000/16",
+		},
+		{
+			name:     "NotAligned",
+			prefixes: []string{"26000000/8", "25000000/8"},
+			expected: "25000000/8,26000000/8",
+		},
+		{
+			name:     "Cascade",
+			prefixes: []string{"26000000/9", "26800000/10", "26C00000/11", "26E00000/11", "27000000/8"},
+			expected: "26000000/7",
+		},
+		{
+			name:     "All",
+			prefixes: []string{"00000000/1", "80000000/1"},
+			expected: "00000000/0",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			res := AggregateDevAddrPrefixes(devAddrPrefixes(t, tc.prefixes...))
+			if actual := joinPrefixes(res); actual != tc.expected {
+				t.Fatalf("expected %s, got %s", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAggregateEUI64Prefixes(t *testing.T) {
+	res := AggregateEUI64Prefixes(eui64Prefixes(t, "70B3D57ED0000000/37", "70B3D57ED8000000/37", "EC656E0000000000/24"))
+	expected := []EUI64Prefix{
+		{Value: 0x70B3D57ED0000000, Length: 36},
+		{Value: 0xEC656E0000000000, Length: 24},
+	}
+	if !reflect.DeepEqual(res, expected) {
+		t.Fatalf("expected %v, got %v", expected, res)
+	}
+}
+
+func TestSubtractAggregate(t *testing.T) {
+	from := DevAddrPrefix{Value: 0x26000000, Length: 7}
+	remove := devAddrPrefixes(t, "26AB0000/16", "26AB1234/32", "27000000/9", "27FFFF00/24")
+	free := SubtractDevAddrPrefixes(from, remove)
+	for _, f := range free {
+		for _, r := range remove {
+			if f.Overlaps(r) {
+				t.Fatalf("free prefix %s overlaps removed prefix %s", f, r)
+			}
+		}
+	}
+	all := AggregateDevAddrPrefixes(append(free, remove...))
+	if len(all) != 1 || all[0] != from {
+		t.Fatalf("expected %s, got %s", from, joinPrefixes(all))
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+	"sort"
+
+	iampb "go.packetbroker.org/api/iam/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var errAPIKeyNotFound = status.Error(codes.NotFound, "API key not found")
+
+// newAPIKey returns a new key ID and, if key is empty, a new secret key.
+func newAPIKey(key string) (string, string) {
+	if key == "" {
+		key = randomString(30)
+	}
+	return randomString(10), key
+}
+
+// AddNetworkAPIKey adds or replaces the network API key.
+func (s *Server) AddNetworkAPIKey(key *packetbroker.NetworkAPIKey) {
+	s.mu.Lock()
+	s.networkAPIKeys[key.GetKeyId()] = clone(key)
+	s.mu.Unlock()
+}
+
+// AddClusterAPIKey adds or replaces the cluster API key.
+func (s *Server) AddClusterAPIKey(key *packetbroker.ClusterAPIKey) {
+	s.mu.Lock()
+	s.clusterAPIKeys[key.GetKeyId()] = clone(key)
+	s.mu.Unlock()
+}
+
+type networkAPIKeyVault struct {
+	iampb.UnimplementedNetworkAPIKeyVaultServer
+	*Server
+}
+
+func (v *networkAPIKeyVault) CreateAPIKey(ctx context.Context, req *iampb.CreateNetworkAPIKeyRequest) (*iampb.CreateNetworkAPIKeyResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	id, secret := newAPIKey(req.Key)
+	// Like in Packet Broker, network API keys need to be approved by an administrator.
+	key := &packetbroker.NetworkAPIKey{
+		KeyId:     id,
+		NetId:     req.NetId,
+		TenantId:  req.TenantId,
+		ClusterId: req.ClusterId,
+		Key:       secret,
+		Rights:    append([]packetbroker.Right(nil), req.Rights...),
+		State:     packetbroker.APIKeyState_REQUESTED,
+		UpdatedAt: timestamppb.New(v.updatedAt()),
+	}
+	v.networkAPIKeys[id] = key
+	return &iampb.CreateNetworkAPIKeyResponse{
+		Key: clone(key),
+	}, nil
+}
+
+func (v *networkAPIKeyVault) ListAPIKeys(ctx context.Context, req *iampb.ListNetworkAPIKeysRequest) (*iampb.ListNetworkAPIKeysResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	res := &iampb.ListNetworkAPIKeysResponse{}
+	for _, k := range v.networkAPIKeys {
+		if (req.NetId != nil && k.NetId != req.NetId.Value) ||
+			(req.TenantId != nil && k.TenantId != req.TenantId.Value) ||
+			(req.ClusterId != nil && k.ClusterId != req.ClusterId.Value) {
+			continue
+		}
+		k = clone(k)
+		k.Key = ""
+		res.Keys = append(res.Keys, k)
+	}
+	sort.Slice(res.Keys, func(i, j int) bool { return res.Keys[i].KeyId < res.Keys[j].KeyId })
+	return res, nil
+}
+
+func (v *networkAPIKeyVault) UpdateAPIKeyState(ctx context.Context, req *iampb.UpdateAPIKeyStateRequest) (*emptypb.Empty, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.networkAPIKeys[req.KeyId]
+	if !ok {
+		return nil, errAPIKeyNotFound
+	}
+	key = clone(key)
+	key.State = req.State
+	key.UpdatedAt = timestamppb.New(v.updatedAt())
+	v.networkAPIKeys[req.KeyId] = key
+	return &emptypb.Empty{}, nil
+}
+
+func (v *networkAPIKeyVault) DeleteAPIKey(ctx context.Context, req *iampb.APIKeyRequest) (*emptypb.Empty, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.networkAPIKeys[req.KeyId]; !ok {
+		return nil, errAPIKeyNotFound
+	}
+	delete(v.networkAPIKeys, req.KeyId)
+	return &emptypb.Empty{}, nil
+}
+
+type clusterAPIKeyVault struct {
+	iampb.UnimplementedClusterAPIKeyVaultServer
+	*Server
+}
+
+func (v *clusterAPIKeyVault) CreateAPIKey(ctx context.Context, req *iampb.CreateClusterAPIKeyRequest) (*iampb.CreateClusterAPIKeyResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	id, secret := newAPIKey(req.Key)
+	key := &packetbroker.ClusterAPIKey{
+		KeyId:     id,
+		ClusterId: req.ClusterId,
+		Key:       secret,
+		Rights:    append([]packetbroker.Right(nil), req.Rights...),
+		State:     packetbroker.APIKeyState_APPROVED,
+		UpdatedAt: timestamppb.New(v.updatedAt()),
+	}
+	v.clusterAPIKeys[id] = key
+	return &iampb.CreateClusterAPIKeyResponse{
+		Key: clone(key),
+	}, nil
+}
+
+func (v *clusterAPIKeyVault) ListAPIKeys(ctx context.Context, req *iampb.ListClusterAPIKeysRequest) (*iampb.ListClusterAPIKeysResponse, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	res := &iampb.ListClusterAPIKeysResponse{}
+	for _, k := range v.clusterAPIKeys {
+		if req.ClusterId != nil && k.ClusterId != req.ClusterId.Value {
+			continue
+		}
+		k = clone(k)
+		k.Key = ""
+		res.Keys = append(res.Keys, k)
+	}
+	sort.Slice(res.Keys, func(i, j int) bool { return res.Keys[i].KeyId < res.Keys[j].KeyId })
+	return res, nil
+}
+
+func (v *clusterAPIKeyVault) UpdateAPIKeyState(ctx context.Context, req *iampb.UpdateAPIKeyStateRequest) (*emptypb.Empty, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.clusterAPIKeys[req.KeyId]
+	if !ok {
+		return nil, errAPIKeyNotFound
+	}
+	key = clone(key)
+	key.State = req.State
+	key.UpdatedAt = timestamppb.New(v.updatedAt())
+	v.clusterAPIKeys[req.KeyId] = key
+	return &emptypb.Empty{}, nil
+}
+
+func (v *clusterAPIKeyVault) DeleteAPIKey(ctx context.Context, req *iampb.APIKeyRequest) (*emptypb.Empty, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if _, ok := v.clusterAPIKeys[req.KeyId]; !ok {
+		return nil, errAPIKeyNotFound
+	}
+	delete(v.clusterAPIKeys, req.KeyId)
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,140 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+
+	iampb "go.packetbroker.org/api/iam/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+)
+
+// catalogFilter filters networks and tenants in the catalog.
+type catalogFilter struct {
+	listedOnly       bool
+	homeNetworksOnly bool
+	nameContains     string
+	tenantIDContains string
+	// policyWith is the network or tenant that must have a Home Network policy with the network or tenant, in
+	// either direction. If nil, the filter does not apply.
+	policyWith *packetbroker.TenantID
+}
+
+// catalogNetworks returns the networks and tenants that pass the filter, sorted by NetID and tenant ID.
+// Each network is followed by its tenants. The targets are omitted. The caller must hold the lock.
+func (s *Server) catalogNetworks(filter catalogFilter) []*packetbroker.NetworkOrTenant {
+	type entry struct {
+		name          string
+		listed        bool
+		devAddrBlocks []*packetbroker.DevAddrBlock
+		value         func() *packetbroker.NetworkOrTenant
+	}
+	entries := make(map[packetbroker.TenantID]entry)
+	if filter.tenantIDContains == "" {
+		for _, n := range s.networks {
+			n := n
+			entries[packetbroker.TenantID{NetID: packetbroker.NetID(n.NetId)}] = entry{
+				name:          n.Name,
+				listed:        n.Listed,
+				devAddrBlocks: n.DevAddrBlocks,
+				value: func() *packetbroker.NetworkOrTenant {
+					n := clone(n)
+					n.Target = nil
+					return &packetbroker.NetworkOrTenant{
+						Value: &packetbroker.NetworkOrTenant_Network{Network: n},
+					}
+				},
+			}
+		}
+	}
+	for id, t := range s.tenants {
+		t := t
+		if !containsFold(id.ID, filter.tenantIDContains) {
+			continue
+		}
+		entries[id] = entry{
+			name:          t.Name,
+			listed:        t.Listed,
+			devAddrBlocks: t.DevAddrBlocks,
+			value: func() *packetbroker.NetworkOrTenant {
+				t := clone(t)
+				t.Target = nil
+				return &packetbroker.NetworkOrTenant{
+					Value: &packetbroker.NetworkOrTenant_Tenant{Tenant: t},
+				}
+			},
+		}
+	}
+
+	ids := make([]packetbroker.TenantID, 0, len(entries))
+	for id, e := range entries {
+		if (filter.listedOnly && !e.listed) ||
+			(filter.homeNetworksOnly && len(e.devAddrBlocks) == 0) ||
+			!containsFold(e.name, filter.nameContains) ||
+			(filter.policyWith != nil && !s.hasHomeNetworkPolicy(*filter.policyWith, id)) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sortTenantIDs(ids)
+	res := make([]*packetbroker.NetworkOrTenant, len(ids))
+	for i, id := range ids {
+		res[i] = entries[id].value()
+	}
+	return res
+}
+
+// policyReference returns the tenant ID of the policy reference, or nil if there is no reference.
+func policyReference(ref *iampb.ListNetworksRequest_PolicyReference) *packetbroker.TenantID {
+	if ref == nil {
+		return nil
+	}
+	id := packetbroker.RequestTenantID(ref)
+	return &id
+}
+
+type catalog struct {
+	iampb.UnimplementedCatalogServer
+	*Server
+}
+
+func (c *catalog) ListNetworks(ctx context.Context, req *iampb.ListNetworksRequest) (*iampb.ListNetworksResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	networks := c.catalogNetworks(catalogFilter{
+		listedOnly:       true,
+		nameContains:     req.NameContains,
+		tenantIDContains: req.TenantIdContains,
+		policyWith:       policyReference(req.PolicyReference),
+	})
+	return &iampb.ListNetworksResponse{
+		Networks: page(networks, req.Offset, req.Limit),
+		Total:    uint32(len(networks)),
+	}, nil
+}
+
+func (c *catalog) ListHomeNetworks(ctx context.Context, req *iampb.ListNetworksRequest) (*iampb.ListNetworksResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	networks := c.catalogNetworks(catalogFilter{
+		listedOnly:       true,
+		homeNetworksOnly: true,
+		nameContains:     req.NameContains,
+		tenantIDContains: req.TenantIdContains,
+		policyWith:       policyReference(req.PolicyReference),
+	})
+	return &iampb.ListNetworksResponse{
+		Networks: page(networks, req.Offset, req.Limit),
+		Total:    uint32(len(networks)),
+	}, nil
+}
+
+func (c *catalog) ListJoinServers(ctx context.Context, req *iampb.ListJoinServersRequest) (*iampb.ListJoinServersResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	joinServers := c.listJoinServers(req.NameContains, true)
+	return &iampb.ListJoinServersResponse{
+		JoinServers: cloneAll(page(joinServers, req.Offset, req.Limit)),
+		Total:       uint32(len(joinServers)),
+	}, nil
+}
@@ -0,0 +1,286 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+
+	routingpb "go.packetbroker.org/api/routing"
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/pkg/lorawan"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// subscriberBuffer is the number of messages that are buffered per subscriber. Messages are dropped when the buffer
+// is full.
+const subscriberBuffer = 64
+
+type subscriber[T any] struct {
+	endpoint packetbroker.Endpoint
+	group    string
+	filters  []*packetbroker.RoutingFilter
+	ch       chan T
+	done     chan error
+}
+
+// close ends the subscription with the error.
+func (s *subscriber[T]) close(err error) {
+	select {
+	case s.done <- err:
+	default:
+	}
+}
+
+// deliver sends the message to one subscriber per group of the endpoint that accepts the message.
+func deliver[T any](subscribers map[*subscriber[T]]struct{}, endpoint packetbroker.Endpoint, msg T, accept func(*subscriber[T]) bool) {
+	groups := make(map[string]bool)
+	for sub := range subscribers {
+		if sub.endpoint != endpoint || groups[sub.group] || !accept(sub) {
+			continue
+		}
+		groups[sub.group] = true
+		select {
+		case sub.ch <- msg:
+		default:
+		}
+	}
+}
+
+// CloseSubscriptions ends the active subscriptions with the error, like a router that restarts.
+func (s *Server) CloseSubscriptions(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for sub := range s.forwarderSubscribers {
+		sub.close(err)
+	}
+	for sub := range s.homeNetworkSubscribers {
+		sub.close(err)
+	}
+}
+
+// UplinkDeliveryStates returns the reported uplink message delivery states.
+func (s *Server) UplinkDeliveryStates() []*packetbroker.UplinkMessageDeliveryStateChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneAll(s.uplinkDeliveryStates)
+}
+
+// DownlinkDeliveryStates returns the reported downlink message delivery states.
+func (s *Server) DownlinkDeliveryStates() []*packetbroker.DownlinkMessageDeliveryStateChange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return cloneAll(s.downlinkDeliveryStates)
+}
+
+// routeUplink returns the Home Network of the uplink message. Data messages are routed by the most specific DevAddr
+// block and join-requests are routed by the most specific JoinEUI prefix of a Join Server with a fixed endpoint.
+// The caller must hold the lock.
+func (s *Server) routeUplink(msg *packetbroker.UplinkMessage) (packetbroker.Endpoint, bool) {
+	var (
+		res    packetbroker.Endpoint
+		length = -1
+	)
+	teaser := msg.GetPhyPayload().GetTeaser()
+	if mac := teaser.GetMac(); mac != nil {
+		for _, r := range s.uplinkRoutes() {
+			if p := lorawan.FromDevAddrPrefix(r.Prefix); p.Match(lorawan.DevAddr(mac.DevAddr)) && int(p.Length) > length {
+				res = packetbroker.Endpoint{
+					TenantID:  packetbroker.RequestTenantID(r),
+					ClusterID: r.HomeNetworkClusterId,
+				}
+				length = int(p.Length)
+			}
+		}
+	}
+	if jr := teaser.GetJoinRequest(); jr != nil {
+		for _, r := range s.joinRequestRoutes() {
+			fixed := r.GetFixed()
+			if p := lorawan.FromJoinEUIPrefix(r.Prefix); fixed != nil && p.Match(lorawan.EUI64(jr.JoinEui)) && int(p.Length) > length {
+				res = packetbroker.Endpoint{
+					TenantID:  packetbroker.RequestTenantID(fixed),
+					ClusterID: fixed.ClusterId,
+				}
+				length = int(p.Length)
+			}
+		}
+	}
+	return res, length >= 0
+}
+
+// matchFilters returns whether the uplink message matches any of the filters. No filters match all messages.
+func matchFilters(filters []*packetbroker.RoutingFilter, msg *packetbroker.UplinkMessage) bool {
+	if len(filters) == 0 {
+		return true
+	}
+	teaser := msg.GetPhyPayload().GetTeaser()
+	for _, f := range filters {
+		if mac, filter := teaser.GetMac(), f.GetMac(); mac != nil && filter != nil {
+			if len(filter.DevAddrPrefixes) == 0 {
+				return true
+			}
+			for _, p := range filter.DevAddrPrefixes {
+				if lorawan.FromDevAddrPrefix(p).Match(lorawan.DevAddr(mac.DevAddr)) {
+					return true
+				}
+			}
+		}
+		if jr, filter := teaser.GetJoinRequest(), f.GetJoinRequest(); jr != nil && filter != nil {
+			if len(filter.EuiPrefixes) == 0 {
+				return true
+			}
+			for _, p := range filter.EuiPrefixes {
+				var (
+					joinEUI = lorawan.EUI64Prefix{Value: lorawan.EUI64(p.JoinEui), Length: uint8(p.JoinEuiLength)}
+					devEUI  = lorawan.EUI64Prefix{Value: lorawan.EUI64(p.DevEui), Length: uint8(p.DevEuiLength)}
+				)
+				if joinEUI.Match(lorawan.EUI64(jr.JoinEui)) && devEUI.Match(lorawan.EUI64(jr.DevEui)) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+type forwarderData struct {
+	routingpb.UnimplementedForwarderDataServer
+	*Server
+}
+
+// Publish routes the uplink message to the subscribers of the Home Network. Messages without a route are dropped.
+// Routing policies are not enforced.
+func (d *forwarderData) Publish(ctx context.Context, req *routingpb.PublishUplinkMessageRequest) (*routingpb.PublishUplinkMessageResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := randomString(16)
+	homeNetwork, ok := d.routeUplink(req.Message)
+	if !ok {
+		return &routingpb.PublishUplinkMessageResponse{Id: id}, nil
+	}
+	msg := &packetbroker.RoutedUplinkMessage{
+		Id:                   id,
+		ForwarderNetId:       req.ForwarderNetId,
+		ForwarderTenantId:    req.ForwarderTenantId,
+		ForwarderClusterId:   req.ForwarderClusterId,
+		HomeNetworkNetId:     uint32(homeNetwork.NetID),
+		HomeNetworkTenantId:  homeNetwork.ID,
+		HomeNetworkClusterId: homeNetwork.ClusterID,
+		Message:              clone(req.Message),
+		ReceivedAt:           timestamppb.Now(),
+	}
+	deliver(d.homeNetworkSubscribers, homeNetwork, msg, func(sub *subscriber[*packetbroker.RoutedUplinkMessage]) bool {
+		return matchFilters(sub.filters, req.Message)
+	})
+	return &routingpb.PublishUplinkMessageResponse{Id: id}, nil
+}
+
+func (d *forwarderData) Subscribe(req *routingpb.SubscribeForwarderRequest, stream routingpb.ForwarderData_SubscribeServer) error {
+	sub := &subscriber[*packetbroker.RoutedDownlinkMessage]{
+		endpoint: packetbroker.Endpoint{
+			TenantID:  packetbroker.ForwarderTenantID(req),
+			ClusterID: req.ForwarderClusterId,
+		},
+		group: req.Group,
+		ch:    make(chan *packetbroker.RoutedDownlinkMessage, subscriberBuffer),
+		done:  make(chan error, 1),
+	}
+	d.mu.Lock()
+	d.forwarderSubscribers[sub] = struct{}{}
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.forwarderSubscribers, sub)
+		d.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case err := <-sub.done:
+			return err
+		case msg := <-sub.ch:
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (d *forwarderData) ReportDownlinkMessageDeliveryState(ctx context.Context, req *routingpb.DownlinkMessageDeliveryStateChangeRequest) (*emptypb.Empty, error) {
+	d.mu.Lock()
+	d.downlinkDeliveryStates = append(d.downlinkDeliveryStates, clone(req.StateChange))
+	d.mu.Unlock()
+	return &emptypb.Empty{}, nil
+}
+
+type homeNetworkData struct {
+	routingpb.UnimplementedHomeNetworkDataServer
+	*Server
+}
+
+// Publish routes the downlink message to the subscribers of the Forwarder.
+// Routing policies are not enforced.
+func (d *homeNetworkData) Publish(ctx context.Context, req *routingpb.PublishDownlinkMessageRequest) (*routingpb.PublishDownlinkMessageResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	msg := &packetbroker.RoutedDownlinkMessage{
+		Id:                   randomString(16),
+		ForwarderNetId:       req.ForwarderNetId,
+		ForwarderTenantId:    req.ForwarderTenantId,
+		ForwarderClusterId:   req.ForwarderClusterId,
+		HomeNetworkNetId:     req.HomeNetworkNetId,
+		HomeNetworkTenantId:  req.HomeNetworkTenantId,
+		HomeNetworkClusterId: req.HomeNetworkClusterId,
+		Message:              clone(req.Message),
+		ReceivedAt:           timestamppb.Now(),
+	}
+	forwarder := packetbroker.Endpoint{
+		TenantID:  packetbroker.ForwarderTenantID(req),
+		ClusterID: req.ForwarderClusterId,
+	}
+	deliver(d.forwarderSubscribers, forwarder, msg, func(*subscriber[*packetbroker.RoutedDownlinkMessage]) bool {
+		return true
+	})
+	return &routingpb.PublishDownlinkMessageResponse{Id: msg.Id}, nil
+}
+
+func (d *homeNetworkData) Subscribe(req *routingpb.SubscribeHomeNetworkRequest, stream routingpb.HomeNetworkData_SubscribeServer) error {
+	sub := &subscriber[*packetbroker.RoutedUplinkMessage]{
+		endpoint: packetbroker.Endpoint{
+			TenantID:  packetbroker.HomeNetworkTenantID(req),
+			ClusterID: req.HomeNetworkClusterId,
+		},
+		group:   req.Group,
+		filters: req.Filters,
+		ch:      make(chan *packetbroker.RoutedUplinkMessage, subscriberBuffer),
+		done:    make(chan error, 1),
+	}
+	d.mu.Lock()
+	d.homeNetworkSubscribers[sub] = struct{}{}
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		delete(d.homeNetworkSubscribers, sub)
+		d.mu.Unlock()
+	}()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case err := <-sub.done:
+			return err
+		case msg := <-sub.ch:
+			if err := stream.Send(msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (d *homeNetworkData) ReportUplinkMessageDeliveryState(ctx context.Context, req *routingpb.UplinkMessageDeliveryStateChangeRequest) (*emptypb.Empty, error) {
+	d.mu.Lock()
+	d.uplinkDeliveryStates = append(d.uplinkDeliveryStates, clone(req.StateChange))
+	d.mu.Unlock()
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,130 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+	"sort"
+
+	iampb "go.packetbroker.org/api/iam/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var errJoinServerNotFound = status.Error(codes.NotFound, "Join Server not found")
+
+// AddJoinServer adds the Join Server and returns its ID. If the ID is zero, a new ID is assigned. Otherwise, an existing
+// Join Server with the ID is replaced.
+func (s *Server) AddJoinServer(js *packetbroker.JoinServer) uint32 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addJoinServer(js)
+}
+
+// addJoinServer adds the Join Server and returns its ID. The caller must hold the lock.
+func (s *Server) addJoinServer(js *packetbroker.JoinServer) uint32 {
+	js = clone(js)
+	if js.Id == 0 {
+		s.lastJoinServerID++
+		js.Id = s.lastJoinServerID
+	} else if js.Id > s.lastJoinServerID {
+		s.lastJoinServerID = js.Id
+	}
+	s.joinServers[js.Id] = js
+	return js.Id
+}
+
+// listJoinServers returns the Join Servers sorted by ID. The caller must hold the lock.
+func (s *Server) listJoinServers(nameContains string, listedOnly bool) []*packetbroker.JoinServer {
+	var res []*packetbroker.JoinServer
+	for _, js := range s.joinServers {
+		if (!listedOnly || js.Listed) && containsFold(js.Name, nameContains) {
+			res = append(res, js)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Id < res[j].Id })
+	return res
+}
+
+type joinServerRegistry struct {
+	iampb.UnimplementedJoinServerRegistryServer
+	*Server
+}
+
+func (r *joinServerRegistry) ListJoinServers(ctx context.Context, req *iampb.ListJoinServersRequest) (*iampb.ListJoinServersResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	joinServers := r.listJoinServers(req.NameContains, false)
+	return &iampb.ListJoinServersResponse{
+		JoinServers: cloneAll(page(joinServers, req.Offset, req.Limit)),
+		Total:       uint32(len(joinServers)),
+	}, nil
+}
+
+func (r *joinServerRegistry) CreateJoinServer(ctx context.Context, req *iampb.CreateJoinServerRequest) (*iampb.CreateJoinServerResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	js := clone(req.JoinServer)
+	js.Id = 0
+	id := r.addJoinServer(js)
+	return &iampb.CreateJoinServerResponse{
+		JoinServer: clone(r.joinServers[id]),
+	}, nil
+}
+
+func (r *joinServerRegistry) GetJoinServer(ctx context.Context, req *iampb.JoinServerRequest) (*iampb.GetJoinServerResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	js, ok := r.joinServers[req.Id]
+	if !ok {
+		return nil, errJoinServerNotFound
+	}
+	return &iampb.GetJoinServerResponse{
+		JoinServer: clone(js),
+	}, nil
+}
+
+func (r *joinServerRegistry) UpdateJoinServer(ctx context.Context, req *iampb.UpdateJoinServerRequest) (*emptypb.Empty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	js, ok := r.joinServers[req.Id]
+	if !ok {
+		return nil, errJoinServerNotFound
+	}
+	js = clone(js)
+	if req.Name != nil {
+		js.Name = req.Name.Value
+	}
+	if req.AdministrativeContact != nil {
+		js.AdministrativeContact = clone(req.AdministrativeContact.Value)
+	}
+	if req.TechnicalContact != nil {
+		js.TechnicalContact = clone(req.TechnicalContact.Value)
+	}
+	if req.JoinEuiPrefixes != nil {
+		js.JoinEuiPrefixes = cloneAll(req.JoinEuiPrefixes.Value)
+	}
+	if req.Listed != nil {
+		js.Listed = req.Listed.Value
+	}
+	switch resolver := req.Resolver.(type) {
+	case *iampb.UpdateJoinServerRequest_Fixed:
+		js.Resolver = &packetbroker.JoinServer_Fixed{Fixed: clone(resolver.Fixed)}
+	case *iampb.UpdateJoinServerRequest_Lookup:
+		js.Resolver = &packetbroker.JoinServer_Lookup{Lookup: clone(resolver.Lookup)}
+	}
+	r.joinServers[req.Id] = js
+	return &emptypb.Empty{}, nil
+}
+
+func (r *joinServerRegistry) DeleteJoinServer(ctx context.Context, req *iampb.JoinServerRequest) (*emptypb.Empty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.joinServers[req.Id]; !ok {
+		return nil, errJoinServerNotFound
+	}
+	delete(r.joinServers, req.Id)
+	return &emptypb.Empty{}, nil
+}
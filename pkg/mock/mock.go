@@ -0,0 +1,229 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package mock implements in-memory Packet Broker services for testing.
+//
+// Server implements the IAM, Control Plane, Reporter and Router services that are used by the Packet Broker clients.
+// The services do not authenticate nor authorize requests, so clients can connect with any credentials.
+package mock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	iampb "go.packetbroker.org/api/iam"
+	iampbv2 "go.packetbroker.org/api/iam/v2"
+	mappingpb "go.packetbroker.org/api/mapping/v2"
+	reportingpb "go.packetbroker.org/api/reporting"
+	routingpb "go.packetbroker.org/api/routing"
+	routingpbv2 "go.packetbroker.org/api/routing/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// defaultLimit is the page size of list calls that do not specify a limit.
+const defaultLimit = 100
+
+// Call is a recorded gRPC call.
+type Call struct {
+	// Method is the full gRPC method name, e.g. /org.packetbroker.iam.v1.NetworkRegistry/ListNetworks.
+	Method string
+	// Request is the request message. For streaming calls, this is the first message received from the client.
+	Request proto.Message
+}
+
+// tenantPair identifies a Forwarder and Home Network pair.
+type tenantPair struct {
+	forwarder, homeNetwork packetbroker.TenantID
+}
+
+// Server is an in-memory Packet Broker server.
+type Server struct {
+	mu            sync.Mutex
+	lastUpdatedAt time.Time
+	calls         []Call
+
+	networks         map[uint32]*packetbroker.Network
+	tenants          map[packetbroker.TenantID]*packetbroker.Tenant
+	joinServers      map[uint32]*packetbroker.JoinServer
+	lastJoinServerID uint32
+	networkAPIKeys   map[string]*packetbroker.NetworkAPIKey
+	clusterAPIKeys   map[string]*packetbroker.ClusterAPIKey
+
+	defaultPolicies            map[packetbroker.TenantID]*packetbroker.RoutingPolicy
+	homeNetworkPolicies        map[tenantPair]*packetbroker.RoutingPolicy
+	deletedDefaultPolicies     map[packetbroker.TenantID]*packetbroker.RoutingPolicy
+	deletedHomeNetworkPolicies map[tenantPair]*packetbroker.RoutingPolicy
+	defaultVisibilities        map[packetbroker.TenantID]*packetbroker.GatewayVisibility
+	homeNetworkVisibilities    map[tenantPair]*packetbroker.GatewayVisibility
+
+	routedMessages         []*reportingpb.RoutedMessagesRecord
+	uplinkDeliveryStates   []*packetbroker.UplinkMessageDeliveryStateChange
+	downlinkDeliveryStates []*packetbroker.DownlinkMessageDeliveryStateChange
+	forwarderSubscribers   map[*subscriber[*packetbroker.RoutedDownlinkMessage]]struct{}
+	homeNetworkSubscribers map[*subscriber[*packetbroker.RoutedUplinkMessage]]struct{}
+}
+
+// NewServer returns a new empty Server.
+func NewServer() *Server {
+	return &Server{
+		networks:                   make(map[uint32]*packetbroker.Network),
+		tenants:                    make(map[packetbroker.TenantID]*packetbroker.Tenant),
+		joinServers:                make(map[uint32]*packetbroker.JoinServer),
+		networkAPIKeys:             make(map[string]*packetbroker.NetworkAPIKey),
+		clusterAPIKeys:             make(map[string]*packetbroker.ClusterAPIKey),
+		defaultPolicies:            make(map[packetbroker.TenantID]*packetbroker.RoutingPolicy),
+		homeNetworkPolicies:        make(map[tenantPair]*packetbroker.RoutingPolicy),
+		deletedDefaultPolicies:     make(map[packetbroker.TenantID]*packetbroker.RoutingPolicy),
+		deletedHomeNetworkPolicies: make(map[tenantPair]*packetbroker.RoutingPolicy),
+		defaultVisibilities:        make(map[packetbroker.TenantID]*packetbroker.GatewayVisibility),
+		homeNetworkVisibilities:    make(map[tenantPair]*packetbroker.GatewayVisibility),
+		forwarderSubscribers:       make(map[*subscriber[*packetbroker.RoutedDownlinkMessage]]struct{}),
+		homeNetworkSubscribers:     make(map[*subscriber[*packetbroker.RoutedUplinkMessage]]struct{}),
+	}
+}
+
+// Register registers the services on the gRPC server.
+func (s *Server) Register(r grpc.ServiceRegistrar) {
+	iampb.RegisterNetworkRegistryServer(r, &networkRegistry{Server: s})
+	iampb.RegisterTenantRegistryServer(r, &tenantRegistry{Server: s})
+	iampbv2.RegisterNetworkAPIKeyVaultServer(r, &networkAPIKeyVault{Server: s})
+	iampbv2.RegisterClusterAPIKeyVaultServer(r, &clusterAPIKeyVault{Server: s})
+	iampbv2.RegisterJoinServerRegistryServer(r, &joinServerRegistry{Server: s})
+	iampbv2.RegisterCatalogServer(r, &catalog{Server: s})
+	routingpb.RegisterPolicyManagerServer(r, &policyManager{Server: s})
+	mappingpb.RegisterGatewayVisibilityManagerServer(r, &gatewayVisibilityManager{Server: s})
+	routingpbv2.RegisterRoutesServer(r, &routes{Server: s})
+	reportingpb.RegisterReporterServer(r, &reporter{Server: s})
+	routingpb.RegisterForwarderDataServer(r, &forwarderData{Server: s})
+	routingpb.RegisterHomeNetworkDataServer(r, &homeNetworkData{Server: s})
+}
+
+// NewGRPCServer returns a new gRPC server with the services registered. The server records the calls, see Calls.
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.recordUnary),
+		grpc.ChainStreamInterceptor(s.recordStream),
+	)
+	res := grpc.NewServer(opts...)
+	s.Register(res)
+	return res
+}
+
+func (s *Server) record(method string, req interface{}) {
+	msg, ok := req.(proto.Message)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	s.calls = append(s.calls, Call{Method: method, Request: msg})
+	s.mu.Unlock()
+}
+
+func (s *Server) recordUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	s.record(info.FullMethod, req)
+	return handler(ctx, req)
+}
+
+type recordingServerStream struct {
+	grpc.ServerStream
+	once   sync.Once
+	record func(interface{})
+}
+
+func (s *recordingServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	s.once.Do(func() { s.record(m) })
+	return nil
+}
+
+func (s *Server) recordStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &recordingServerStream{
+		ServerStream: ss,
+		record:       func(m interface{}) { s.record(info.FullMethod, m) },
+	})
+}
+
+// Calls returns the recorded calls in the order they were received.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// ResetCalls clears the recorded calls.
+func (s *Server) ResetCalls() {
+	s.mu.Lock()
+	s.calls = nil
+	s.mu.Unlock()
+}
+
+// updatedAt returns the current time, which is guaranteed to be after the previously returned time.
+// This makes sure that clients can page by the last updated timestamp.
+func (s *Server) updatedAt() time.Time {
+	now := time.Now().UTC()
+	if !now.After(s.lastUpdatedAt) {
+		now = s.lastUpdatedAt.Add(time.Nanosecond)
+	}
+	s.lastUpdatedAt = now
+	return now
+}
+
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// randomString returns a random base32 string encoding n bytes.
+func randomString(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return keyEncoding.EncodeToString(buf)
+}
+
+func clone[T proto.Message](m T) T {
+	return proto.Clone(m).(T)
+}
+
+func cloneAll[T proto.Message](ms []T) []T {
+	res := make([]T, len(ms))
+	for i, m := range ms {
+		res[i] = clone(m)
+	}
+	return res
+}
+
+// containsFold returns whether substr is within s, ignoring case. An empty substr matches any s.
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// page returns the items in the page by offset and limit. A zero limit uses defaultLimit.
+func page[T any](items []T, offset, limit uint32) []T {
+	if limit == 0 {
+		limit = defaultLimit
+	}
+	if offset >= uint32(len(items)) {
+		return nil
+	}
+	end := uint64(offset) + uint64(limit)
+	if end > uint64(len(items)) {
+		end = uint64(len(items))
+	}
+	return items[offset:end]
+}
+
+func sortTenantIDs(ids []packetbroker.TenantID) {
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i].NetID != ids[j].NetID {
+			return ids[i].NetID < ids[j].NetID
+		}
+		return ids[i].ID < ids[j].ID
+	})
+}
@@ -0,0 +1,352 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	reportingpb "go.packetbroker.org/api/reporting"
+	routingpb "go.packetbroker.org/api/routing"
+	routingpbv2 "go.packetbroker.org/api/routing/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func dial(t *testing.T, s *Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := s.NewGRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestPage(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	for _, tc := range []struct {
+		offset, limit uint32
+		expected      int
+	}{
+		{0, 0, 5},
+		{0, 2, 2},
+		{4, 2, 1},
+		{5, 2, 0},
+		{10, 0, 0},
+	} {
+		if actual := page(items, tc.offset, tc.limit); len(actual) != tc.expected {
+			t.Fatalf("page(%d, %d): expected %d items, got %d", tc.offset, tc.limit, tc.expected, len(actual))
+		}
+	}
+}
+
+func TestReportTimeRange(t *testing.T) {
+	now := time.Date(2024, 3, 15, 12, 30, 0, 0, time.UTC)
+
+	from, to := reportTimeRange(&reportingpb.GetRoutedMessagesRequest{
+		Time: &reportingpb.GetRoutedMessagesRequest_Today{Today: &reportingpb.Today{}},
+	}, now)
+	if !from.Equal(time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)) || !to.IsZero() {
+		t.Fatalf("today: unexpected range %v - %v", from, to)
+	}
+
+	from, to = reportTimeRange(&reportingpb.GetRoutedMessagesRequest{
+		Time: &reportingpb.GetRoutedMessagesRequest_Period{
+			Period: &reportingpb.MonthPeriod{
+				From: &reportingpb.MonthYear{Year: 2023, Month: 12},
+				To:   &reportingpb.MonthYear{Year: 2024, Month: 1},
+			},
+		},
+	}, now)
+	if !from.Equal(time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("period: unexpected range %v - %v", from, to)
+	}
+
+	from, to = reportTimeRange(&reportingpb.GetRoutedMessagesRequest{}, now)
+	if !from.IsZero() || !to.IsZero() {
+		t.Fatalf("all: unexpected range %v - %v", from, to)
+	}
+}
+
+func TestEffectivePolicies(t *testing.T) {
+	s := NewServer()
+	ctx := context.Background()
+	m := &policyManager{Server: s}
+
+	for _, p := range []*packetbroker.RoutingPolicy{
+		{ForwarderNetId: 0x13, Uplink: &packetbroker.RoutingPolicy_Uplink{MacData: true}},
+		{ForwarderNetId: 0x14, Uplink: &packetbroker.RoutingPolicy_Uplink{JoinRequest: true}},
+	} {
+		if _, err := m.SetDefaultPolicy(ctx, &routingpb.SetPolicyRequest{Policy: p}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := m.SetHomeNetworkPolicy(ctx, &routingpb.SetPolicyRequest{
+		Policy: &packetbroker.RoutingPolicy{
+			ForwarderNetId:   0x14,
+			HomeNetworkNetId: 0x13,
+			Uplink:           &packetbroker.RoutingPolicy_Uplink{ApplicationData: true},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := m.ListEffectivePolicies(ctx, &routingpb.ListEffectivePoliciesRequest{HomeNetworkNetId: 0x13})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 2 || len(res.Policies) != 2 {
+		t.Fatalf("Expected 2 policies, got %d", len(res.Policies))
+	}
+	if p := res.Policies[0]; p.ForwarderNetId != 0x13 || p.HomeNetworkNetId != 0x13 || !p.GetUplink().GetMacData() {
+		t.Fatalf("Expected default policy of 000013, got %v", p)
+	}
+	if p := res.Policies[1]; p.ForwarderNetId != 0x14 || !p.GetUplink().GetApplicationData() || p.GetUplink().GetJoinRequest() {
+		t.Fatalf("Expected Home Network policy of 000014, got %v", p)
+	}
+
+	// Page by the last updated timestamp.
+	list, err := m.ListDefaultPolicies(ctx, &routingpb.ListDefaultPoliciesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err = m.ListDefaultPolicies(ctx, &routingpb.ListDefaultPoliciesRequest{
+		UpdatedSince: list.Policies[0].UpdatedAt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Policies) != 1 || list.Policies[0].ForwarderNetId != 0x14 {
+		t.Fatalf("Expected default policy of 000014 updated since the first, got %v", list.Policies)
+	}
+
+	// A policy without uplink and downlink deletes the policy.
+	if _, err := m.SetHomeNetworkPolicy(ctx, &routingpb.SetPolicyRequest{
+		Policy: &packetbroker.RoutingPolicy{ForwarderNetId: 0x14, HomeNetworkNetId: 0x13},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.GetHomeNetworkPolicy(ctx, &routingpb.GetHomeNetworkPolicyRequest{
+		ForwarderNetId:   0x14,
+		HomeNetworkNetId: 0x13,
+	}); err != errPolicyNotFound {
+		t.Fatalf("Expected policy not found, got %v", err)
+	}
+}
+
+func TestRouteUplink(t *testing.T) {
+	s := NewServer()
+	s.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}, HomeNetworkClusterId: "eu1"},
+		},
+	})
+	s.AddTenant(&packetbroker.Tenant{
+		NetId:    0x13,
+		TenantId: "tenant",
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26010000, Length: 16}},
+		},
+	})
+	mac := func(devAddr uint32) *packetbroker.UplinkMessage {
+		return &packetbroker.UplinkMessage{
+			PhyPayload: &packetbroker.UplinkMessage_PHYPayload{
+				Teaser: &packetbroker.PHYPayloadTeaser{
+					Payload: &packetbroker.PHYPayloadTeaser_Mac{
+						Mac: &packetbroker.PHYPayloadTeaser_MACPayloadTeaser{DevAddr: devAddr},
+					},
+				},
+			},
+		}
+	}
+
+	for _, tc := range []struct {
+		devAddr  uint32
+		expected packetbroker.Endpoint
+		ok       bool
+	}{
+		{
+			devAddr:  0x26000001,
+			expected: packetbroker.Endpoint{TenantID: packetbroker.TenantID{NetID: 0x13}, ClusterID: "eu1"},
+			ok:       true,
+		},
+		{
+			devAddr:  0x26010001,
+			expected: packetbroker.Endpoint{TenantID: packetbroker.TenantID{NetID: 0x13, ID: "tenant"}},
+			ok:       true,
+		},
+		{
+			devAddr: 0x01020304,
+		},
+	} {
+		s.mu.Lock()
+		actual, ok := s.routeUplink(mac(tc.devAddr))
+		s.mu.Unlock()
+		if ok != tc.ok || actual != tc.expected {
+			t.Fatalf("%08X: expected %v (%v), got %v (%v)", tc.devAddr, tc.expected, tc.ok, actual, ok)
+		}
+	}
+
+	filters := []*packetbroker.RoutingFilter{
+		{
+			Message: &packetbroker.RoutingFilter_Mac{
+				Mac: &packetbroker.RoutingFilter_MACPayload{
+					DevAddrPrefixes: []*packetbroker.DevAddrPrefix{{Value: 0x26010000, Length: 16}},
+				},
+			},
+		},
+	}
+	if !matchFilters(filters, mac(0x26010001)) {
+		t.Fatal("Expected filter to match")
+	}
+	if matchFilters(filters, mac(0x26000001)) {
+		t.Fatal("Expected filter not to match")
+	}
+}
+
+func TestData(t *testing.T) {
+	s := NewServer()
+	s.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+		},
+	})
+	conn := dial(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	routes, err := routingpbv2.NewRoutesClient(conn).ListUplinkRoutes(ctx, &routingpbv2.ListUplinkRoutesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routes.Total != 1 || routes.Routes[0].NetId != 0x13 {
+		t.Fatalf("Expected route of 000013, got %v", routes.Routes)
+	}
+
+	stream, err := routingpb.NewHomeNetworkDataClient(conn).Subscribe(ctx, &routingpb.SubscribeHomeNetworkRequest{
+		HomeNetworkNetId: 0x13,
+		Group:            "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the subscription to be registered before publishing.
+	for {
+		s.mu.Lock()
+		n := len(s.homeNetworkSubscribers)
+		s.mu.Unlock()
+		if n > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	pub, err := routingpb.NewForwarderDataClient(conn).Publish(ctx, &routingpb.PublishUplinkMessageRequest{
+		ForwarderNetId: 0x14,
+		Message: &packetbroker.UplinkMessage{
+			Frequency: 868100000,
+			PhyPayload: &packetbroker.UplinkMessage_PHYPayload{
+				Teaser: &packetbroker.PHYPayloadTeaser{
+					Payload: &packetbroker.PHYPayloadTeaser_Mac{
+						Mac: &packetbroker.PHYPayloadTeaser_MACPayloadTeaser{DevAddr: 0x26000001},
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Id != pub.Id || msg.ForwarderNetId != 0x14 || msg.HomeNetworkNetId != 0x13 || msg.GetMessage().GetFrequency() != 868100000 {
+		t.Fatalf("Unexpected message %v", msg)
+	}
+
+	if _, err := routingpb.NewHomeNetworkDataClient(conn).ReportUplinkMessageDeliveryState(ctx, &routingpb.UplinkMessageDeliveryStateChangeRequest{
+		StateChange: &packetbroker.UplinkMessageDeliveryStateChange{
+			Id:               msg.Id,
+			ForwarderNetId:   msg.ForwarderNetId,
+			HomeNetworkNetId: msg.HomeNetworkNetId,
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if states := s.UplinkDeliveryStates(); len(states) != 1 || states[0].Id != msg.Id {
+		t.Fatalf("Expected delivery state of %s, got %v", msg.Id, states)
+	}
+
+	var methods []string
+	for _, c := range s.Calls() {
+		methods = append(methods, c.Method)
+	}
+	if expected := strings.Join([]string{
+		"/org.packetbroker.routing.v2.Routes/ListUplinkRoutes",
+		"/org.packetbroker.routing.v1.HomeNetworkData/Subscribe",
+		"/org.packetbroker.routing.v1.ForwarderData/Publish",
+		"/org.packetbroker.routing.v1.HomeNetworkData/ReportUplinkMessageDeliveryState",
+	}, "\n"); strings.Join(methods, "\n") != expected {
+		t.Fatalf("Expected calls:\n%s\ngot:\n%s", expected, strings.Join(methods, "\n"))
+	}
+}
+
+func TestTokenHandler(t *testing.T) {
+	srv := httptest.NewServer(TokenHandler())
+	defer srv.Close()
+
+	res, err := http.PostForm(srv.URL, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {"test"},
+		"client_secret": {"secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", res.StatusCode)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&token); err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken == "" || token.TokenType != "Bearer" || token.ExpiresIn != 3600 {
+		t.Fatalf("Unexpected token %+v", token)
+	}
+
+	res, err = http.PostForm(srv.URL, url.Values{"grant_type": {"password"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", res.StatusCode)
+	}
+}
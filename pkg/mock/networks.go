@@ -0,0 +1,266 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+	"sort"
+
+	iampb "go.packetbroker.org/api/iam"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+var (
+	errNetworkNotFound      = status.Error(codes.NotFound, "network not found")
+	errNetworkAlreadyExists = status.Error(codes.AlreadyExists, "network already exists")
+	errTenantNotFound       = status.Error(codes.NotFound, "tenant not found")
+	errTenantAlreadyExists  = status.Error(codes.AlreadyExists, "tenant already exists")
+)
+
+// AddNetwork adds or replaces the network.
+func (s *Server) AddNetwork(network *packetbroker.Network) {
+	s.mu.Lock()
+	s.networks[network.GetNetId()] = clone(network)
+	s.mu.Unlock()
+}
+
+// AddTenant adds or replaces the tenant. The network of the tenant does not need to exist.
+func (s *Server) AddTenant(tenant *packetbroker.Tenant) {
+	s.mu.Lock()
+	s.tenants[packetbroker.RequestTenantID(tenant)] = clone(tenant)
+	s.mu.Unlock()
+}
+
+// sortedNetworks returns the networks sorted by NetID. The caller must hold the lock.
+func (s *Server) sortedNetworks() []*packetbroker.Network {
+	res := make([]*packetbroker.Network, 0, len(s.networks))
+	for _, n := range s.networks {
+		res = append(res, n)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].NetId < res[j].NetId })
+	return res
+}
+
+// sortedTenants returns the tenants sorted by NetID and tenant ID. The caller must hold the lock.
+func (s *Server) sortedTenants() []*packetbroker.Tenant {
+	ids := make([]packetbroker.TenantID, 0, len(s.tenants))
+	for id := range s.tenants {
+		ids = append(ids, id)
+	}
+	sortTenantIDs(ids)
+	res := make([]*packetbroker.Tenant, len(ids))
+	for i, id := range ids {
+		res[i] = s.tenants[id]
+	}
+	return res
+}
+
+type networkRegistry struct {
+	iampb.UnimplementedNetworkRegistryServer
+	*Server
+}
+
+func (r *networkRegistry) ListNetworks(ctx context.Context, req *iampb.ListNetworksRequest) (*iampb.ListNetworksResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var networks []*packetbroker.Network
+	for _, n := range r.sortedNetworks() {
+		if containsFold(n.Name, req.NameContains) {
+			networks = append(networks, n)
+		}
+	}
+	return &iampb.ListNetworksResponse{
+		Networks: cloneAll(page(networks, req.Offset, req.Limit)),
+		Total:    uint32(len(networks)),
+	}, nil
+}
+
+func (r *networkRegistry) CreateNetwork(ctx context.Context, req *iampb.CreateNetworkRequest) (*iampb.CreateNetworkResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.networks[req.Network.GetNetId()]; ok {
+		return nil, errNetworkAlreadyExists
+	}
+	network := clone(req.Network)
+	r.networks[network.GetNetId()] = network
+	return &iampb.CreateNetworkResponse{
+		Network: clone(network),
+	}, nil
+}
+
+func (r *networkRegistry) GetNetwork(ctx context.Context, req *iampb.NetworkRequest) (*iampb.GetNetworkResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	network, ok := r.networks[req.NetId]
+	if !ok {
+		return nil, errNetworkNotFound
+	}
+	return &iampb.GetNetworkResponse{
+		Network: clone(network),
+	}, nil
+}
+
+func (r *networkRegistry) UpdateNetwork(ctx context.Context, req *iampb.UpdateNetworkRequest) (*emptypb.Empty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	network, ok := r.networks[req.NetId]
+	if !ok {
+		return nil, errNetworkNotFound
+	}
+	network = clone(network)
+	if req.Name != nil {
+		network.Name = req.Name.Value
+	}
+	if req.DevAddrBlocks != nil {
+		network.DevAddrBlocks = cloneAll(req.DevAddrBlocks.Value)
+	}
+	if req.AdministrativeContact != nil {
+		network.AdministrativeContact = clone(req.AdministrativeContact.Value)
+	}
+	if req.TechnicalContact != nil {
+		network.TechnicalContact = clone(req.TechnicalContact.Value)
+	}
+	if req.Target != nil {
+		network.Target = clone(req.Target.Value)
+	}
+	if req.DelegatedNetId != nil {
+		network.DelegatedNetId = clone(req.DelegatedNetId.Value)
+	}
+	r.networks[req.NetId] = network
+	return &emptypb.Empty{}, nil
+}
+
+func (r *networkRegistry) UpdateNetworkListed(ctx context.Context, req *iampb.UpdateNetworkListedRequest) (*emptypb.Empty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	network, ok := r.networks[req.NetId]
+	if !ok {
+		return nil, errNetworkNotFound
+	}
+	network = clone(network)
+	network.Listed = req.Listed
+	r.networks[req.NetId] = network
+	return &emptypb.Empty{}, nil
+}
+
+func (r *networkRegistry) DeleteNetwork(ctx context.Context, req *iampb.NetworkRequest) (*emptypb.Empty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.networks[req.NetId]; !ok {
+		return nil, errNetworkNotFound
+	}
+	delete(r.networks, req.NetId)
+	for id := range r.tenants {
+		if uint32(id.NetID) == req.NetId {
+			delete(r.tenants, id)
+		}
+	}
+	return &emptypb.Empty{}, nil
+}
+
+type tenantRegistry struct {
+	iampb.UnimplementedTenantRegistryServer
+	*Server
+}
+
+func (r *tenantRegistry) ListTenants(ctx context.Context, req *iampb.ListTenantsRequest) (*iampb.ListTenantsResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tenants []*packetbroker.Tenant
+	for _, t := range r.sortedTenants() {
+		if t.NetId == req.NetId &&
+			containsFold(t.TenantId, req.TenantIdContains) &&
+			containsFold(t.Name, req.NameContains) {
+			tenants = append(tenants, t)
+		}
+	}
+	return &iampb.ListTenantsResponse{
+		Tenants: cloneAll(page(tenants, req.Offset, req.Limit)),
+		Total:   uint32(len(tenants)),
+	}, nil
+}
+
+func (r *tenantRegistry) CreateTenant(ctx context.Context, req *iampb.CreateTenantRequest) (*iampb.CreateTenantResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := packetbroker.RequestTenantID(req.Tenant)
+	if _, ok := r.networks[uint32(id.NetID)]; !ok {
+		return nil, errNetworkNotFound
+	}
+	if _, ok := r.tenants[id]; ok {
+		return nil, errTenantAlreadyExists
+	}
+	tenant := clone(req.Tenant)
+	r.tenants[id] = tenant
+	return &iampb.CreateTenantResponse{
+		Tenant: clone(tenant),
+	}, nil
+}
+
+func (r *tenantRegistry) GetTenant(ctx context.Context, req *iampb.TenantRequest) (*iampb.GetTenantResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	tenant, ok := r.tenants[packetbroker.RequestTenantID(req)]
+	if !ok {
+		return nil, errTenantNotFound
+	}
+	return &iampb.GetTenantResponse{
+		Tenant: clone(tenant),
+	}, nil
+}
+
+func (r *tenantRegistry) UpdateTenant(ctx context.Context, req *iampb.UpdateTenantRequest) (*emptypb.Empty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := packetbroker.RequestTenantID(req)
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, errTenantNotFound
+	}
+	tenant = clone(tenant)
+	if req.Name != nil {
+		tenant.Name = req.Name.Value
+	}
+	if req.DevAddrBlocks != nil {
+		tenant.DevAddrBlocks = cloneAll(req.DevAddrBlocks.Value)
+	}
+	if req.AdministrativeContact != nil {
+		tenant.AdministrativeContact = clone(req.AdministrativeContact.Value)
+	}
+	if req.TechnicalContact != nil {
+		tenant.TechnicalContact = clone(req.TechnicalContact.Value)
+	}
+	if req.Target != nil {
+		tenant.Target = clone(req.Target.Value)
+	}
+	r.tenants[id] = tenant
+	return &emptypb.Empty{}, nil
+}
+
+func (r *tenantRegistry) UpdateTenantListed(ctx context.Context, req *iampb.UpdateTenantListedRequest) (*emptypb.Empty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := packetbroker.RequestTenantID(req)
+	tenant, ok := r.tenants[id]
+	if !ok {
+		return nil, errTenantNotFound
+	}
+	tenant = clone(tenant)
+	tenant.Listed = req.Listed
+	r.tenants[id] = tenant
+	return &emptypb.Empty{}, nil
+}
+
+func (r *tenantRegistry) DeleteTenant(ctx context.Context, req *iampb.TenantRequest) (*emptypb.Empty, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := packetbroker.RequestTenantID(req)
+	if _, ok := r.tenants[id]; !ok {
+		return nil, errTenantNotFound
+	}
+	delete(r.tenants, id)
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,230 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+	"sort"
+
+	routingpb "go.packetbroker.org/api/routing"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var errPolicyNotFound = status.Error(codes.NotFound, "policy not found")
+
+// hasHomeNetworkPolicy returns whether there is a Home Network policy between the networks or tenants, in either
+// direction. The caller must hold the lock.
+func (s *Server) hasHomeNetworkPolicy(a, b packetbroker.TenantID) bool {
+	_, ab := s.homeNetworkPolicies[tenantPair{forwarder: a, homeNetwork: b}]
+	_, ba := s.homeNetworkPolicies[tenantPair{forwarder: b, homeNetwork: a}]
+	return ab || ba
+}
+
+// DefaultPolicy returns the default policy of the Forwarder, if any.
+func (s *Server) DefaultPolicy(forwarder packetbroker.TenantID) (*packetbroker.RoutingPolicy, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policy, ok := s.defaultPolicies[forwarder]
+	return clone(policy), ok
+}
+
+// HomeNetworkPolicy returns the policy of the Forwarder with the Home Network, if any.
+func (s *Server) HomeNetworkPolicy(forwarder, homeNetwork packetbroker.TenantID) (*packetbroker.RoutingPolicy, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	policy, ok := s.homeNetworkPolicies[tenantPair{forwarder: forwarder, homeNetwork: homeNetwork}]
+	return clone(policy), ok
+}
+
+// deletedPolicy returns the policy without uplink and downlink that is listed as updated when the policy is deleted.
+func (s *Server) deletedPolicy(p *packetbroker.RoutingPolicy) *packetbroker.RoutingPolicy {
+	return &packetbroker.RoutingPolicy{
+		ForwarderNetId:      p.ForwarderNetId,
+		ForwarderTenantId:   p.ForwarderTenantId,
+		HomeNetworkNetId:    p.HomeNetworkNetId,
+		HomeNetworkTenantId: p.HomeNetworkTenantId,
+		UpdatedAt:           timestamppb.New(s.updatedAt()),
+	}
+}
+
+// updatedPolicies returns the policies updated after updatedSince, sorted by their last updated timestamp.
+// If updatedSince is nil, all policies are returned.
+func updatedPolicies(policies []*packetbroker.RoutingPolicy, updatedSince *timestamppb.Timestamp) []*packetbroker.RoutingPolicy {
+	var res []*packetbroker.RoutingPolicy
+	for _, p := range policies {
+		if updatedSince == nil || p.GetUpdatedAt().AsTime().After(updatedSince.AsTime()) {
+			res = append(res, p)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].GetUpdatedAt().AsTime().Before(res[j].GetUpdatedAt().AsTime()) })
+	return res
+}
+
+type policyManager struct {
+	routingpb.UnimplementedPolicyManagerServer
+	*Server
+}
+
+func (m *policyManager) ListDefaultPolicies(ctx context.Context, req *routingpb.ListDefaultPoliciesRequest) (*routingpb.ListDefaultPoliciesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	policies := make([]*packetbroker.RoutingPolicy, 0, len(m.defaultPolicies))
+	for _, p := range m.defaultPolicies {
+		policies = append(policies, p)
+	}
+	// Deleted policies are listed as updated, so that clients that poll for updates can detect deletions.
+	if req.UpdatedSince != nil {
+		for _, p := range m.deletedDefaultPolicies {
+			policies = append(policies, p)
+		}
+	}
+	policies = updatedPolicies(policies, req.UpdatedSince)
+	return &routingpb.ListDefaultPoliciesResponse{
+		Policies: cloneAll(page(policies, 0, 0)),
+		Total:    uint32(len(policies)),
+	}, nil
+}
+
+func (m *policyManager) GetDefaultPolicy(ctx context.Context, req *routingpb.GetDefaultPolicyRequest) (*routingpb.GetPolicyResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	policy, ok := m.defaultPolicies[packetbroker.ForwarderTenantID(req)]
+	if !ok {
+		return nil, errPolicyNotFound
+	}
+	return &routingpb.GetPolicyResponse{
+		Policy: clone(policy),
+	}, nil
+}
+
+func (m *policyManager) SetDefaultPolicy(ctx context.Context, req *routingpb.SetPolicyRequest) (*emptypb.Empty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	forwarder := packetbroker.ForwarderTenantID(req.Policy)
+	// A policy without uplink and downlink deletes the policy.
+	if req.Policy.GetUplink() == nil && req.Policy.GetDownlink() == nil {
+		if p, ok := m.defaultPolicies[forwarder]; ok {
+			m.deletedDefaultPolicies[forwarder] = m.deletedPolicy(p)
+			delete(m.defaultPolicies, forwarder)
+		}
+		return &emptypb.Empty{}, nil
+	}
+	policy := clone(req.Policy)
+	policy.HomeNetworkNetId, policy.HomeNetworkTenantId = 0, ""
+	policy.UpdatedAt = timestamppb.New(m.updatedAt())
+	m.defaultPolicies[forwarder] = policy
+	delete(m.deletedDefaultPolicies, forwarder)
+	return &emptypb.Empty{}, nil
+}
+
+func (m *policyManager) ListHomeNetworkPolicies(ctx context.Context, req *routingpb.ListHomeNetworkPoliciesRequest) (*routingpb.ListHomeNetworkPoliciesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// An empty Forwarder lists the Home Network policies of all Forwarders.
+	forwarder := packetbroker.ForwarderTenantID(req)
+	var policies []*packetbroker.RoutingPolicy
+	for pair, p := range m.homeNetworkPolicies {
+		if forwarder.IsEmpty() || pair.forwarder == forwarder {
+			policies = append(policies, p)
+		}
+	}
+	if req.UpdatedSince != nil {
+		for pair, p := range m.deletedHomeNetworkPolicies {
+			if forwarder.IsEmpty() || pair.forwarder == forwarder {
+				policies = append(policies, p)
+			}
+		}
+	}
+	policies = updatedPolicies(policies, req.UpdatedSince)
+	return &routingpb.ListHomeNetworkPoliciesResponse{
+		Policies: cloneAll(page(policies, 0, 0)),
+		Total:    uint32(len(policies)),
+	}, nil
+}
+
+func (m *policyManager) GetHomeNetworkPolicy(ctx context.Context, req *routingpb.GetHomeNetworkPolicyRequest) (*routingpb.GetPolicyResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	policy, ok := m.homeNetworkPolicies[tenantPair{
+		forwarder:   packetbroker.ForwarderTenantID(req),
+		homeNetwork: packetbroker.HomeNetworkTenantID(req),
+	}]
+	if !ok {
+		return nil, errPolicyNotFound
+	}
+	return &routingpb.GetPolicyResponse{
+		Policy: clone(policy),
+	}, nil
+}
+
+func (m *policyManager) SetHomeNetworkPolicy(ctx context.Context, req *routingpb.SetPolicyRequest) (*emptypb.Empty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pair := tenantPair{
+		forwarder:   packetbroker.ForwarderTenantID(req.Policy),
+		homeNetwork: packetbroker.HomeNetworkTenantID(req.Policy),
+	}
+	// A policy without uplink and downlink deletes the policy.
+	if req.Policy.GetUplink() == nil && req.Policy.GetDownlink() == nil {
+		if p, ok := m.homeNetworkPolicies[pair]; ok {
+			m.deletedHomeNetworkPolicies[pair] = m.deletedPolicy(p)
+			delete(m.homeNetworkPolicies, pair)
+		}
+		return &emptypb.Empty{}, nil
+	}
+	policy := clone(req.Policy)
+	policy.UpdatedAt = timestamppb.New(m.updatedAt())
+	m.homeNetworkPolicies[pair] = policy
+	delete(m.deletedHomeNetworkPolicies, pair)
+	return &emptypb.Empty{}, nil
+}
+
+func (m *policyManager) ListEffectivePolicies(ctx context.Context, req *routingpb.ListEffectivePoliciesRequest) (*routingpb.ListEffectivePoliciesResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	homeNetwork := packetbroker.HomeNetworkTenantID(req)
+	// The effective policy of a Forwarder is the policy with the Home Network, or the default policy otherwise.
+	effective := make(map[packetbroker.TenantID]*packetbroker.RoutingPolicy)
+	for forwarder, p := range m.defaultPolicies {
+		p = clone(p)
+		p.HomeNetworkNetId, p.HomeNetworkTenantId = req.HomeNetworkNetId, req.HomeNetworkTenantId
+		effective[forwarder] = p
+	}
+	for pair, p := range m.homeNetworkPolicies {
+		if pair.homeNetwork == homeNetwork {
+			effective[pair.forwarder] = clone(p)
+		}
+	}
+	forwarders := make([]packetbroker.TenantID, 0, len(effective))
+	for forwarder := range effective {
+		forwarders = append(forwarders, forwarder)
+	}
+	sortTenantIDs(forwarders)
+	policies := make([]*packetbroker.RoutingPolicy, len(forwarders))
+	for i, forwarder := range forwarders {
+		policies[i] = effective[forwarder]
+	}
+	return &routingpb.ListEffectivePoliciesResponse{
+		Policies: page(policies, req.Offset, 0),
+		Total:    uint32(len(policies)),
+	}, nil
+}
+
+func (m *policyManager) ListNetworksWithPolicy(ctx context.Context, req *routingpb.ListNetworksWithPolicyRequest) (*routingpb.ListNetworksWithPolicyResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := packetbroker.RequestTenantID(req)
+	networks := m.catalogNetworks(catalogFilter{
+		nameContains:     req.NameContains,
+		tenantIDContains: req.TenantIdContains,
+		policyWith:       &id,
+	})
+	return &routingpb.ListNetworksWithPolicyResponse{
+		Networks: page(networks, req.Offset, 0),
+		Total:    uint32(len(networks)),
+	}, nil
+}
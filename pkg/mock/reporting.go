@@ -0,0 +1,67 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+	"time"
+
+	reportingpb "go.packetbroker.org/api/reporting"
+	packetbroker "go.packetbroker.org/api/v3"
+)
+
+// AddRoutedMessagesRecord adds the record of routed messages that is reported by the Reporter.
+func (s *Server) AddRoutedMessagesRecord(record *reportingpb.RoutedMessagesRecord) {
+	s.mu.Lock()
+	s.routedMessages = append(s.routedMessages, clone(record))
+	s.mu.Unlock()
+}
+
+// reportTimeRange returns the time range of the request. The zero time means that the range is unbounded.
+func reportTimeRange(req *reportingpb.GetRoutedMessagesRequest, now time.Time) (from, to time.Time) {
+	now = now.UTC()
+	switch {
+	case req.GetToday() != nil:
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), time.Time{}
+	case req.GetLast_30Days() != nil:
+		return now.AddDate(0, 0, -30), time.Time{}
+	case req.GetPeriod() != nil:
+		period := req.GetPeriod()
+		from = time.Date(int(period.GetFrom().GetYear()), time.Month(period.GetFrom().GetMonth()), 1, 0, 0, 0, 0, time.UTC)
+		to = time.Date(int(period.GetTo().GetYear()), time.Month(period.GetTo().GetMonth())+1, 1, 0, 0, 0, 0, time.UTC)
+		return from, to
+	default:
+		return time.Time{}, time.Time{}
+	}
+}
+
+type reporter struct {
+	reportingpb.UnimplementedReporterServer
+	*Server
+}
+
+func (r *reporter) GetRoutedMessages(ctx context.Context, req *reportingpb.GetRoutedMessagesRequest) (*reportingpb.GetRoutedMessagesResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	from, to := reportTimeRange(req, time.Now())
+	res := &reportingpb.GetRoutedMessagesResponse{}
+	for _, rec := range r.routedMessages {
+		var (
+			forwarder   = packetbroker.ForwarderTenantID(rec)
+			homeNetwork = packetbroker.HomeNetworkTenantID(rec)
+		)
+		if (req.ForwarderNetId != nil && uint32(forwarder.NetID) != req.ForwarderNetId.Value) ||
+			(req.ForwarderTenantId != nil && forwarder.ID != req.ForwarderTenantId.Value) ||
+			(req.HomeNetworkNetId != nil && uint32(homeNetwork.NetID) != req.HomeNetworkNetId.Value) ||
+			(req.HomeNetworkTenantId != nil && homeNetwork.ID != req.HomeNetworkTenantId.Value) {
+			continue
+		}
+		// Report the records that overlap with the time range.
+		if (!from.IsZero() && rec.GetTo() != nil && !rec.GetTo().AsTime().After(from)) ||
+			(!to.IsZero() && rec.GetFrom() != nil && !rec.GetFrom().AsTime().Before(to)) {
+			continue
+		}
+		res.Records = append(res.Records, clone(rec))
+	}
+	return res, nil
+}
@@ -0,0 +1,108 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+
+	routingpb "go.packetbroker.org/api/routing/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+)
+
+// uplinkRoutes returns the routes of the DevAddr blocks of the networks, followed by the routes of the tenants.
+// The caller must hold the lock.
+func (s *Server) uplinkRoutes() []*packetbroker.DevAddrPrefixRoute {
+	var res []*packetbroker.DevAddrPrefixRoute
+	add := func(netID uint32, tenantID string, blocks []*packetbroker.DevAddrBlock, target *packetbroker.Target) {
+		for _, b := range blocks {
+			res = append(res, &packetbroker.DevAddrPrefixRoute{
+				Prefix:               b.GetPrefix(),
+				NetId:                netID,
+				TenantId:             tenantID,
+				HomeNetworkClusterId: b.GetHomeNetworkClusterId(),
+				Target:               target,
+			})
+		}
+	}
+	for _, n := range s.sortedNetworks() {
+		add(n.NetId, "", n.DevAddrBlocks, n.Target)
+	}
+	for _, t := range s.sortedTenants() {
+		add(t.NetId, t.TenantId, t.DevAddrBlocks, t.Target)
+	}
+	return res
+}
+
+// joinRequestRoutes returns the routes of the JoinEUI prefixes of the Join Servers, sorted by Join Server ID.
+// The caller must hold the lock.
+func (s *Server) joinRequestRoutes() []*packetbroker.JoinEUIPrefixRoute {
+	var res []*packetbroker.JoinEUIPrefixRoute
+	for _, js := range s.listJoinServers("", false) {
+		for _, p := range js.JoinEuiPrefixes {
+			route := &packetbroker.JoinEUIPrefixRoute{
+				Prefix: p,
+				Id:     js.Id,
+			}
+			switch resolver := js.Resolver.(type) {
+			case *packetbroker.JoinServer_Fixed:
+				route.Resolver = &packetbroker.JoinEUIPrefixRoute_Fixed{Fixed: resolver.Fixed}
+			case *packetbroker.JoinServer_Lookup:
+				route.Resolver = &packetbroker.JoinEUIPrefixRoute_Lookup{Lookup: resolver.Lookup}
+			}
+			res = append(res, route)
+		}
+	}
+	return res
+}
+
+type routes struct {
+	routingpb.UnimplementedRoutesServer
+	*Server
+}
+
+func (r *routes) ListUplinkRoutes(ctx context.Context, req *routingpb.ListUplinkRoutesRequest) (*routingpb.ListUplinkRoutesResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	routes := r.uplinkRoutes()
+	return &routingpb.ListUplinkRoutesResponse{
+		Routes: cloneAll(page(routes, req.Offset, req.Limit)),
+		Total:  uint32(len(routes)),
+	}, nil
+}
+
+func (r *routes) ListJoinRequestRoutes(ctx context.Context, req *routingpb.ListJoinRequestRoutesRequest) (*routingpb.ListJoinRequestRoutesResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	routes := r.joinRequestRoutes()
+	return &routingpb.ListJoinRequestRoutesResponse{
+		Routes: cloneAll(page(routes, req.Offset, req.Limit)),
+		Total:  uint32(len(routes)),
+	}, nil
+}
+
+func (r *routes) ListNetworkTargets(ctx context.Context, req *routingpb.ListNetworkTargetsRequest) (*routingpb.ListNetworkTargetsResponse, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var targets []*packetbroker.NetworkTarget
+	for _, n := range r.sortedNetworks() {
+		if n.Target != nil {
+			targets = append(targets, &packetbroker.NetworkTarget{
+				NetId:  n.NetId,
+				Target: n.Target,
+			})
+		}
+	}
+	for _, t := range r.sortedTenants() {
+		if t.Target != nil {
+			targets = append(targets, &packetbroker.NetworkTarget{
+				NetId:    t.NetId,
+				TenantId: t.TenantId,
+				Target:   t.Target,
+			})
+		}
+	}
+	return &routingpb.ListNetworkTargetsResponse{
+		Targets: cloneAll(page(targets, req.Offset, req.Limit)),
+		Total:   uint32(len(targets)),
+	}, nil
+}
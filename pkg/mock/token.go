@@ -0,0 +1,44 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// tokenLifetime is the lifetime of the access tokens issued by TokenHandler.
+const tokenLifetime = time.Hour
+
+// TokenHandler returns an HTTP handler that issues OAuth 2.0 access tokens with the client credentials grant.
+// Any client ID and secret are accepted. Use the URL of the handler as token URL of the clients.
+func TokenHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON := func(status int, v interface{}) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(v)
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeJSON(http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+		if grantType := r.PostForm.Get("grant_type"); grantType != "client_credentials" {
+			writeJSON(http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+			return
+		}
+		writeJSON(http.StatusOK, map[string]interface{}{
+			"access_token": randomString(30),
+			"token_type":   "Bearer",
+			"expires_in":   int(tokenLifetime.Seconds()),
+			"scope":        r.PostForm.Get("scope"),
+		})
+	})
+}
@@ -0,0 +1,90 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package mock
+
+import (
+	"context"
+
+	mappingpb "go.packetbroker.org/api/mapping/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var errVisibilityNotFound = status.Error(codes.NotFound, "gateway visibility not found")
+
+// DefaultVisibility returns the default gateway visibility of the Forwarder, if any.
+func (s *Server) DefaultVisibility(forwarder packetbroker.TenantID) (*packetbroker.GatewayVisibility, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	visibility, ok := s.defaultVisibilities[forwarder]
+	return clone(visibility), ok
+}
+
+// HomeNetworkVisibility returns the gateway visibility of the Forwarder with the Home Network, if any.
+func (s *Server) HomeNetworkVisibility(forwarder, homeNetwork packetbroker.TenantID) (*packetbroker.GatewayVisibility, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	visibility, ok := s.homeNetworkVisibilities[tenantPair{forwarder: forwarder, homeNetwork: homeNetwork}]
+	return clone(visibility), ok
+}
+
+type gatewayVisibilityManager struct {
+	mappingpb.UnimplementedGatewayVisibilityManagerServer
+	*Server
+}
+
+func (m *gatewayVisibilityManager) GetDefaultVisibility(ctx context.Context, req *mappingpb.GetDefaultGatewayVisibilityRequest) (*mappingpb.GetGatewayVisibilityResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	visibility, ok := m.defaultVisibilities[packetbroker.ForwarderTenantID(req)]
+	if !ok {
+		return nil, errVisibilityNotFound
+	}
+	return &mappingpb.GetGatewayVisibilityResponse{
+		Visibility: clone(visibility),
+	}, nil
+}
+
+func (m *gatewayVisibilityManager) SetDefaultVisibility(ctx context.Context, req *mappingpb.SetGatewayVisibilityRequest) (*emptypb.Empty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	forwarder := packetbroker.ForwarderTenantID(req.Visibility)
+	// Gateway visibilities cannot be deleted, so an empty visibility is stored as well.
+	visibility := clone(req.Visibility)
+	visibility.HomeNetworkNetId, visibility.HomeNetworkTenantId = 0, ""
+	visibility.UpdatedAt = timestamppb.New(m.updatedAt())
+	m.defaultVisibilities[forwarder] = visibility
+	return &emptypb.Empty{}, nil
+}
+
+func (m *gatewayVisibilityManager) GetHomeNetworkVisibility(ctx context.Context, req *mappingpb.GetHomeNetworkGatewayVisibilityRequest) (*mappingpb.GetGatewayVisibilityResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	visibility, ok := m.homeNetworkVisibilities[tenantPair{
+		forwarder:   packetbroker.ForwarderTenantID(req),
+		homeNetwork: packetbroker.HomeNetworkTenantID(req),
+	}]
+	if !ok {
+		return nil, errVisibilityNotFound
+	}
+	return &mappingpb.GetGatewayVisibilityResponse{
+		Visibility: clone(visibility),
+	}, nil
+}
+
+func (m *gatewayVisibilityManager) SetHomeNetworkVisibility(ctx context.Context, req *mappingpb.SetGatewayVisibilityRequest) (*emptypb.Empty, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pair := tenantPair{
+		forwarder:   packetbroker.ForwarderTenantID(req.Visibility),
+		homeNetwork: packetbroker.HomeNetworkTenantID(req.Visibility),
+	}
+	// Gateway visibilities cannot be deleted, so an empty visibility is stored as well.
+	visibility := clone(req.Visibility)
+	visibility.UpdatedAt = timestamppb.New(m.updatedAt())
+	m.homeNetworkVisibilities[pair] = visibility
+	return &emptypb.Empty{}, nil
+}
@@ -0,0 +1,133 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package sdk
+
+import (
+	"context"
+
+	routingpb "go.packetbroker.org/api/routing"
+	routingpbv2 "go.packetbroker.org/api/routing/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ControlPlane is a client of the Packet Broker Control Plane services: routing policies and routes.
+type ControlPlane struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewControlPlane returns a new ControlPlane client.
+func NewControlPlane(conn grpc.ClientConnInterface) *ControlPlane {
+	return &ControlPlane{conn: conn}
+}
+
+// ListDefaultPolicies lists the default routing policies of all Forwarders that are updated after updatedSince. If
+// updatedSince is nil, all default policies are listed.
+func (c *ControlPlane) ListDefaultPolicies(ctx context.Context, updatedSince *timestamppb.Timestamp) *Iterator[*packetbroker.RoutingPolicy] {
+	client := routingpb.NewPolicyManagerClient(c.conn)
+	return listByUpdatedSince(updatedSince, func(updatedSince *timestamppb.Timestamp) ([]*packetbroker.RoutingPolicy, error) {
+		res, err := client.ListDefaultPolicies(ctx, &routingpb.ListDefaultPoliciesRequest{
+			UpdatedSince: updatedSince,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return res.Policies, nil
+	})
+}
+
+// ListHomeNetworkPolicies lists the routing policies between Forwarders and Home Networks that are updated after
+// updatedSince. If updatedSince is nil, all policies are listed. If the Forwarder is not empty, only the policies of
+// the Forwarder are listed.
+func (c *ControlPlane) ListHomeNetworkPolicies(ctx context.Context, forwarder packetbroker.TenantID, updatedSince *timestamppb.Timestamp) *Iterator[*packetbroker.RoutingPolicy] {
+	client := routingpb.NewPolicyManagerClient(c.conn)
+	return listByUpdatedSince(updatedSince, func(updatedSince *timestamppb.Timestamp) ([]*packetbroker.RoutingPolicy, error) {
+		req := &routingpb.ListHomeNetworkPoliciesRequest{
+			UpdatedSince: updatedSince,
+		}
+		if !forwarder.IsEmpty() {
+			req.ForwarderNetId = uint32(forwarder.NetID)
+			req.ForwarderTenantId = forwarder.ID
+		}
+		res, err := client.ListHomeNetworkPolicies(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return res.Policies, nil
+	})
+}
+
+// ListEffectivePolicies lists the routing policies that are effective for the Home Network: the policy of each
+// Forwarder with the Home Network, or the default policy of the Forwarder.
+func (c *ControlPlane) ListEffectivePolicies(ctx context.Context, homeNetwork packetbroker.TenantID) *Iterator[*packetbroker.RoutingPolicy] {
+	client := routingpb.NewPolicyManagerClient(c.conn)
+	return listByOffset(0, func(offset uint32) ([]*packetbroker.RoutingPolicy, uint32, error) {
+		res, err := client.ListEffectivePolicies(ctx, &routingpb.ListEffectivePoliciesRequest{
+			HomeNetworkNetId:    uint32(homeNetwork.NetID),
+			HomeNetworkTenantId: homeNetwork.ID,
+			Offset:              offset,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Policies, res.Total, nil
+	})
+}
+
+// ListNetworksWithPolicy lists the networks and tenants that have a routing policy with the network or tenant of the
+// request. The request filters the networks and tenants; its offset is the offset of the first page.
+func (c *ControlPlane) ListNetworksWithPolicy(ctx context.Context, req *routingpb.ListNetworksWithPolicyRequest) *Iterator[*packetbroker.NetworkOrTenant] {
+	req = cloneRequest(req, func() *routingpb.ListNetworksWithPolicyRequest { return new(routingpb.ListNetworksWithPolicyRequest) })
+	client := routingpb.NewPolicyManagerClient(c.conn)
+	return listByOffset(req.Offset, func(offset uint32) ([]*packetbroker.NetworkOrTenant, uint32, error) {
+		req.Offset = offset
+		res, err := client.ListNetworksWithPolicy(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Networks, res.Total, nil
+	})
+}
+
+// ListUplinkRoutes lists the uplink routes by DevAddr prefix.
+func (c *ControlPlane) ListUplinkRoutes(ctx context.Context) *Iterator[*packetbroker.DevAddrPrefixRoute] {
+	client := routingpbv2.NewRoutesClient(c.conn)
+	return listByOffset(0, func(offset uint32) ([]*packetbroker.DevAddrPrefixRoute, uint32, error) {
+		res, err := client.ListUplinkRoutes(ctx, &routingpbv2.ListUplinkRoutesRequest{
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Routes, res.Total, nil
+	})
+}
+
+// ListJoinRequestRoutes lists the join-request routes by JoinEUI prefix.
+func (c *ControlPlane) ListJoinRequestRoutes(ctx context.Context) *Iterator[*packetbroker.JoinEUIPrefixRoute] {
+	client := routingpbv2.NewRoutesClient(c.conn)
+	return listByOffset(0, func(offset uint32) ([]*packetbroker.JoinEUIPrefixRoute, uint32, error) {
+		res, err := client.ListJoinRequestRoutes(ctx, &routingpbv2.ListJoinRequestRoutesRequest{
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Routes, res.Total, nil
+	})
+}
+
+// ListNetworkTargets lists the targets of the networks and tenants.
+func (c *ControlPlane) ListNetworkTargets(ctx context.Context) *Iterator[*packetbroker.NetworkTarget] {
+	client := routingpbv2.NewRoutesClient(c.conn)
+	return listByOffset(0, func(offset uint32) ([]*packetbroker.NetworkTarget, uint32, error) {
+		res, err := client.ListNetworkTargets(ctx, &routingpbv2.ListNetworkTargetsRequest{
+			Offset: offset,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Targets, res.Total, nil
+	})
+}
@@ -0,0 +1,113 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package sdk
+
+import (
+	"context"
+
+	iampb "go.packetbroker.org/api/iam"
+	iampbv2 "go.packetbroker.org/api/iam/v2"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc"
+)
+
+// IAM is a client of the Packet Broker Identity and Access Management services: the registries of networks, tenants
+// and Join Servers, and the catalog.
+type IAM struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewIAM returns a new IAM client.
+func NewIAM(conn grpc.ClientConnInterface) *IAM {
+	return &IAM{conn: conn}
+}
+
+// ListAllNetworks lists the networks in the registry. The request filters the networks; its offset is the offset of
+// the first page. The request may be nil.
+func (c *IAM) ListAllNetworks(ctx context.Context, req *iampb.ListNetworksRequest) *Iterator[*packetbroker.Network] {
+	req = cloneRequest(req, func() *iampb.ListNetworksRequest { return new(iampb.ListNetworksRequest) })
+	client := iampb.NewNetworkRegistryClient(c.conn)
+	return listByOffset(req.Offset, func(offset uint32) ([]*packetbroker.Network, uint32, error) {
+		req.Offset = offset
+		res, err := client.ListNetworks(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Networks, res.Total, nil
+	})
+}
+
+// ListAllTenants lists the tenants in the registry. The request filters the tenants; its offset is the offset of the
+// first page. The request may be nil.
+func (c *IAM) ListAllTenants(ctx context.Context, req *iampb.ListTenantsRequest) *Iterator[*packetbroker.Tenant] {
+	req = cloneRequest(req, func() *iampb.ListTenantsRequest { return new(iampb.ListTenantsRequest) })
+	client := iampb.NewTenantRegistryClient(c.conn)
+	return listByOffset(req.Offset, func(offset uint32) ([]*packetbroker.Tenant, uint32, error) {
+		req.Offset = offset
+		res, err := client.ListTenants(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Tenants, res.Total, nil
+	})
+}
+
+// ListAllJoinServers lists the Join Servers in the registry. The request filters the Join Servers; its offset is the
+// offset of the first page. The request may be nil.
+func (c *IAM) ListAllJoinServers(ctx context.Context, req *iampbv2.ListJoinServersRequest) *Iterator[*packetbroker.JoinServer] {
+	req = cloneRequest(req, func() *iampbv2.ListJoinServersRequest { return new(iampbv2.ListJoinServersRequest) })
+	client := iampbv2.NewJoinServerRegistryClient(c.conn)
+	return listByOffset(req.Offset, func(offset uint32) ([]*packetbroker.JoinServer, uint32, error) {
+		req.Offset = offset
+		res, err := client.ListJoinServers(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.JoinServers, res.Total, nil
+	})
+}
+
+// ListCatalogNetworks lists the networks and tenants in the catalog, i.e. the Forwarders and Home Networks. The
+// request filters the networks and tenants; its offset is the offset of the first page. The request may be nil.
+func (c *IAM) ListCatalogNetworks(ctx context.Context, req *iampbv2.ListNetworksRequest) *Iterator[*packetbroker.NetworkOrTenant] {
+	req = cloneRequest(req, func() *iampbv2.ListNetworksRequest { return new(iampbv2.ListNetworksRequest) })
+	client := iampbv2.NewCatalogClient(c.conn)
+	return listByOffset(req.Offset, func(offset uint32) ([]*packetbroker.NetworkOrTenant, uint32, error) {
+		req.Offset = offset
+		res, err := client.ListNetworks(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Networks, res.Total, nil
+	})
+}
+
+// ListCatalogHomeNetworks lists the Home Networks in the catalog. The request filters the networks and tenants; its
+// offset is the offset of the first page. The request may be nil.
+func (c *IAM) ListCatalogHomeNetworks(ctx context.Context, req *iampbv2.ListNetworksRequest) *Iterator[*packetbroker.NetworkOrTenant] {
+	req = cloneRequest(req, func() *iampbv2.ListNetworksRequest { return new(iampbv2.ListNetworksRequest) })
+	client := iampbv2.NewCatalogClient(c.conn)
+	return listByOffset(req.Offset, func(offset uint32) ([]*packetbroker.NetworkOrTenant, uint32, error) {
+		req.Offset = offset
+		res, err := client.ListHomeNetworks(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.Networks, res.Total, nil
+	})
+}
+
+// ListCatalogJoinServers lists the Join Servers in the catalog. The request filters the Join Servers; its offset is
+// the offset of the first page. The request may be nil.
+func (c *IAM) ListCatalogJoinServers(ctx context.Context, req *iampbv2.ListJoinServersRequest) *Iterator[*packetbroker.JoinServer] {
+	req = cloneRequest(req, func() *iampbv2.ListJoinServersRequest { return new(iampbv2.ListJoinServersRequest) })
+	client := iampbv2.NewCatalogClient(c.conn)
+	return listByOffset(req.Offset, func(offset uint32) ([]*packetbroker.JoinServer, uint32, error) {
+		req.Offset = offset
+		res, err := client.ListJoinServers(ctx, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		return res.JoinServers, res.Total, nil
+	})
+}
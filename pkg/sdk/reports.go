@@ -0,0 +1,60 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package sdk
+
+import (
+	"context"
+
+	reportingpb "go.packetbroker.org/api/reporting"
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/grpc"
+)
+
+// Reports is a client of the Packet Broker Reporter service.
+type Reports struct {
+	conn grpc.ClientConnInterface
+}
+
+// NewReports returns a new Reports client.
+func NewReports(conn grpc.ClientConnInterface) *Reports {
+	return &Reports{conn: conn}
+}
+
+type routedMessagesKey struct {
+	forwarder, homeNetwork packetbroker.TenantID
+	from, to               int64
+}
+
+// GetRoutedMessages returns the routed messages records of the requests. The requests are made in order, when needed.
+// Records that are returned by multiple requests are returned once. This is useful to get the routed messages of a
+// network or tenant both as Forwarder and as Home Network.
+func (c *Reports) GetRoutedMessages(ctx context.Context, reqs ...*reportingpb.GetRoutedMessagesRequest) *Iterator[*reportingpb.RoutedMessagesRecord] {
+	client := reportingpb.NewReporterClient(c.conn)
+	seen := make(map[routedMessagesKey]struct{})
+	return newIterator(func() ([]*reportingpb.RoutedMessagesRecord, bool, error) {
+		if len(reqs) == 0 {
+			return nil, false, nil
+		}
+		req := reqs[0]
+		reqs = reqs[1:]
+		res, err := client.GetRoutedMessages(ctx, req)
+		if err != nil {
+			return nil, false, err
+		}
+		var records []*reportingpb.RoutedMessagesRecord
+		for _, r := range res.Records {
+			key := routedMessagesKey{
+				forwarder:   packetbroker.ForwarderTenantID(r),
+				homeNetwork: packetbroker.HomeNetworkTenantID(r),
+				from:        r.GetFrom().AsTime().UnixNano(),
+				to:          r.GetTo().AsTime().UnixNano(),
+			}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			records = append(records, r)
+		}
+		return records, len(reqs) > 0, nil
+	})
+}
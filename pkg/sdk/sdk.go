@@ -0,0 +1,111 @@
+// Copyright © 2024 The Things Industries B.V.
+
+// Package sdk provides typed clients of the Packet Broker services.
+//
+// The clients hide pagination: list methods return an Iterator that requests the next page when the current page is
+// consumed. Use package client to dial the services:
+//
+//	conn, err := client.DialContext(ctx, logger, config, 443)
+//	if err != nil {
+//		return err
+//	}
+//	defer conn.Close()
+//	it := sdk.NewIAM(conn).ListCatalogNetworks(ctx, &iampbv2.ListNetworksRequest{NetId: 0x13})
+//	for it.Next() {
+//		fmt.Println(it.Value())
+//	}
+//	if err := it.Err(); err != nil {
+//		return err
+//	}
+package sdk
+
+import (
+	packetbroker "go.packetbroker.org/api/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Iterator iterates over the results of a list request. Pages are requested when needed.
+type Iterator[T any] struct {
+	fetch func() (page []T, more bool, err error)
+	page  []T
+	value T
+	done  bool
+	err   error
+}
+
+func newIterator[T any](fetch func() (page []T, more bool, err error)) *Iterator[T] {
+	return &Iterator[T]{fetch: fetch}
+}
+
+// Next advances the iterator to the next value, which is then available through Value. Next returns false when there
+// are no more values or when requesting a page fails; check Err to distinguish between the two.
+func (it *Iterator[T]) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		var more bool
+		it.page, more, it.err = it.fetch()
+		it.done = !more
+	}
+	it.value, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Value returns the current value.
+func (it *Iterator[T]) Value() T {
+	return it.value
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *Iterator[T]) Err() error {
+	return it.err
+}
+
+// All returns the remaining values.
+func (it *Iterator[T]) All() ([]T, error) {
+	var res []T
+	for it.Next() {
+		res = append(res, it.Value())
+	}
+	return res, it.Err()
+}
+
+// listByOffset returns an Iterator over the results of list, starting at the offset. The iteration stops when a page
+// is empty or when the total is reached.
+func listByOffset[T any](offset uint32, list func(offset uint32) (page []T, total uint32, err error)) *Iterator[T] {
+	return newIterator(func() ([]T, bool, error) {
+		page, total, err := list(offset)
+		if err != nil {
+			return nil, false, err
+		}
+		offset += uint32(len(page))
+		return page, len(page) > 0 && offset < total, nil
+	})
+}
+
+// listByUpdatedSince returns an Iterator over the policies of list, updated after updatedSince. The policies are
+// paginated by their last updated timestamp. The iteration stops when a page is empty.
+func listByUpdatedSince(updatedSince *timestamppb.Timestamp, list func(updatedSince *timestamppb.Timestamp) ([]*packetbroker.RoutingPolicy, error)) *Iterator[*packetbroker.RoutingPolicy] {
+	return newIterator(func() ([]*packetbroker.RoutingPolicy, bool, error) {
+		page, err := list(updatedSince)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(page) == 0 {
+			return nil, false, nil
+		}
+		updatedSince = page[len(page)-1].GetUpdatedAt()
+		return page, true, nil
+	})
+}
+
+// cloneRequest returns a copy of the request, or a new request if req is nil. Iterators modify the copy to request
+// pages.
+func cloneRequest[T proto.Message](req T, empty func() T) T {
+	if !req.ProtoReflect().IsValid() {
+		return empty()
+	}
+	return proto.Clone(req).(T)
+}
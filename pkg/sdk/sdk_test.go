@@ -0,0 +1,206 @@
+// Copyright © 2024 The Things Industries B.V.
+
+package sdk
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	iampb "go.packetbroker.org/api/iam"
+	reportingpb "go.packetbroker.org/api/reporting"
+	routingpb "go.packetbroker.org/api/routing"
+	packetbroker "go.packetbroker.org/api/v3"
+	"go.packetbroker.org/pb/pkg/mock"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func dial(t *testing.T, s *mock.Server) *grpc.ClientConn {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := s.NewGRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestIterator(t *testing.T) {
+	pages := [][]int{{1, 2}, {}, {3}}
+	it := newIterator(func() ([]int, bool, error) {
+		page := pages[0]
+		pages = pages[1:]
+		return page, len(pages) > 0, nil
+	})
+	values, err := it.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 3 || values[0] != 1 || values[2] != 3 {
+		t.Fatalf("Unexpected values %v", values)
+	}
+	if it.Next() {
+		t.Fatal("Expected no more values")
+	}
+
+	errFetch := errors.New("fetch")
+	calls := 0
+	it = newIterator(func() ([]int, bool, error) {
+		calls++
+		if calls > 1 {
+			return nil, false, errFetch
+		}
+		return []int{1}, true, nil
+	})
+	if !it.Next() || it.Value() != 1 {
+		t.Fatal("Expected first value")
+	}
+	if it.Next() {
+		t.Fatal("Expected no value after error")
+	}
+	if !errors.Is(it.Err(), errFetch) {
+		t.Fatalf("Expected fetch error, got %v", it.Err())
+	}
+	if it.Next() || calls != 2 {
+		t.Fatalf("Expected no more fetches after error, got %d", calls)
+	}
+}
+
+func TestIAM(t *testing.T) {
+	s := mock.NewServer()
+	for _, netID := range []uint32{0x13, 0x9, 0x1} {
+		s.AddNetwork(&packetbroker.Network{NetId: netID, Listed: true})
+	}
+	for _, id := range []string{"a", "b"} {
+		s.AddTenant(&packetbroker.Tenant{NetId: 0x13, TenantId: id})
+	}
+	s.AddTenant(&packetbroker.Tenant{NetId: 0x9, TenantId: "c"})
+	iam := NewIAM(dial(t, s))
+	ctx := context.Background()
+
+	req := &iampb.ListNetworksRequest{Limit: 1}
+	networks, err := iam.ListAllNetworks(ctx, req).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(networks) != 3 {
+		t.Fatalf("Expected 3 networks, got %d", len(networks))
+	}
+	if req.Offset != 0 {
+		t.Fatal("Expected request to be unmodified")
+	}
+	if n := len(s.Calls()); n != 3 {
+		t.Fatalf("Expected 3 pages, got %d", n)
+	}
+
+	tenants, err := iam.ListAllTenants(ctx, &iampb.ListTenantsRequest{NetId: 0x13, Limit: 1}).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tenants) != 2 || tenants[0].TenantId != "a" || tenants[1].TenantId != "b" {
+		t.Fatalf("Unexpected tenants %v", tenants)
+	}
+
+	catalog, err := iam.ListCatalogNetworks(ctx, nil).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog) != 3 {
+		t.Fatalf("Expected 3 listed networks, got %d", len(catalog))
+	}
+}
+
+func TestControlPlane(t *testing.T) {
+	s := mock.NewServer()
+	s.AddNetwork(&packetbroker.Network{
+		NetId: 0x13,
+		DevAddrBlocks: []*packetbroker.DevAddrBlock{
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x26000000, Length: 7}},
+			{Prefix: &packetbroker.DevAddrPrefix{Value: 0x27000000, Length: 8}},
+		},
+	})
+	s.AddNetwork(&packetbroker.Network{NetId: 0x9})
+	conn := dial(t, s)
+	cp := NewControlPlane(conn)
+	ctx := context.Background()
+
+	routes, err := cp.ListUplinkRoutes(ctx).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 || routes[0].NetId != 0x13 {
+		t.Fatalf("Unexpected routes %v", routes)
+	}
+
+	policies := routingpb.NewPolicyManagerClient(conn)
+	if _, err := policies.SetDefaultPolicy(ctx, &routingpb.SetPolicyRequest{
+		Policy: &packetbroker.RoutingPolicy{
+			ForwarderNetId: 0x13,
+			Uplink:         &packetbroker.RoutingPolicy_Uplink{JoinRequest: true},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := cp.ListDefaultPolicies(ctx, nil).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(defaults) != 1 || defaults[0].ForwarderNetId != 0x13 {
+		t.Fatalf("Unexpected default policies %v", defaults)
+	}
+	updated, err := cp.ListDefaultPolicies(ctx, defaults[0].UpdatedAt).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 0 {
+		t.Fatalf("Expected no updated policies, got %v", updated)
+	}
+
+	effective, err := cp.ListEffectivePolicies(ctx, packetbroker.TenantID{NetID: 0x9}).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(effective) != 1 || effective[0].ForwarderNetId != 0x13 || !effective[0].GetUplink().GetJoinRequest() {
+		t.Fatalf("Unexpected effective policies %v", effective)
+	}
+}
+
+func TestReports(t *testing.T) {
+	s := mock.NewServer()
+	for _, rec := range []*reportingpb.RoutedMessagesRecord{
+		{ForwarderNetId: 0x13, HomeNetworkNetId: 0x13},
+		{ForwarderNetId: 0x13, HomeNetworkNetId: 0x9},
+		{ForwarderNetId: 0x9, HomeNetworkNetId: 0x13},
+		{ForwarderNetId: 0x9, HomeNetworkNetId: 0x9},
+	} {
+		rec.From = timestamppb.Now()
+		rec.To = rec.From
+		s.AddRoutedMessagesRecord(rec)
+	}
+	reports := NewReports(dial(t, s))
+
+	records, err := reports.GetRoutedMessages(context.Background(),
+		&reportingpb.GetRoutedMessagesRequest{ForwarderNetId: wrapperspb.UInt32(0x13)},
+		&reportingpb.GetRoutedMessagesRequest{HomeNetworkNetId: wrapperspb.UInt32(0x13)},
+	).All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("Expected 3 records, got %d", len(records))
+	}
+}